@@ -75,7 +75,7 @@ func (o *allowArtifactOptions) run(args []string) error {
 		if err != nil {
 			return err
 		}
-		if o.fingerprintOptions.artifactType == "dir" || o.fingerprintOptions.artifactType == "file" {
+		if o.fingerprintOptions.artifactType == "dir" || o.fingerprintOptions.artifactType == "file" || o.fingerprintOptions.artifactType == "archive" {
 			o.payload.Filename = filepath.Base(args[0])
 		} else {
 			o.payload.Filename = args[0]
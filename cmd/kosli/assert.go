@@ -1,13 +1,46 @@
 package main
 
 import (
+	"fmt"
 	"io"
 
+	"github.com/kosli-dev/cli/internal/policy"
 	"github.com/spf13/cobra"
 )
 
 const assertDesc = `All Kosli assertion commands. Return non-zero exit code if the assertion fails.`
 
+const assertPolicyDesc = `
+
+You can also evaluate your own policy locally against the JSON data returned by Kosli 
+(as shown by the ^--output json^ option of the matching ^kosli get^ command).
+A policy is a list of jq rules that must all evaluate to ^true^. Rules can be provided with ^--jq^ 
+(repeat the flag to add more rules) or in a policy file provided with ^--policy^. 
+The policy file can be in YAML, JSON or TOML formats. A rule in a policy file can have a ^name^ and 
+a ^for_each^ jq expression that selects the items (e.g. attestations) to evaluate the rule against, one at a time.
+When the assertion fails, the error lists each rule that failed and the item it failed for.
+
+This is an example YAML policy file:
+` +
+	"```yaml\n" +
+	`version: 1
+rules:
+  - name: junit-attested
+    jq: '[.attestations_statuses[] | select(.attestation_type == "junit")] | length > 0'
+  - name: no-junit-failures
+    for_each: '.attestations_statuses[] | select(.attestation_type == "junit")'
+    jq: '.attestation_data.failures == 0'
+  - name: no-high-snyk-vulnerabilities
+    for_each: '.attestations_statuses[] | select(.attestation_type == "snyk")'
+    jq: '.attestation_data.high_count == 0'` +
+	"\n```"
+
+// assertPolicyOptions holds the local policy options of assert commands
+type assertPolicyOptions struct {
+	policyFile string
+	jqRules    []string
+}
+
 func newAssertCmd(out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "assert",
@@ -26,3 +59,36 @@ func newAssertCmd(out io.Writer) *cobra.Command {
 
 	return cmd
 }
+
+// load returns the local policy to evaluate, or nil if no policy is provided
+func (o *assertPolicyOptions) load() (*policy.Policy, error) {
+	var filePolicy, rulesPolicy *policy.Policy
+	var err error
+	if o.policyFile != "" {
+		filePolicy, err = policy.LoadPolicyFile(o.policyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(o.jqRules) > 0 {
+		rulesPolicy, err = policy.NewPolicyFromRules(o.jqRules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --jq rule: %v", err)
+		}
+	}
+	return policy.Merge(filePolicy, rulesPolicy), nil
+}
+
+// evaluateAssertPolicy evaluates the local policy (if any) against data and returns
+// a description of the failed rules, or an empty string if all rules pass
+func evaluateAssertPolicy(p *policy.Policy, data interface{}) string {
+	if p == nil {
+		return ""
+	}
+	violations := p.Evaluate(data)
+	if len(violations) == 0 {
+		logger.Info("all [%d] policy rules passed", len(p.Rules))
+		return ""
+	}
+	return fmt.Sprintf("[%d] policy rule(s) failed:\n%s", len(violations), policy.FormatViolations(violations))
+}
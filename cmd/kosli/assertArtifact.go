@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/kosli-dev/cli/internal/requests"
 	"github.com/spf13/cobra"
@@ -13,7 +14,7 @@ import (
 const assertArtifactShortDesc = `Assert the compliance status of an artifact in Kosli.  `
 
 const assertArtifactLongDesc = assertArtifactShortDesc + `
-Exits with non-zero code if the artifact has a non-compliant status.`
+Exits with non-zero code if the artifact has a non-compliant status.` + assertPolicyDesc
 
 const assertArtifactExample = `
 # fail if an artifact has a non-compliant status (using the artifact fingerprint)
@@ -29,12 +30,22 @@ kosli assert artifact library/nginx:1.21 \
 	--flow yourFlowName \
 	--api-token yourAPIToken \
 	--org yourOrgName 
+
+# fail if an artifact has a non-compliant status or does not pass a local policy
+kosli assert artifact \
+	--fingerprint 184c799cd551dd1d8d5c5f9a5d593b2e931f5e36122ee5c793c1d08a19839cc0 \
+	--flow yourFlowName \
+	--policy policy.yaml \
+	--jq '.git_commit != null' \
+	--api-token yourAPIToken \
+	--org yourOrgName 
 `
 
 type assertArtifactOptions struct {
 	fingerprintOptions *fingerprintOptions
 	fingerprint        string // This is calculated or provided by the user
 	flowName           string
+	policyOptions      assertPolicyOptions
 }
 
 func newAssertArtifactCmd(out io.Writer) *cobra.Command {
@@ -65,6 +76,7 @@ func newAssertArtifactCmd(out io.Writer) *cobra.Command {
 	cmd.Flags().StringVarP(&o.fingerprint, "fingerprint", "F", "", fingerprintFlag)
 	cmd.Flags().StringVarP(&o.flowName, "flow", "f", "", flowNameFlag)
 	addFingerprintFlags(cmd, o.fingerprintOptions)
+	addAssertPolicyFlags(cmd, &o.policyOptions)
 	addDryRunFlag(cmd)
 
 	err := RequireFlags(cmd, []string{"flow"})
@@ -76,7 +88,11 @@ func newAssertArtifactCmd(out io.Writer) *cobra.Command {
 }
 
 func (o *assertArtifactOptions) run(out io.Writer, args []string) error {
-	var err error
+	localPolicy, err := o.policyOptions.load()
+	if err != nil {
+		return err
+	}
+
 	if o.fingerprint == "" {
 		o.fingerprint, err = GetSha256Digest(args[0], o.fingerprintOptions, logger)
 		if err != nil {
@@ -102,14 +118,19 @@ func (o *assertArtifactOptions) run(out io.Writer, args []string) error {
 		return err
 	}
 
-	if artifactData["state"].(string) == "COMPLIANT" {
-		logger.Info("COMPLIANT")
-		logger.Info("See more details at %s", artifactData["html_url"].(string))
-	} else {
-		return fmt.Errorf("%s: %s\nSee more details at %s", artifactData["state"].(string),
-			artifactData["state_info"].(string),
-			artifactData["html_url"].(string))
+	failures := []string{}
+	if artifactData["state"].(string) != "COMPLIANT" {
+		failures = append(failures, fmt.Sprintf("%s: %s", artifactData["state"].(string), artifactData["state_info"].(string)))
+	}
+	if policyFailure := evaluateAssertPolicy(localPolicy, artifactData); policyFailure != "" {
+		failures = append(failures, policyFailure)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s\nSee more details at %s", strings.Join(failures, "\n"), artifactData["html_url"].(string))
 	}
+	logger.Info("COMPLIANT")
+	logger.Info("See more details at %s", artifactData["html_url"].(string))
 
 	return nil
 }
@@ -78,6 +78,23 @@ func (suite *AssertArtifactCommandTestSuite) TestAssertArtifactCmd() {
 		// 	cmd:       fmt.Sprintf(`assert artifact --artifact-type file --fingerprint %s --flow %s %s`, suite.fingerprint, suite.flowName, suite.defaultKosliArguments),
 		// 	golden:    "COMPLIANT\n",
 		// },
+		{
+			name:        "asserting an existing compliant artifact with a passing local policy results in OK and zero exit",
+			cmd:         fmt.Sprintf(`assert artifact --fingerprint %s --flow %s --jq '.fingerprint == "%s"' %s`, suite.fingerprint, suite.flowName, suite.fingerprint, suite.defaultKosliArguments),
+			goldenRegex: "all \\[1\\] policy rules passed\nCOMPLIANT\nSee more details at http://localhost:8001/docs-cmd-test-user/flows/assert-artifact/artifacts/.*\n",
+		},
+		{
+			wantError:   true,
+			name:        "asserting an existing compliant artifact with a failing local policy lists the failed rules",
+			cmd:         fmt.Sprintf(`assert artifact --fingerprint %s --flow %s --jq '.flow_name == "other-flow"' %s`, suite.fingerprint, suite.flowName, suite.defaultKosliArguments),
+			goldenRegex: "^Error: \\[1\\] policy rule\\(s\\) failed:\n  - rule \\[.flow_name == \"other-flow\"\\] failed: the rule returned false\nSee more details at http://localhost:8001/docs-cmd-test-user/flows/assert-artifact/artifacts/.*\n",
+		},
+		{
+			wantError:   true,
+			name:        "asserting with a non-existing policy file fails",
+			cmd:         fmt.Sprintf(`assert artifact --fingerprint %s --flow %s --policy testdata/non-existing-policy.yml %s`, suite.fingerprint, suite.flowName, suite.defaultKosliArguments),
+			goldenRegex: "^Error: failed to parse policy file \\[testdata/non-existing-policy.yml\\]",
+		},
 		{
 			wantError: true,
 			name:      "missing --flow fails",
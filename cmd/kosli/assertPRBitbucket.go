@@ -17,15 +17,25 @@ const assertPRBitbucketShortDesc = `Assert a Bitbucket pull request for a git co
 
 const assertPRBitbucketLongDesc = assertPRBitbucketShortDesc + `
 The command exits with non-zero exit code 
-if no pull requests were found for the commit.`
+if no pull requests were found for the commit.
+For Bitbucket Data Center (or Server), set ^--bitbucket-server-url^ and use the project key as ^--bitbucket-workspace^.`
 
 const assertPRBitbucketExample = `
+# assert a Bitbucket Cloud pull request exists:
 kosli assert pullrequest bitbucket  \
 	--bitbucket-username yourBitbucketUsername \
 	--bitbucket-password yourBitbucketPassword \
 	--bitbucket-workspace yourBitbucketWorkspace \
 	--commit yourGitCommit \
 	--repository yourBitbucketGitRepository
+
+# assert a Bitbucket Data Center pull request exists:
+kosli assert pullrequest bitbucket  \
+	--bitbucket-server-url https://bitbucket.example.com \
+	--bitbucket-access-token yourBitbucketAccessToken \
+	--bitbucket-workspace yourBitbucketProjectKey \
+	--commit yourGitCommit \
+	--repository yourBitbucketGitRepository
 `
 
 func newAssertPullRequestBitbucketCmd(out io.Writer) *cobra.Command {
@@ -41,17 +51,21 @@ func newAssertPullRequestBitbucketCmd(out io.Writer) *cobra.Command {
 		Example: assertPRBitbucketExample,
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := validateBitbucketAuthFlags(o.bbConfig)
+			if err != nil {
+				return err
+			}
 			return o.run(args)
 		},
 	}
 
 	ci := WhichCI()
 	addBitbucketFlags(cmd, o.bbConfig, ci)
+	addBitbucketServerFlags(cmd, o.bbConfig)
 	cmd.Flags().StringVar(&o.commit, "commit", DefaultValueForCommit(ci, true), commitPREvidenceFlag)
 	addDryRunFlag(cmd)
 
-	err := RequireFlags(cmd, []string{"bitbucket-username", "bitbucket-password",
-		"bitbucket-workspace", "commit", "repository"})
+	err := RequireFlags(cmd, []string{"bitbucket-workspace", "commit", "repository"})
 	if err != nil {
 		logger.Error("failed to configure required flags: %v", err)
 	}
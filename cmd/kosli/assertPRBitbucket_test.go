@@ -54,6 +54,27 @@ func (suite *AssertPRBitbucketCommandTestSuite) TestAssertPRBitbucketCmd() {
 	runTestCmd(suite.T(), tests)
 }
 
+func (suite *AssertPRBitbucketCommandTestSuite) TestAssertPRBitbucketCmdAuthFlags() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "assert Bitbucket PR evidence fails when --bitbucket-access-token is used without --bitbucket-server-url",
+			cmd: `assert pullrequest bitbucket --bitbucket-workspace kosli-dev --repository cli-test --bitbucket-access-token xxx
+			--commit fd54040fc90e7e83f7b152619bfa18917b72c34f` + suite.defaultKosliArguments,
+			golden: "Error: flag --bitbucket-access-token is only allowed when flag --bitbucket-server-url is set\n",
+		},
+		{
+			wantError: true,
+			name:      "assert Bitbucket PR evidence fails when neither an access token nor a username and password are provided",
+			cmd: `assert pullrequest bitbucket --bitbucket-workspace KOS --repository cli-test --bitbucket-username "" --bitbucket-password ""
+			--bitbucket-server-url https://bitbucket.example.com --commit fd54040fc90e7e83f7b152619bfa18917b72c34f` + suite.defaultKosliArguments,
+			golden: "Error: required flag(s) \"bitbucket-password\", \"bitbucket-username\" not set\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestAssertPRBitbucketCommandTestSuite(t *testing.T) {
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	giteaUtils "github.com/kosli-dev/cli/internal/gitea"
+	"github.com/spf13/cobra"
+)
+
+type assertPullRequestGiteaOptions struct {
+	giteaConfig *giteaUtils.GiteaConfig
+	commit      string
+}
+
+const assertPRGiteaShortDesc = `Assert a Gitea pull request for a git commit exists.  `
+
+const assertPRGiteaLongDesc = assertPRGiteaShortDesc + `
+The command exits with non-zero exit code 
+if no pull requests were found for the commit.
+It works with Gitea and Forgejo installations.`
+
+const assertPRGiteaExample = `
+kosli assert pullrequest gitea \
+	--gitea-token yourGiteaToken \
+	--gitea-base-url yourGiteaBaseURL \
+	--gitea-org yourGiteaOrg \
+	--commit yourGitCommit \
+	--repository yourGiteaGitRepository
+`
+
+func newAssertPullRequestGiteaCmd(out io.Writer) *cobra.Command {
+	o := new(assertPullRequestGiteaOptions)
+	giteaFlagsValues := new(giteaUtils.GiteaFlagsTempValueHolder)
+	cmd := &cobra.Command{
+		Use:     "gitea",
+		Aliases: []string{"forgejo"},
+		Short:   assertPRGiteaShortDesc,
+		Long:    assertPRGiteaLongDesc,
+		Example: assertPRGiteaExample,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.giteaConfig = giteaUtils.NewGiteaConfig(giteaFlagsValues.Token, giteaFlagsValues.BaseURL,
+				giteaFlagsValues.Org, giteaFlagsValues.Repository)
+			return o.run(args)
+		},
+	}
+
+	ci := WhichCI()
+	addGiteaFlags(cmd, giteaFlagsValues, ci)
+	cmd.Flags().StringVar(&o.commit, "commit", DefaultValueForCommit(ci, true), commitPREvidenceFlag)
+	addDryRunFlag(cmd)
+
+	err := RequireFlags(cmd, []string{"gitea-token", "gitea-org", "gitea-base-url", "commit", "repository"})
+	if err != nil {
+		logger.Error("failed to configure required flags: %v", err)
+	}
+
+	return cmd
+}
+
+func (o *assertPullRequestGiteaOptions) run(args []string) error {
+	pullRequestsEvidence, err := o.giteaConfig.PREvidenceForCommit(o.commit)
+	if err != nil {
+		return err
+	}
+	if len(pullRequestsEvidence) == 0 {
+		return fmt.Errorf("assert failed: found no pull request(s) in Gitea for commit: %s", o.commit)
+	}
+	logger.Info("found [%d] pull request(s) in Gitea for commit: %s", len(pullRequestsEvidence), o.commit)
+	return nil
+}
@@ -17,7 +17,7 @@ const assertPRGithubShortDesc = `Assert a Github pull request for a git commit e
 
 const assertPRGithubLongDesc = assertPRGithubShortDesc + `
 The command exits with non-zero exit code 
-if no pull requests were found for the commit.`
+if no pull requests were found for the commit.` + githubAuthDesc
 
 const assertPRGithubExample = `
 kosli assert pullrequest github \
@@ -25,6 +25,14 @@ kosli assert pullrequest github \
 	--github-org yourGithubOrg \
 	--commit yourGitCommit \
 	--repository yourGithubGitRepository
+
+# assert a Github pull request exists, authenticating as a Github App installation
+kosli assert pullrequest github \
+	--github-app-id yourGithubAppID \
+	--github-app-private-key yourGithubAppPrivateKeyFile \
+	--github-org yourGithubOrg \
+	--commit yourGitCommit \
+	--repository yourGithubGitRepository
 `
 
 func newAssertPullRequestGithubCmd(out io.Writer) *cobra.Command {
@@ -38,18 +46,22 @@ func newAssertPullRequestGithubCmd(out io.Writer) *cobra.Command {
 		Example: assertPRGithubExample,
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			o.githubConfig = ghUtils.NewGithubConfig(githubFlagsValues.Token, githubFlagsValues.BaseURL,
-				githubFlagsValues.Org, githubFlagsValues.Repository)
+			githubConfig, err := newGithubConfig(githubFlagsValues)
+			if err != nil {
+				return err
+			}
+			o.githubConfig = githubConfig
 			return o.run(args)
 		},
 	}
 
 	ci := WhichCI()
 	addGithubFlags(cmd, githubFlagsValues, ci)
+	addGithubAppFlags(cmd, githubFlagsValues)
 	cmd.Flags().StringVar(&o.commit, "commit", DefaultValueForCommit(ci, true), commitPREvidenceFlag)
 	addDryRunFlag(cmd)
 
-	err := RequireFlags(cmd, []string{"github-token", "github-org", "commit", "repository"})
+	err := RequireFlags(cmd, []string{"github-org", "commit", "repository"})
 	if err != nil {
 		logger.Error("failed to configure required flags: %v", err)
 	}
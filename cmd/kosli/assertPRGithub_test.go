@@ -54,6 +54,48 @@ func (suite *AssertPRGithubCommandTestSuite) TestAssertPRGithubCmd() {
 	runTestCmd(suite.T(), tests)
 }
 
+func (suite *AssertPRGithubCommandTestSuite) TestAssertPRGithubCmdAuthFlags() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "assert Github PR evidence fails when neither a token nor a Github App are provided",
+			cmd: `assert pullrequest github --github-org kosli-dev --repository cli --github-token ""
+			--commit ` + testHelpers.GithubCommitWithPR() + suite.defaultKosliArguments,
+			golden: "Error: at least one of --github-token, --github-app-id is required\n",
+		},
+		{
+			wantError: true,
+			name:      "assert Github PR evidence fails when both a token and a Github App are provided",
+			cmd: `assert pullrequest github --github-org kosli-dev --repository cli --github-token xxx
+			--github-app-id 123 --github-app-private-key app.pem --commit ` + testHelpers.GithubCommitWithPR() + suite.defaultKosliArguments,
+			golden: "Error: only one of --github-token, --github-app-id is allowed\n",
+		},
+		{
+			wantError: true,
+			name:      "assert Github PR evidence fails when --github-app-id is used without --github-app-private-key",
+			cmd: `assert pullrequest github --github-org kosli-dev --repository cli --github-token "" --github-app-id 123
+			--commit ` + testHelpers.GithubCommitWithPR() + suite.defaultKosliArguments,
+			golden: "Error: flag --github-app-private-key is required when flag --github-app-id is set\n",
+		},
+		{
+			wantError: true,
+			name:      "assert Github PR evidence fails when --github-app-installation-id is used without --github-app-id",
+			cmd: `assert pullrequest github --github-org kosli-dev --repository cli --github-app-installation-id 42
+			--commit ` + testHelpers.GithubCommitWithPR() + suite.defaultKosliArguments,
+			golden: "Error: flag --github-app-installation-id is only allowed when flag --github-app-id is set\n",
+		},
+		{
+			wantError: true,
+			name:      "assert Github PR evidence fails when the Github App private key file does not exist",
+			cmd: `assert pullrequest github --github-org kosli-dev --repository cli --github-token ""
+			--github-app-id 123 --github-app-private-key non-existing.pem --commit ` + testHelpers.GithubCommitWithPR() + suite.defaultKosliArguments,
+			golden: "Error: failed to read Github App private key: open non-existing.pem: no such file or directory\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestAssertPRGithubCommandTestSuite(t *testing.T) {
@@ -22,6 +22,7 @@ func newAssertPRCmd(out io.Writer) *cobra.Command {
 		newAssertPullRequestGithubCmd(out),
 		newAssertPullRequestGitlabCmd(out),
 		newAssertPullRequestAzureCmd(out),
+		newAssertPullRequestGiteaCmd(out),
 	)
 
 	return cmd
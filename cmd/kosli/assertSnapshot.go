@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/kosli-dev/cli/internal/requests"
 	"github.com/spf13/cobra"
@@ -25,15 +26,27 @@ Examples of valid expressions are:
 - prod (latest snapshot of prod)
 - prod#10 (snapshot number 10 of prod)
 - prod~2 (third latest snapshot of prod)
-`
+` + assertPolicyDesc
 
 const assertSnapshotExample = `
 kosli assert snapshot prod#5 \
 	--api-token yourAPIToken \
 	--org yourOrgName
+
+# fail if the latest snapshot of prod is non-compliant or does not pass a local policy
+kosli assert snapshot prod \
+	--jq '.artifacts | length > 0' \
+	--policy policy.yaml \
+	--api-token yourAPIToken \
+	--org yourOrgName
 `
 
+type assertSnapshotOptions struct {
+	policyOptions assertPolicyOptions
+}
+
 func newAssertSnapshotCmd(out io.Writer) *cobra.Command {
+	o := new(assertSnapshotOptions)
 	cmd := &cobra.Command{
 		Use:     "snapshot ENVIRONMENT-NAME-OR-EXPRESSION",
 		Short:   assertSnapshotShortDesc,
@@ -48,15 +61,21 @@ func newAssertSnapshotCmd(out io.Writer) *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(out, args)
+			return o.run(out, args)
 		},
 	}
+	addAssertPolicyFlags(cmd, &o.policyOptions)
 	addDryRunFlag(cmd)
 
 	return cmd
 }
 
-func run(out io.Writer, args []string) error {
+func (o *assertSnapshotOptions) run(out io.Writer, args []string) error {
+	localPolicy, err := o.policyOptions.load()
+	if err != nil {
+		return err
+	}
+
 	envName, id, err := handleExpressions(args[0])
 	if err != nil {
 		return err
@@ -79,11 +98,18 @@ func run(out io.Writer, args []string) error {
 		return err
 	}
 
-	if environmentData["compliant"].(bool) {
-		logger.Info("COMPLIANT")
-	} else {
-		return fmt.Errorf("INCOMPLIANT")
+	failures := []string{}
+	if !environmentData["compliant"].(bool) {
+		failures = append(failures, "INCOMPLIANT")
+	}
+	if policyFailure := evaluateAssertPolicy(localPolicy, environmentData); policyFailure != "" {
+		failures = append(failures, policyFailure)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "\n"))
 	}
+	logger.Info("COMPLIANT")
 
 	return nil
 }
@@ -77,6 +77,24 @@ func (suite *AssertSnapshotCommandTestSuite) TestAssertSnapshotCmd() {
 			},
 			golden: "Error: INCOMPLIANT\n",
 		},
+		{
+			wantError: true,
+			name:      "asserting a non compliant env with a failing local policy lists the failed rules",
+			cmd:       fmt.Sprintf(`assert snapshot %s --jq '.artifacts | length == 0' %s`, suite.envName, suite.defaultKosliArguments),
+			additionalConfig: assertSnapshotTestConfig{
+				reportToEnv: true,
+			},
+			golden: "Error: INCOMPLIANT\n[1] policy rule(s) failed:\n  - rule [.artifacts | length == 0] failed: the rule returned false\n",
+		},
+		{
+			wantError: true,
+			name:      "asserting with an invalid jq rule fails",
+			cmd:       fmt.Sprintf(`assert snapshot %s --jq '.artifacts |' %s`, suite.envName, suite.defaultKosliArguments),
+			additionalConfig: assertSnapshotTestConfig{
+				reportToEnv: true,
+			},
+			goldenRegex: "^Error: invalid --jq rule: rule 1 \\(.artifacts \\|\\) has an invalid jq expression",
+		},
 	}
 
 	for _, t := range tests {
@@ -20,6 +20,10 @@ func newAttestCmd(out io.Writer) *cobra.Command {
 		newAttestArtifactCmd(out),
 		newAttestGenericCmd(out),
 		newAttestSnykCmd(out),
+		newAttestSarifCmd(out),
+		newAttestSignatureCmd(out),
+		newAttestProvenanceCmd(out),
+		newAttestSBOMCmd(out),
 		newAttestJunitCmd(out),
 		newAttestJiraCmd(out),
 		newAttestPRCmd(out),
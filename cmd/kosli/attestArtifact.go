@@ -5,7 +5,9 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
+	"strings"
 
+	"github.com/kosli-dev/cli/internal/digest"
 	"github.com/kosli-dev/cli/internal/gitview"
 	"github.com/kosli-dev/cli/internal/requests"
 	"github.com/spf13/cobra"
@@ -22,6 +24,7 @@ type attestArtifactOptions struct {
 	externalFingerprints map[string]string
 	externalURLs         map[string]string
 	annotations          map[string]string
+	platformDigests      []digest.ImagePlatformDigest
 }
 
 type AttestArtifactPayload struct {
@@ -43,7 +46,11 @@ const attestArtifactShortDesc = `Attest an artifact creation to a Kosli flow.  `
 const attestArtifactLongDesc = attestArtifactShortDesc + `
 ` + fingerprintDesc + kosliIgnoreDesc + `
 This command requires access to a git repo to associate the artifact to the git commit it is originating from. 
-You can optionally redact some of the git commit data sent to Kosli using ^--redact-commit-info^`
+You can optionally redact some of the git commit data sent to Kosli using ^--redact-commit-info^
+
+For a multi-platform image, use ^--platform^ or ^--all-platforms^ to also report the fingerprints of its platforms.
+They are linked to the artifact as external URLs labeled ^platform-OS-ARCH[-VARIANT]^ (e.g. ^platform-linux-arm64^)
+with the reference of the image of the platform and its fingerprint.`
 
 const attestArtifactExample = `
 # Attest that a file type artifact has been created, and let Kosli calculate its fingerprint
@@ -59,6 +66,19 @@ kosli attest artifact FILE.tgz \
 	--org yourOrgName
 
 
+# Attest that a multi-platform image has been created, with the fingerprints of all its platforms
+kosli attest artifact yourRegistry/yourImage:yourTag \
+	--artifact-type oci \
+	--all-platforms \
+	--build-url https://exampleci.com \
+	--commit-url https://github.com/YourOrg/YourProject/commit/yourCommitShaThatThisArtifactWasBuiltFrom \
+	--commit yourCommitShaThatThisArtifactWasBuiltFrom \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--name yourTemplateArtifactName \
+	--api-token yourApiToken \
+	--org yourOrgName
+
 # Attest that an artifact has been created and provide its fingerprint (sha256) 
 kosli attest artifact ANOTHER_FILE.txt \
 	--build-url https://exampleci.com \
@@ -116,6 +136,13 @@ func newAttestArtifactCmd(out io.Writer) *cobra.Command {
 			if err != nil {
 				return ErrorBeforePrintingUsage(cmd, err.Error())
 			}
+			if o.fingerprintOptions.platformsRequested() && o.payload.Fingerprint != "" {
+				return ErrorBeforePrintingUsage(cmd, "--platform and --all-platforms cannot be used with --fingerprint")
+			}
+			err = ValidatePlatformFlags(cmd, o.fingerprintOptions)
+			if err != nil {
+				return err
+			}
 			return ValidateRegistryFlags(cmd, o.fingerprintOptions)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -138,6 +165,7 @@ func newAttestArtifactCmd(out io.Writer) *cobra.Command {
 	cmd.Flags().StringToStringVar(&o.externalURLs, "external-url", map[string]string{}, externalURLFlag)
 	cmd.Flags().StringToStringVar(&o.annotations, "annotate", map[string]string{}, annotationFlag)
 	addFingerprintFlags(cmd, o.fingerprintOptions)
+	addPlatformFlags(cmd, o.fingerprintOptions)
 
 	addDryRunFlag(cmd)
 
@@ -154,7 +182,7 @@ func (o *attestArtifactOptions) run(args []string) error {
 	if o.displayName != "" {
 		o.payload.Filename = o.displayName
 	} else {
-		if o.fingerprintOptions.artifactType == "dir" || o.fingerprintOptions.artifactType == "file" {
+		if o.fingerprintOptions.artifactType == "dir" || o.fingerprintOptions.artifactType == "file" || o.fingerprintOptions.artifactType == "archive" {
 			o.payload.Filename = filepath.Base(args[0])
 		} else {
 			o.payload.Filename = args[0]
@@ -172,7 +200,16 @@ func (o *attestArtifactOptions) run(args []string) error {
 		return err
 	}
 
-	if o.payload.Fingerprint == "" {
+	if o.fingerprintOptions.platformsRequested() {
+		o.payload.Fingerprint, o.platformDigests, err = GetPlatformSha256Digests(args[0], o.fingerprintOptions, logger)
+		if err != nil {
+			return err
+		}
+		err = linkPlatformFingerprints(o.payload.ExternalURLs, args[0], o.platformDigests)
+		if err != nil {
+			return err
+		}
+	} else if o.payload.Fingerprint == "" {
 		o.payload.Fingerprint, err = GetSha256Digest(args[0], o.fingerprintOptions, logger)
 		if err != nil {
 			return err
@@ -208,6 +245,26 @@ func (o *attestArtifactOptions) run(args []string) error {
 	_, err = kosliClient.Do(reqParams)
 	if err == nil && !global.DryRun {
 		logger.Info("artifact %s was attested with fingerprint: %s", o.payload.Filename, o.payload.Fingerprint)
+		for _, platformDigest := range o.platformDigests {
+			logger.Info("  platform %s has fingerprint: %s", platformDigest.Platform, platformDigest.Digest)
+		}
 	}
 	return err
 }
+
+// linkPlatformFingerprints adds the fingerprints of the platforms of a multi-platform image to the external URLs
+// of its artifact, labeled platform-OS-ARCH[-VARIANT], with the reference of the image of each platform
+func linkPlatformFingerprints(externalURLs map[string]*URLInfo, imageName string, platformDigests []digest.ImagePlatformDigest) error {
+	for _, platformDigest := range platformDigests {
+		label := "platform-" + strings.ReplaceAll(platformDigest.Platform, "/", "-")
+		if _, exists := externalURLs[label]; exists {
+			return fmt.Errorf("%s in --external-url is the label of the fingerprint of platform %s", label, platformDigest.Platform)
+		}
+		imageRef, err := digest.PlatformImageReference(imageName, platformDigest.Digest)
+		if err != nil {
+			return err
+		}
+		externalURLs[label] = &URLInfo{Href: "docker://" + imageRef, Fingerprint: platformDigest.Digest}
+	}
+	return nil
+}
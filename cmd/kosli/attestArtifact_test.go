@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -81,6 +83,12 @@ func (suite *AttestArtifactCommandTestSuite) TestAttestArtifactCmd() {
 			cmd:       fmt.Sprintf("attest artifact testdata/file1 --fingerprint 7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9 --name cli --commit HEAD --build-url http://www.example.com --commit-url http://www.example.com --external-fingerprint file=7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9  %s", suite.defaultKosliArguments),
 			golden:    "Error: --external-fingerprints have labels that don't have a URL in --external-url\n",
 		},
+		{
+			wantError: true,
+			name:      "fails when --all-platforms is used with --fingerprint",
+			cmd:       fmt.Sprintf("attest artifact library/alpine:latest --artifact-type oci --all-platforms --fingerprint 7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9 --name cli --commit HEAD --build-url http://www.example.com --commit-url http://www.example.com %s", suite.defaultKosliArguments),
+			golden:    "Error: --platform and --all-platforms cannot be used with --fingerprint\nUsage: kosli attest artifact {IMAGE-NAME | FILE-PATH | DIR-PATH} [flags]\n",
+		},
 		{
 			wantError: true,
 			name:      "fails (from server) when --external-fingerprint has invalid fingerprint",
@@ -114,6 +122,32 @@ func (suite *AttestArtifactCommandTestSuite) TestAttestArtifactCmd() {
 	runTestCmd(suite.T(), tests)
 }
 
+func (suite *AttestArtifactCommandTestSuite) TestLinkPlatformFingerprints() {
+	platformDigests := []digest.ImagePlatformDigest{
+		{Platform: "linux/amd64", Digest: "1111111111111111111111111111111111111111111111111111111111111111"},
+		{Platform: "linux/arm64/v8", Digest: "2222222222222222222222222222222222222222222222222222222222222222"},
+	}
+
+	externalURLs := map[string]*URLInfo{"docs": {Href: "https://example.com"}}
+	err := linkPlatformFingerprints(externalURLs, "nginx:latest", platformDigests)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), map[string]*URLInfo{
+		"docs": {Href: "https://example.com"},
+		"platform-linux-amd64": {
+			Href:        "docker://docker.io/library/nginx@sha256:" + platformDigests[0].Digest,
+			Fingerprint: platformDigests[0].Digest,
+		},
+		"platform-linux-arm64-v8": {
+			Href:        "docker://docker.io/library/nginx@sha256:" + platformDigests[1].Digest,
+			Fingerprint: platformDigests[1].Digest,
+		},
+	}, externalURLs)
+
+	externalURLs = map[string]*URLInfo{"platform-linux-amd64": {Href: "https://example.com"}}
+	err = linkPlatformFingerprints(externalURLs, "nginx:latest", platformDigests)
+	require.EqualError(suite.T(), err, "platform-linux-amd64 in --external-url is the label of the fingerprint of platform linux/amd64")
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestAttestArtifactCommandTestSuite(t *testing.T) {
@@ -12,7 +12,7 @@ const attestPRAzureShortDesc = `Report an Azure Devops pull request attestation
 
 const attestPRAzureLongDesc = attestPRAzureShortDesc + `
 It checks if a pull request exists for the artifact (based on its git commit) and reports the pull-request attestation to the artifact in Kosli.
-` + attestationBindingDesc
+` + attestPRFourEyesDesc + attestationBindingDesc
 
 const attestPRAzureExample = `
 # report an Azure Devops pull request attestation about a pre-built docker artifact (kosli calculates the fingerprint):
@@ -83,7 +83,7 @@ kosli attest pullrequest azure \
 	--api-token yourAPIToken \
 	--org yourOrgName
 
-# fail if a pull request does not exist for your artifact
+# fail if a pull request does not exist for your artifact, or if it was not approved by someone else after its last commit
 kosli attest pullrequest azure \
 	--name yourTemplateArtifactName.yourAttestationName \
 	--flow yourFlowName \
@@ -155,7 +155,7 @@ func newAttestAzurePRCmd(out io.Writer) *cobra.Command {
 	ci := WhichCI()
 	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
 	addAzureFlags(cmd, azureFlagsValues, ci)
-	cmd.Flags().BoolVar(&o.assert, "assert", false, assertPREvidenceFlag)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestPRAssertFlag)
 
 	err := RequireFlags(cmd, []string{"flow", "trail", "name",
 		"azure-token", "azure-org-url",
@@ -12,7 +12,9 @@ const attestPRBitbucketShortDesc = `Report a Bitbucket pull request attestation
 
 const attestPRBitbucketLongDesc = attestPRBitbucketShortDesc + `
 It checks if a pull request exists for a given merge commit and reports the pull-request attestation to Kosli.
-` + attestationBindingDesc
+For Bitbucket Data Center (or Server), set ^--bitbucket-server-url^ and use the project key as ^--bitbucket-workspace^.
+You can authenticate to Bitbucket Data Center with an HTTP access token (^--bitbucket-access-token^) instead of a username and password.
+` + attestPRFourEyesDesc + attestationBindingDesc
 
 const attestPRBitbucketExample = `
 # report a Bitbucket pull request attestation about a pre-built docker artifact (kosli calculates the fingerprint):
@@ -83,7 +85,7 @@ kosli attest pullrequest bitbucket \
 	--api-token yourAPIToken \
 	--org yourOrgName
 
-# fail if a pull request does not exist for your artifact
+# fail if a pull request does not exist for your artifact, or if it was not approved by someone else after its last commit
 kosli attest pullrequest bitbucket \
 	--name yourTemplateArtifactName.yourAttestationName \
 	--flow yourFlowName \
@@ -96,6 +98,19 @@ kosli attest pullrequest bitbucket \
 	--api-token yourAPIToken \
 	--org yourOrgName \
 	--assert
+
+# report a Bitbucket Data Center pull request attestation about a trail:
+kosli attest pullrequest bitbucket \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--bitbucket-server-url https://bitbucket.example.com \
+	--bitbucket-access-token yourBitbucketAccessToken \
+	--bitbucket-workspace yourBitbucketProjectKey \
+	--commit yourArtifactGitCommit \
+	--repository yourBitbucketGitRepository \
+	--api-token yourAPIToken \
+	--org yourOrgName
 `
 
 func newAttestBitbucketPRCmd(out io.Writer) *cobra.Command {
@@ -151,6 +166,10 @@ func newAttestBitbucketPRCmd(out io.Writer) *cobra.Command {
 
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := validateBitbucketAuthFlags(config)
+			if err != nil {
+				return err
+			}
 			return o.run(args)
 		},
 	}
@@ -158,10 +177,10 @@ func newAttestBitbucketPRCmd(out io.Writer) *cobra.Command {
 	ci := WhichCI()
 	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
 	addBitbucketFlags(cmd, o.getRetriever().(*bbUtils.Config), ci)
-	cmd.Flags().BoolVar(&o.assert, "assert", false, assertPREvidenceFlag)
+	addBitbucketServerFlags(cmd, config)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestPRAssertFlag)
 
 	err := RequireFlags(cmd, []string{"flow", "trail", "name",
-		"bitbucket-username", "bitbucket-password",
 		"bitbucket-workspace", "commit", "repository"})
 	if err != nil {
 		logger.Error("failed to configure required flags: %v", err)
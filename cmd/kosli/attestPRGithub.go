@@ -12,7 +12,7 @@ const attestPRGithubShortDesc = `Report a Github pull request attestation to an
 
 const attestPRGithubLongDesc = attestPRGithubShortDesc + `
 It checks if a pull request exists for a given merge commit and reports the pull-request attestation to Kosli.
-` + attestationBindingDesc
+` + attestPRFourEyesDesc + githubAuthDesc + attestationBindingDesc
 
 const attestPRGithubExample = `
 # report a Github pull request attestation about a pre-built docker artifact (kosli calculates the fingerprint):
@@ -78,7 +78,22 @@ kosli attest pullrequest github \
 	--api-token yourAPIToken \
 	--org yourOrgName
 
-# fail if a pull request does not exist for your artifact
+# report a Github pull request attestation about a trail, authenticating as a Github App installation
+# on a GitHub Enterprise Server:
+kosli attest pullrequest github \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--github-app-id yourGithubAppID \
+	--github-app-private-key yourGithubAppPrivateKeyFile \
+	--github-base-url https://github.example.com \
+	--github-org yourGithubOrg \
+	--commit yourArtifactGitCommit \
+	--repository yourGithubGitRepository \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# fail if a pull request does not exist for your artifact, or if it was not approved by someone else after its last commit
 kosli attest pullrequest github \
 	--name yourTemplateArtifactName.yourAttestationName \
 	--flow yourFlowName \
@@ -141,8 +156,11 @@ func newAttestGithubPRCmd(out io.Writer) *cobra.Command {
 
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			o.retriever = ghUtils.NewGithubConfig(githubFlagsValues.Token, githubFlagsValues.BaseURL,
-				githubFlagsValues.Org, githubFlagsValues.Repository)
+			githubConfig, err := newGithubConfig(githubFlagsValues)
+			if err != nil {
+				return err
+			}
+			o.retriever = githubConfig
 			return o.run(args)
 		},
 	}
@@ -150,10 +168,11 @@ func newAttestGithubPRCmd(out io.Writer) *cobra.Command {
 	ci := WhichCI()
 	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
 	addGithubFlags(cmd, githubFlagsValues, ci)
-	cmd.Flags().BoolVar(&o.assert, "assert", false, assertPREvidenceFlag)
+	addGithubAppFlags(cmd, githubFlagsValues)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestPRAssertFlag)
 
 	err := RequireFlags(cmd, []string{"flow", "trail", "name",
-		"github-token", "github-org", "commit", "repository"})
+		"github-org", "commit", "repository"})
 	if err != nil {
 		logger.Error("failed to configure required flags: %v", err)
 	}
@@ -12,7 +12,7 @@ const attestPRGitlabShortDesc = `Report a Gitlab merge request attestation to an
 
 const attestPRGitlabLongDesc = attestPRGitlabShortDesc + `
 It checks if a merge request exists for a given merge commit and reports the merge request attestation to Kosli.
-` + attestationBindingDesc
+` + attestPRFourEyesDesc + attestationBindingDesc
 
 const attestPRGitlabExample = `
 # report a Gitlab merge request attestation about a pre-built docker artifact (kosli calculates the fingerprint):
@@ -78,7 +78,7 @@ kosli attest pullrequest gitlab \
 	--api-token yourAPIToken \
 	--org yourOrgName
 
-# fail if a merge request does not exist for your artifact
+# fail if a merge request does not exist for your artifact, or if it was not approved by someone else after its last commit
 kosli attest pullrequest gitlab \
 	--name yourTemplateArtifactName.yourAttestationName \
 	--flow yourFlowName \
@@ -148,7 +148,7 @@ func newAttestGitlabPRCmd(out io.Writer) *cobra.Command {
 	ci := WhichCI()
 	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
 	addGitlabFlags(cmd, o.getRetriever().(*gitlabUtils.GitlabConfig), ci)
-	cmd.Flags().BoolVar(&o.assert, "assert", false, assertPREvidenceFlag)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestPRAssertFlag)
 
 	err := RequireFlags(cmd, []string{"flow", "trail", "name",
 		"gitlab-token", "gitlab-org", "commit", "repository"})
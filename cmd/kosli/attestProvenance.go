@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/kosli-dev/cli/internal/provenance"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+)
+
+type ProvenanceAttestationPayload struct {
+	*CommonAttestationPayload
+	Provenance        *provenance.ProvenanceData `json:"provenance"`
+	AllowedBuilderIDs []string                   `json:"allowed_builder_ids,omitempty"`
+	Compliant         bool                       `json:"is_compliant"`
+}
+
+type attestProvenanceOptions struct {
+	*CommonAttestationOptions
+	provenanceFilePath string
+	uploadProvenance   bool
+	assert             bool
+	payload            ProvenanceAttestationPayload
+}
+
+const attestProvenanceShortDesc = `Report a provenance attestation to an artifact in a Kosli flow.  `
+
+const attestProvenanceLongDesc = attestProvenanceShortDesc + `
+The ^--provenance^ file must have a SLSA provenance (v1 or v0.2) in-toto statement about the artifact.
+It can be a plain in-toto statement, a DSSE envelope (e.g. the .intoto.jsonl file of the SLSA GitHub generator)
+or a sigstore bundle (e.g. from ^cosign attest-blob --bundle^ or GitHub artifact attestations).
+Files with several statements or envelopes (one per line) are supported.
+The signatures of DSSE envelopes and bundles are not verified; use ^kosli attest signature^ for that.
+
+The provenance must have the artifact fingerprint in its subjects. The builder ID, build type,
+build invocation, source repository and commit, and materials (resolved dependencies) of the build
+are reported to Kosli.
+
+Use ^--allowed-builder-ids^ to set the builders allowed to build the artifact. The attestation is
+compliant if the builder of the provenance is allowed (or if no builders are set). Use ^--assert^
+to exit with non-zero code if the attestation is non-compliant.
+
+By default, the ^--provenance^ file is also uploaded to Kosli's evidence vault.
+You can disable that by setting ^--upload-provenance=false^
+` + attestationBindingDesc + `
+
+` + commitDescription
+
+const attestProvenanceExample = `
+# report a provenance attestation about a pre-built docker artifact (kosli calculates the fingerprint):
+kosli attest provenance yourDockerImageName \
+	--artifact-type docker \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--provenance yourProvenanceFile \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a provenance attestation about an artifact built by the SLSA GitHub generator,
+# and fail if it was built by another builder:
+kosli attest provenance \
+	--fingerprint yourArtifactFingerprint \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--provenance yourArtifact.intoto.jsonl \
+	--allowed-builder-ids https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml \
+	--assert \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a provenance attestation about an artifact without uploading the provenance file:
+kosli attest provenance yourFilePath \
+	--artifact-type file \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--provenance yourProvenanceFile \
+	--upload-provenance=false \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+func newAttestProvenanceCmd(out io.Writer) *cobra.Command {
+	o := &attestProvenanceOptions{
+		CommonAttestationOptions: &CommonAttestationOptions{
+			fingerprintOptions: &fingerprintOptions{},
+		},
+		payload: ProvenanceAttestationPayload{
+			CommonAttestationPayload: &CommonAttestationPayload{},
+		},
+	}
+	cmd := &cobra.Command{
+		// Args:    cobra.MaximumNArgs(1),  // See CustomMaximumNArgs() below
+		Use:     "provenance [IMAGE-NAME | FILE-PATH | DIR-PATH]",
+		Short:   attestProvenanceShortDesc,
+		Long:    attestProvenanceLongDesc,
+		Example: attestProvenanceExample,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+
+			err := CustomMaximumNArgs(1, args)
+			if err != nil {
+				return err
+			}
+
+			err = RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			err = MuXRequiredFlags(cmd, []string{"fingerprint", "artifact-type"}, true)
+			if err != nil {
+				return err
+			}
+
+			err = ValidateSliceValues(o.redactedCommitInfo, allowedCommitRedactionValues)
+			if err != nil {
+				return fmt.Errorf("%s for --redact-commit-info", err.Error())
+			}
+
+			err = ValidateAttestationArtifactArg(args, o.fingerprintOptions.artifactType, o.payload.ArtifactFingerprint)
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			return ValidateRegistryFlags(cmd, o.fingerprintOptions)
+
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	ci := WhichCI()
+	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
+	cmd.Flags().StringVarP(&o.provenanceFilePath, "provenance", "P", "", provenanceFileFlag)
+	cmd.Flags().BoolVar(&o.uploadProvenance, "upload-provenance", true, uploadProvenanceFlag)
+	cmd.Flags().StringSliceVar(&o.payload.AllowedBuilderIDs, "allowed-builder-ids", []string{}, allowedBuilderIDsFlag)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestationAssertFlag)
+
+	err := RequireFlags(cmd, []string{"flow", "trail", "name", "provenance"})
+	if err != nil {
+		logger.Error("failed to configure required flags: %v", err)
+	}
+
+	return cmd
+}
+
+func (o *attestProvenanceOptions) run(args []string) error {
+	url := fmt.Sprintf("%s/api/v2/attestations/%s/%s/trail/%s/provenance", global.Host, global.Org, o.flowName, o.trailName)
+
+	err := o.CommonAttestationOptions.run(args, o.payload.CommonAttestationPayload)
+	if err != nil {
+		return err
+	}
+
+	o.payload.Provenance, err = provenance.ProcessProvenanceFile(o.provenanceFilePath, o.payload.ArtifactFingerprint)
+	if err != nil {
+		return err
+	}
+
+	o.payload.Compliant = len(o.payload.AllowedBuilderIDs) == 0 || o.payload.Provenance.CheckBuilder(o.payload.AllowedBuilderIDs)
+
+	if o.uploadProvenance {
+		o.attachments = append(o.attachments, o.provenanceFilePath)
+	}
+
+	form, cleanupNeeded, evidencePath, err := prepareAttestationForm(o.payload, o.attachments)
+	if err != nil {
+		return err
+	}
+	// if we created a tar package, remove it after uploading it
+	if cleanupNeeded {
+		defer os.Remove(evidencePath)
+	}
+
+	reqParams := &requests.RequestParams{
+		Method: http.MethodPost,
+		URL:    url,
+		Form:   form,
+		DryRun: global.DryRun,
+		Token:  global.ApiToken,
+	}
+	_, err = kosliClient.Do(reqParams)
+	if err == nil && !global.DryRun {
+		logger.Info("provenance attestation '%s' is reported to trail: %s", o.payload.AttestationName, o.trailName)
+	}
+
+	if !o.payload.Compliant && o.assert && !global.DryRun {
+		return fmt.Errorf("assert failed: builder %s is not one of the allowed builders", o.payload.Provenance.BuilderID)
+	}
+	return wrapAttestationError(err)
+}
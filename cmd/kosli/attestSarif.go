@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/sarif"
+	"github.com/spf13/cobra"
+)
+
+type SarifAttestationPayload struct {
+	*CommonAttestationPayload
+	SarifResults *sarif.SarifData `json:"sarif_results"`
+	MaxSeverity  map[string]int   `json:"max_severity,omitempty"`
+	Compliant    bool             `json:"is_compliant"`
+}
+
+type attestSarifOptions struct {
+	*CommonAttestationOptions
+	sarifFilePaths    []string
+	uploadResultsFile bool
+	assert            bool
+	payload           SarifAttestationPayload
+}
+
+const attestSarifShortDesc = `Report a SARIF attestation to an artifact or a trail in a Kosli flow.  `
+
+const attestSarifLongDesc = attestSarifShortDesc + `
+Any SARIF 2.1 output is accepted, e.g. from Trivy, Semgrep, CodeQL, Checkov, gitleaks or Snyk.
+One or more ^--scan-results^ files can be provided. Each run in each file is summarized
+and reported to Kosli together with the name and version of the tool that produced it.
+
+The results of all tools are normalized to one of these severities: critical, high, medium, low and info.
+The severity of a result is taken from (in order of precedence):
+- the ^security-severity^ property (a CVSS score) of the result or its rule, using the same ranges as GitHub code scanning.
+- the ^severity^ or ^problem.severity^ property of the result or its rule.
+- the level of the result or the default level of its rule (error is high, warning is medium, note is low).
+Suppressed results are counted but not included in the severity counts.
+
+Use ^--max-severity^ to set the maximum number of findings allowed per severity. A maximum applies
+to the findings of that severity and all more severe ones, e.g. ^--max-severity high=0^ allows
+no high nor critical findings. The attestation is compliant if no maximum is exceeded. Use ^--assert^ 
+to exit with non-zero code if the attestation is non-compliant.
+
+By default, the ^--scan-results^ files are also uploaded to Kosli's evidence vault.
+You can disable that by setting ^--upload-results=false^
+` + attestationBindingDesc + `
+
+` + commitDescription
+
+const attestSarifExample = `
+# report a SARIF attestation about a pre-built docker artifact (kosli calculates the fingerprint):
+kosli attest sarif yourDockerImageName \
+	--artifact-type docker \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--scan-results yourSARIFScanResults \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a SARIF attestation about a trail from the results of several scanners:
+kosli attest sarif \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--scan-results trivy.sarif,semgrep.sarif \
+	--scan-results gitleaks.sarif \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a SARIF attestation about a trail and fail if there are high (or critical) findings 
+# or more than 5 medium (or more severe) findings:
+kosli attest sarif \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--scan-results yourSARIFScanResults \
+	--max-severity high=0,medium=5 \
+	--assert \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a SARIF attestation about a trail without uploading the results files:
+kosli attest sarif \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--scan-results yourSARIFScanResults \
+	--upload-results=false \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+func newAttestSarifCmd(out io.Writer) *cobra.Command {
+	o := &attestSarifOptions{
+		CommonAttestationOptions: &CommonAttestationOptions{
+			fingerprintOptions: &fingerprintOptions{},
+		},
+		payload: SarifAttestationPayload{
+			CommonAttestationPayload: &CommonAttestationPayload{},
+		},
+	}
+	cmd := &cobra.Command{
+		// Args:    cobra.MaximumNArgs(1),  // See CustomMaximumNArgs() below
+		Use:     "sarif [IMAGE-NAME | FILE-PATH | DIR-PATH]",
+		Short:   attestSarifShortDesc,
+		Long:    attestSarifLongDesc,
+		Example: attestSarifExample,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+
+			err := CustomMaximumNArgs(1, args)
+			if err != nil {
+				return err
+			}
+
+			err = RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			err = MuXRequiredFlags(cmd, []string{"fingerprint", "artifact-type"}, false)
+			if err != nil {
+				return err
+			}
+
+			err = ValidateSliceValues(o.redactedCommitInfo, allowedCommitRedactionValues)
+			if err != nil {
+				return fmt.Errorf("%s for --redact-commit-info", err.Error())
+			}
+
+			err = sarif.ValidateThresholds(o.payload.MaxSeverity)
+			if err != nil {
+				return fmt.Errorf("%s for --max-severity", err.Error())
+			}
+
+			err = ValidateAttestationArtifactArg(args, o.fingerprintOptions.artifactType, o.payload.ArtifactFingerprint)
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			return ValidateRegistryFlags(cmd, o.fingerprintOptions)
+
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	ci := WhichCI()
+	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
+	cmd.Flags().StringSliceVarP(&o.sarifFilePaths, "scan-results", "R", []string{}, sarifResultsFilesFlag)
+	cmd.Flags().BoolVar(&o.uploadResultsFile, "upload-results", true, uploadSarifResultsFlag)
+	cmd.Flags().StringToIntVar(&o.payload.MaxSeverity, "max-severity", map[string]int{}, sarifMaxSeverityFlag)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestationAssertFlag)
+
+	err := RequireFlags(cmd, []string{"flow", "trail", "name", "scan-results"})
+	if err != nil {
+		logger.Error("failed to configure required flags: %v", err)
+	}
+
+	return cmd
+}
+
+func (o *attestSarifOptions) run(args []string) error {
+	url := fmt.Sprintf("%s/api/v2/attestations/%s/%s/trail/%s/sarif", global.Host, global.Org, o.flowName, o.trailName)
+
+	err := o.CommonAttestationOptions.run(args, o.payload.CommonAttestationPayload)
+	if err != nil {
+		return err
+	}
+
+	o.payload.SarifResults, err = sarif.ProcessSarifResultFiles(o.sarifFilePaths)
+	if err != nil {
+		return err
+	}
+
+	exceeded := o.payload.SarifResults.CheckThresholds(o.payload.MaxSeverity)
+	o.payload.Compliant = len(exceeded) == 0
+
+	if o.uploadResultsFile {
+		o.attachments = append(o.attachments, o.sarifFilePaths...)
+	}
+
+	form, cleanupNeeded, evidencePath, err := prepareAttestationForm(o.payload, o.attachments)
+	if err != nil {
+		return err
+	}
+	// if we created a tar package, remove it after uploading it
+	if cleanupNeeded {
+		defer os.Remove(evidencePath)
+	}
+
+	reqParams := &requests.RequestParams{
+		Method: http.MethodPost,
+		URL:    url,
+		Form:   form,
+		DryRun: global.DryRun,
+		Token:  global.ApiToken,
+	}
+	_, err = kosliClient.Do(reqParams)
+	if err == nil && !global.DryRun {
+		logger.Info("sarif attestation '%s' is reported to trail: %s", o.payload.AttestationName, o.trailName)
+	}
+
+	if !o.payload.Compliant && o.assert && !global.DryRun {
+		return fmt.Errorf("assert failed: %s", strings.Join(exceeded, ", "))
+	}
+	return wrapAttestationError(err)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/sbom"
+	"github.com/spf13/cobra"
+)
+
+type SBOMAttestationPayload struct {
+	*CommonAttestationPayload
+	SBOM          *sbom.SBOMData `json:"sbom"`
+	DigestChecked bool           `json:"digest_checked"`
+}
+
+type attestSBOMOptions struct {
+	*CommonAttestationOptions
+	sbomFilePath string
+	uploadSBOM   bool
+	payload      SBOMAttestationPayload
+}
+
+const attestSBOMShortDesc = `Report an SBOM attestation to an artifact in a Kosli flow.  `
+
+const attestSBOMLongDesc = attestSBOMShortDesc + `
+The ^--sbom^ file must be a CycloneDX (1.2 to 1.6) or SPDX (2.x) SBOM in JSON format, e.g. as
+generated by ^syft^ or ^trivy^. The SBOM is validated, and its format, the tools which generated it,
+its primary component, its components (name, version and package URL) and a breakdown of their licenses
+are reported to Kosli. Components without a license are counted as ^unknown^.
+Use ^kosli search component^ to find the running artifacts which contain a component.
+
+If the primary component of the SBOM (the component the SBOM describes) has a sha256 digest
+(e.g. the digest of the image for image SBOMs), the command fails if it does not match the artifact fingerprint.
+
+By default, the ^--sbom^ file is also uploaded to Kosli's evidence vault.
+You can disable that by setting ^--upload-sbom=false^
+` + attestationBindingDesc + `
+
+` + commitDescription
+
+const attestSBOMExample = `
+# report an SBOM attestation about a pre-built docker artifact (kosli calculates the fingerprint):
+kosli attest sbom yourDockerImageName \
+	--artifact-type docker \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--sbom yourSBOM.cdx.json \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report an SBOM attestation about a pre-built docker artifact (you provide the fingerprint):
+kosli attest sbom \
+	--fingerprint yourDockerImageFingerprint \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--sbom yourSBOM.spdx.json \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report an SBOM attestation about an artifact without uploading the SBOM file:
+kosli attest sbom yourFilePath \
+	--artifact-type file \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--sbom yourSBOM.cdx.json \
+	--upload-sbom=false \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+func newAttestSBOMCmd(out io.Writer) *cobra.Command {
+	o := &attestSBOMOptions{
+		CommonAttestationOptions: &CommonAttestationOptions{
+			fingerprintOptions: &fingerprintOptions{},
+		},
+		payload: SBOMAttestationPayload{
+			CommonAttestationPayload: &CommonAttestationPayload{},
+		},
+	}
+	cmd := &cobra.Command{
+		// Args:    cobra.MaximumNArgs(1),  // See CustomMaximumNArgs() below
+		Use:     "sbom [IMAGE-NAME | FILE-PATH | DIR-PATH]",
+		Short:   attestSBOMShortDesc,
+		Long:    attestSBOMLongDesc,
+		Example: attestSBOMExample,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+
+			err := CustomMaximumNArgs(1, args)
+			if err != nil {
+				return err
+			}
+
+			err = RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			err = MuXRequiredFlags(cmd, []string{"fingerprint", "artifact-type"}, true)
+			if err != nil {
+				return err
+			}
+
+			err = ValidateSliceValues(o.redactedCommitInfo, allowedCommitRedactionValues)
+			if err != nil {
+				return fmt.Errorf("%s for --redact-commit-info", err.Error())
+			}
+
+			err = ValidateAttestationArtifactArg(args, o.fingerprintOptions.artifactType, o.payload.ArtifactFingerprint)
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			return ValidateRegistryFlags(cmd, o.fingerprintOptions)
+
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	ci := WhichCI()
+	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
+	cmd.Flags().StringVar(&o.sbomFilePath, "sbom", "", sbomFileFlag)
+	cmd.Flags().BoolVar(&o.uploadSBOM, "upload-sbom", true, uploadSBOMFlag)
+
+	err := RequireFlags(cmd, []string{"flow", "trail", "name", "sbom"})
+	if err != nil {
+		logger.Error("failed to configure required flags: %v", err)
+	}
+
+	return cmd
+}
+
+func (o *attestSBOMOptions) run(args []string) error {
+	url := fmt.Sprintf("%s/api/v2/attestations/%s/%s/trail/%s/sbom", global.Host, global.Org, o.flowName, o.trailName)
+
+	err := o.CommonAttestationOptions.run(args, o.payload.CommonAttestationPayload)
+	if err != nil {
+		return err
+	}
+
+	o.payload.SBOM, err = sbom.ProcessSBOMFile(o.sbomFilePath)
+	if err != nil {
+		return err
+	}
+
+	o.payload.DigestChecked, err = o.payload.SBOM.CheckDigest(o.payload.ArtifactFingerprint)
+	if err != nil {
+		return err
+	}
+	if !o.payload.DigestChecked {
+		logger.Warning("the primary component of the SBOM has no sha256 digest, it is not checked against the artifact fingerprint")
+	}
+
+	if o.uploadSBOM {
+		o.attachments = append(o.attachments, o.sbomFilePath)
+	}
+
+	form, cleanupNeeded, evidencePath, err := prepareAttestationForm(o.payload, o.attachments)
+	if err != nil {
+		return err
+	}
+	// if we created a tar package, remove it after uploading it
+	if cleanupNeeded {
+		defer os.Remove(evidencePath)
+	}
+
+	reqParams := &requests.RequestParams{
+		Method: http.MethodPost,
+		URL:    url,
+		Form:   form,
+		DryRun: global.DryRun,
+		Token:  global.ApiToken,
+	}
+	_, err = kosliClient.Do(reqParams)
+	if err == nil && !global.DryRun {
+		logger.Info("sbom attestation '%s' is reported to trail: %s", o.payload.AttestationName, o.trailName)
+	}
+	return wrapAttestationError(err)
+}
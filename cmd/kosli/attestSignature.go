@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/signature"
+	"github.com/spf13/cobra"
+)
+
+type SignatureAttestationPayload struct {
+	*CommonAttestationPayload
+	SignatureResults *signature.SignatureData `json:"signature_results"`
+	Compliant        bool                     `json:"is_compliant"`
+}
+
+type attestSignatureOptions struct {
+	*CommonAttestationOptions
+	bundleFilePaths       []string
+	publicKeyPath         string
+	certificateChainPath  string
+	rekorPublicKeyPath    string
+	certificateIdentity   string
+	certificateOIDCIssuer string
+	assert                bool
+	payload               SignatureAttestationPayload
+}
+
+const attestSignatureShortDesc = `Report a signature attestation to an artifact in a Kosli flow.  `
+
+const attestSignatureLongDesc = attestSignatureShortDesc + `
+The cosign signatures and in-toto attestations of the artifact are verified offline, and the
+identity of their signers, their issuers and the verification results are reported to Kosli.
+
+By default, the cosign signatures and attestations of an image are fetched from its registry, where
+cosign stores them with the tags ^sha256-<fingerprint>.sig^ and ^sha256-<fingerprint>.att^.
+The registry is authenticated to in the same way as when fingerprinting images.
+Use ^--bundle^ to read them from sigstore bundle files instead (e.g. from ^cosign sign-blob --bundle^,
+^cosign attest-blob --bundle^ or GitHub artifact attestations), which works for any artifact type.
+
+Signatures made with a key pair are verified with the public key provided with ^--key^.
+Keyless signatures are verified with the Fulcio certificate chain provided with ^--certificate-chain^,
+the Rekor public key provided with ^--rekor-public-key^ (which verifies the signed entry timestamp of
+their transparency log entry), and must be signed by ^--certificate-identity^ with a certificate
+issued for ^--certificate-oidc-issuer^.
+The trusted root material of the public sigstore instance can be downloaded from https://github.com/sigstore/root-signing.
+
+The attestation is compliant if at least one signature or attestation of the artifact is verified.
+Use ^--assert^ to exit with non-zero code if the attestation is non-compliant.
+` + attestationBindingDesc + `
+
+` + commitDescription
+
+const attestSignatureExample = `
+# report a signature attestation about an image signed with cosign and a key pair
+# (kosli calculates the fingerprint and fetches the signatures from the registry):
+kosli attest signature yourImageName \
+	--artifact-type oci \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--key cosign.pub \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a signature attestation about an image signed keyless with cosign in GitHub Actions,
+# and fail if it is not verified:
+kosli attest signature yourImageName \
+	--artifact-type oci \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--certificate-chain fulcio.crt.pem \
+	--rekor-public-key rekor.pub \
+	--certificate-identity https://github.com/yourOrg/yourRepo/.github/workflows/release.yml@refs/heads/main \
+	--certificate-oidc-issuer https://token.actions.githubusercontent.com \
+	--assert \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report a signature attestation about a file from a sigstore bundle:
+kosli attest signature yourFilePath \
+	--artifact-type file \
+	--name yourAttestationName \
+	--flow yourFlowName \
+	--trail yourTrailName \
+	--bundle yourFile.sigstore.json \
+	--key cosign.pub \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+func newAttestSignatureCmd(out io.Writer) *cobra.Command {
+	o := &attestSignatureOptions{
+		CommonAttestationOptions: &CommonAttestationOptions{
+			fingerprintOptions: &fingerprintOptions{},
+		},
+		payload: SignatureAttestationPayload{
+			CommonAttestationPayload: &CommonAttestationPayload{},
+		},
+	}
+	cmd := &cobra.Command{
+		// Args:    cobra.MaximumNArgs(1),  // See CustomMaximumNArgs() below
+		Use:     "signature [IMAGE-NAME | FILE-PATH | DIR-PATH]",
+		Short:   attestSignatureShortDesc,
+		Long:    attestSignatureLongDesc,
+		Example: attestSignatureExample,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+
+			err := CustomMaximumNArgs(1, args)
+			if err != nil {
+				return err
+			}
+
+			err = RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			err = MuXRequiredFlags(cmd, []string{"fingerprint", "artifact-type"}, true)
+			if err != nil {
+				return err
+			}
+
+			err = RequireAtLeastOneOfFlags(cmd, []string{"key", "certificate-chain"})
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("certificate-chain") && !cmd.Flags().Changed("rekor-public-key") {
+				return fmt.Errorf("flag --rekor-public-key is required when flag --certificate-chain is set")
+			}
+			for _, flagName := range []string{"certificate-identity", "certificate-oidc-issuer"} {
+				err = ConditionallyRequiredFlags(cmd, flagName, "certificate-chain")
+				if err != nil {
+					return err
+				}
+			}
+
+			err = ValidateSliceValues(o.redactedCommitInfo, allowedCommitRedactionValues)
+			if err != nil {
+				return fmt.Errorf("%s for --redact-commit-info", err.Error())
+			}
+
+			err = ValidateAttestationArtifactArg(args, o.fingerprintOptions.artifactType, o.payload.ArtifactFingerprint)
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			if len(o.bundleFilePaths) == 0 {
+				if len(args) == 0 {
+					return ErrorBeforePrintingUsage(cmd, "an image name argument is required to fetch the signatures from its registry when --bundle is not set")
+				}
+				if o.fingerprintOptions.artifactType != "" && o.fingerprintOptions.artifactType != "docker" && o.fingerprintOptions.artifactType != "oci" {
+					return ErrorBeforePrintingUsage(cmd, "--bundle is required when --artifact-type is not 'docker' or 'oci'")
+				}
+			}
+
+			return ValidateRegistryFlags(cmd, o.fingerprintOptions)
+
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	ci := WhichCI()
+	addAttestationFlags(cmd, o.CommonAttestationOptions, o.payload.CommonAttestationPayload, ci)
+	cmd.Flags().StringSliceVar(&o.bundleFilePaths, "bundle", []string{}, signatureBundleFlag)
+	cmd.Flags().StringVar(&o.publicKeyPath, "key", "", signatureKeyFlag)
+	cmd.Flags().StringVar(&o.certificateChainPath, "certificate-chain", "", signatureCertificateChainFlag)
+	cmd.Flags().StringVar(&o.rekorPublicKeyPath, "rekor-public-key", "", signatureRekorPublicKeyFlag)
+	cmd.Flags().StringVar(&o.certificateIdentity, "certificate-identity", "", signatureCertificateIdentityFlag)
+	cmd.Flags().StringVar(&o.certificateOIDCIssuer, "certificate-oidc-issuer", "", signatureCertificateOIDCIssuerFlag)
+	cmd.Flags().BoolVar(&o.assert, "assert", false, attestationAssertFlag)
+
+	err := RequireFlags(cmd, []string{"flow", "trail", "name"})
+	if err != nil {
+		logger.Error("failed to configure required flags: %v", err)
+	}
+
+	return cmd
+}
+
+func (o *attestSignatureOptions) run(args []string) error {
+	url := fmt.Sprintf("%s/api/v2/attestations/%s/%s/trail/%s/signature", global.Host, global.Org, o.flowName, o.trailName)
+
+	err := o.CommonAttestationOptions.run(args, o.payload.CommonAttestationPayload)
+	if err != nil {
+		return err
+	}
+
+	verifyOptions, err := o.verifyOptions()
+	if err != nil {
+		return err
+	}
+
+	if len(o.bundleFilePaths) > 0 {
+		o.payload.SignatureResults, err = signature.VerifyBundleFiles(o.bundleFilePaths, o.payload.ArtifactFingerprint, verifyOptions)
+	} else {
+		err = readFromRegistry(args[0], o.fingerprintOptions, logger, func(username, password string) error {
+			sysCtx, sysCtxErr := digest.RegistrySystemContext(args[0], username, password)
+			if sysCtxErr != nil {
+				return sysCtxErr
+			}
+			var verifyErr error
+			o.payload.SignatureResults, verifyErr = signature.VerifyImageSignatures(args[0], o.payload.ArtifactFingerprint, sysCtx, verifyOptions)
+			return verifyErr
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	for _, result := range o.payload.SignatureResults.Signatures {
+		if result.Verified {
+			logger.Debug("verified %s from %s signed by %s", result.Kind, result.Source, result.SignerIdentity)
+		} else {
+			failures = append(failures, fmt.Sprintf("%s from %s: %s", result.Kind, result.Source, result.Error))
+		}
+	}
+	o.payload.Compliant = o.payload.SignatureResults.Verified()
+
+	form, cleanupNeeded, evidencePath, err := prepareAttestationForm(o.payload, o.attachments)
+	if err != nil {
+		return err
+	}
+	// if we created a tar package, remove it after uploading it
+	if cleanupNeeded {
+		defer os.Remove(evidencePath)
+	}
+
+	reqParams := &requests.RequestParams{
+		Method: http.MethodPost,
+		URL:    url,
+		Form:   form,
+		DryRun: global.DryRun,
+		Token:  global.ApiToken,
+	}
+	_, err = kosliClient.Do(reqParams)
+	if err == nil && !global.DryRun {
+		logger.Info("signature attestation '%s' is reported to trail: %s", o.payload.AttestationName, o.trailName)
+	}
+
+	if !o.payload.Compliant && o.assert && !global.DryRun {
+		if len(failures) == 0 {
+			return fmt.Errorf("assert failed: no signature found for artifact %s", o.payload.ArtifactFingerprint)
+		}
+		return fmt.Errorf("assert failed: no signature verified for artifact %s: %s", o.payload.ArtifactFingerprint, strings.Join(failures, ", "))
+	}
+	return wrapAttestationError(err)
+}
+
+// verifyOptions loads the trusted keys and certificates to verify the signatures with
+func (o *attestSignatureOptions) verifyOptions() (*signature.VerifyOptions, error) {
+	opts := &signature.VerifyOptions{
+		CertificateIdentity:   o.certificateIdentity,
+		CertificateOIDCIssuer: o.certificateOIDCIssuer,
+	}
+	var err error
+	if o.publicKeyPath != "" {
+		opts.PublicKey, err = signature.LoadPublicKey(o.publicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --key: %v", err)
+		}
+	}
+	if o.certificateChainPath != "" {
+		opts.Roots, opts.Intermediates, err = signature.LoadCertificateChain(o.certificateChainPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --certificate-chain: %v", err)
+		}
+	}
+	if o.rekorPublicKeyPath != "" {
+		opts.RekorPublicKey, err = signature.LoadPublicKey(o.rekorPublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --rekor-public-key: %v", err)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+const batchDesc = `All Kosli batch commands.`
+
+func newBatchCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: batchDesc,
+		Long:  batchDesc,
+	}
+
+	// Add subcommands
+	cmd.AddCommand(
+		newBatchRunCmd(out),
+	)
+
+	return cmd
+}
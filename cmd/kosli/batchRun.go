@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const batchRunShortDesc = `Run multiple Kosli commands from a batch manifest file.  `
+
+const batchManifestDesc = `The batch manifest can be in YAML, JSON or TOML formats.
+It lists the steps to run. Each step has a unique ^name^, the kosli ^command^ to run (without the ^kosli^ prefix),
+and optionally its positional ^args^ and its ^flags^ (flag names without the ^--^ prefix).
+Flag values can be scalars, lists (for flags that can be repeated) or maps (for key=value flags such as ^--annotate^).
+
+Values shared by many steps (e.g. flow, trail and commit) can be set once in ^defaults^. A default is applied 
+to every step whose command has a flag with that name, unless the step sets the flag itself.
+Flags that are not set in the manifest can still come from environment variables or the config file, 
+the same way as when running the commands one by one.
+
+Global flags (e.g. ^--api-token^, ^--org^, ^--host^ and ^--dry-run^) cannot be set in the manifest. They are set
+once for the whole batch.
+
+Steps that do not depend on each other run concurrently (see ^--max-parallel^). Use ^depends_on^ to make 
+a step wait for other steps. A step only runs if all the steps it depends on succeeded, otherwise it is skipped.
+
+When a step fails, its ^on_failure^ policy decides what happens next:
+- ^stop^ (default): steps that have not started yet are skipped. Steps already running are allowed to finish.
+- ^continue^: the remaining steps are run.
+
+This is an example YAML batch manifest:
+` +
+	"```yaml\n" +
+	`version: 1
+defaults:
+  flow: backend
+  trail: release-1.2.0
+  commit: 2d5ae9f
+steps:
+  - name: unit-tests
+    command: attest junit
+    flags:
+      name: unit-tests
+      results-dir: build/test-results
+    on_failure: continue
+  - name: snyk
+    command: attest snyk
+    flags:
+      name: snyk-scan
+      scan-results: snyk.json
+  - name: artifact
+    command: attest artifact
+    args: [backend:1.2.0]
+    flags:
+      artifact-type: docker
+      name: backend
+      annotate:
+        team: platform
+    depends_on: [unit-tests, snyk]` +
+	"\n```"
+
+const batchRunLongDesc = batchRunShortDesc + `
+All steps share the same configuration and HTTP client, so flags, the config file and environment 
+variables are only processed once for the whole batch.
+Log messages are printed while the steps run. The output of each step is printed when the batch finishes,
+followed by a summary of the status of each step.
+The command fails if any of the steps fails.
+
+` + batchManifestDesc
+
+const batchRunExample = `
+# run the steps of a batch manifest:
+kosli batch run batch.yaml \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# run the steps of a batch manifest, one step at a time:
+kosli batch run batch.yaml \
+	--max-parallel 1 \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+const (
+	batchStepSucceeded = "succeeded"
+	batchStepFailed    = "failed"
+	batchStepSkipped   = "skipped"
+
+	batchOnFailureStop     = "stop"
+	batchOnFailureContinue = "continue"
+)
+
+// BatchManifest is the specification of a batch of kosli commands
+type BatchManifest struct {
+	Version  int                    `mapstructure:"version" validate:"required,oneof=1"`
+	Defaults map[string]interface{} `mapstructure:"defaults"`
+	Steps    []*BatchStep           `mapstructure:"steps" validate:"required,min=1,dive"`
+}
+
+// BatchStep is a single kosli command in a batch manifest
+type BatchStep struct {
+	Name      string                 `mapstructure:"name" validate:"required"`
+	Command   string                 `mapstructure:"command" validate:"required"`
+	Args      []string               `mapstructure:"args"`
+	Flags     map[string]interface{} `mapstructure:"flags"`
+	DependsOn []string               `mapstructure:"depends_on"`
+	OnFailure string                 `mapstructure:"on_failure" validate:"omitempty,oneof=stop continue"`
+}
+
+// batchStepRun is a batch step that is ready to run
+type batchStepRun struct {
+	step     *BatchStep
+	cmd      *cobra.Command
+	out      *bytes.Buffer
+	status   string
+	details  string
+	duration time.Duration
+}
+
+// batchStepOutcome is the result of running a batch step
+type batchStepOutcome struct {
+	run      *batchStepRun
+	err      error
+	duration time.Duration
+}
+
+type batchRunOptions struct {
+	maxParallel int
+}
+
+func newBatchRunCmd(out io.Writer) *cobra.Command {
+	o := new(batchRunOptions)
+	cmd := &cobra.Command{
+		Use:     "run MANIFEST-FILE",
+		Short:   batchRunShortDesc,
+		Long:    batchRunLongDesc,
+		Example: batchRunExample,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if o.maxParallel < 1 {
+				return ErrorBeforePrintingUsage(cmd, "--max-parallel must be at least 1")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, out, args)
+		},
+	}
+
+	cmd.Flags().IntVar(&o.maxParallel, "max-parallel", 4, batchMaxParallelFlag)
+	addDryRunFlag(cmd)
+
+	return cmd
+}
+
+func (o *batchRunOptions) run(cmd *cobra.Command, out io.Writer, args []string) error {
+	manifest, err := processBatchManifestFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	runs, err := prepareBatchSteps(manifest, cmd.Root().PersistentFlags())
+	if err != nil {
+		return err
+	}
+
+	o.runSteps(runs)
+
+	failed := 0
+	for _, run := range runs {
+		if run.out.Len() > 0 {
+			fmt.Fprintf(out, "--- %s\n%s", run.step.Name, run.out.String())
+			if !strings.HasSuffix(run.out.String(), "\n") {
+				fmt.Fprintln(out)
+			}
+		}
+		if run.status == batchStepFailed {
+			failed++
+		}
+	}
+	printBatchSummary(out, runs)
+
+	if failed > 0 {
+		return fmt.Errorf("[%d] of [%d] batch step(s) failed", failed, len(runs))
+	}
+	return nil
+}
+
+// runSteps runs the prepared steps, respecting their dependencies and failure policies.
+// Steps are started in the order of the manifest as soon as their dependencies have
+// finished, and at most maxParallel steps run at the same time.
+func (o *batchRunOptions) runSteps(runs []*batchStepRun) {
+	results := make(map[string]*batchStepRun, len(runs))
+	for _, run := range runs {
+		results[run.step.Name] = run
+	}
+
+	finished := make(chan batchStepOutcome)
+	pending := runs
+	running := 0
+	stopped := false
+	for len(pending) > 0 || running > 0 {
+		waiting := []*batchStepRun{}
+		skipped := false
+		for _, run := range pending {
+			ready, skipReason := batchStepReadiness(run, results)
+			if ready && stopped {
+				skipReason = "a previous step failed"
+			}
+			switch {
+			case skipReason != "":
+				run.status = batchStepSkipped
+				run.details = skipReason
+				skipped = true
+			case ready && running < o.maxParallel:
+				running++
+				go func(run *batchStepRun) {
+					start := time.Now()
+					err := executeBatchStep(run.cmd, run.step.Args)
+					finished <- batchStepOutcome{run: run, err: err, duration: time.Since(start)}
+				}(run)
+			default:
+				waiting = append(waiting, run)
+			}
+		}
+
+		pending = waiting
+		// skipping a step may unblock the steps depending on it
+		if skipped {
+			continue
+		}
+		if running == 0 {
+			break
+		}
+
+		outcome := <-finished
+		running--
+		run := outcome.run
+		run.duration = outcome.duration
+		if outcome.err != nil {
+			run.status = batchStepFailed
+			run.details = outcome.err.Error()
+			if run.step.OnFailure != batchOnFailureContinue {
+				stopped = true
+			}
+		} else {
+			run.status = batchStepSucceeded
+		}
+	}
+}
+
+// batchStepReadiness tells if all the dependencies of a step have finished.
+// When a dependency did not succeed, it returns the reason to skip the step.
+func batchStepReadiness(run *batchStepRun, results map[string]*batchStepRun) (bool, string) {
+	for _, dependency := range run.step.DependsOn {
+		switch results[dependency].status {
+		case batchStepSucceeded:
+		case "":
+			return false, ""
+		default:
+			return false, fmt.Sprintf("step %s did not succeed", dependency)
+		}
+	}
+	return true, ""
+}
+
+// executeBatchStep runs the hooks of a prepared command the same way cobra does,
+// without parsing the command line again. The flag values and args are validated as
+// on the command line, where an arg starting with '-' would be parsed as a flag.
+func executeBatchStep(cmd *cobra.Command, args []string) error {
+	if err := validateFlagValues(cmd); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			return fmt.Errorf("arg '%s' is illegal", arg)
+		}
+	}
+
+	if cmd.PreRunE != nil {
+		if err := cmd.PreRunE(cmd, args); err != nil {
+			return err
+		}
+	} else if cmd.PreRun != nil {
+		cmd.PreRun(cmd, args)
+	}
+
+	if cmd.RunE != nil {
+		return cmd.RunE(cmd, args)
+	}
+	cmd.Run(cmd, args)
+	return nil
+}
+
+// prepareBatchSteps creates a command for each step and sets its flags.
+// Creating commands registers the global --dry-run flag again, so the global
+// options are restored once all steps are prepared.
+func prepareBatchSteps(manifest *BatchManifest, globalFlags *pflag.FlagSet) ([]*batchStepRun, error) {
+	savedGlobal := *global
+	defer func() { *global = savedGlobal }()
+
+	v, err := newConfigViper()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*batchStepRun{}
+	for _, step := range manifest.Steps {
+		run, err := prepareBatchStep(step, manifest.Defaults, v, globalFlags)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %v", step.Name, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func prepareBatchStep(step *BatchStep, defaults map[string]interface{}, v *viper.Viper, globalFlags *pflag.FlagSet) (*batchStepRun, error) {
+	run := &batchStepRun{
+		step: step,
+		out:  new(bytes.Buffer),
+	}
+
+	root := &cobra.Command{Use: "kosli"}
+	root.AddCommand(newSubcommands(run.out)...)
+	cmd, rest, err := root.Find(strings.Fields(step.Command))
+	if err != nil {
+		return nil, err
+	}
+	if cmd == root || len(rest) > 0 || !cmd.Runnable() {
+		return nil, fmt.Errorf("unknown command: %s", step.Command)
+	}
+	if strings.HasPrefix(cmd.CommandPath(), "kosli batch") {
+		return nil, fmt.Errorf("batch commands cannot be run from a batch manifest")
+	}
+	// merge the persistent flags of the parent commands into the command flags
+	if err := cmd.ParseFlags([]string{}); err != nil {
+		return nil, err
+	}
+	run.cmd = cmd
+
+	for _, name := range sortedKeys(step.Flags) {
+		if err := setBatchStepFlag(cmd, name, step.Flags[name], globalFlags); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sortedKeys(defaults) {
+		if _, ok := step.Flags[name]; ok || cmd.Flags().Lookup(name) == nil {
+			continue
+		}
+		if err := setBatchStepFlag(cmd, name, defaults[name], globalFlags); err != nil {
+			return nil, err
+		}
+	}
+
+	// flags that are not set in the manifest can come from env variables or the config file
+	bindFlags(cmd, v)
+
+	if err := cmd.ValidateArgs(step.Args); err != nil {
+		return nil, err
+	}
+	if err := cmd.ValidateRequiredFlags(); err != nil {
+		return nil, err
+	}
+	if err := cmd.ValidateFlagGroups(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// setBatchStepFlag sets a flag of a step command from a manifest value
+func setBatchStepFlag(cmd *cobra.Command, name string, value interface{}, globalFlags *pflag.FlagSet) error {
+	if globalFlags.Lookup(name) != nil || name == "dry-run" {
+		return fmt.Errorf("global flag --%s cannot be set in a batch manifest, set it for the whole batch instead", name)
+	}
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil {
+		return fmt.Errorf("unknown flag --%s for command: %s", name, cmd.CommandPath())
+	}
+
+	values := []string{}
+	switch typedValue := value.(type) {
+	case []interface{}:
+		for _, item := range typedValue {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(typedValue) {
+			values = append(values, fmt.Sprintf("%s=%v", key, typedValue[key]))
+		}
+	default:
+		values = append(values, fmt.Sprintf("%v", typedValue))
+	}
+
+	for _, item := range values {
+		if err := cmd.Flags().Set(name, item); err != nil {
+			return fmt.Errorf("invalid value for flag --%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func printBatchSummary(out io.Writer, runs []*batchStepRun) {
+	header := []string{"STEP", "COMMAND", "STATUS", "EXIT STATUS", "DURATION", "DETAILS"}
+	rows := []string{}
+	for _, run := range runs {
+		exitStatus := "-"
+		duration := "-"
+		switch run.status {
+		case batchStepSucceeded:
+			exitStatus = "0"
+		case batchStepFailed:
+			exitStatus = "1"
+		}
+		if run.status != batchStepSkipped {
+			duration = run.duration.Round(time.Millisecond).String()
+		}
+		details := strings.ReplaceAll(run.details, "\n", " ")
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", run.step.Name, run.step.Command, run.status, exitStatus, duration, details)
+		rows = append(rows, row)
+	}
+	tabFormattedPrint(out, header, rows)
+}
+
+func processBatchManifestFile(manifestFile string) (*BatchManifest, error) {
+	var manifest *BatchManifest
+	v := viper.New()
+	v.SetConfigFile(manifestFile)
+
+	if err := v.ReadInConfig(); err != nil {
+		return manifest, fmt.Errorf("failed to parse batch manifest file [%s] : %v", manifestFile, err)
+	}
+
+	if err := v.UnmarshalExact(&manifest); err != nil {
+		return manifest, fmt.Errorf("failed to unmarshal batch manifest file [%s] : %v", manifestFile, err)
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(manifest); err != nil {
+		return manifest, fmt.Errorf("batch manifest file [%s] is invalid: %v", manifestFile, err)
+	}
+
+	if err := validateBatchSteps(manifest.Steps); err != nil {
+		return manifest, fmt.Errorf("batch manifest file [%s] is invalid: %v", manifestFile, err)
+	}
+
+	return manifest, nil
+}
+
+// validateBatchSteps checks that step names are unique and that
+// step dependencies exist and do not form a cycle
+func validateBatchSteps(steps []*BatchStep) error {
+	stepsByName := make(map[string]*BatchStep, len(steps))
+	for _, step := range steps {
+		if _, ok := stepsByName[step.Name]; ok {
+			return fmt.Errorf("step name %s is used more than once", step.Name)
+		}
+		stepsByName[step.Name] = step
+	}
+	for _, step := range steps {
+		for _, dependency := range step.DependsOn {
+			if _, ok := stepsByName[dependency]; !ok {
+				return fmt.Errorf("step %s depends on unknown step %s", step.Name, dependency)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(steps))
+	var visit func(step *BatchStep) error
+	visit = func(step *BatchStep) error {
+		switch state[step.Name] {
+		case visiting:
+			return fmt.Errorf("step %s has a circular dependency", step.Name)
+		case visited:
+			return nil
+		}
+		state[step.Name] = visiting
+		for _, dependency := range step.DependsOn {
+			if err := visit(stepsByName[dependency]); err != nil {
+				return err
+			}
+		}
+		state[step.Name] = visited
+		return nil
+	}
+	for _, step := range steps {
+		if err := visit(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type BatchRunCommandTestSuite struct {
+	suite.Suite
+}
+
+func (suite *BatchRunCommandTestSuite) TestBatchRunCmd() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "fails when the manifest file is missing",
+			cmd:       "batch run",
+			golden:    "Error: accepts 1 arg(s), received 0\n",
+		},
+		{
+			wantError:   true,
+			name:        "fails when the manifest file does not exist",
+			cmd:         "batch run testdata/batch/non-existing.yml",
+			goldenRegex: "^Error: failed to parse batch manifest file \\[testdata/batch/non-existing.yml\\]",
+		},
+		{
+			wantError: true,
+			name:      "fails when --max-parallel is less than 1",
+			cmd:       "batch run testdata/batch/valid-batch.yml --max-parallel 0",
+			golden:    "Error: --max-parallel must be at least 1\nUsage: kosli batch run MANIFEST-FILE [flags]\n",
+		},
+		{
+			name:        "runs all steps of a valid manifest",
+			cmd:         "batch run testdata/batch/valid-batch.yml",
+			goldenRegex: "7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9\\n773fd3300860454a2b065c5912c03008adb11e6a6dcf7c1c64c094ceab8f430a\\nSTEP\\s+COMMAND\\s+STATUS\\s+EXIT STATUS\\s+DURATION\\s+DETAILS\\nfile\\s+fingerprint\\s+succeeded\\s+0\\s+\\S+\\s*\\ndir\\s+fingerprint\\s+succeeded\\s+0\\s+\\S+\\s*\\n$",
+		},
+		{
+			wantError:   true,
+			name:        "skips remaining steps when a step with the stop policy fails",
+			cmd:         "batch run testdata/batch/failing-step-stop.yml --max-parallel 1",
+			goldenRegex: "broken\\s+fingerprint\\s+failed\\s+1\\s+\\S+\\s+testdata/file1 is not a directory\\nindependent\\s+fingerprint\\s+skipped\\s+-\\s+-\\s+a previous step failed\\nError: \\[1\\] of \\[2\\] batch step\\(s\\) failed\\n",
+		},
+		{
+			wantError:   true,
+			name:        "runs remaining steps when a step with the continue policy fails",
+			cmd:         "batch run testdata/batch/failing-step-continue.yml --max-parallel 1",
+			goldenRegex: "broken\\s+fingerprint\\s+failed\\s+1\\s+\\S+\\s+testdata/file1 is not a directory\\ndependent\\s+fingerprint\\s+skipped\\s+-\\s+-\\s+step broken did not succeed\\nindependent\\s+fingerprint\\s+succeeded\\s+0\\s+\\S+\\s*\\nError: \\[1\\] of \\[3\\] batch step\\(s\\) failed\\n",
+		},
+		{
+			wantError:   true,
+			name:        "fails a step with a flag value starting with '-'",
+			cmd:         "batch run testdata/batch/illegal-flag-value.yml",
+			goldenRegex: "file\\s+fingerprint\\s+failed\\s+1\\s+\\S+\\s+flag '--artifact-type' has value '--exclude' which is illegal\\nError: \\[1\\] of \\[1\\] batch step\\(s\\) failed\\n",
+		},
+		{
+			wantError:   true,
+			name:        "fails a step with an arg starting with '-'",
+			cmd:         "batch run testdata/batch/illegal-arg.yml",
+			goldenRegex: "file\\s+fingerprint\\s+failed\\s+1\\s+\\S+\\s+arg '--exclude' is illegal\\nError: \\[1\\] of \\[1\\] batch step\\(s\\) failed\\n",
+		},
+		{
+			wantError: true,
+			name:      "fails for an unknown command",
+			cmd:       "batch run testdata/batch/unknown-command.yml",
+			golden:    "Error: step unknown: unknown command: attest something\n",
+		},
+		{
+			wantError: true,
+			name:      "fails for an unknown flag",
+			cmd:       "batch run testdata/batch/unknown-flag.yml",
+			golden:    "Error: step file: unknown flag --not-a-flag for command: kosli fingerprint\n",
+		},
+		{
+			wantError: true,
+			name:      "fails when a step sets a global flag",
+			cmd:       "batch run testdata/batch/global-flag.yml",
+			golden:    "Error: step file: global flag --api-token cannot be set in a batch manifest, set it for the whole batch instead\n",
+		},
+		{
+			wantError: true,
+			name:      "fails when steps have a circular dependency",
+			cmd:       "batch run testdata/batch/circular-dependency.yml",
+			golden:    "Error: batch manifest file [testdata/batch/circular-dependency.yml] is invalid: step first has a circular dependency\n",
+		},
+		{
+			wantError: true,
+			name:      "fails when step names are duplicated",
+			cmd:       "batch run testdata/batch/duplicate-names.yml",
+			golden:    "Error: batch manifest file [testdata/batch/duplicate-names.yml] is invalid: step name file is used more than once\n",
+		},
+		{
+			wantError:   true,
+			name:        "fails when the failure policy is invalid",
+			cmd:         "batch run testdata/batch/invalid-on-failure.yml",
+			goldenRegex: "^Error: batch manifest file \\[testdata/batch/invalid-on-failure.yml\\] is invalid: .*OnFailure",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+func (suite *BatchRunCommandTestSuite) TestPrepareBatchStepsKeepsGlobalOptions() {
+	_, _, err := executeCommandC("version")
+	require.NoError(suite.T(), err)
+	globalFlags := newBatchRunCmd(nil).Flags()
+	global.DryRun = true
+
+	manifest, err := processBatchManifestFile("testdata/batch/valid-batch.yml")
+	require.NoError(suite.T(), err)
+	runs, err := prepareBatchSteps(manifest, globalFlags)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), runs, 2)
+	require.True(suite.T(), global.DryRun)
+	require.Equal(suite.T(), "dir", runs[1].cmd.Flags().Lookup("artifact-type").Value.String())
+	require.Equal(suite.T(), "file", runs[0].cmd.Flags().Lookup("artifact-type").Value.String())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestBatchRunCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchRunCommandTestSuite))
+}
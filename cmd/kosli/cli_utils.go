@@ -15,7 +15,10 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/kosli-dev/cli/internal/aws"
+	"github.com/kosli-dev/cli/internal/azure"
 	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/kosli-dev/cli/internal/gcp"
 	"github.com/kosli-dev/cli/internal/gitview"
 	log "github.com/kosli-dev/cli/internal/logger"
 	"github.com/kosli-dev/cli/internal/utils"
@@ -33,11 +36,12 @@ const (
 	circleci    = "CircleCI"
 	codeBuild   = "Code Build"
 	jenkins     = "Jenkins"
+	gitea       = "Gitea"
 	unknown     = "Unknown"
 )
 
 // supportedCIs the set of CI tools that are supported for defaulting
-var supportedCIs = []string{bitbucket, github, teamcity, gitlab, azureDevops, circleci, codeBuild}
+var supportedCIs = []string{bitbucket, github, teamcity, gitlab, azureDevops, circleci, codeBuild, gitea}
 
 // ciTemplates a map of kosli flags and corresponding default templates in supported CI tools
 var ciTemplates = map[string]map[string]string{
@@ -84,6 +88,15 @@ var ciTemplates = map[string]map[string]string{
 		"commit-url": "${CODEBUILD_SOURCE_REPO_URL}/commit/${CODEBUILD_RESOLVED_SOURCE_VERSION}",
 		"build-url":  "${CODEBUILD_BUILD_URL}",
 	},
+	// Gitea (and Forgejo) Actions set the same variables as GitHub Actions
+	gitea: {
+		"git-commit": "${GITHUB_SHA}",
+		"repository": "${GITHUB_REPOSITORY}",
+		"org":        "${GITHUB_REPOSITORY_OWNER}",
+		"base-url":   "${GITHUB_SERVER_URL}",
+		"commit-url": "${GITHUB_SERVER_URL}/${GITHUB_REPOSITORY}/commit/${GITHUB_SHA}",
+		"build-url":  "${GITHUB_SERVER_URL}/${GITHUB_REPOSITORY}/actions/runs/${GITHUB_RUN_NUMBER}",
+	},
 	jenkins: {
 		"git-commit": "${GIT_COMMIT}",
 		"commit-url": "${GIT_URL}/commit/${GIT_COMMIT}", // GIT_URL is the git repository url can be http or ssh
@@ -117,6 +130,11 @@ func GetCIDefaultsTemplates(ciTools, keys []string) string {
 func WhichCI() string {
 	if _, ok := os.LookupEnv("BITBUCKET_BUILD_NUMBER"); ok {
 		return bitbucket
+	} else if _, ok := os.LookupEnv("GITEA_ACTIONS"); ok {
+		// must be checked before Github since Gitea Actions also sets GITHUB_RUN_NUMBER
+		return gitea
+	} else if _, ok := os.LookupEnv("FORGEJO_ACTIONS"); ok {
+		return gitea
 	} else if _, ok := os.LookupEnv("GITHUB_RUN_NUMBER"); ok {
 		return github
 	} else if _, ok := os.LookupEnv("TEAMCITY_VERSION"); ok {
@@ -310,7 +328,7 @@ func GetFlagFromVarName(varName string) string {
 }
 
 // GetSha256Digest calculates the sha256 digest of an artifact.
-// Supported artifact types are: dir, file, docker
+// Supported artifact types are: dir, file, archive, oci, docker, oci-dir, docker-archive
 func GetSha256Digest(artifactName string, o *fingerprintOptions, logger *log.Logger) (string, error) {
 	var err error
 	var fingerprint string
@@ -319,8 +337,18 @@ func GetSha256Digest(artifactName string, o *fingerprintOptions, logger *log.Log
 		fingerprint, err = digest.FileSha256(artifactName)
 	case "dir":
 		fingerprint, err = digest.DirSha256(artifactName, o.excludePaths, logger)
+	case "archive":
+		fingerprint, err = digest.ArchiveSha256(artifactName, o.excludePaths, logger)
+	case "oci-dir":
+		fingerprint, err = digest.OciDirSha256(artifactName, logger)
+	case "docker-archive":
+		fingerprint, err = digest.DockerArchiveSha256(artifactName, logger)
 	case "oci":
-		fingerprint, err = digest.OciSha256(artifactName, o.registryUsername, o.registryPassword)
+		err = readFromRegistry(artifactName, o, logger, func(username, password string) error {
+			var readErr error
+			fingerprint, readErr = digest.OciSha256(artifactName, username, password)
+			return readErr
+		})
 	case "docker":
 		if o.registryUsername != "" {
 			fingerprint, err = digest.OciSha256(artifactName, o.registryUsername, o.registryPassword)
@@ -335,6 +363,94 @@ func GetSha256Digest(artifactName string, o *fingerprintOptions, logger *log.Log
 	return fingerprint, err
 }
 
+// registryCredentials returns the credentials to read an image from its registry. When they are not provided
+// with --registry-username and --registry-password and the docker config file has none for the registry,
+// the cloud credentials (from the flags, or else of the environment) are exchanged for a token of ECR,
+// GCR/Artifact Registry and ACR registries. Empty credentials are returned when there are none, and the image
+// is read anonymously. An error is returned when the cloud credentials cannot be exchanged for a registry token.
+func registryCredentials(imageName string, o *fingerprintOptions, logger *log.Logger) (string, string, error) {
+	if o.registryUsername != "" {
+		return o.registryUsername, o.registryPassword, nil
+	}
+	registryHost, err := digest.ImageRegistryHost(imageName)
+	if err != nil {
+		// the invalid image name is reported when reading the image
+		return "", "", nil
+	}
+	if creds, err := digest.DockerConfigCredentials(registryHost); err != nil || creds != nil {
+		// the docker config file credentials are used when reading the image
+		return "", "", nil
+	}
+
+	var username, password string
+	switch {
+	case aws.IsECRRegistry(registryHost):
+		username, password, err = o.awsStaticCreds.ECRCredentials(registryHost)
+	case azure.IsACRRegistry(registryHost):
+		username, password, err = o.azureStaticCredentials.ACRCredentials(registryHost)
+	case gcp.IsGCPRegistry(registryHost):
+		username, password, err = o.gcpStaticCredentials.RegistryCredentials()
+	default:
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get credentials for registry %s: %v", registryHost, err)
+	}
+	logger.Debug("using cloud credentials to read %s from registry %s", imageName, registryHost)
+	return username, password, nil
+}
+
+// cloudCredentialsProvided returns true if registry credentials of a cloud provider are given with flags
+func (o *fingerprintOptions) cloudCredentialsProvided() bool {
+	return o.awsStaticCreds.AccessKeyID != "" || o.azureStaticCredentials.ClientId != "" ||
+		o.gcpStaticCredentials.CredentialsFile != ""
+}
+
+// readFromRegistry calls read with the credentials of registryCredentials to read an image from its registry.
+// When the cloud credentials of the environment cannot be exchanged for a registry token, the image is read
+// anonymously (e.g. a public image of gcr.io) and the exchange error is added to the error of reading it.
+// The exchange error is returned as is when the cloud credentials are given with flags.
+func readFromRegistry(imageName string, o *fingerprintOptions, logger *log.Logger, read func(username, password string) error) error {
+	username, password, credsErr := registryCredentials(imageName, o, logger)
+	if credsErr == nil {
+		return read(username, password)
+	}
+	if o.cloudCredentialsProvided() {
+		return credsErr
+	}
+	logger.Debug("%v, reading %s anonymously", credsErr, imageName)
+	if err := read("", ""); err != nil {
+		return fmt.Errorf("%v (the image was read anonymously: %v)", err, credsErr)
+	}
+	return nil
+}
+
+// GetPlatformSha256Digests returns the fingerprint of a multi-platform image (the digest of its image index)
+// and the fingerprints of its platforms selected with --platform (or all of them with --all-platforms).
+// The image is always read from its registry, as the local docker daemon only has the image of one platform.
+func GetPlatformSha256Digests(artifactName string, o *fingerprintOptions, logger *log.Logger) (string, []digest.ImagePlatformDigest, error) {
+	var (
+		fingerprint     string
+		platformDigests []digest.ImagePlatformDigest
+	)
+	err := readFromRegistry(artifactName, o, logger, func(username, password string) error {
+		var err error
+		fingerprint, platformDigests, err = digest.OciPlatformSha256s(artifactName, username, password)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if o.allPlatforms {
+		return fingerprint, platformDigests, nil
+	}
+	platformDigests, err = digest.SelectImagePlatforms(platformDigests, o.platforms)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %v", artifactName, err)
+	}
+	return fingerprint, platformDigests, nil
+}
+
 // LoadJsonData loads json data from a file
 func LoadJsonData(filepath string) (interface{}, error) {
 	var err error
@@ -426,6 +542,20 @@ func ValidateRegistryFlags(cmd *cobra.Command, o *fingerprintOptions) error {
 	return nil
 }
 
+// ValidatePlatformFlags validates the --platform and --all-platforms flags
+func ValidatePlatformFlags(cmd *cobra.Command, o *fingerprintOptions) error {
+	if !o.platformsRequested() {
+		return nil
+	}
+	if len(o.platforms) > 0 && o.allPlatforms {
+		return ErrorBeforePrintingUsage(cmd, "only one of --platform, --all-platforms is allowed")
+	}
+	if o.artifactType != "docker" && o.artifactType != "oci" {
+		return ErrorBeforePrintingUsage(cmd, "--platform and --all-platforms are only applicable when --artifact-type is 'docker' or 'oci'")
+	}
+	return nil
+}
+
 // ValidateSliceValues checks if all elements in the slice are one of the allowed values
 func ValidateSliceValues(values []string, allowedValues map[string]struct{}) error {
 	for _, value := range values {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/kosli-dev/cli/internal/gcp"
 	log "github.com/kosli-dev/cli/internal/logger"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
@@ -33,6 +34,11 @@ func (suite *CliUtilsTestSuite) TestWhichCI() {
 			envVars: map[string]string{"GITHUB_RUN_NUMBER": "50"},
 			want:    github,
 		},
+		{
+			name:    "Gitea actions is detected even though it also sets Github variables.",
+			envVars: map[string]string{"GITEA_ACTIONS": "true", "GITHUB_RUN_NUMBER": "50"},
+			want:    gitea,
+		},
 		{
 			name:    "Bitbucket actions is detected.",
 			envVars: map[string]string{"BITBUCKET_BUILD_NUMBER": "50"},
@@ -612,6 +618,75 @@ func (suite *CliUtilsTestSuite) TestValidateRegistryFlags() {
 	}
 }
 
+func (suite *CliUtilsTestSuite) TestReadFromRegistry() {
+	// no docker config credentials and no Application Default Credentials for gcr.io
+	suite.T().Setenv("DOCKER_CONFIG", suite.T().TempDir())
+	suite.T().Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(suite.T().TempDir(), "missing.json"))
+
+	for _, t := range []struct {
+		name            string
+		imageName       string
+		options         *fingerprintOptions
+		readErr         error
+		wantCredentials []string
+		wantReads       int
+		wantError       string
+	}{
+		{
+			name:            "registry username and password are used",
+			imageName:       "gcr.io/my-project/app:1.0",
+			options:         &fingerprintOptions{registryUsername: "user", registryPassword: "pass"},
+			wantCredentials: []string{"user", "pass"},
+			wantReads:       1,
+		},
+		{
+			name:            "images of other registries are read anonymously",
+			imageName:       "registry.example.com/app:1.0",
+			options:         &fingerprintOptions{},
+			wantCredentials: []string{"", ""},
+			wantReads:       1,
+		},
+		{
+			name:      "the token exchange error of credentials given with flags is returned",
+			imageName: "gcr.io/my-project/app:1.0",
+			options:   &fingerprintOptions{gcpStaticCredentials: gcp.GCPStaticCredentials{CredentialsFile: "missing.json"}},
+			wantError: "failed to get credentials for registry gcr.io",
+		},
+		{
+			name:            "public images are read anonymously when the environment credentials cannot be exchanged",
+			imageName:       "gcr.io/my-project/app:1.0",
+			options:         &fingerprintOptions{},
+			wantCredentials: []string{"", ""},
+			wantReads:       1,
+		},
+		{
+			name:      "the token exchange error of environment credentials is added to the read error",
+			imageName: "gcr.io/my-project/app:1.0",
+			options:   &fingerprintOptions{},
+			readErr:   fmt.Errorf("unauthorized"),
+			wantReads: 1,
+			wantError: "unauthorized (the image was read anonymously: failed to get credentials for registry gcr.io",
+		},
+	} {
+		suite.Run(t.name, func() {
+			reads := 0
+			var credentials []string
+			err := readFromRegistry(t.imageName, t.options, log.NewStandardLogger(), func(username, password string) error {
+				reads++
+				credentials = []string{username, password}
+				return t.readErr
+			})
+			require.Equal(suite.T(), t.wantReads, reads)
+			if t.wantError != "" {
+				require.ErrorContains(suite.T(), err, t.wantError)
+				return
+			}
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.wantCredentials, credentials)
+		})
+	}
+}
+
 // setEnvVars sets env variables
 func (suite *CliUtilsTestSuite) setEnvVars(envVars map[string]string) {
 	for key, value := range envVars {
@@ -17,10 +17,12 @@ const createEnvironmentLongDesc = createEnvironmentShortDesc + `
 The following types are supported:
   - k8s        - Kubernetes
   - ecs        - Amazon Elastic Container Service
+  - ec2        - Amazon EC2 instances (AMIs)
   - s3         - Amazon S3 object storage
   - lambda     - AWS Lambda serverless
   - docker     - Docker images
   - azure-apps - Azure app services
+  - cloudrun   - Google Cloud Run
   - server     - Generic type
   - logical    - Logical grouping of real environments
 
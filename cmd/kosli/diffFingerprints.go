@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/kosli-dev/cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+const diffFingerprintsShortDesc = `Diff the fingerprints of two directories file by file.  `
+
+const diffFingerprintsLongDesc = diffFingerprintsShortDesc + `
+Each argument is either a DIR-PATH to fingerprint or a MANIFEST file created with
+^kosli fingerprint --artifact-type dir --explain^ (e.g. on another machine).
+The diff lists the paths that make the fingerprints differ: paths only present on one side
+(with the pattern that excluded them on the other side, if any) and paths with different digests.
+
+Use ^--exclude^ to exclude paths when fingerprinting DIR-PATH arguments, as with ^kosli fingerprint^.
+`
+
+const diffFingerprintsExample = `
+# compare the manifests of a dir fingerprinted on two machines
+kosli diff fingerprints build-server-manifest.json prod-server-manifest.json
+
+# compare a dir with a manifest created on another machine
+kosli diff fingerprints mydir prod-server-manifest.json
+
+# compare two dirs while excluding paths
+kosli diff fingerprints mydir1 mydir2 --exclude logs
+`
+
+type diffFingerprintsOptions struct {
+	excludePaths []string
+	output       string
+}
+
+func newDiffFingerprintsCmd(out io.Writer) *cobra.Command {
+	o := new(diffFingerprintsOptions)
+	cmd := &cobra.Command{
+		Use:     "fingerprints {DIR-PATH | MANIFEST} {DIR-PATH | MANIFEST}",
+		Short:   diffFingerprintsShortDesc,
+		Long:    diffFingerprintsLongDesc,
+		Example: diffFingerprintsExample,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args, out)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&o.excludePaths, "exclude", "x", []string{}, diffFingerprintsExcludeFlag)
+	cmd.Flags().StringVarP(&o.output, "output", "o", "table", outputFlag)
+
+	return cmd
+}
+
+func (o *diffFingerprintsOptions) run(args []string, out io.Writer) error {
+	manifest1, err := o.loadManifest(args[0])
+	if err != nil {
+		return err
+	}
+	manifest2, err := o.loadManifest(args[1])
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(digest.DiffDirManifests(manifest1, manifest2))
+	if err != nil {
+		return err
+	}
+
+	wrapper := func(raw string, out io.Writer, page int) error {
+		return printFingerprintsDiffAsTable(args[0], args[1], manifest1, manifest2, raw, out)
+	}
+
+	return output.FormattedPrint(string(content), o.output, out, 0,
+		map[string]output.FormatOutputFunc{
+			"table": wrapper,
+			"json":  output.PrintJson,
+		})
+}
+
+// loadManifest fingerprints a dir or loads a manifest file
+func (o *diffFingerprintsOptions) loadManifest(path string) (*digest.DirManifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return digest.DirManifestSha256(path, o.excludePaths, logger)
+	}
+	return digest.LoadDirManifest(path)
+}
+
+func printFingerprintsDiffAsTable(name1, name2 string, manifest1, manifest2 *digest.DirManifest, raw string, out io.Writer) error {
+	var diff digest.DirManifestDiff
+	err := json.Unmarshal([]byte(raw), &diff)
+	if err != nil {
+		return err
+	}
+
+	if diff.Fingerprint1 == diff.Fingerprint2 {
+		fmt.Fprintf(out, "The fingerprints of %s and %s are identical: %s\n", name1, name2, diff.Fingerprint1)
+		return nil
+	}
+
+	tabFormattedPrint(out, []string{}, []string{
+		fmt.Sprintf("Fingerprint of %s:\t%s", name1, diff.Fingerprint1),
+		fmt.Sprintf("Fingerprint of %s:\t%s", name2, diff.Fingerprint2),
+	})
+
+	for _, only := range []struct {
+		name      string
+		otherName string
+		other     *digest.DirManifest
+		entries   []digest.DirManifestEntry
+	}{
+		{name1, name2, manifest2, diff.OnlyIn1},
+		{name2, name1, manifest1, diff.OnlyIn2},
+	} {
+		if len(only.entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\nOnly present in %s\n", only.name)
+		rows := []string{}
+		for _, entry := range only.entries {
+			row := fmt.Sprintf("\t%s\t%s", entry.Path, entry.Type)
+			if excluded := only.other.ExcludedBy(entry.Path); excluded != nil {
+				row += fmt.Sprintf("\texcluded in %s by %s pattern %q", only.otherName, excluded.Source, excluded.Pattern)
+			}
+			rows = append(rows, row)
+		}
+		tabFormattedPrint(out, []string{}, rows)
+	}
+
+	if len(diff.Changed) > 0 {
+		fmt.Fprintf(out, "\nChanged between %s and %s\n", name1, name2)
+		rows := []string{}
+		for _, change := range diff.Changed {
+			if change.Entry1.Type != change.Entry2.Type {
+				rows = append(rows, fmt.Sprintf("\t%s\ttype:\t%s -> %s", change.Path, change.Entry1.Type, change.Entry2.Type))
+			} else {
+				rows = append(rows, fmt.Sprintf("\t%s\tcontent digest:\t%s -> %s", change.Path, change.Entry1.ContentDigest, change.Entry2.ContentDigest))
+			}
+		}
+		tabFormattedPrint(out, []string{}, rows)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type DiffFingerprintsCommandTestSuite struct {
+	suite.Suite
+	manifestPath        string
+	invalidManifestPath string
+}
+
+func (suite *DiffFingerprintsCommandTestSuite) SetupTest() {
+	manifest, err := digest.DirManifestSha256("testdata/folder1", []string{}, logger)
+	require.NoError(suite.T(), err)
+	content, err := json.Marshal(manifest)
+	require.NoError(suite.T(), err)
+	suite.manifestPath = filepath.Join(suite.T().TempDir(), "manifest.json")
+	require.NoError(suite.T(), os.WriteFile(suite.manifestPath, content, 0644))
+
+	manifest.Entries = manifest.Entries[1:]
+	content, err = json.Marshal(manifest)
+	require.NoError(suite.T(), err)
+	suite.invalidManifestPath = filepath.Join(suite.T().TempDir(), "invalid-manifest.json")
+	require.NoError(suite.T(), os.WriteFile(suite.invalidManifestPath, content, 0644))
+}
+
+func (suite *DiffFingerprintsCommandTestSuite) TestFingerprintExplainCmd() {
+	tests := []cmdTestCase{
+		{
+			name: "dir fingerprint can be explained",
+			cmd:  "fingerprint --artifact-type dir --explain testdata/folder1-with-ignore",
+			golden: `{
+  "fingerprint": "038897ea5334462098d65125380d58a493671fb3b8bdbbee1e75ec8bd4a65c23",
+  "entries": [
+    {
+      "path": ".kosli_ignore",
+      "type": "file",
+      "nameDigest": "4ca097c21c80b4f2b31429eaf2ca6198b13f08754c767c3be8587c4051e66553",
+      "contentDigest": "a5fb76b68afc913eaa97a94c25b01d301a2527face29b296cd6b6c4b8d73b491"
+    },
+    {
+      "path": "hello.txt",
+      "type": "file",
+      "nameDigest": "734cad14909bedfafb5b273b6b0eb01fbfa639587d217f78ce9639bba41f4415",
+      "contentDigest": "fcf33337634c2577a5d86fd7ecb0a25a7c1bb5d89c14fd236f546a5759252c02"
+    }
+  ],
+  "excluded": [
+    {
+      "path": "folder2",
+      "pattern": "folder2",
+      "source": ".kosli_ignore"
+    }
+  ]
+}
+`,
+		},
+		{
+			wantError: true,
+			name:      "fails if --explain is used with another artifact type",
+			cmd:       "fingerprint --artifact-type file --explain testdata/file1",
+			golden:    "Error: --explain is only applicable when --artifact-type is 'dir' or 'archive'\nUsage: kosli fingerprint {IMAGE-NAME | FILE-PATH | DIR-PATH} [flags]\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+func (suite *DiffFingerprintsCommandTestSuite) TestDiffFingerprintsCmd() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "fails if 1 arg is provided",
+			cmd:       "diff fingerprints testdata/folder1",
+			golden:    "Error: accepts 2 arg(s), received 1\n",
+		},
+		{
+			wantError:   true,
+			name:        "fails if an arg does not exist",
+			cmd:         "diff fingerprints testdata/folder1 testdata/not-existing",
+			goldenRegex: "Error: stat testdata/not-existing: no such file or directory\n",
+		},
+		{
+			wantError:   true,
+			name:        "fails if a file is not a manifest",
+			cmd:         "diff fingerprints testdata/folder1 testdata/file1",
+			goldenRegex: "Error: testdata/file1 is not a valid fingerprint manifest: .*\n",
+		},
+		{
+			wantError: true,
+			name:      "fails if the fingerprint of a manifest does not match its entries",
+			cmd:       fmt.Sprintf("diff fingerprints testdata/folder1 %s", suite.invalidManifestPath),
+			goldenRegex: fmt.Sprintf("Error: %s is not a valid fingerprint manifest: its entries give fingerprint [0-9a-f]{64} instead of %s\n",
+				suite.invalidManifestPath, "c43808cb04c6e66c4c6fc1f972dd67c3b9b71c81e0a0c78730da3699922d17be"),
+		},
+		{
+			name:   "a dir and its manifest have identical fingerprints",
+			cmd:    fmt.Sprintf("diff fingerprints testdata/folder1 %s", suite.manifestPath),
+			golden: fmt.Sprintf("The fingerprints of testdata/folder1 and %s are identical: c43808cb04c6e66c4c6fc1f972dd67c3b9b71c81e0a0c78730da3699922d17be\n", suite.manifestPath),
+		},
+		{
+			name: "the paths making two dirs differ are listed",
+			cmd:  "diff fingerprints testdata/folder1 testdata/folder1-with-ignore",
+			golden: `Fingerprint of testdata/folder1:              c43808cb04c6e66c4c6fc1f972dd67c3b9b71c81e0a0c78730da3699922d17be
+Fingerprint of testdata/folder1-with-ignore:  038897ea5334462098d65125380d58a493671fb3b8bdbbee1e75ec8bd4a65c23
+
+Only present in testdata/folder1
+     folder2             dir   excluded in testdata/folder1-with-ignore by .kosli_ignore pattern "folder2"
+     folder2/hello2.txt  file  excluded in testdata/folder1-with-ignore by .kosli_ignore pattern "folder2"
+     folder2/hello3.txt  file  excluded in testdata/folder1-with-ignore by .kosli_ignore pattern "folder2"
+
+Only present in testdata/folder1-with-ignore
+     .kosli_ignore  file
+`,
+		},
+		{
+			name:   "dir args can exclude paths",
+			cmd:    "diff fingerprints testdata/folder1 testdata/folder1-with-ignore --exclude folder2,.kosli_ignore",
+			golden: "The fingerprints of testdata/folder1 and testdata/folder1-with-ignore are identical: 773fd3300860454a2b065c5912c03008adb11e6a6dcf7c1c64c094ceab8f430a\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestDiffFingerprintsCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(DiffFingerprintsCommandTestSuite))
+}
@@ -1,29 +1,60 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 
+	"github.com/kosli-dev/cli/internal/aws"
+	"github.com/kosli-dev/cli/internal/azure"
+	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/kosli-dev/cli/internal/gcp"
 	"github.com/spf13/cobra"
 )
 
 const fingerprintShortDesc = `Calculate the SHA256 fingerprint of an artifact.`
 
-const fingerprintDirSynopsis = `When fingerprinting a 'dir' artifact, you can exclude certain paths from fingerprint calculation 
+const fingerprintDirSynopsis = `When fingerprinting a 'dir' or 'archive' artifact, you can exclude certain paths from fingerprint calculation 
 using the ^--exclude^ flag.
 Excluded paths are relative to the DIR-PATH and can be literal paths or
 glob patterns.  
 The supported glob pattern syntax is what is documented here: https://pkg.go.dev/path/filepath#Match , 
 plus the ability to use recursive globs "**"
 
-` + kosliIgnoreDesc
+` + kosliIgnoreDesc + `
+
+Use ^--explain^ to output a JSON manifest of a 'dir' or 'archive' fingerprint instead of the fingerprint. The manifest
+lists every included path with the digests of its name and content, and every excluded path with the pattern
+that excluded it. Use ^kosli diff fingerprints^ to find the paths that make two fingerprints differ.
+`
 
 const fingerprintLongDesc = fingerprintShortDesc + `
 Requires ^--artifact-type^ flag to be set.
-Artifact type can be one of: "file" for files, "dir" for directories, "oci" for container
-images in registries or "docker" for local docker images.
+Artifact type can be one of: "file" for files, "dir" for directories, "archive" for the contents of
+tar, tar.gz and zip archives (e.g. jar, wheel), "oci" for container images in registries, "docker" for
+local docker images, "oci-dir" for OCI image layouts or "docker-archive" for tarballs created by ^docker save^.
+
+The fingerprint of an 'archive' artifact is calculated without extracting it, and is the same as the fingerprint
+of the directory the archive is extracted to (as a 'dir' artifact). Paths are excluded from 'archive' artifacts
+in the same way as from 'dir' artifacts, including with a ^.kosli_ignore^ file at the root of the archive.
 
 Fingerprinting container images can be done using the local docker daemon or the fingerprint can be fetched
-from a remote registry.
+from a remote registry. Private registries are authenticated with ^--registry-username^ and ^--registry-password^
+if provided, or else with the credentials of the docker config file (^$DOCKER_CONFIG/config.json^ or
+^~/.docker/config.json^), including its ^credHelpers^ and ^credsStore^. For ECR, GCR/Artifact Registry and ACR
+registries without credentials in the docker config file, the AWS, GCP or Azure credentials provided with
+^--aws-key-id^ and ^--aws-secret-key^, ^--gcp-credentials-file^ or ^--azure-client-id^, ^--azure-client-secret^
+and ^--azure-tenant-id^ (or else the credentials of the environment) are exchanged for a registry token.
+
+Images saved to disk can be fingerprinted offline as an 'oci-dir' (an OCI image layout directory or tarball)
+or a 'docker-archive' (a tarball created by ^docker save^, optionally gzipped). The fingerprint is the manifest
+digest the registry reports once the image is pushed. When the layout or tarball contains more than one image,
+select one with ^PATH:REF^, where REF is the tag (e.g. ^image.tar:myapp:1.0^).
+
+The fingerprint of a multi-platform image is the digest of its image index (manifest list), while the
+environments running the image report the digest of the image manifest of their platform. Use ^--platform^ to
+get the fingerprint of a platform (e.g. ^--platform linux/arm64^), or ^--all-platforms^ to get the fingerprints
+of all the platforms. They are listed after the fingerprint of the image index.
 
 ` + fingerprintDirSynopsis
 
@@ -37,6 +68,24 @@ kosli fingerprint --artifact-type dir mydir
 # fingerprint a dir while excluding paths
 kosli fingerprint --artifact-type dir --exclude logs --exclude *.exe mydir
 
+# save the manifest of a dir fingerprint to compare it with the same dir on another machine
+kosli fingerprint --artifact-type dir --explain mydir > mydir-manifest.json
+
+# fingerprint the contents of a tarball (the same fingerprint as the extracted dir)
+kosli fingerprint --artifact-type archive myapp-1.0.tar.gz
+
+# fingerprint an image saved with docker save, without a docker daemon
+kosli fingerprint --artifact-type docker-archive myapp.tar
+
+# fingerprint one of the images in an OCI image layout
+kosli fingerprint --artifact-type oci-dir ./oci-layout:1.0
+
+# fingerprint the linux/arm64 image of a multi-platform image in a registry
+kosli fingerprint --artifact-type oci --platform linux/arm64 docker.io/library/nginx:latest
+
+# list the fingerprints of all the platforms of a multi-platform image in a registry
+kosli fingerprint --artifact-type oci --all-platforms docker.io/library/nginx:latest
+
 # fingerprint a locally available docker image (requires docker daemon running)
 kosli fingerprint --artifact-type docker nginx:latest
 
@@ -45,14 +94,30 @@ kosli fingerprint --artifact-type oci nginx:latest
 
 # fingerprint a private image from a remote registry
 kosli fingerprint --artifact-type oci private:latest --registry-username YourUsername --registry-password YourPassword
+
+# fingerprint a private image from an ECR registry with AWS credentials
+kosli fingerprint --artifact-type oci 123456789012.dkr.ecr.eu-west-1.amazonaws.com/myapp:1.0 \
+	--aws-key-id yourAWSAccessKeyID \
+	--aws-secret-key yourAWSSecretAccessKey
 `
 
 type fingerprintOptions struct {
-	artifactType     string
-	registryProvider string
-	registryUsername string
-	registryPassword string
-	excludePaths     []string
+	artifactType           string
+	registryProvider       string
+	registryUsername       string
+	registryPassword       string
+	excludePaths           []string
+	explain                bool
+	platforms              []string
+	allPlatforms           bool
+	awsStaticCreds         aws.AWSStaticCreds
+	azureStaticCredentials azure.AzureStaticCredentials
+	gcpStaticCredentials   gcp.GCPStaticCredentials
+}
+
+// platformsRequested returns true when the fingerprints of the platforms of a multi-platform image are requested
+func (o *fingerprintOptions) platformsRequested() bool {
+	return len(o.platforms) > 0 || o.allPlatforms
 }
 
 func newFingerprintCmd(out io.Writer) *cobra.Command {
@@ -64,6 +129,13 @@ func newFingerprintCmd(out io.Writer) *cobra.Command {
 		Example: fingerprintExamples,
 		Args:    cobra.ExactArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if o.explain && o.artifactType != "dir" && o.artifactType != "archive" {
+				return ErrorBeforePrintingUsage(cmd, "--explain is only applicable when --artifact-type is 'dir' or 'archive'")
+			}
+			err := ValidatePlatformFlags(cmd, o)
+			if err != nil {
+				return err
+			}
 			return ValidateRegistryFlags(cmd, o)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -73,6 +145,8 @@ func newFingerprintCmd(out io.Writer) *cobra.Command {
 
 	addFingerprintFlags(cmd, o)
 	cmd.Flags().StringSliceVarP(&o.excludePaths, "e", "e", []string{}, excludePathsFlag)
+	cmd.Flags().BoolVar(&o.explain, "explain", false, fingerprintExplainFlag)
+	addPlatformFlags(cmd, o)
 	err := RequireFlags(cmd, []string{"artifact-type"})
 	if err != nil {
 		logger.Error("failed to configure required flags: %v", err)
@@ -90,6 +164,38 @@ func newFingerprintCmd(out io.Writer) *cobra.Command {
 }
 
 func (o *fingerprintOptions) run(args []string, out io.Writer) error {
+	if o.explain {
+		var manifest *digest.DirManifest
+		var err error
+		if o.artifactType == "archive" {
+			manifest, err = digest.ArchiveManifestSha256(args[0], o.excludePaths, logger)
+		} else {
+			manifest, err = digest.DirManifestSha256(args[0], o.excludePaths, logger)
+		}
+		if err != nil {
+			return err
+		}
+		content, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(content))
+		return err
+	}
+
+	if o.platformsRequested() {
+		fingerprint, platformDigests, err := GetPlatformSha256Digests(args[0], o, logger)
+		if err != nil {
+			return err
+		}
+		rows := []string{fmt.Sprintf("index\t%s", fingerprint)}
+		for _, platformDigest := range platformDigests {
+			rows = append(rows, fmt.Sprintf("%s\t%s", platformDigest.Platform, platformDigest.Digest))
+		}
+		tabFormattedPrint(out, []string{"PLATFORM", "FINGERPRINT"}, rows)
+		return nil
+	}
+
 	fingerprint, err := GetSha256Digest(args[0], o, logger)
 	if err != nil {
 		return err
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kosli-dev/cli/internal/docker"
@@ -44,6 +46,27 @@ func (suite *FingerprintTestSuite) TestFingerprintCmd() {
 			cmd:    "fingerprint --artifact-type dir testdata/folder1-with-ignore",
 			golden: "038897ea5334462098d65125380d58a493671fb3b8bdbbee1e75ec8bd4a65c23\n",
 		},
+		{
+			name:   "archive fingerprint is the fingerprint of the extracted dir",
+			cmd:    "fingerprint --artifact-type archive testdata/archives/folder1.tar.gz",
+			golden: "c43808cb04c6e66c4c6fc1f972dd67c3b9b71c81e0a0c78730da3699922d17be\n",
+		},
+		{
+			name:   "archive fingerprint with exclude",
+			cmd:    "fingerprint --artifact-type archive testdata/archives/folder1.tar.gz -x folder2",
+			golden: "773fd3300860454a2b065c5912c03008adb11e6a6dcf7c1c64c094ceab8f430a\n",
+		},
+		{
+			name:   "zip archive fingerprint with ignore file",
+			cmd:    "fingerprint --artifact-type archive testdata/archives/folder1-with-ignore.zip",
+			golden: "038897ea5334462098d65125380d58a493671fb3b8bdbbee1e75ec8bd4a65c23\n",
+		},
+		{
+			wantError: true,
+			name:      "fails if type is archive but the argument is not an archive",
+			cmd:       "fingerprint --artifact-type archive testdata/file1",
+			golden:    "Error: failed to read archive testdata/file1: unsupported archive format. Supported formats are: tar, tar.gz, zip (e.g. jar, wheel)\n",
+		},
 		{
 			name:      "fails if type is directory but the argument is not a dir",
 			cmd:       "fingerprint --artifact-type dir testdata/file1",
@@ -89,6 +112,41 @@ func (suite *FingerprintTestSuite) TestFingerprintCmd() {
 			cmd:       "fingerprint --artifact-type file --registry-provider dockerhub --registry-username user --registry-password pass merkely/change",
 			wantError: true,
 		},
+		{
+			name:      "setting platform flags with non-image artifact-type causes an error",
+			cmd:       "fingerprint --artifact-type file --platform linux/arm64 testdata/file1",
+			golden:    "Error: --platform and --all-platforms are only applicable when --artifact-type is 'docker' or 'oci'\nUsage: kosli fingerprint {IMAGE-NAME | FILE-PATH | DIR-PATH} [flags]\n",
+			wantError: true,
+		},
+		{
+			name:      "setting both --platform and --all-platforms causes an error",
+			cmd:       "fingerprint --artifact-type oci --platform linux/arm64 --all-platforms library/alpine:latest",
+			golden:    "Error: only one of --platform, --all-platforms is allowed\nUsage: kosli fingerprint {IMAGE-NAME | FILE-PATH | DIR-PATH} [flags]\n",
+			wantError: true,
+		},
+	}
+	runTestCmd(suite.T(), tests)
+}
+
+func (suite *FingerprintTestSuite) TestFingerprintFileNamedLikeACommand() {
+	// a file named diff is fingerprinted, and not taken for the diff command
+	dir := suite.T().TempDir()
+	content, err := os.ReadFile("testdata/file1")
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "diff"), content, 0644))
+	cwd, err := os.Getwd()
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), os.Chdir(dir))
+	defer func() {
+		require.NoError(suite.T(), os.Chdir(cwd))
+	}()
+
+	tests := []cmdTestCase{
+		{
+			name:   "file named diff fingerprint",
+			cmd:    "fingerprint --artifact-type file diff",
+			golden: "7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9\n",
+		},
 	}
 	runTestCmd(suite.T(), tests)
 }
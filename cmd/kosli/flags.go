@@ -4,6 +4,8 @@ import (
 	"github.com/kosli-dev/cli/internal/aws"
 	azUtils "github.com/kosli-dev/cli/internal/azure"
 	bbUtils "github.com/kosli-dev/cli/internal/bitbucket"
+	"github.com/kosli-dev/cli/internal/gcp"
+	giteaUtils "github.com/kosli-dev/cli/internal/gitea"
 	ghUtils "github.com/kosli-dev/cli/internal/github"
 	gitlabUtils "github.com/kosli-dev/cli/internal/gitlab"
 	"github.com/spf13/cobra"
@@ -23,6 +25,12 @@ func addFingerprintFlags(cmd *cobra.Command, o *fingerprintOptions) {
 	cmd.Flags().StringVar(&o.registryUsername, "registry-username", "", registryUsernameFlag)
 	cmd.Flags().StringVar(&o.registryPassword, "registry-password", "", registryPasswordFlag)
 	cmd.Flags().StringSliceVarP(&o.excludePaths, "exclude", "x", []string{}, excludePathsFlag)
+	cmd.Flags().StringVar(&o.awsStaticCreds.AccessKeyID, "aws-key-id", "", registryAWSKeyIdFlag)
+	cmd.Flags().StringVar(&o.awsStaticCreds.SecretAccessKey, "aws-secret-key", "", registryAWSSecretKeyFlag)
+	cmd.Flags().StringVar(&o.azureStaticCredentials.ClientId, "azure-client-id", "", registryAzureClientIdFlag)
+	cmd.Flags().StringVar(&o.azureStaticCredentials.ClientSecret, "azure-client-secret", "", registryAzureClientSecretFlag)
+	cmd.Flags().StringVar(&o.azureStaticCredentials.TenantId, "azure-tenant-id", "", registryAzureTenantIdFlag)
+	cmd.Flags().StringVar(&o.gcpStaticCredentials.CredentialsFile, "gcp-credentials-file", "", registryGCPCredentialsFileFlag)
 
 	err := DeprecateFlags(cmd, map[string]string{
 		"registry-provider": "no longer used",
@@ -33,16 +41,32 @@ func addFingerprintFlags(cmd *cobra.Command, o *fingerprintOptions) {
 	}
 }
 
+func addPlatformFlags(cmd *cobra.Command, o *fingerprintOptions) {
+	cmd.Flags().StringSliceVar(&o.platforms, "platform", []string{}, platformFlag)
+	cmd.Flags().BoolVar(&o.allPlatforms, "all-platforms", false, allPlatformsFlag)
+}
+
 func addAWSAuthFlags(cmd *cobra.Command, o *aws.AWSStaticCreds) {
 	cmd.Flags().StringVar(&o.AccessKeyID, "aws-key-id", "", awsKeyIdFlag)
 	cmd.Flags().StringVar(&o.SecretAccessKey, "aws-secret-key", "", awsSecretKeyFlag)
 	cmd.Flags().StringVar(&o.Region, "aws-region", "", awsRegionFlag)
 }
 
+func addGCPFlags(cmd *cobra.Command, o *gcp.GCPStaticCredentials) {
+	cmd.Flags().StringSliceVar(&o.Projects, "gcp-projects", []string{}, gcpProjectsFlag)
+	cmd.Flags().StringSliceVar(&o.Regions, "gcp-regions", []string{}, gcpRegionsFlag)
+	cmd.Flags().StringVar(&o.CredentialsFile, "gcp-credentials-file", "", gcpCredentialsFileFlag)
+	cmd.Flags().StringVar(&o.Endpoint, "cloudrun-endpoint", "", cloudRunEndpointFlag)
+}
+
 func addDryRunFlag(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVarP(&global.DryRun, "dry-run", "D", false, dryRunFlag)
 }
 
+func addFingerprintCacheFlag(cmd *cobra.Command, fingerprintCache *string) {
+	cmd.Flags().StringVar(fingerprintCache, "fingerprint-cache", "", fingerprintCacheFlag)
+}
+
 func addBitbucketFlags(cmd *cobra.Command, bbConfig *bbUtils.Config, ci string) {
 	cmd.Flags().StringVar(&bbConfig.Username, "bitbucket-username", "", bbUsernameFlag)
 	cmd.Flags().StringVar(&bbConfig.Password, "bitbucket-password", "", bbPasswordFlag)
@@ -50,6 +74,11 @@ func addBitbucketFlags(cmd *cobra.Command, bbConfig *bbUtils.Config, ci string)
 	cmd.Flags().StringVar(&bbConfig.Repository, "repository", DefaultValue(ci, "repository"), repositoryFlag)
 }
 
+func addBitbucketServerFlags(cmd *cobra.Command, bbConfig *bbUtils.Config) {
+	cmd.Flags().StringVar(&bbConfig.ServerURL, "bitbucket-server-url", "", bbServerURLFlag)
+	cmd.Flags().StringVar(&bbConfig.AccessToken, "bitbucket-access-token", "", bbAccessTokenFlag)
+}
+
 func addGithubFlags(cmd *cobra.Command, githubFlagsValueHolder *ghUtils.GithubFlagsTempValueHolder, ci string) {
 	cmd.Flags().StringVar(&githubFlagsValueHolder.Token, "github-token", "", githubTokenFlag)
 	cmd.Flags().StringVar(&githubFlagsValueHolder.Org, "github-org", DefaultValue(ci, "org"), githubOrgFlag)
@@ -57,6 +86,19 @@ func addGithubFlags(cmd *cobra.Command, githubFlagsValueHolder *ghUtils.GithubFl
 	cmd.Flags().StringVar(&githubFlagsValueHolder.BaseURL, "github-base-url", "", githubBaseURLFlag)
 }
 
+func addGithubAppFlags(cmd *cobra.Command, githubFlagsValueHolder *ghUtils.GithubFlagsTempValueHolder) {
+	cmd.Flags().Int64Var(&githubFlagsValueHolder.AppID, "github-app-id", 0, githubAppIDFlag)
+	cmd.Flags().StringVar(&githubFlagsValueHolder.AppPrivateKey, "github-app-private-key", "", githubAppPrivateKeyFlag)
+	cmd.Flags().Int64Var(&githubFlagsValueHolder.AppInstallationID, "github-app-installation-id", 0, githubAppInstallationIDFlag)
+}
+
+func addGiteaFlags(cmd *cobra.Command, giteaFlagsValueHolder *giteaUtils.GiteaFlagsTempValueHolder, ci string) {
+	cmd.Flags().StringVar(&giteaFlagsValueHolder.Token, "gitea-token", "", giteaTokenFlag)
+	cmd.Flags().StringVar(&giteaFlagsValueHolder.Org, "gitea-org", DefaultValue(ci, "org"), giteaOrgFlag)
+	cmd.Flags().StringVar(&giteaFlagsValueHolder.Repository, "repository", DefaultValue(ci, "repository"), repositoryFlag)
+	cmd.Flags().StringVar(&giteaFlagsValueHolder.BaseURL, "gitea-base-url", DefaultValue(ci, "base-url"), giteaBaseURLFlag)
+}
+
 func addAzureFlags(cmd *cobra.Command, azureFlagsValueHolder *azUtils.AzureFlagsTempValueHolder, ci string) {
 	cmd.Flags().StringVar(&azureFlagsValueHolder.Token, "azure-token", "", azureTokenFlag)
 	cmd.Flags().StringVar(&azureFlagsValueHolder.OrgUrl, "azure-org-url", DefaultValue(ci, "org-url"), azureOrgUrlFlag)
@@ -132,3 +174,8 @@ func addAttestationFlags(cmd *cobra.Command, o *CommonAttestationOptions, payloa
 	addFingerprintFlags(cmd, o.fingerprintOptions)
 	addDryRunFlag(cmd)
 }
+
+func addAssertPolicyFlags(cmd *cobra.Command, o *assertPolicyOptions) {
+	cmd.Flags().StringVar(&o.policyFile, "policy", "", assertPolicyFileFlag)
+	cmd.Flags().StringArrayVar(&o.jqRules, "jq", []string{}, assertPolicyJqFlag)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+const outboxDesc = `All Kosli outbox commands.`
+
+const outboxLongDesc = outboxDesc + `
+The outbox holds reporting requests that could not be sent to Kosli because the Kosli host
+was not reachable (or responded with a server error). Requests are only stored in the outbox when
+the global ^--outbox-dir^ flag (or the ^KOSLI_OUTBOX_DIR^ env variable) is set.
+The API token is never stored in the outbox. The token provided to ^kosli outbox flush^ is used instead.
+`
+
+func newOutboxCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "outbox",
+		Short: outboxDesc,
+		Long:  outboxLongDesc,
+	}
+
+	// Add subcommands
+	cmd.AddCommand(
+		newOutboxListCmd(out),
+		newOutboxFlushCmd(out),
+		newOutboxDropCmd(out),
+	)
+	return cmd
+}
+
+// requireOutboxDir validates that the global outbox dir flag is set
+func requireOutboxDir(cmd *cobra.Command) error {
+	if global.OutboxDir == "" {
+		return ErrorBeforePrintingUsage(cmd, "--outbox-dir is not set")
+	}
+	return nil
+}
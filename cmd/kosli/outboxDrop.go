@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+)
+
+const outboxDropShortDesc = `Remove requests from the outbox without sending them to Kosli.`
+
+const outboxDropLongDesc = outboxDropShortDesc + `
+Provide the IDs of the requests to remove (as shown by ^kosli outbox list^), or use ^--all^ to empty the outbox.
+`
+
+const outboxDropExample = `
+# remove one request from the outbox:
+kosli outbox drop yourRequestID \
+	--outbox-dir /path/to/outbox
+
+# remove all requests from the outbox:
+kosli outbox drop \
+	--all \
+	--outbox-dir /path/to/outbox
+`
+
+type outboxDropOptions struct {
+	all bool
+}
+
+func newOutboxDropCmd(out io.Writer) *cobra.Command {
+	o := new(outboxDropOptions)
+	cmd := &cobra.Command{
+		Use:     "drop [REQUEST-ID...]",
+		Aliases: []string{"rm"},
+		Short:   outboxDropShortDesc,
+		Long:    outboxDropLongDesc,
+		Example: outboxDropExample,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			err := requireOutboxDir(cmd)
+			if err != nil {
+				return err
+			}
+			if o.all && len(args) > 0 {
+				return ErrorBeforePrintingUsage(cmd, "request IDs cannot be provided together with --all")
+			}
+			if !o.all && len(args) == 0 {
+				return ErrorBeforePrintingUsage(cmd, "at least one request ID or --all is required")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.all, "all", false, outboxDropAllFlag)
+
+	return cmd
+}
+
+func (o *outboxDropOptions) run(args []string) error {
+	outbox := requests.NewOutbox(global.OutboxDir)
+	ids := args
+	if o.all {
+		entries, err := outbox.List()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+	}
+
+	for _, id := range ids {
+		if err := outbox.Remove(id); err != nil {
+			return err
+		}
+	}
+	logger.Info("[%d] request(s) were removed from the outbox", len(ids))
+	return nil
+}
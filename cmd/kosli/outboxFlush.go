@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+)
+
+const outboxFlushShortDesc = `Send the requests queued in the outbox to Kosli.`
+
+const outboxFlushLongDesc = outboxFlushShortDesc + `
+Requests are sent in the order they were queued, using the API token provided to this command.
+Each request is removed from the outbox once it is accepted by Kosli.
+Flushing stops at the first request that fails, so that the order of reported events is preserved.
+`
+
+const outboxFlushExample = `
+# send all queued requests to Kosli:
+kosli outbox flush \
+	--outbox-dir /path/to/outbox \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+func newOutboxFlushCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "flush",
+		Short:   outboxFlushShortDesc,
+		Long:    outboxFlushLongDesc,
+		Example: outboxFlushExample,
+		Args:    cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			err := requireOutboxDir(cmd)
+			if err != nil {
+				return err
+			}
+			err = RequireGlobalFlags(global, []string{"ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOutboxFlush()
+		},
+	}
+	addDryRunFlag(cmd)
+
+	return cmd
+}
+
+func runOutboxFlush() error {
+	outbox := requests.NewOutbox(global.OutboxDir)
+	entries, err := outbox.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		logger.Info("the outbox is empty")
+		return nil
+	}
+
+	// replay with a client that does not queue failed requests again
+	client := *kosliClient
+	client.Outbox = nil
+
+	sent := 0
+	for _, entry := range entries {
+		reqParams := entry.RequestParams(global.ApiToken)
+		reqParams.DryRun = global.DryRun
+		_, err := client.Do(reqParams)
+		if err != nil {
+			logger.Info("[%d] of [%d] queued request(s) were sent to Kosli", sent, len(entries))
+			return fmt.Errorf("failed to send outbox entry %s (%s %s): %v", entry.ID, entry.Method, entry.URL, err)
+		}
+		if global.DryRun {
+			continue
+		}
+		err = outbox.Remove(entry.ID)
+		if err != nil {
+			return err
+		}
+		sent++
+	}
+
+	if !global.DryRun {
+		logger.Info("[%d] queued request(s) were sent to Kosli", sent)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/output"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+)
+
+const outboxListDesc = `List the requests queued in the outbox, oldest first.`
+
+const outboxListExample = `
+# list the requests queued in the outbox:
+kosli outbox list \
+	--outbox-dir /path/to/outbox
+`
+
+type outboxListOptions struct {
+	output string
+}
+
+func newOutboxListCmd(out io.Writer) *cobra.Command {
+	o := new(outboxListOptions)
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   outboxListDesc,
+		Long:    outboxListDesc,
+		Example: outboxListExample,
+		Args:    cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return requireOutboxDir(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(out)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "table", outputFlag)
+
+	return cmd
+}
+
+func (o *outboxListOptions) run(out io.Writer) error {
+	entries, err := requests.NewOutbox(global.OutboxDir).List()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return output.FormattedPrint(string(raw), o.output, out, 0,
+		map[string]output.FormatOutputFunc{
+			"table": printOutboxEntriesAsTable,
+			"json":  output.PrintJson,
+		})
+}
+
+func printOutboxEntriesAsTable(raw string, out io.Writer, page int) error {
+	var entries []*requests.OutboxEntry
+	err := json.Unmarshal([]byte(raw), &entries)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		logger.Info("No requests were found in the outbox.")
+		return nil
+	}
+
+	header := []string{"ID", "QUEUED AT", "METHOD", "URL", "ATTACHMENTS", "REASON"}
+	rows := []string{}
+	for _, entry := range entries {
+		attachments := 0
+		for _, item := range entry.Form {
+			if item.Type == "file" {
+				attachments++
+			}
+		}
+		queuedAt := time.Unix(entry.CreatedAt, 0).Format(time.RFC3339)
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%s", entry.ID, queuedAt, entry.Method, entry.URL, attachments, entry.Reason)
+		rows = append(rows, row)
+	}
+	tabFormattedPrint(out, header, rows)
+	return nil
+}
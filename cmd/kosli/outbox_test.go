@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type OutboxCommandTestSuite struct {
+	suite.Suite
+	outboxDir string
+	server    *httptest.Server
+	received  []string
+}
+
+func (suite *OutboxCommandTestSuite) SetupSuite() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "unauthorized"}`)
+			return
+		}
+		suite.received = append(suite.received, r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	}))
+}
+
+func (suite *OutboxCommandTestSuite) TearDownSuite() {
+	suite.server.Close()
+}
+
+func (suite *OutboxCommandTestSuite) SetupTest() {
+	suite.outboxDir = filepath.Join(suite.T().TempDir(), "outbox")
+	suite.received = []string{}
+	outbox := requests.NewOutbox(suite.outboxDir)
+	for _, path := range []string{"/first", "/second"} {
+		_, err := outbox.Add(&requests.RequestParams{
+			Method:  http.MethodPut,
+			URL:     suite.server.URL + path,
+			Payload: map[string]string{"name": path},
+		}, fmt.Errorf("host unreachable"))
+		require.NoError(suite.T(), err)
+	}
+}
+
+func (suite *OutboxCommandTestSuite) TestOutboxListCmd() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "outbox list fails when --outbox-dir is not set",
+			cmd:       "outbox list",
+			golden:    "Error: --outbox-dir is not set\nUsage: kosli outbox list [flags]\n",
+		},
+		{
+			name:        "outbox list shows queued requests",
+			cmd:         fmt.Sprintf("outbox list --outbox-dir %s", suite.outboxDir),
+			goldenRegex: "ID\\s+QUEUED AT\\s+METHOD\\s+URL\\s+ATTACHMENTS\\s+REASON\\n.*PUT.*/first\\s+0\\s+host unreachable\\n.*PUT.*/second",
+		},
+		{
+			name:   "outbox list on an empty outbox",
+			cmd:    fmt.Sprintf("outbox list --outbox-dir %s", filepath.Join(suite.T().TempDir(), "empty")),
+			golden: "No requests were found in the outbox.\n",
+		},
+		{
+			name:        "outbox list with json output",
+			cmd:         fmt.Sprintf("outbox list --outbox-dir %s --output json", suite.outboxDir),
+			goldenRegex: "\"method\": \"PUT\"",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+func (suite *OutboxCommandTestSuite) TestOutboxDropCmd() {
+	entries, err := requests.NewOutbox(suite.outboxDir).List()
+	require.NoError(suite.T(), err)
+
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "outbox drop fails without IDs or --all",
+			cmd:       fmt.Sprintf("outbox drop --outbox-dir %s", suite.outboxDir),
+			golden:    "Error: at least one request ID or --all is required\nUsage: kosli outbox drop [REQUEST-ID...] [flags]\n",
+		},
+		{
+			wantError: true,
+			name:      "outbox drop fails with IDs and --all",
+			cmd:       fmt.Sprintf("outbox drop %s --all --outbox-dir %s", entries[0].ID, suite.outboxDir),
+			golden:    "Error: request IDs cannot be provided together with --all\nUsage: kosli outbox drop [REQUEST-ID...] [flags]\n",
+		},
+		{
+			wantError: true,
+			name:      "outbox drop fails for a non-existing ID",
+			cmd:       fmt.Sprintf("outbox drop 123 --outbox-dir %s", suite.outboxDir),
+			golden:    "Error: outbox entry 123 does not exist\n",
+		},
+		{
+			name:   "outbox drop removes one request",
+			cmd:    fmt.Sprintf("outbox drop %s --outbox-dir %s", entries[0].ID, suite.outboxDir),
+			golden: "[1] request(s) were removed from the outbox\n",
+		},
+		{
+			name:   "outbox drop --all removes the remaining requests",
+			cmd:    fmt.Sprintf("outbox drop --all --outbox-dir %s", suite.outboxDir),
+			golden: "[1] request(s) were removed from the outbox\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+func (suite *OutboxCommandTestSuite) TestOutboxFlushCmd() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "outbox flush fails without an API token",
+			cmd:       fmt.Sprintf("outbox flush --outbox-dir %s", suite.outboxDir),
+			golden:    "Error: --api-token is not set\nUsage: kosli outbox flush [flags]\n",
+		},
+		{
+			wantError:   true,
+			name:        "outbox flush stops at the first failing request",
+			cmd:         fmt.Sprintf("outbox flush --outbox-dir %s --api-token wrong", suite.outboxDir),
+			goldenRegex: "\\[0\\] of \\[2\\] queued request\\(s\\) were sent to Kosli\\nError: failed to send outbox entry .* unauthorized",
+		},
+		{
+			name:   "outbox flush sends all requests in order",
+			cmd:    fmt.Sprintf("outbox flush --outbox-dir %s --api-token secret", suite.outboxDir),
+			golden: "[2] queued request(s) were sent to Kosli\n",
+		},
+		{
+			name:   "outbox flush on an empty outbox",
+			cmd:    fmt.Sprintf("outbox flush --outbox-dir %s --api-token secret", suite.outboxDir),
+			golden: "the outbox is empty\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+	require.Equal(suite.T(), []string{"/first", "/second"}, suite.received)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestOutboxCommandTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxCommandTestSuite))
+}
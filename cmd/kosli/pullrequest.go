@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"slices"
+	"strings"
 
 	azUtils "github.com/kosli-dev/cli/internal/azure"
 	bbUtils "github.com/kosli-dev/cli/internal/bitbucket"
+	giteaUtils "github.com/kosli-dev/cli/internal/gitea"
 	ghUtils "github.com/kosli-dev/cli/internal/github"
 	gitlabUtils "github.com/kosli-dev/cli/internal/gitlab"
 	"github.com/kosli-dev/cli/internal/requests"
@@ -152,12 +155,31 @@ func (o *attestPROptions) run(args []string) error {
 		logger.Info("%s %s attestation '%s' is reported to trail: %s", o.payload.GitProvider, label, o.payload.AttestationName, o.trailName)
 	}
 
-	if len(pullRequestsEvidence) == 0 && o.assert && !global.DryRun {
-		return fmt.Errorf("assert failed: no %s found for the given commit: %s", label, o.payload.Commit.Sha1)
+	if o.assert && !global.DryRun {
+		if len(pullRequestsEvidence) == 0 {
+			return fmt.Errorf("assert failed: no %s found for the given commit: %s", label, o.payload.Commit.Sha1)
+		}
+		if !slices.ContainsFunc(pullRequestsEvidence, (*types.PREvidence).FourEyes) {
+			return fmt.Errorf("assert failed: four-eyes principle is not satisfied by any %s for the given commit: %s. %s",
+				label, o.payload.Commit.Sha1, fourEyesFailures(pullRequestsEvidence))
+		}
 	}
 	return wrapAttestationError(err)
 }
 
+// fourEyesFailures describes why pull requests do not satisfy the four-eyes principle
+func fourEyesFailures(pullRequestsEvidence []*types.PREvidence) string {
+	failures := []string{}
+	for _, pr := range pullRequestsEvidence {
+		reason := "not approved by someone other than its author and last committer after its last commit"
+		if pr.SelfApproved {
+			reason = "self-approved and " + reason
+		}
+		failures = append(failures, fmt.Sprintf("%s is %s", pr.URL, reason))
+	}
+	return strings.Join(failures, ", ")
+}
+
 type pullRequestCommitOptions struct {
 	pullRequestOptions
 }
@@ -214,6 +236,58 @@ func (o *pullRequestCommitOptions) run(args []string) error {
 	return err
 }
 
+// validateBitbucketAuthFlags checks that a username and a password are given for Bitbucket, unless
+// an HTTP access token is given for Bitbucket Data Center
+func validateBitbucketAuthFlags(bbConfig *bbUtils.Config) error {
+	if bbConfig.AccessToken != "" {
+		if bbConfig.ServerURL == "" {
+			return fmt.Errorf("flag --bitbucket-access-token is only allowed when flag --bitbucket-server-url is set")
+		}
+		return nil
+	}
+	missingFlags := []string{}
+	if bbConfig.Password == "" {
+		missingFlags = append(missingFlags, `"bitbucket-password"`)
+	}
+	if bbConfig.Username == "" {
+		missingFlags = append(missingFlags, `"bitbucket-username"`)
+	}
+	if len(missingFlags) > 0 {
+		return fmt.Errorf("required flag(s) %s not set", strings.Join(missingFlags, ", "))
+	}
+	return nil
+}
+
+// newGithubConfig returns the Github config from the Github flags. It checks that either a Github token
+// or the ID and the private key of a Github App are given.
+func newGithubConfig(githubFlagsValues *ghUtils.GithubFlagsTempValueHolder) (*ghUtils.GithubConfig, error) {
+	authFlags := []string{"github-token", "github-app-id"}
+	if githubFlagsValues.Token != "" && githubFlagsValues.AppID != 0 {
+		return nil, fmt.Errorf("only one of %s is allowed", JoinFlagNames(authFlags))
+	}
+	if githubFlagsValues.AppID != 0 {
+		if githubFlagsValues.AppPrivateKey == "" {
+			return nil, fmt.Errorf("flag --github-app-private-key is required when flag --github-app-id is set")
+		}
+	} else {
+		if githubFlagsValues.AppPrivateKey != "" {
+			return nil, fmt.Errorf("flag --github-app-private-key is only allowed when flag --github-app-id is set")
+		}
+		if githubFlagsValues.AppInstallationID != 0 {
+			return nil, fmt.Errorf("flag --github-app-installation-id is only allowed when flag --github-app-id is set")
+		}
+		if githubFlagsValues.Token == "" {
+			return nil, fmt.Errorf("at least one of %s is required", JoinFlagNames(authFlags))
+		}
+	}
+	githubConfig := ghUtils.NewGithubConfig(githubFlagsValues.Token, githubFlagsValues.BaseURL,
+		githubFlagsValues.Org, githubFlagsValues.Repository)
+	githubConfig.AppID = githubFlagsValues.AppID
+	githubConfig.AppPrivateKey = githubFlagsValues.AppPrivateKey
+	githubConfig.AppInstallationID = githubFlagsValues.AppInstallationID
+	return githubConfig, nil
+}
+
 func getGitProviderAndLabel(retriever interface{}) (string, string) {
 	label := "pull request"
 	provider := ""
@@ -228,6 +302,8 @@ func getGitProviderAndLabel(retriever interface{}) (string, string) {
 		provider = "azure"
 	case reflect.TypeOf(&bbUtils.Config{}):
 		provider = "bitbucket"
+	case reflect.TypeOf(&giteaUtils.GiteaConfig{}):
+		provider = "gitea"
 	}
 	return provider, label
 }
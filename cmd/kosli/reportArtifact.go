@@ -109,7 +109,7 @@ func (o *reportArtifactOptions) run(args []string) error {
 	if o.name != "" {
 		o.payload.Filename = o.name
 	} else {
-		if o.fingerprintOptions.artifactType == "dir" || o.fingerprintOptions.artifactType == "file" {
+		if o.fingerprintOptions.artifactType == "dir" || o.fingerprintOptions.artifactType == "file" || o.fingerprintOptions.artifactType == "archive" {
 			o.payload.Filename = filepath.Base(args[0])
 		} else {
 			o.payload.Filename = args[0]
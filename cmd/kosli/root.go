@@ -44,8 +44,9 @@ const (
 The artifact fingerprint can be provided directly with the ^--fingerprint^ flag, or 
 calculated based on ^--artifact-type^ flag.
 
-Artifact type can be one of: "file" for files, "dir" for directories, "oci" for container
-images in registries or "docker" for local docker images.
+Artifact type can be one of: "file" for files, "dir" for directories, "archive" for the contents of
+tar, tar.gz and zip archives (e.g. jar, wheel), "oci" for container images in registries, "docker" for
+local docker images, "oci-dir" for OCI image layouts or "docker-archive" for tarballs created by ^docker save^.
 
 `
 
@@ -56,6 +57,9 @@ The attestation can be bound to a trail using the trail name.
 If the attestation is for an artifact, the attestation can be bound to the artifact using one of two ways:
 - using the artifact's SHA256 fingerprint which is calculated (based on the ^--artifact-type^ flag and the artifact name/path argument) or can be provided directly (with the ^--fingerprint^ flag).
 - using the artifact's name in the flow yaml template and the git commit from which the artifact is/will be created. Useful when reporting an attestation before creating/reporting the artifact.`
+	attestPRFourEyesDesc = `
+The author, the reviews and the last commit of each pull request are reported too, together with whether it was
+approved by its author or last committer (self-approved) and whether someone else approved it after its last commit.`
 	awsAuthDesc = `
 
 To authenticate to AWS, you can either:  
@@ -66,6 +70,16 @@ To authenticate to AWS, you can either:
 Option 1 takes highest precedence, while option 3 is the lowest.  
 More details can be found here: https://aws.github.io/aws-sdk-go-v2/docs/configuring-sdk/#specifying-credentials
 	`
+	githubAuthDesc = `
+
+To authenticate to Github, you can either:  
+  1) provide a Github token with ^--github-token^ (or by exporting KOSLI_GITHUB_TOKEN)  
+  2) authenticate as an installation of a Github App with ^--github-app-id^ and ^--github-app-private-key^.  
+  
+With option 2, a short-lived installation token is created for the repository and reused until the command ends.  
+The installation of the app on the repository is looked up unless ^--github-app-installation-id^ is given.  
+The app needs read access to the pull requests and the contents of the repository.
+`
 	azureAuthDesc = `
 
 To authenticate to Azure, you need to create Azure service principal with a secret  
@@ -74,6 +88,15 @@ The service principal needs to have the following permissions:
   1) Microsoft.Web/sites/Read  
   2) Microsoft.ContainerRegistry/registries/pull/read  
 
+	`
+	gcpAuthDesc = `
+
+To authenticate to Google Cloud, you can either:  
+  1) provide the path to a service account key or authorized user JSON file with ^--gcp-credentials-file^  
+  2) use Application Default Credentials (e.g. export GOOGLE_APPLICATION_CREDENTIALS, run ^gcloud auth application-default login^,  
+     or run on Google Cloud with an attached service account).  
+
+The credentials need the ^run.services.list^ and ^run.revisions.get^ permissions (e.g. the Cloud Run Viewer role) in the reported projects.
 	`
 	kosliIgnoreDesc = `To specify paths in a directory artifact that should always be excluded from the SHA256 calculation, you can add a ^.kosli_ignore^ file to the root of the artifact.
 Each line should specify a relative path or path glob to be ignored. You can include comments in this file, using ^#^.
@@ -88,9 +111,10 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	httpProxyFlag                        = "[optional] The HTTP proxy URL including protocol and port number. e.g. 'http://proxy-server-ip:proxy-port'"
 	dryRunFlag                           = "[optional] Run in dry-run mode. When enabled, no data is sent to Kosli and the CLI exits with 0 exit code regardless of any errors."
 	maxAPIRetryFlag                      = "[defaulted] How many times should API calls be retried when the API host is not reachable."
+	outboxDirFlag                        = "[optional] The directory of the offline outbox. When set, reporting requests that fail because the Kosli host is not reachable (or responds with a server error) are stored in the outbox and can be sent later with 'kosli outbox flush'."
 	configFileFlag                       = "[optional] The Kosli config file path."
 	debugFlag                            = "[optional] Print debug logs to stdout. A boolean flag https://docs.kosli.com/faq/#boolean-flags (default false)"
-	artifactTypeFlag                     = "The type of the artifact to calculate its SHA256 fingerprint. One of: [oci, docker, file, dir]. Only required if you want Kosli to calculate the fingerprint for you (i.e. when you don't specify '--fingerprint' on commands that allow it)."
+	artifactTypeFlag                     = "The type of the artifact to calculate its SHA256 fingerprint. One of: [oci, docker, file, dir, archive, oci-dir, docker-archive]. Only required if you want Kosli to calculate the fingerprint for you (i.e. when you don't specify '--fingerprint' on commands that allow it)."
 	flowNameFlag                         = "The Kosli flow name."
 	trailNameFlag                        = "The Kosli trail name."
 	trailNameFlagOptional                = "[optional] The Kosli trail name."
@@ -101,7 +125,7 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	approvalEnvironmentNameFlag          = "[defaulted] The environment the artifact is approved for. (defaults to all environments)"
 	pageNumberFlag                       = "[defaulted] The page number of a response."
 	pageLimitFlag                        = "[defaulted] The number of elements per page."
-	newEnvTypeFlag                       = "The type of environment. Valid types are: [K8S, ECS, server, S3, lambda, docker, azure-apps, logical]."
+	newEnvTypeFlag                       = "The type of environment. Valid types are: [K8S, ECS, server, S3, lambda, docker, azure-apps, cloudrun, EC2, logical]."
 	envAllowListFlag                     = "The environment name for which the artifact is allowlisted."
 	reasonFlag                           = "The reason why this artifact is allowlisted."
 	oldestCommitFlag                     = "[conditional] The source commit sha for the oldest change in the deployment. Can be any commit-ish. Only required if you don't specify '--environment'."
@@ -134,11 +158,14 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	evidenceCompliantFlag                = "[defaulted] Whether the evidence is compliant or not. A boolean flag https://docs.kosli.com/faq/#boolean-flags"
 	bbUsernameFlag                       = "Bitbucket username."
 	bbPasswordFlag                       = "Bitbucket App password. See https://developer.atlassian.com/cloud/bitbucket/rest/intro/#authentication for more details."
-	bbWorkspaceFlag                      = "Bitbucket workspace ID."
+	bbWorkspaceFlag                      = "Bitbucket workspace ID. For Bitbucket Data Center, the key of the project which the repository belongs to."
+	bbServerURLFlag                      = "[optional] Bitbucket Data Center (or Server) base URL, e.g. https://bitbucket.example.com (only needed for self-hosted Bitbucket installations)."
+	bbAccessTokenFlag                    = "[optional] Bitbucket Data Center HTTP access token. It can be used instead of --bitbucket-username and --bitbucket-password when --bitbucket-server-url is set."
 	commitPREvidenceFlag                 = "Git commit for which to find pull request evidence. (defaulted in some CIs: https://docs.kosli.com/ci-defaults )."
 	commitEvidenceFlag                   = "Git commit for which to verify a given evidence. (defaulted in some CIs: https://docs.kosli.com/ci-defaults )."
 	repositoryFlag                       = "Git repository. (defaulted in some CIs: https://docs.kosli.com/ci-defaults )."
 	assertPREvidenceFlag                 = "[optional] Exit with non-zero code if no pull requests found for the given commit."
+	attestPRAssertFlag                   = "[optional] Exit with non-zero code if no pull requests found for the given commit, or if none of them was approved after its last commit by someone other than its author and last committer (four-eyes principle)."
 	assertJiraEvidenceFlag               = "[optional] Exit with non-zero code if no jira issue reference found, or jira issue does not exist, for the given commit or branch."
 	assertStatusFlag                     = "[optional] Exit with non-zero code if Kosli server is not responding."
 	azureTokenFlag                       = "Azure Personal Access token."
@@ -153,12 +180,24 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	githubTokenFlag                      = "Github token."
 	githubOrgFlag                        = "Github organization. (defaulted if you are running in GitHub Actions: https://docs.kosli.com/ci-defaults )."
 	githubBaseURLFlag                    = "[optional] GitHub base URL (only needed for GitHub Enterprise installations)."
+	githubAppIDFlag                      = "[optional] Github App ID. Authenticates as an installation of the Github App instead of with --github-token. Requires --github-app-private-key."
+	githubAppPrivateKeyFlag              = "[conditional] Github App private key, either as a path to a PEM file or as the PEM contents. Only required if --github-app-id is set."
+	githubAppInstallationIDFlag          = "[optional] Github App installation ID. Defaults to the installation of the app on the repository."
+	giteaTokenFlag                       = "Gitea (or Forgejo) access token."
+	giteaOrgFlag                         = "Gitea (or Forgejo) repository owner, a user or an organization. (defaulted if you are running in Gitea Actions: https://docs.kosli.com/ci-defaults )."
+	giteaBaseURLFlag                     = "Gitea (or Forgejo) base URL, e.g. https://gitea.example.com (defaulted if you are running in Gitea Actions: https://docs.kosli.com/ci-defaults )."
 	gitlabTokenFlag                      = "Gitlab token."
 	gitlabOrgFlag                        = "Gitlab organization. (defaulted if you are running in Gitlab Pipelines: https://docs.kosli.com/ci-defaults )."
 	gitlabBaseURLFlag                    = "[optional] Gitlab base URL (only needed for on-prem Gitlab installations)."
 	registryProviderFlag                 = "[deprecated] The docker registry provider or url. Only required if you want to read docker image SHA256 digest from a remote docker registry."
-	registryUsernameFlag                 = "[conditional] The container registry username. Only required if you want to read container image SHA256 digest from a remote container registry."
-	registryPasswordFlag                 = "[conditional] The container registry password or access token. Only required if you want to read container image SHA256 digest from a remote container registry."
+	registryUsernameFlag                 = "[optional] The container registry username. Only required if you want to read container image SHA256 digest from a remote private container registry that you are not logged in to with docker."
+	registryPasswordFlag                 = "[optional] The container registry password or access token. Only required if you want to read container image SHA256 digest from a remote private container registry that you are not logged in to with docker."
+	registryAWSKeyIdFlag                 = "[optional] The AWS access key ID to read container images from a private ECR registry. Defaults to the AWS credentials of the environment."
+	registryAWSSecretKeyFlag             = "[optional] The AWS secret access key to read container images from a private ECR registry. Defaults to the AWS credentials of the environment."
+	registryAzureClientIdFlag            = "[optional] The Azure client ID to read container images from a private ACR registry. Defaults to the Azure credentials of the environment."
+	registryAzureClientSecretFlag        = "[optional] The Azure client secret to read container images from a private ACR registry. Defaults to the Azure credentials of the environment."
+	registryAzureTenantIdFlag            = "[optional] The Azure tenant ID to read container images from a private ACR registry."
+	registryGCPCredentialsFileFlag       = "[optional] The path to a GCP service account key or authorized user JSON file to read container images from a private GCR or Artifact Registry registry. Defaults to Application Default Credentials."
 	resultsDirFlag                       = "[defaulted] The path to a directory with JUnit test results. By default, the directory will be uploaded to Kosli's evidence vault."
 	snykJsonResultsFileFlag              = "The path to Snyk SARIF or JSON scan results file from 'snyk test' and 'snyk container test'. By default, the Snyk results will be uploaded to Kosli's evidence vault."
 	snykSarifResultsFileFlag             = "The path to Snyk scan SARIF results file from 'snyk test' and 'snyk container test'. By default, the Snyk results will be uploaded to Kosli's evidence vault."
@@ -168,7 +207,25 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	ecsExcludeClustersFlag               = "[optional] The comma-separated list of ECS cluster names to exclude. Can't be used together with --exclude or --exclude-regex."
 	ecsExcludeClustersRegexFlag          = "[optional] The comma-separated list of ECS cluster name regex patterns to exclude. Can't be used together with --clusters or --clusters-regex."
 	ecsServiceFlag                       = "[optional] The name of the ECS service."
+	ec2TagsFlag                          = "[optional] The comma-separated list of key=value tags the EC2 instances to snapshot must have."
+	ec2AutoScalingGroupsFlag             = "[optional] The comma-separated list of Auto Scaling group names to snapshot the EC2 instances of."
+	ec2FingerprintTagFlag                = "[defaulted] The AMI tag holding the SHA256 fingerprint of the AMI."
+	cloudRunServicesFlag                 = "[optional] The comma-separated list of Cloud Run service names to snapshot. Can't be used together with --exclude or --exclude-regex."
+	cloudRunServicesRegexFlag            = "[optional] The comma-separated list of Cloud Run service name regex patterns to snapshot. Can't be used together with --exclude or --exclude-regex."
+	cloudRunExcludeServicesFlag          = "[optional] The comma-separated list of Cloud Run service names to exclude. Can't be used together with --services or --services-regex."
+	cloudRunExcludeServicesRegexFlag     = "[optional] The comma-separated list of Cloud Run service name regex patterns to exclude. Can't be used together with --services or --services-regex."
+	cloudRunEndpointFlag                 = "[optional] The Cloud Run admin API endpoint to use for all requests. Defaults to the global and regional Cloud Run endpoints."
+	gcpProjectsFlag                      = "The comma-separated list of GCP project IDs to snapshot."
+	gcpRegionsFlag                       = "[optional] The comma-separated list of GCP regions to snapshot. Defaults to all regions."
+	gcpCredentialsFileFlag               = "[optional] The path to a GCP service account key or authorized user JSON file. Defaults to Application Default Credentials."
 	kubeconfigFlag                       = "[defaulted] The kubeconfig path for the target cluster."
+	k8sManifestsFlag                     = "[optional] The comma separated list of files or directories with exported or rendered Kubernetes resources (YAML or JSON) to report instead of a live cluster (--kubeconfig is then ignored). Use '-' to read from stdin."
+	snapshotWatchFlag                    = "[optional] Keep running and report a snapshot only when the running artifacts change."
+	snapshotWatchIntervalFlag            = "[defaulted] How often to check the environment for changes in --watch mode."
+	snapshotHealthAddressFlag            = "[optional] The address (e.g. :8080) to serve a /healthz endpoint on in --watch mode."
+	kubeconfigOperatorFlag               = "[optional] The kubeconfig path for the target cluster. Defaults to the in-cluster config."
+	operatorNamespaceFlag                = "[optional] The namespace to watch KosliEnvironment resources in. Defaults to all namespaces."
+	helmReleasesFlag                     = "[optional] Include the Helm release, chart, chart version and app version of each pod. Requires read permissions for secrets and workloads in the scanned namespaces."
 	namespacesFlag                       = "[optional] The comma separated list of namespaces names to report artifacts info from. Can't be used together with --exclude-namespaces or --exclude-namespaces-regex."
 	excludeNamespacesFlag                = "[optional] The comma separated list of namespaces names to exclude from reporting artifacts info from. Requires cluster-wide read permissions for pods and namespaces. Can't be used together with --namespaces or --namespaces-regex."
 	namespacesRegexFlag                  = "[optional] The comma separated list of namespaces regex patterns to report artifacts info from. Requires cluster-wide read permissions for pods and namespaces. Can't be used together with --exclude-namespaces --exclude-namespaces-regex."
@@ -186,7 +243,9 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	bucketPathsFlag                      = "[optional] The comma separated list of file and/or directory paths in the S3 bucket to include when fingerprinting. Cannot be used together with --exclude."
 	excludeBucketPathsFlag               = "[optional] The comma separated list of file and/or directory paths in the S3 bucket to exclude when fingerprinting. Cannot be used together with --include."
 	pathsFlag                            = "The comma separated list of absolute or relative paths of artifact directories or files. Can take glob patterns, but be aware that each matching path will be reported as an artifact."
-	excludePathsFlag                     = "[optional] The comma separated list of directories and files to exclude from fingerprinting. Can take glob patterns. Only applicable for --artifact-type dir."
+	platformFlag                         = "[optional] The comma separated list of platforms (e.g. linux/arm64) of a multi-platform image to report the fingerprints of. The fingerprint of each platform is the digest of the image manifest pulled on that platform. Only applicable for --artifact-type oci and docker."
+	allPlatformsFlag                     = "[optional] Report the fingerprints of all the platforms of a multi-platform image. Only applicable for --artifact-type oci and docker."
+	excludePathsFlag                     = "[optional] The comma separated list of directories and files to exclude from fingerprinting. Can take glob patterns. Only applicable for --artifact-type dir and archive."
 	serverExcludePathsFlag               = "[optional] The comma separated list of directories and files to exclude from fingerprinting. Can take glob patterns."
 	shortFlag                            = "[optional] Print only the Kosli CLI version number."
 	reverseFlag                          = "[defaulted] Reverse the order of output list."
@@ -208,6 +267,21 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	attestationCustomTypeNameFlag        = "The name of the custom attestation type."
 	attestationCustomDataFileFlag        = "The filepath of a json file containing the custom attestation data."
 	uploadJunitResultsFlag               = "[defaulted] Whether to upload the provided Junit results directory as an attachment to Kosli or not."
+	sarifResultsFilesFlag                = "The comma-separated list of paths of SARIF 2.1 scan results files. Can be repeated. By default, the results files will be uploaded to Kosli's evidence vault."
+	uploadSarifResultsFlag               = "[defaulted] Whether to upload the provided SARIF results files as attachments to Kosli or not."
+	sarifMaxSeverityFlag                 = "[optional] The maximum number of findings allowed per severity (critical, high, medium, low or info) in the format severity=count, e.g. high=0,medium=5. A maximum applies to the findings of that severity and all more severe ones."
+	signatureBundleFlag                  = "[optional] The comma-separated list of paths of sigstore bundle files with signatures or attestations of the artifact (e.g. from 'cosign sign-blob --bundle'). Can be repeated. If not set, the cosign signatures and attestations of the image are fetched from its registry."
+	signatureKeyFlag                     = "[conditional] The path to the PEM encoded public key verifying signatures made with a key pair (e.g. cosign.pub). Only required if --certificate-chain is not set."
+	signatureCertificateChainFlag        = "[conditional] The path to the PEM encoded certificate chain (e.g. the Fulcio root and intermediate certificates) verifying the certificates of keyless signatures. Only required if --key is not set."
+	signatureRekorPublicKeyFlag          = "[conditional] The path to the PEM encoded public key of the Rekor transparency log, verifying the log entries of signatures offline. Only required if --certificate-chain is set."
+	signatureCertificateIdentityFlag     = "[conditional] The expected identity (email or URI) of the signer in the certificates of keyless signatures. Only required if --certificate-chain is set."
+	signatureCertificateOIDCIssuerFlag   = "[conditional] The expected OIDC issuer in the certificates of keyless signatures, e.g. https://token.actions.githubusercontent.com. Only required if --certificate-chain is set."
+	provenanceFileFlag                   = "The path to a file with the SLSA provenance of the artifact: an in-toto statement, a DSSE envelope (e.g. an .intoto.jsonl file) or a sigstore bundle. By default, the provenance file will be uploaded to Kosli's evidence vault."
+	uploadProvenanceFlag                 = "[defaulted] Whether to upload the provided provenance file as an attachment to Kosli or not."
+	allowedBuilderIDsFlag                = "[optional] The comma-separated list of builder IDs allowed to build the artifact. A builder ID without a version allows all its versions (e.g. its @refs/tags/v2.0.0 version)."
+	sbomFileFlag                         = "The path to a CycloneDX or SPDX SBOM file in JSON format. By default, the SBOM file will be uploaded to Kosli's evidence vault."
+	uploadSBOMFlag                       = "[defaulted] Whether to upload the provided SBOM file as an attachment to Kosli or not."
+	searchComponentEnvironmentsFlag      = "[optional] The comma-separated list of environments to search. Defaults to all environments."
 	uploadSnykResultsFlag                = "[defaulted] Whether to upload the provided Snyk results file as an attachment to Kosli or not."
 	attestationAssertFlag                = "[optional] Exit with non-zero code if the attestation is non-compliant"
 	beginTrailCommitFlag                 = "[defaulted] The git commit from which the trail is begun. (defaulted in some CIs: https://docs.kosli.com/ci-defaults, otherwise defaults to HEAD )."
@@ -226,6 +300,9 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	pathsSpecFileFlag                    = "The path to a paths file in YAML/JSON/TOML format. Cannot be used together with --path ."
 	snapshotPathPathFlag                 = "The base path for the artifact to snapshot."
 	snapshotPathExcludeFlag              = "[optional] The comma-separated list of literal paths or glob patterns to exclude when fingerprinting the artifact."
+	fingerprintCacheFlag                 = "[optional] The path to a file where the fingerprints of files are cached between runs. Only files that changed (size, modification time or inode) since the previous run are hashed again. The file can be shared by several snapshots, and the fingerprints of files that were not fingerprinted for 30 days are dropped."
+	fingerprintExplainFlag               = "[optional] Output a JSON manifest of the paths included in and excluded from the fingerprint instead of the fingerprint. Only applicable for --artifact-type dir and archive."
+	diffFingerprintsExcludeFlag          = "[optional] The comma separated list of directories and files to exclude from fingerprinting DIR-PATH arguments. Can take glob patterns."
 	snapshotPathArtifactNameFlag         = "The reported name of the artifact."
 	policyDescriptionFlag                = "[optional] policy description."
 	policyCommentFlag                    = "[optional] comment about the change made in a policy file when updating a policy."
@@ -242,6 +319,10 @@ The ^.kosli_ignore^ will be treated as part of the artifact like any other file,
 	attestationTypeDescriptionFlag       = "[optional] The attestation type description."
 	attestationTypeSchemaFlag            = "[optional] Path to the attestation type schema in JSON Schema format."
 	attestationTypeJqFlag                = "[optional] The attestation type evaluation JQ rules."
+	outboxDropAllFlag                    = "[optional] Remove all requests from the outbox."
+	assertPolicyFileFlag                 = "[optional] The path to a policy file (in YAML, JSON or TOML) with jq rules to evaluate locally."
+	assertPolicyJqFlag                   = "[optional] A jq rule to evaluate locally. The rule must return true for the assertion to pass. Can be repeated."
+	batchMaxParallelFlag                 = "[defaulted] The maximum number of batch steps to run at the same time."
 )
 
 var global *GlobalOpts
@@ -255,6 +336,7 @@ type GlobalOpts struct {
 	MaxAPIRetries int
 	ConfigFile    string
 	Debug         bool
+	OutboxDir     string
 }
 
 // ConfigGetter defines an interface for getting the default config file path
@@ -311,23 +393,7 @@ func newRootCmd(out io.Writer, args []string) (*cobra.Command, error) {
 				global.DryRun = true
 			}
 
-			// If the user types "--description $variable --sha256 ..." and $variable is "" then Cobra
-			// will assign --sha256 as the value of --description, and give a very misleading error message.
-			// So we do some extra checking to tell the user about this.
-			var flagError error = nil
-			cmd.Flags().VisitAll(func(f *pflag.Flag) {
-				if strings.HasPrefix(f.Value.String(), "-") {
-					flagError = fmt.Errorf("flag '--%s' has value '%s' which is illegal", f.Name, f.Value.String())
-				}
-
-				if _, ok := f.Annotations[cobra.BashCompOneRequiredFlag]; ok {
-					if f.Changed && f.Value.String() == "" {
-						flagError = fmt.Errorf("flag '--%s' is required, but empty string was provided", f.Name)
-					}
-				}
-			})
-
-			return flagError
+			return validateFlagValues(cmd)
 		},
 	}
 	cmd.PersistentFlags().StringVarP(&global.ApiToken, "api-token", "a", "", apiTokenFlag)
@@ -337,10 +403,21 @@ func newRootCmd(out io.Writer, args []string) (*cobra.Command, error) {
 	cmd.PersistentFlags().IntVarP(&global.MaxAPIRetries, "max-api-retries", "r", defaultMaxAPIRetries, maxAPIRetryFlag)
 	cmd.PersistentFlags().StringVarP(&global.ConfigFile, "config-file", "c", getConfigFileFlagDefault(), configFileFlag)
 	cmd.PersistentFlags().BoolVar(&global.Debug, "debug", false, debugFlag)
+	cmd.PersistentFlags().StringVar(&global.OutboxDir, "outbox-dir", "", outboxDirFlag)
 
 	// Add subcommands
-	cmd.AddCommand(
+	cmd.AddCommand(newSubcommands(out)...)
+
+	cobra.AddTemplateFunc("isBeta", isBeta)
+	cobra.AddTemplateFunc("isDeprecated", isDeprecated)
+	cmd.SetUsageTemplate(usageTemplate)
+
+	return cmd, nil
+}
 
+// newSubcommands returns the top level kosli subcommands
+func newSubcommands(out io.Writer) []*cobra.Command {
+	return []*cobra.Command{
 		newVersionCmd(out),
 		newFingerprintCmd(out),
 		newAssertCmd(out),
@@ -372,17 +449,12 @@ func newRootCmd(out io.Writer, args []string) (*cobra.Command, error) {
 		newConfigCmd(out),
 		newAttachPolicyCmd(out),
 		newDetachPolicyCmd(out),
-	)
-
-	cobra.AddTemplateFunc("isBeta", isBeta)
-	cobra.AddTemplateFunc("isDeprecated", isDeprecated)
-	cmd.SetUsageTemplate(usageTemplate)
-
-	return cmd, nil
+		newOutboxCmd(out),
+		newBatchCmd(out),
+	}
 }
 
 func initialize(cmd *cobra.Command, out io.Writer) error {
-	v := viper.New()
 	logger.SetInfoOut(out) // needed to allow tests to overwrite the logger output stream
 	// assign debug value early here to enable debug logs during config file and env var binding
 	// if --debug is used. The value is re-assigned later after binding config file and env vars
@@ -399,6 +471,33 @@ func initialize(cmd *cobra.Command, out io.Writer) error {
 			global.ConfigFile = path
 		}
 	}
+	v, err := newConfigViper()
+	if err != nil {
+		return err
+	}
+
+	// Bind the current command's flags to viper
+	bindFlags(cmd, v)
+
+	// re-assign debug after binding flags to config or env vars as it may have
+	// a different value now
+	logger.DebugEnabled = global.Debug
+
+	kosliClient, err = requests.NewKosliClient(global.HttpProxy, global.MaxAPIRetries, global.Debug, logger)
+	if err != nil {
+		return err
+	}
+	if global.OutboxDir != "" {
+		kosliClient.Outbox = requests.NewOutbox(global.OutboxDir)
+	}
+
+	return nil
+}
+
+// newConfigViper returns a viper instance loaded with the config file (if it exists)
+// and bound to the KOSLI_ prefixed environment variables
+func newConfigViper() (*viper.Viper, error) {
+	v := viper.New()
 	dir, file := filepath.Split(global.ConfigFile)
 	file = strings.TrimSuffix(file, filepath.Ext(file))
 
@@ -419,7 +518,7 @@ func initialize(cmd *cobra.Command, out io.Writer) error {
 	if err := v.ReadInConfig(); err != nil {
 		// It's okay if there isn't a config file
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return fmt.Errorf("failed to parse config file [%s] : %v", global.ConfigFile, err)
+			return nil, fmt.Errorf("failed to parse config file [%s] : %v", global.ConfigFile, err)
 		} else {
 			logger.Debug("config file [%s] not found. Skipping.", global.ConfigFile)
 		}
@@ -435,24 +534,32 @@ func initialize(cmd *cobra.Command, out io.Writer) error {
 	// like --kube-config which we fix in the bindFlags function
 	v.AutomaticEnv()
 
-	// Bind the current command's flags to viper
-	bindFlags(cmd, v)
+	return v, nil
+}
 
-	// re-assign debug after binding flags to config or env vars as it may have
-	// a different value now
-	logger.DebugEnabled = global.Debug
+// Bind each cobra flag to its associated viper configuration
+// (coming either from environment variables or config file)
+// validateFlagValues returns an error if a flag value starts with '-' or a required flag is set to an empty string.
+// If the user types "--description $variable --sha256 ..." and $variable is "" then Cobra
+// will assign --sha256 as the value of --description, and give a very misleading error message.
+// So we do some extra checking to tell the user about this.
+func validateFlagValues(cmd *cobra.Command) error {
+	var flagError error = nil
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if strings.HasPrefix(f.Value.String(), "-") {
+			flagError = fmt.Errorf("flag '--%s' has value '%s' which is illegal", f.Name, f.Value.String())
+		}
 
-	var err error
-	kosliClient, err = requests.NewKosliClient(global.HttpProxy, global.MaxAPIRetries, global.Debug, logger)
-	if err != nil {
-		return err
-	}
+		if _, ok := f.Annotations[cobra.BashCompOneRequiredFlag]; ok {
+			if f.Changed && f.Value.String() == "" {
+				flagError = fmt.Errorf("flag '--%s' is required, but empty string was provided", f.Name)
+			}
+		}
+	})
 
-	return nil
+	return flagError
 }
 
-// Bind each cobra flag to its associated viper configuration
-// (coming either from environment variables or config file)
 func bindFlags(cmd *cobra.Command, v *viper.Viper) {
 	// for some reason, logger does not print errors at the point
 	// of calling this function, so we ensure to point errors to stderr
@@ -80,6 +80,8 @@ func newSearchCmd(out io.Writer) *cobra.Command {
 
 	cmd.Flags().StringVarP(&o.output, "output", "o", "table", outputFlag)
 
+	cmd.AddCommand(newSearchComponentCmd(out))
+
 	return cmd
 }
 
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/kosli-dev/cli/internal/output"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/sbom"
+	"github.com/spf13/cobra"
+)
+
+type searchComponentOptions struct {
+	output       string
+	environments []string
+}
+
+type ComponentMatch struct {
+	Environment   string `json:"environment"`
+	SnapshotIndex int    `json:"snapshot_index"`
+	Artifact      string `json:"artifact"`
+	Fingerprint   string `json:"fingerprint"`
+	Flow          string `json:"flow"`
+	Attestation   string `json:"attestation"`
+	Component     string `json:"component"`
+	Version       string `json:"version"`
+	PURL          string `json:"purl,omitempty"`
+}
+
+type sbomAttestation struct {
+	AttestationName string
+	SBOM            *sbom.SBOMData
+}
+
+const searchComponentShortDesc = `Search for the running artifacts which contain a component.  `
+
+const searchComponentLongDesc = searchComponentShortDesc + `
+The component can be a package URL (e.g. **pkg:npm/lodash@4.17.21**) or a component name
+(e.g. **lodash@4.17.21**). Without a version, all versions of the component match.
+
+The latest snapshot of each environment is searched. The components of each running artifact
+are the components of its SBOM attestations (see ^kosli attest sbom^).
+Running artifacts without SBOM attestations are not searched.`
+
+const searchComponentExample = `
+# search for the running artifacts which contain any version of a component:
+kosli search component pkg:golang/golang.org/x/net \
+	--api-token yourApiToken \
+	--org yourOrgName
+
+# search for the artifacts running in the prod environment which contain a version of a component:
+kosli search component log4j-core@2.14.1 \
+	--environments prod \
+	--api-token yourApiToken \
+	--org yourOrgName
+`
+
+func newSearchComponentCmd(out io.Writer) *cobra.Command {
+	o := new(searchComponentOptions)
+	cmd := &cobra.Command{
+		Use:     "component {PURL | NAME[@VERSION]}",
+		Short:   searchComponentShortDesc,
+		Long:    searchComponentLongDesc,
+		Example: searchComponentExample,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			err := RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(out, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "table", outputFlag)
+	cmd.Flags().StringSliceVar(&o.environments, "environments", []string{}, searchComponentEnvironmentsFlag)
+
+	return cmd
+}
+
+func (o *searchComponentOptions) run(out io.Writer, args []string) error {
+	query, err := sbom.ParseComponentQuery(args[0])
+	if err != nil {
+		return err
+	}
+
+	environments := o.environments
+	if len(environments) == 0 {
+		environments, err = listEnvironmentNames()
+		if err != nil {
+			return err
+		}
+	}
+
+	matches := []ComponentMatch{}
+	// the SBOM attestations of artifacts running in several environments are only fetched once
+	attestations := map[string][]sbomAttestation{}
+	for _, envName := range environments {
+		snapshot, err := getLatestSnapshot(envName)
+		if err != nil {
+			return err
+		}
+		for _, artifact := range snapshot.Artifacts {
+			if artifact.Annotation.Now == 0 || artifact.FlowName == "" {
+				continue
+			}
+			key := artifact.FlowName + "@" + artifact.Fingerprint
+			if _, ok := attestations[key]; !ok {
+				attestations[key], err = getSBOMAttestations(artifact.FlowName, artifact.Fingerprint)
+				if err != nil {
+					return err
+				}
+			}
+			for _, attestation := range attestations[key] {
+				for _, component := range attestation.SBOM.Components {
+					if !query.Matches(component) {
+						continue
+					}
+					match := ComponentMatch{
+						Environment:   envName,
+						SnapshotIndex: snapshot.Index,
+						Artifact:      artifact.Name,
+						Fingerprint:   artifact.Fingerprint,
+						Flow:          artifact.FlowName,
+						Attestation:   attestation.AttestationName,
+						Component:     component.Name,
+						Version:       component.Version,
+						PURL:          component.PURL,
+					}
+					if !slices.Contains(matches, match) {
+						matches = append(matches, match)
+					}
+				}
+			}
+		}
+	}
+
+	raw, err := json.Marshal(matches)
+	if err != nil {
+		return err
+	}
+	return output.FormattedPrint(string(raw), o.output, out, 0,
+		map[string]output.FormatOutputFunc{
+			"table": printComponentMatchesAsTable,
+			"json":  output.PrintJson,
+		})
+}
+
+// listEnvironmentNames returns the names of all environments of the org
+func listEnvironmentNames() ([]string, error) {
+	reqParams := &requests.RequestParams{
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("%s/api/v2/environments/%s", global.Host, global.Org),
+		Token:  global.ApiToken,
+	}
+	response, err := kosliClient.Do(reqParams)
+	if err != nil {
+		return nil, err
+	}
+	var envs []struct {
+		Name string `json:"name"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &envs)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+	return names, nil
+}
+
+// getLatestSnapshot returns the latest snapshot of an environment
+func getLatestSnapshot(envName string) (*Snapshot, error) {
+	reqParams := &requests.RequestParams{
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("%s/api/v2/snapshots/%s/%s/-1", global.Host, global.Org, envName),
+		Token:  global.ApiToken,
+	}
+	response, err := kosliClient.Do(reqParams)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	err = json.Unmarshal([]byte(response.Body), &snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// getSBOMAttestations returns the SBOM attestations of an artifact, from the artifact
+// as returned by the API of kosli get artifact
+func getSBOMAttestations(flowName, fingerprint string) ([]sbomAttestation, error) {
+	reqParams := &requests.RequestParams{
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("%s/api/v2/artifacts/%s/%s/fingerprint/%s", global.Host, global.Org, url.PathEscape(flowName), fingerprint),
+		Token:  global.ApiToken,
+	}
+	response, err := kosliClient.Do(reqParams)
+	if err != nil {
+		return nil, err
+	}
+	var artifact struct {
+		AttestationsStatuses []struct {
+			AttestationName string          `json:"attestation_name"`
+			AttestationType string          `json:"attestation_type"`
+			AttestationData json.RawMessage `json:"attestation_data"`
+		} `json:"attestations_statuses"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &artifact)
+	if err != nil {
+		return nil, err
+	}
+	attestations := []sbomAttestation{}
+	for _, status := range artifact.AttestationsStatuses {
+		// attestations without SBOM data have no components to search
+		if status.AttestationType != "sbom" || len(status.AttestationData) == 0 {
+			continue
+		}
+		attestation := sbomAttestation{AttestationName: status.AttestationName}
+		err = json.Unmarshal(status.AttestationData, &attestation.SBOM)
+		if err != nil {
+			return nil, err
+		}
+		if attestation.SBOM != nil {
+			attestations = append(attestations, attestation)
+		}
+	}
+	return attestations, nil
+}
+
+func printComponentMatchesAsTable(raw string, out io.Writer, page int) error {
+	var matches []ComponentMatch
+	err := json.Unmarshal([]byte(raw), &matches)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		logger.Info("No running artifacts contain the component")
+		return nil
+	}
+
+	header := []string{"ENVIRONMENT", "ARTIFACT", "FLOW", "COMPONENT", "VERSION"}
+	rows := []string{}
+	for _, match := range matches {
+		rows = append(rows, fmt.Sprintf("%s#%d\tName: %s\t%s\t%s\t%s", match.Environment, match.SnapshotIndex, match.Artifact, match.Flow, match.Component, match.Version))
+		rows = append(rows, fmt.Sprintf("\tFingerprint: %s\t\t\t", match.Fingerprint))
+		rows = append(rows, "\t\t\t\t")
+	}
+	tabFormattedPrint(out, header, rows)
+	return nil
+}
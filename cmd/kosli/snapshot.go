@@ -19,11 +19,14 @@ func newSnapshotCmd(out io.Writer) *cobra.Command {
 	cmd.AddCommand(
 		newSnapshotDockerCmd(out),
 		newSnapshotECSCmd(out),
+		newSnapshotEC2Cmd(out),
 		newSnapshotK8SCmd(out),
+		newSnapshotK8SOperatorCmd(out),
 		newSnapshotServerCmd(out),
 		newSnapshotLambdaCmd(out),
 		newSnapshotS3Cmd(out),
 		newSnapshotAzureAppsCmd(out),
+		newSnapshotCloudRunCmd(out),
 		newSnapshotPathsCmd(out),
 		newSnapshotPathCmd(out),
 	)
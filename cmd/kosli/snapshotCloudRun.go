@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	"github.com/kosli-dev/cli/internal/gcp"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+)
+
+const snapshotCloudRunShortDesc = `Report a snapshot of running Google Cloud Run services in one or more GCP project(s) to Kosli.  `
+
+const snapshotCloudRunLongDesc = snapshotCloudRunShortDesc + `
+Every revision serving traffic is reported, so a service splitting traffic between revisions reports all of them.
+Skip ^--services^ and ^--services-regex^ to report all services in the given projects. Or use ^--exclude^ and/or ^--exclude-regex^ to report all services excluding some.
+Use ^--gcp-regions^ to only report services in some regions.
+The reported data includes container image digests and the time each revision started serving.
+
+GKE clusters, including GKE Autopilot clusters, are reported with ^kosli snapshot k8s^.` + snapshotWatchDesc + gcpAuthDesc
+
+const snapshotCloudRunExample = `
+# report what is running in all Cloud Run services of a GCP project (using Application Default Credentials):
+kosli snapshot cloudrun yourEnvironmentName \
+	--gcp-projects yourGCPProjectID \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report what is running in some Cloud Run services of two GCP projects in one region:
+kosli snapshot cloudrun yourEnvironmentName \
+	--gcp-projects yourGCPProjectID,yourOtherGCPProjectID \
+	--gcp-regions europe-west1 \
+	--services yourServiceName \
+	--services-regex "api-.*" \
+	--gcp-credentials-file yourServiceAccountKey.json \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report what is running in all Cloud Run services of a GCP project except for services with names matching given regex patterns:
+kosli snapshot cloudrun yourEnvironmentName \
+	--gcp-projects yourGCPProjectID \
+	--exclude-regex "those-names.*" \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+type snapshotCloudRunOptions struct {
+	filter         *filters.ResourceFilterOptions
+	gcpStaticCreds *gcp.GCPStaticCredentials
+	watch          *snapshotWatchOptions
+}
+
+func newSnapshotCloudRunCmd(out io.Writer) *cobra.Command {
+	o := new(snapshotCloudRunOptions)
+	o.filter = new(filters.ResourceFilterOptions)
+	o.gcpStaticCreds = new(gcp.GCPStaticCredentials)
+	o.watch = new(snapshotWatchOptions)
+	cmd := &cobra.Command{
+		Use:     "cloudrun ENVIRONMENT-NAME",
+		Short:   snapshotCloudRunShortDesc,
+		Long:    snapshotCloudRunLongDesc,
+		Example: snapshotCloudRunExample,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			err := RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+
+			err = MuXRequiredFlags(cmd, []string{"services", "exclude"}, false)
+			if err != nil {
+				return err
+			}
+			err = MuXRequiredFlags(cmd, []string{"services-regex", "exclude"}, false)
+			if err != nil {
+				return err
+			}
+			err = MuXRequiredFlags(cmd, []string{"services", "exclude-regex"}, false)
+			if err != nil {
+				return err
+			}
+			err = MuXRequiredFlags(cmd, []string{"services-regex", "exclude-regex"}, false)
+			if err != nil {
+				return err
+			}
+			return validateSnapshotWatchFlags(cmd, o.watch)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&o.filter.IncludeNames, "services", []string{}, cloudRunServicesFlag)
+	cmd.Flags().StringSliceVar(&o.filter.IncludeNamesRegex, "services-regex", []string{}, cloudRunServicesRegexFlag)
+	cmd.Flags().StringSliceVar(&o.filter.ExcludeNames, "exclude", []string{}, cloudRunExcludeServicesFlag)
+	cmd.Flags().StringSliceVar(&o.filter.ExcludeNamesRegex, "exclude-regex", []string{}, cloudRunExcludeServicesRegexFlag)
+	addGCPFlags(cmd, o.gcpStaticCreds)
+	addSnapshotWatchFlags(cmd, o.watch)
+	addDryRunFlag(cmd)
+
+	err := RequireFlags(cmd, []string{"gcp-projects"})
+	if err != nil {
+		logger.Error("failed to configure required flags: %v", err)
+	}
+
+	return cmd
+}
+
+func (o *snapshotCloudRunOptions) run(args []string) error {
+	envName := args[0]
+	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/cloudrun", global.Host, global.Org, envName)
+
+	return o.watch.run(envName, snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			revisionsData, err := o.gcpStaticCreds.GetCloudRunData(o.filter, logger)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &gcp.CloudRunEnvRequest{Artifacts: revisionsData}, revisionsData, nil
+		},
+		report: func(payload interface{}) error {
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   global.ApiToken,
+			}
+			_, err := kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("[%d] cloud run revisions were reported to environment %s", len(payload.(*gcp.CloudRunEnvRequest).Artifacts), envName)
+			}
+			return err
+		},
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type SnapshotCloudRunTestSuite struct {
+	suite.Suite
+	defaultKosliArguments string
+	envName               string
+	kosliServer           *httptest.Server
+	cloudRunServer        *httptest.Server
+	credentialsFile       string
+	reportedURL           string
+	reportedPayload       string
+}
+
+func (suite *SnapshotCloudRunTestSuite) SetupTest() {
+	suite.envName = "snapshot-cloudrun-env"
+	suite.reportedURL = ""
+	suite.reportedPayload = ""
+	digest := strings.Repeat("a", 64)
+
+	// a fake of the Cloud Run admin API and its token endpoint
+	suite.cloudRunServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/token":
+			fmt.Fprint(w, `{"access_token": "gcp-token", "token_type": "Bearer", "expires_in": 3600}`)
+		case r.Header.Get("Authorization") != "Bearer gcp-token":
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": {"code": 401, "message": "invalid credentials"}}`)
+		case r.URL.Path == "/apis/serving.knative.dev/v1/namespaces/kosli-test/services":
+			fmt.Fprint(w, `{"metadata": {}, "items": [
+				{"metadata": {"name": "web", "labels": {"cloud.googleapis.com/location": "europe-west1"}},
+				 "status": {"traffic": [{"revisionName": "web-00001", "percent": 100}]}}
+			]}`)
+		case r.URL.Path == "/apis/serving.knative.dev/v1/namespaces/kosli-test/revisions/web-00001":
+			fmt.Fprintf(w, `{
+				"metadata": {"name": "web-00001", "creationTimestamp": "2024-10-01T10:00:00Z"},
+				"spec": {"containers": [{"name": "web", "image": "europe-docker.pkg.dev/kosli-test/apps/web:1.0"}]},
+				"status": {"imageDigest": "europe-docker.pkg.dev/kosli-test/apps/web@sha256:%s"}
+			}`, digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "not found"}}`)
+		}
+	}))
+
+	suite.credentialsFile = filepath.Join(suite.T().TempDir(), "credentials.json")
+	err := os.WriteFile(suite.credentialsFile, []byte(fmt.Sprintf(`{
+		"type": "authorized_user",
+		"client_id": "id",
+		"client_secret": "secret",
+		"refresh_token": "refresh",
+		"token_uri": "%s/token"
+	}`, suite.cloudRunServer.URL)), 0600)
+	require.NoError(suite.T(), err)
+
+	suite.kosliServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		suite.reportedURL = fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+		suite.reportedPayload = string(body)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	}))
+
+	global = &GlobalOpts{
+		ApiToken: "secret",
+		Org:      "docs-cmd-test-user",
+		Host:     suite.kosliServer.URL,
+	}
+	suite.defaultKosliArguments = fmt.Sprintf(" --host %s --org %s --api-token %s", global.Host, global.Org, global.ApiToken)
+}
+
+func (suite *SnapshotCloudRunTestSuite) TearDownTest() {
+	suite.kosliServer.Close()
+	suite.cloudRunServer.Close()
+}
+
+func (suite *SnapshotCloudRunTestSuite) TestSnapshotCloudRunCmd() {
+	gcpArguments := fmt.Sprintf(" --gcp-projects kosli-test --gcp-credentials-file %s --cloudrun-endpoint %s", suite.credentialsFile, suite.cloudRunServer.URL)
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "snapshot cloudrun fails if 2 args are provided",
+			cmd:       fmt.Sprintf(`snapshot cloudrun %s xxx %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden:    "Error: accepts 1 arg(s), received 2\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot cloudrun fails if no args are set",
+			cmd:       fmt.Sprintf(`snapshot cloudrun %s %s`, gcpArguments, suite.defaultKosliArguments),
+			golden:    "Error: accepts 1 arg(s), received 0\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot cloudrun fails if --gcp-projects is missing",
+			cmd:       fmt.Sprintf(`snapshot cloudrun %s %s`, suite.envName, suite.defaultKosliArguments),
+			golden:    "Error: required flag(s) \"gcp-projects\" not set\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot cloudrun fails if --services and --exclude are set",
+			cmd:       fmt.Sprintf(`snapshot cloudrun %s --services web --exclude api %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden:    "Error: only one of --services, --exclude is allowed\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot cloudrun fails if --services-regex and --exclude-regex are set",
+			cmd:       fmt.Sprintf(`snapshot cloudrun %s --services-regex web --exclude-regex api %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden:    "Error: only one of --services-regex, --exclude-regex is allowed\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot cloudrun fails if --watch-interval is set without --watch",
+			cmd:       fmt.Sprintf(`snapshot cloudrun %s --watch-interval 10s %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden:    "Error: --watch-interval is only allowed with --watch\n",
+		},
+		{
+			wantError:   true,
+			name:        "snapshot cloudrun fails if the project does not exist",
+			cmd:         fmt.Sprintf(`snapshot cloudrun %s %s --gcp-projects unknown %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			goldenRegex: "Error: failed to list Cloud Run services in project unknown: 404 Not Found.*\n",
+		},
+		{
+			name:   "snapshot cloudrun reports the revisions serving traffic",
+			cmd:    fmt.Sprintf(`snapshot cloudrun %s %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden: fmt.Sprintf("[1] cloud run revisions were reported to environment %s\n", suite.envName),
+		},
+		{
+			name:   "snapshot cloudrun reports no revisions when all services are excluded",
+			cmd:    fmt.Sprintf(`snapshot cloudrun %s --exclude web %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden: fmt.Sprintf("[0] cloud run revisions were reported to environment %s\n", suite.envName),
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+func (suite *SnapshotCloudRunTestSuite) TestSnapshotCloudRunCmdPayload() {
+	gcpArguments := fmt.Sprintf(" --gcp-projects kosli-test --gcp-credentials-file %s --cloudrun-endpoint %s", suite.credentialsFile, suite.cloudRunServer.URL)
+	runTestCmd(suite.T(), []cmdTestCase{
+		{
+			name:   "snapshot cloudrun reports revisions in the given region",
+			cmd:    fmt.Sprintf(`snapshot cloudrun %s --gcp-regions europe-west1 %s %s`, suite.envName, gcpArguments, suite.defaultKosliArguments),
+			golden: fmt.Sprintf("[1] cloud run revisions were reported to environment %s\n", suite.envName),
+		},
+	})
+
+	require.Equal(suite.T(), fmt.Sprintf("PUT /api/v2/environments/docs-cmd-test-user/%s/report/cloudrun", suite.envName), suite.reportedURL)
+	require.JSONEq(suite.T(), fmt.Sprintf(`{"artifacts": [{
+		"project": "kosli-test",
+		"region": "europe-west1",
+		"service": "web",
+		"revision": "web-00001",
+		"digests": {"europe-docker.pkg.dev/kosli-test/apps/web:1.0": "%s"},
+		"creationTimestamp": 1727776800
+	}]}`, strings.Repeat("a", 64)), suite.reportedPayload)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSnapshotCloudRunTestSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotCloudRunTestSuite))
+}
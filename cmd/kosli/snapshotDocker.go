@@ -21,18 +21,28 @@ const snapshotDockerShortDesc = `Report a snapshot of running containers from do
 const snapshotDockerLongDesc = snapshotDockerShortDesc + `
 The reported data includes container image digests 
 and creation timestamps. Containers running images which have not
-been pushed to or pulled from a registry will be ignored.`
+been pushed to or pulled from a registry will be ignored.` + snapshotWatchDesc
 
 const snapshotDockerExample = `
 # report what is running in a docker host:
 kosli snapshot docker yourEnvironmentName \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# keep checking what is running in a docker host every 30 seconds and report it when it changes:
+kosli snapshot docker yourEnvironmentName \
+	--watch \
+	--watch-interval 30s \
 	--api-token yourAPIToken \
 	--org yourOrgName`
 
-type snapshotDockerOptions struct{}
+type snapshotDockerOptions struct {
+	watch *snapshotWatchOptions
+}
 
 func newSnapshotDockerCmd(out io.Writer) *cobra.Command {
 	o := new(snapshotDockerOptions)
+	o.watch = new(snapshotWatchOptions)
 	cmd := &cobra.Command{
 		Use:     "docker ENVIRONMENT-NAME",
 		Short:   snapshotDockerShortDesc,
@@ -45,42 +55,44 @@ func newSnapshotDockerCmd(out io.Writer) *cobra.Command {
 				return ErrorBeforePrintingUsage(cmd, err.Error())
 			}
 
-			return nil
+			return validateSnapshotWatchFlags(cmd, o.watch)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return o.run(args)
 		},
 	}
+	addSnapshotWatchFlags(cmd, o.watch)
 	addDryRunFlag(cmd)
 	return cmd
 }
 
 func (o *snapshotDockerOptions) run(args []string) error {
 	envName := args[0]
-
 	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/docker", global.Host, global.Org, envName)
 
-	artifacts, err := CreateDockerArtifactsData()
-	if err != nil {
-		return err
-	}
-
-	payload := &server.ServerEnvRequest{
-		Artifacts: artifacts,
-	}
-
-	reqParams := &requests.RequestParams{
-		Method:  http.MethodPut,
-		URL:     url,
-		Payload: payload,
-		DryRun:  global.DryRun,
-		Token:   global.ApiToken,
-	}
-	_, err = kosliClient.Do(reqParams)
-	if err == nil && !global.DryRun {
-		logger.Info("[%d] containers were reported to environment %s", len(payload.Artifacts), envName)
-	}
-	return err
+	return o.watch.run(envName, snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			artifacts, err := CreateDockerArtifactsData()
+			if err != nil {
+				return nil, nil, err
+			}
+			return &server.ServerEnvRequest{Artifacts: artifacts}, artifacts, nil
+		},
+		report: func(payload interface{}) error {
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   global.ApiToken,
+			}
+			_, err := kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("[%d] containers were reported to environment %s", len(payload.(*server.ServerEnvRequest).Artifacts), envName)
+			}
+			return err
+		},
+	})
 }
 
 func CreateDockerArtifactsData() ([]*server.ServerData, error) {
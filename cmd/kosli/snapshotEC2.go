@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/kosli-dev/cli/internal/aws"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+)
+
+const snapshotEC2ShortDesc = `Report a snapshot of the AMIs of running AWS EC2 instances to Kosli.  `
+
+const snapshotEC2LongDesc = snapshotEC2ShortDesc + `
+Skip ^--tags^ and ^--auto-scaling-groups^ to report all running instances in a given AWS account and region.
+Use ^--tags^ to only report instances with all the given tag values, and ^--auto-scaling-groups^ to only report
+instances launched by some Auto Scaling groups (e.g. the node groups of an EKS cluster).
+
+The fingerprint of each AMI is read from an AMI tag (^kosli_fingerprint^ by default, see ^--fingerprint-tag^),
+typically set when baking the AMI (e.g. with Packer). Instances whose AMI does not have a valid fingerprint tag are not reported.
+The reported data includes the AMI IDs and fingerprints, the Auto Scaling groups and launch templates of the instances,
+and the instance launch times.` + snapshotWatchDesc + awsAuthDesc
+
+const snapshotEC2Example = `
+# report the AMIs of all running EC2 instances in an AWS account and region:
+export AWS_REGION=yourAWSRegion
+export AWS_ACCESS_KEY_ID=yourAWSAccessKeyID
+export AWS_SECRET_ACCESS_KEY=yourAWSSecretAccessKey
+
+kosli snapshot ec2 yourEnvironmentName \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report the AMIs of running EC2 instances with some tag values (AWS auth provided in flags):
+kosli snapshot ec2 yourEnvironmentName \
+	--tags env=prod,team=payments \
+	--aws-key-id yourAWSAccessKeyID \
+	--aws-secret-key yourAWSSecretAccessKey \
+	--aws-region yourAWSRegion \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report the AMIs of running EC2 instances in some Auto Scaling groups, with fingerprints in a custom AMI tag:
+kosli snapshot ec2 yourEnvironmentName \
+	--auto-scaling-groups web-asg,api-asg \
+	--fingerprint-tag sha256 \
+	--api-token yourAPIToken \
+	--org yourOrgName
+`
+
+type snapshotEC2Options struct {
+	filter         *aws.Ec2FilterOptions
+	awsStaticCreds *aws.AWSStaticCreds
+	watch          *snapshotWatchOptions
+}
+
+func newSnapshotEC2Cmd(out io.Writer) *cobra.Command {
+	o := new(snapshotEC2Options)
+	o.filter = new(aws.Ec2FilterOptions)
+	o.awsStaticCreds = new(aws.AWSStaticCreds)
+	o.watch = new(snapshotWatchOptions)
+	cmd := &cobra.Command{
+		Use:     "ec2 ENVIRONMENT-NAME",
+		Short:   snapshotEC2ShortDesc,
+		Long:    snapshotEC2LongDesc,
+		Example: snapshotEC2Example,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			err := RequireGlobalFlags(global, []string{"Org", "ApiToken"})
+			if err != nil {
+				return ErrorBeforePrintingUsage(cmd, err.Error())
+			}
+			if o.filter.FingerprintTag == "" {
+				return fmt.Errorf("--fingerprint-tag must not be empty")
+			}
+			return validateSnapshotWatchFlags(cmd, o.watch)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(args)
+		},
+	}
+
+	cmd.Flags().StringToStringVar(&o.filter.Tags, "tags", map[string]string{}, ec2TagsFlag)
+	cmd.Flags().StringSliceVar(&o.filter.AutoScalingGroups, "auto-scaling-groups", []string{}, ec2AutoScalingGroupsFlag)
+	cmd.Flags().StringVar(&o.filter.FingerprintTag, "fingerprint-tag", aws.DefaultAmiFingerprintTag, ec2FingerprintTagFlag)
+	addAWSAuthFlags(cmd, o.awsStaticCreds)
+	addSnapshotWatchFlags(cmd, o.watch)
+	addDryRunFlag(cmd)
+
+	return cmd
+}
+
+func (o *snapshotEC2Options) run(args []string) error {
+	envName := args[0]
+	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/EC2", global.Host, global.Org, envName)
+
+	return o.watch.run(envName, snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			instancesData, err := o.awsStaticCreds.GetEc2InstancesData(o.filter, logger)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &aws.Ec2EnvRequest{Artifacts: instancesData}, instancesData, nil
+		},
+		report: func(payload interface{}) error {
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   global.ApiToken,
+			}
+			_, err := kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("[%d] instances were reported to environment %s", len(payload.(*aws.Ec2EnvRequest).Artifacts), envName)
+			}
+			return err
+		},
+	})
+}
@@ -14,7 +14,7 @@ import (
 const snapshotECSShortDesc = `Report a snapshot of running containers in one or more AWS ECS cluster(s) to Kosli.  `
 const snapshotECSLongDesc = snapshotECSShortDesc + `
 Skip ^--clusters^ and ^--clusters-regex^ to report all clusters in a given AWS account. Or use ^--exclude^ and/or ^--exclude-regex^ to report all clusters excluding some.
-The reported data includes container image digests and creation timestamps.` + awsAuthDesc
+The reported data includes container image digests and creation timestamps.` + snapshotWatchDesc + awsAuthDesc
 
 const snapshotECSExample = `
 # report what is running in an entire AWS ECS cluster:
@@ -60,12 +60,14 @@ type snapshotECSOptions struct {
 	filter         *filters.ResourceFilterOptions
 	serviceName    string
 	awsStaticCreds *aws.AWSStaticCreds
+	watch          *snapshotWatchOptions
 }
 
 func newSnapshotECSCmd(out io.Writer) *cobra.Command {
 	o := new(snapshotECSOptions)
 	o.awsStaticCreds = new(aws.AWSStaticCreds)
 	o.filter = new(filters.ResourceFilterOptions)
+	o.watch = new(snapshotWatchOptions)
 	cmd := &cobra.Command{
 		Use:     "ecs ENVIRONMENT-NAME",
 		Short:   snapshotECSShortDesc,
@@ -94,7 +96,7 @@ func newSnapshotECSCmd(out io.Writer) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			return nil
+			return validateSnapshotWatchFlags(cmd, o.watch)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return o.run(args)
@@ -109,6 +111,7 @@ func newSnapshotECSCmd(out io.Writer) *cobra.Command {
 	cmd.Flags().StringSliceVarP(&o.filter.IncludeNames, "cluster", "C", []string{}, ecsClusterFlag)
 	cmd.Flags().StringVarP(&o.serviceName, "service-name", "s", "", ecsServiceFlag)
 	addAWSAuthFlags(cmd, o.awsStaticCreds)
+	addSnapshotWatchFlags(cmd, o.watch)
 	addDryRunFlag(cmd)
 
 	err := DeprecateFlags(cmd, map[string]string{
@@ -126,25 +129,27 @@ func (o *snapshotECSOptions) run(args []string) error {
 	envName := args[0]
 	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/ECS", global.Host, global.Org, envName)
 
-	tasksData, err := o.awsStaticCreds.GetEcsTasksData(o.filter)
-	if err != nil {
-		return err
-	}
-
-	payload := &aws.EcsEnvRequest{
-		Artifacts: tasksData,
-	}
-
-	reqParams := &requests.RequestParams{
-		Method:  http.MethodPut,
-		URL:     url,
-		Payload: payload,
-		DryRun:  global.DryRun,
-		Token:   global.ApiToken,
-	}
-	_, err = kosliClient.Do(reqParams)
-	if err == nil && !global.DryRun {
-		logger.Info("[%d] containers were reported to environment %s", len(payload.Artifacts), envName)
-	}
-	return err
+	return o.watch.run(envName, snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			tasksData, err := o.awsStaticCreds.GetEcsTasksData(o.filter)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &aws.EcsEnvRequest{Artifacts: tasksData}, tasksData, nil
+		},
+		report: func(payload interface{}) error {
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   global.ApiToken,
+			}
+			_, err := kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("[%d] containers were reported to environment %s", len(payload.(*aws.EcsEnvRequest).Artifacts), envName)
+			}
+			return err
+		},
+	})
 }
@@ -19,7 +19,20 @@ const snapshotK8SShortDesc = `Report a snapshot of running pods in a K8S cluster
 const snapshotK8SLongDesc = snapshotK8SShortDesc + `
 Skip ^--namespaces^ and ^--namespaces-regex^ to report all pods in all namespaces in a cluster.
 The reported data includes pod container images digests and creation timestamps. You can customize the scope of reporting
-to include or exclude namespaces.`
+to include or exclude namespaces.
+
+Use ^--helm-releases^ to also report the Helm release (release name, revision, chart, chart version and app version)
+that produced each pod. Releases are read from the Helm release secrets in the scanned namespaces and each pod is
+mapped to its release through the Helm annotations of the workload that owns it, the release manifest or the
+^app.kubernetes.io/instance^ label. This requires read permissions for secrets, replicasets, deployments,
+statefulsets, daemonsets, jobs and cronjobs in the scanned namespaces.
+
+Use ^--manifests^ to report from exported or rendered resources instead of a live cluster, e.g. when the cluster API
+is not reachable from where the snapshot is reported. It accepts YAML or JSON files, directories of such files
+or ^-^ to read from stdin. Exported pods (e.g. ^kubectl get pods -A -o json^) are reported as if they were read from
+the cluster. Pods without a status and workloads (e.g. a rendered Helm chart or kustomization) are reported by their
+pod template and only images pinned to a digest (^image@sha256:...^) are included.
+The namespaces filter flags apply to the resources in the manifests too.` + snapshotWatchDesc
 
 const snapshotK8SExample = `
 # report what is running in an entire cluster using kubeconfig at $HOME/.kube/config:
@@ -46,6 +59,34 @@ kosli snapshot k8s yourEnvironmentName \
 	--api-token yourAPIToken \
 	--org yourOrgName
 
+# report what is running in a given namespace in the cluster together with the Helm releases of the pods:
+kosli snapshot k8s yourEnvironmentName \
+	--namespaces your-namespace \
+	--helm-releases \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report what is running in a cluster from pods exported on another machine:
+kubectl get pods -A -o json > pods.json
+kosli snapshot k8s yourEnvironmentName \
+	--manifests pods.json \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# report what a rendered Helm chart deploys, reading the manifests from stdin:
+helm template your-release your-chart | kosli snapshot k8s yourEnvironmentName \
+	--manifests - \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
+# keep watching a namespace in the cluster and report only when the running pods change:
+kosli snapshot k8s yourEnvironmentName \
+	--namespaces your-namespace \
+	--watch \
+	--health-address :8080 \
+	--api-token yourAPIToken \
+	--org yourOrgName
+
 # report what is running in a cluster using kubeconfig at a custom path:
 kosli snapshot k8s yourEnvironmentName \
 	--kubeconfig /path/to/kube/config \
@@ -54,15 +95,19 @@ kosli snapshot k8s yourEnvironmentName \
 `
 
 type snapshotK8SOptions struct {
-	kubeconfig string
+	kubeconfig   string
+	helmReleases bool
+	manifests    []string
 	// namespaces        []string
 	// excludeNamespaces []string
 	filter *filters.ResourceFilterOptions
+	watch  *snapshotWatchOptions
 }
 
 func newSnapshotK8SCmd(out io.Writer) *cobra.Command {
 	o := new(snapshotK8SOptions)
 	o.filter = new(filters.ResourceFilterOptions)
+	o.watch = new(snapshotWatchOptions)
 	cmd := &cobra.Command{
 		Use:     "k8s ENVIRONMENT-NAME",
 		Aliases: []string{"kubernetes"},
@@ -75,10 +120,24 @@ func newSnapshotK8SCmd(out io.Writer) *cobra.Command {
 			if err != nil {
 				return ErrorBeforePrintingUsage(cmd, err.Error())
 			}
-			return MuXRequiredFlags(cmd, []string{"namespaces", "exclude-namespaces"}, false)
+			err = MuXRequiredFlags(cmd, []string{"namespaces", "exclude-namespaces"}, false)
+			if err != nil {
+				return err
+			}
+			// --kubeconfig is not rejected with --manifests, as it is also set from KOSLI_KUBECONFIG
+			// and the config file, and it is ignored
+			err = MuXRequiredFlags(cmd, []string{"manifests", "helm-releases"}, false)
+			if err != nil {
+				return err
+			}
+			err = MuXRequiredFlags(cmd, []string{"manifests", "watch"}, false)
+			if err != nil {
+				return err
+			}
+			return validateSnapshotWatchFlags(cmd, o.watch)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return o.run(args)
+			return o.run(args, cmd.InOrStdin())
 		},
 	}
 
@@ -87,38 +146,84 @@ func newSnapshotK8SCmd(out io.Writer) *cobra.Command {
 	cmd.Flags().StringSliceVar(&o.filter.IncludeNamesRegex, "namespaces-regex", []string{}, namespacesRegexFlag)
 	cmd.Flags().StringSliceVarP(&o.filter.ExcludeNames, "exclude-namespaces", "x", []string{}, excludeNamespacesFlag)
 	cmd.Flags().StringSliceVar(&o.filter.ExcludeNamesRegex, "exclude-namespaces-regex", []string{}, excludeNamespacesRegexFlag)
+	cmd.Flags().BoolVar(&o.helmReleases, "helm-releases", false, helmReleasesFlag)
+	cmd.Flags().StringSliceVar(&o.manifests, "manifests", []string{}, k8sManifestsFlag)
+	addSnapshotWatchFlags(cmd, o.watch)
 	addDryRunFlag(cmd)
 	return cmd
 }
 
-func (o *snapshotK8SOptions) run(args []string) error {
+func (o *snapshotK8SOptions) run(args []string, stdin io.Reader) error {
 	envName := args[0]
 	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/K8S", global.Host, global.Org, envName)
-	clientset, err := kube.NewK8sClientSet(o.kubeconfig)
-	if err != nil {
-		return err
+
+	// one clientset is used for watching the cluster and taking all the snapshots
+	var clientset *kube.K8SConnection
+	if len(o.manifests) == 0 {
+		var err error
+		clientset, err = kube.NewK8sClientSet(o.kubeconfig)
+		if err != nil {
+			return err
+		}
 	}
-	podsData, err := clientset.GetPodsData(o.filter, logger)
-	if err != nil {
-		return err
+	// in watch mode, the pods are read from the cache of the informers which watch them
+	var pods *kube.PodWatcher
+	if o.watch.watch {
+		pods = clientset.NewPodWatcher(o.filter)
 	}
 
-	payload := &kube.K8sEnvRequest{
-		Artifacts: podsData,
+	funcs := snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			podsData, err := o.getPodsData(clientset, pods, stdin)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &kube.K8sEnvRequest{Artifacts: podsData}, podsData, nil
+		},
+		report: func(payload interface{}) error {
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   global.ApiToken,
+			}
+			_, err := kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("[%d] pods were reported to environment %s", len(payload.(*kube.K8sEnvRequest).Artifacts), envName)
+			}
+			return err
+		},
+	}
+	if pods != nil {
+		funcs.watchChanges = pods.Watch
 	}
+	return o.watch.run(envName, funcs)
+}
 
-	reqParams := &requests.RequestParams{
-		Method:  http.MethodPut,
-		URL:     url,
-		Payload: payload,
-		DryRun:  global.DryRun,
-		Token:   global.ApiToken,
+// getPodsData gets the pods data from the manifests if provided, otherwise from the
+// pods watcher in watch mode, or from the cluster
+func (o *snapshotK8SOptions) getPodsData(clientset *kube.K8SConnection, pods *kube.PodWatcher, stdin io.Reader) ([]*kube.PodData, error) {
+	if len(o.manifests) > 0 {
+		return kube.GetPodsDataFromManifests(o.manifests, stdin, o.filter, logger)
+	}
+	var podsData []*kube.PodData
+	var err error
+	if pods != nil {
+		podsData, err = pods.GetPodsData()
+	} else {
+		podsData, err = clientset.GetPodsData(o.filter, logger)
 	}
-	_, err = kosliClient.Do(reqParams)
-	if err == nil && !global.DryRun {
-		logger.Info("[%d] pods were reported to environment %s", len(payload.Artifacts), envName)
+	if err != nil {
+		return nil, err
+	}
+	if o.helmReleases {
+		err = clientset.AddHelmReleases(podsData, logger)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return err
+	return podsData, nil
 }
 
 func defaultKubeConfigPath() string {
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/kube"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+const snapshotK8SOperatorShortDesc = `Run an operator that reports the Kosli environments defined by KosliEnvironment resources in a K8S cluster.  `
+
+const snapshotK8SOperatorLongDesc = snapshotK8SOperatorShortDesc + `
+The operator is meant to run inside the cluster (e.g. as a Deployment) and uses the in-cluster
+config unless ^--kubeconfig^ is set. It watches ^KosliEnvironment^ resources (^kosli.com/v1alpha1^)
+and keeps reporting each of them as a separate environment, in the same way as ^kosli snapshot k8s --watch^.
+The k8s-reporter Helm chart runs the operator, with the CustomResourceDefinition and the permissions it needs,
+when ^operator.enabled^ is set.
+
+Each KosliEnvironment holds the Kosli environment name, the namespaces to include or exclude
+(by names or regex patterns) and a reference to a secret holding the Kosli API token. The org defaults
+to the operator's ^--org^ when not set in the resource. The result of the last check of each
+environment is written to the status of its resource.
+
+Use ^--namespace^ to only watch KosliEnvironment resources in one namespace.
+Use ^--health-address^ to serve a health endpoint at ^/healthz^. It responds with 200 unless the last check of
+any environment failed.
+
+The operator needs permissions to watch KosliEnvironment resources and update their status, to get the
+referenced secrets and to read the pods and workloads in the reported namespaces.`
+
+const snapshotK8SOperatorExample = `
+# run the operator inside the cluster, watching KosliEnvironment resources in all namespaces:
+kosli snapshot k8s-operator \
+	--org yourOrgName
+
+# run the operator using kubeconfig at $HOME/.kube/config, watching KosliEnvironment resources in one namespace:
+kosli snapshot k8s-operator \
+	--kubeconfig $HOME/.kube/config \
+	--namespace kosli \
+	--health-address :8080 \
+	--org yourOrgName
+
+# an example KosliEnvironment resource:
+apiVersion: kosli.com/v1alpha1
+kind: KosliEnvironment
+metadata:
+  name: production
+  namespace: kosli
+spec:
+  environmentName: production-k8s
+  namespaces: [payments, orders]
+  apiTokenSecretRef:
+    name: kosli-api-token
+    key: token
+`
+
+type snapshotK8SOperatorOptions struct {
+	kubeconfig    string
+	namespace     string
+	interval      time.Duration
+	healthAddress string
+}
+
+func newSnapshotK8SOperatorCmd(out io.Writer) *cobra.Command {
+	o := new(snapshotK8SOperatorOptions)
+	cmd := &cobra.Command{
+		Use:     "k8s-operator",
+		Short:   snapshotK8SOperatorShortDesc,
+		Long:    snapshotK8SOperatorLongDesc,
+		Example: snapshotK8SOperatorExample,
+		Args:    cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if o.interval <= 0 {
+				return fmt.Errorf("--watch-interval must be positive")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.kubeconfig, "kubeconfig", "k", "", kubeconfigOperatorFlag)
+	cmd.Flags().StringVarP(&o.namespace, "namespace", "n", "", operatorNamespaceFlag)
+	cmd.Flags().DurationVar(&o.interval, "watch-interval", time.Minute, snapshotWatchIntervalFlag)
+	cmd.Flags().StringVar(&o.healthAddress, "health-address", "", snapshotHealthAddressFlag)
+	addDryRunFlag(cmd)
+	return cmd
+}
+
+func (o *snapshotK8SOperatorOptions) run() error {
+	clientset, err := kube.NewK8sClientSet(o.kubeconfig)
+	if err != nil {
+		return err
+	}
+	envClient, err := kube.NewEnvironmentClient(o.kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	operator := newK8sOperator(clientset, envClient, o.interval)
+	if o.healthAddress != "" {
+		server := &http.Server{Addr: o.healthAddress, Handler: operator.healthHandler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Warning("health endpoint stopped: %v", err)
+			}
+		}()
+		defer server.Close()
+		logger.Info("serving health endpoint on %s/healthz", o.healthAddress)
+	}
+
+	err = envClient.WatchEnvironments(ctx, o.namespace, o.interval, operator.eventHandler(ctx))
+	if err != nil {
+		return err
+	}
+	logger.Info("watching KosliEnvironment resources")
+	<-ctx.Done()
+	operator.stopAll()
+	logger.Info("operator stopped")
+	return nil
+}
+
+// k8sOperator runs a watcher for each KosliEnvironment resource
+type k8sOperator struct {
+	clientset *kube.K8SConnection
+	envClient *kube.EnvironmentClient
+	interval  time.Duration
+	mu        sync.Mutex
+	runners   map[string]*environmentRunner
+	// wg tracks the running watchers
+	wg sync.WaitGroup
+}
+
+// environmentRunner is the watcher of one KosliEnvironment resource.
+// Invalid resources have a runner without a watcher, to not process them again until they change.
+type environmentRunner struct {
+	generation int64
+	cancel     context.CancelFunc
+	health     *snapshotWatchHealth
+}
+
+func newK8sOperator(clientset *kube.K8SConnection, envClient *kube.EnvironmentClient, interval time.Duration) *k8sOperator {
+	return &k8sOperator{
+		clientset: clientset,
+		envClient: envClient,
+		interval:  interval,
+		runners:   make(map[string]*environmentRunner),
+	}
+}
+
+func (op *k8sOperator) eventHandler(ctx context.Context) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if object, ok := obj.(*unstructured.Unstructured); ok {
+				op.reconcile(ctx, object)
+			}
+		},
+		UpdateFunc: func(_, newObj interface{}) {
+			if object, ok := newObj.(*unstructured.Unstructured); ok {
+				op.reconcile(ctx, object)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if object, ok := obj.(*unstructured.Unstructured); ok {
+				op.remove(object.GetNamespace() + "/" + object.GetName())
+			}
+		},
+	}
+}
+
+// reconcile (re)starts the watcher of a KosliEnvironment when it is new or its spec changed
+func (op *k8sOperator) reconcile(ctx context.Context, object *unstructured.Unstructured) {
+	key := object.GetNamespace() + "/" + object.GetName()
+	op.mu.Lock()
+	runner, exists := op.runners[key]
+	op.mu.Unlock()
+	if exists && runner.generation == object.GetGeneration() {
+		// only the status changed
+		return
+	}
+	op.remove(key)
+
+	env, err := kube.NewKosliEnvironmentFromUnstructured(object)
+	if err == nil {
+		err = env.Spec.Validate()
+	}
+	if err == nil && env.Spec.Org == "" && global.Org == "" {
+		err = fmt.Errorf("spec.org is required when the operator is not configured with --org")
+	}
+	if err != nil {
+		logger.Warning("invalid KosliEnvironment %s: %v", key, err)
+		op.mu.Lock()
+		op.runners[key] = &environmentRunner{generation: object.GetGeneration()}
+		op.mu.Unlock()
+		op.updateStatus(ctx, object.GetNamespace(), object.GetName(), kube.KosliEnvironmentStatus{
+			ObservedGeneration: object.GetGeneration(),
+			Phase:              kube.KosliEnvironmentFailing,
+			Message:            err.Error(),
+		})
+		return
+	}
+
+	runnerCtx, cancel := context.WithCancel(ctx)
+	watcher := newSnapshotWatcher(env.Spec.EnvironmentName, op.interval, op.environmentFuncs(runnerCtx, env))
+	op.mu.Lock()
+	op.runners[key] = &environmentRunner{generation: env.Generation, cancel: cancel, health: watcher.health}
+	op.mu.Unlock()
+
+	op.wg.Add(1)
+	go func() {
+		defer op.wg.Done()
+		if err := watcher.run(runnerCtx, ""); err != nil {
+			logger.Warning("failed to watch KosliEnvironment %s: %v", key, err)
+		}
+	}()
+}
+
+// environmentFuncs returns the functions to take and report snapshots of a KosliEnvironment
+func (op *k8sOperator) environmentFuncs(ctx context.Context, env *kube.KosliEnvironment) snapshotFuncs {
+	filter := env.Spec.Filter()
+	org := env.Spec.Org
+	if org == "" {
+		org = global.Org
+	}
+	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/K8S", global.Host, org, env.Spec.EnvironmentName)
+	lastReportTime := env.Status.LastReportTime
+	// the pods are read from the cache of the informers which watch them
+	pods := op.clientset.NewPodWatcher(filter)
+
+	return snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			podsData, err := pods.GetPodsData()
+			if err != nil {
+				return nil, nil, err
+			}
+			return &kube.K8sEnvRequest{Artifacts: podsData}, podsData, nil
+		},
+		report: func(payload interface{}) error {
+			// the token is read on every report to pick up rotated tokens
+			token, err := op.clientset.GetSecretValue(env.Namespace, env.Spec.APITokenSecretRef.Name, env.Spec.APITokenSecretKey())
+			if err != nil {
+				return err
+			}
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   token,
+			}
+			_, err = kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("[%d] pods were reported to environment %s", len(payload.(*kube.K8sEnvRequest).Artifacts), env.Spec.EnvironmentName)
+			}
+			return err
+		},
+		watchChanges: pods.Watch,
+		checked: func(result snapshotCheckResult) {
+			now := metav1.Now()
+			status := kube.KosliEnvironmentStatus{
+				ObservedGeneration: env.Generation,
+				Phase:              kube.KosliEnvironmentReporting,
+				LastCheckTime:      &now,
+				ReportedPods:       result.Artifacts,
+			}
+			if result.Err != nil {
+				status.Phase = kube.KosliEnvironmentFailing
+				status.Message = result.Err.Error()
+			}
+			if result.Reported {
+				lastReportTime = &now
+			}
+			status.LastReportTime = lastReportTime
+			op.updateStatus(ctx, env.Namespace, env.Name, status)
+		},
+	}
+}
+
+func (op *k8sOperator) updateStatus(ctx context.Context, namespace, name string, status kube.KosliEnvironmentStatus) {
+	if err := op.envClient.UpdateEnvironmentStatus(ctx, namespace, name, status); err != nil && ctx.Err() == nil {
+		logger.Warning("failed to update the status of KosliEnvironment %s/%s: %v", namespace, name, err)
+	}
+}
+
+// remove stops the watcher of a KosliEnvironment, if any
+func (op *k8sOperator) remove(key string) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if runner, ok := op.runners[key]; ok {
+		if runner.cancel != nil {
+			runner.cancel()
+		}
+		delete(op.runners, key)
+	}
+}
+
+// stopAll stops all watchers and waits for them to finish
+func (op *k8sOperator) stopAll() {
+	op.mu.Lock()
+	for key, runner := range op.runners {
+		if runner.cancel != nil {
+			runner.cancel()
+		}
+		delete(op.runners, key)
+	}
+	op.mu.Unlock()
+	op.wg.Wait()
+}
+
+// healthHandler serves the health of all watched environments. It is unhealthy when
+// any environment is failing or stale.
+func (op *k8sOperator) healthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		op.mu.Lock()
+		keys := make([]string, 0, len(op.runners))
+		for key, runner := range op.runners {
+			if runner.health != nil {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		healthy := true
+		environments := make(map[string]snapshotWatchHealthStatus)
+		for _, key := range keys {
+			status, ok := op.runners[key].health.status()
+			if !ok && status.Status != "starting" {
+				healthy = false
+			}
+			environments[key] = status
+		}
+		op.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"environments": environments})
+	})
+	return mux
+}
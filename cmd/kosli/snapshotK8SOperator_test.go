@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/kube"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type SnapshotK8SOperatorTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	mu       sync.Mutex
+	received []string
+	operator *k8sOperator
+}
+
+func (suite *SnapshotK8SOperatorTestSuite) SetupTest() {
+	suite.received = []string{}
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		suite.mu.Lock()
+		suite.received = append(suite.received, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("Authorization"), body))
+		suite.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	}))
+	global = &GlobalOpts{Host: suite.server.URL, Org: "acme"}
+
+	clientset := &kube.K8SConnection{Interface: fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "kosli-api-token", Namespace: "kosli"},
+			Data:       map[string][]byte{"token": []byte("secret")},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "payments"},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Image: "nginx:1.25", ImageID: "docker.io/library/nginx@sha256:" + strings.Repeat("a", 64)},
+				},
+			},
+		},
+	)}
+	envClient := &kube.EnvironmentClient{Interface: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{kube.KosliEnvironmentGVR: "KosliEnvironmentList"},
+		suite.environment("production", "production-k8s", "kosli-api-token"),
+		suite.environment("invalid", "", "kosli-api-token"),
+	)}
+	suite.operator = newK8sOperator(clientset, envClient, time.Minute)
+}
+
+func (suite *SnapshotK8SOperatorTestSuite) TearDownTest() {
+	suite.operator.stopAll()
+	suite.server.Close()
+}
+
+func (suite *SnapshotK8SOperatorTestSuite) TestReconcileReportsEnvironmentAndWritesStatus() {
+	ctx := context.Background()
+	suite.operator.reconcile(ctx, suite.environment("production", "production-k8s", "kosli-api-token"))
+
+	require.Eventually(suite.T(), func() bool {
+		status := suite.status("production")
+		return status.Phase == kube.KosliEnvironmentReporting && status.LastReportTime != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	status := suite.status("production")
+	require.Equal(suite.T(), 1, status.ReportedPods)
+	require.Equal(suite.T(), int64(1), status.ObservedGeneration)
+
+	suite.mu.Lock()
+	require.Len(suite.T(), suite.received, 1)
+	require.Contains(suite.T(), suite.received[0], "PUT /api/v2/environments/acme/production-k8s/report/K8S Bearer secret")
+	require.Contains(suite.T(), suite.received[0], strings.Repeat("a", 64))
+	suite.mu.Unlock()
+
+	// a status update does not restart the watcher
+	runner := suite.operator.runners["kosli/production"]
+	suite.operator.reconcile(ctx, suite.environment("production", "production-k8s", "kosli-api-token"))
+	require.Same(suite.T(), runner, suite.operator.runners["kosli/production"])
+
+	// deleting the resource stops its watcher
+	suite.operator.remove("kosli/production")
+	require.Empty(suite.T(), suite.operator.runners)
+}
+
+func (suite *SnapshotK8SOperatorTestSuite) TestReconcileMarksInvalidEnvironmentAsFailing() {
+	suite.operator.reconcile(context.Background(), suite.environment("invalid", "", "kosli-api-token"))
+
+	status := suite.status("invalid")
+	require.Equal(suite.T(), kube.KosliEnvironmentFailing, status.Phase)
+	require.Equal(suite.T(), "spec.environmentName is required", status.Message)
+	require.Nil(suite.T(), suite.operator.runners["kosli/invalid"].health)
+}
+
+func (suite *SnapshotK8SOperatorTestSuite) TestReconcileMarksMissingTokenAsFailing() {
+	suite.operator.reconcile(context.Background(), suite.environment("production", "production-k8s", "missing-secret"))
+
+	require.Eventually(suite.T(), func() bool {
+		return suite.status("production").Phase == kube.KosliEnvironmentFailing
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Contains(suite.T(), suite.status("production").Message, "could not get secret missing-secret on namespace kosli")
+
+	server := httptest.NewServer(suite.operator.healthHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/healthz")
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+	require.Equal(suite.T(), http.StatusServiceUnavailable, resp.StatusCode)
+	health := map[string]map[string]snapshotWatchHealthStatus{}
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&health))
+	require.Equal(suite.T(), "failing", health["environments"]["kosli/production"].Status)
+}
+
+func (suite *SnapshotK8SOperatorTestSuite) TestSnapshotK8SOperatorCmd() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "snapshot k8s-operator does not accept arguments",
+			cmd:       "snapshot k8s-operator foo",
+			golden:    "Error: unknown command \"foo\" for \"kosli snapshot k8s-operator\"\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot k8s-operator fails when --watch-interval is not positive",
+			cmd:       "snapshot k8s-operator --watch-interval 0s",
+			golden:    "Error: --watch-interval must be positive\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+// environment creates a KosliEnvironment resource in the kosli namespace
+func (suite *SnapshotK8SOperatorTestSuite) environment(name, envName, secretName string) *unstructured.Unstructured {
+	env := &kube.KosliEnvironment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kosli.com/v1alpha1", Kind: "KosliEnvironment"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kosli", Generation: 1},
+		Spec: kube.KosliEnvironmentSpec{
+			EnvironmentName:   envName,
+			Namespaces:        []string{"payments"},
+			APITokenSecretRef: kube.SecretKeyReference{Name: secretName},
+		},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(env)
+	require.NoError(suite.T(), err)
+	return &unstructured.Unstructured{Object: content}
+}
+
+// status gets the current status of a KosliEnvironment resource in the kosli namespace
+func (suite *SnapshotK8SOperatorTestSuite) status(name string) kube.KosliEnvironmentStatus {
+	object, err := suite.operator.envClient.Resource(kube.KosliEnvironmentGVR).Namespace("kosli").Get(context.Background(), name, metav1.GetOptions{})
+	require.NoError(suite.T(), err)
+	env, err := kube.NewKosliEnvironmentFromUnstructured(object)
+	require.NoError(suite.T(), err)
+	return env.Status
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSnapshotK8SOperatorTestSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotK8SOperatorTestSuite))
+}
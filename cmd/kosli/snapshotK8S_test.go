@@ -48,6 +48,30 @@ func (suite *SnapshotK8STestSuite) TestSnapshotK8SCmd() {
 			cmd:       fmt.Sprintf(`snapshot k8s %s`, suite.defaultKosliArguments),
 			golden:    "Error: accepts 1 arg(s), received 0\n",
 		},
+		{
+			wantError: true,
+			name:      "snapshot K8S fails if both --manifests and --helm-releases are set",
+			cmd:       fmt.Sprintf(`snapshot k8s %s --manifests ../../internal/kube/testdata/manifests/pods.json --helm-releases %s`, suite.envName, suite.defaultKosliArguments),
+			golden:    "Error: only one of --manifests, --helm-releases is allowed\n",
+		},
+		{
+			name:        "snapshot K8S ignores --kubeconfig when --manifests is set",
+			cmd:         fmt.Sprintf(`snapshot k8s %s --manifests ../../internal/kube/testdata/manifests/pods.json --kubeconfig non-existing %s`, suite.envName, suite.defaultKosliArguments),
+			goldenRegex: "pods were reported to environment snapshot-k8s-env\n",
+		},
+		{
+			wantError: true,
+			name:      "snapshot K8S fails if --manifests does not exist",
+			cmd:       fmt.Sprintf(`snapshot k8s %s --manifests non-existing.json %s`, suite.envName, suite.defaultKosliArguments),
+			golden:    "Error: failed to open manifests path non-existing.json: stat non-existing.json: no such file or directory\n",
+		},
+		{
+			name: "snapshot K8S can report pods from exported and rendered manifests",
+			cmd:  fmt.Sprintf(`snapshot k8s %s --manifests ../../internal/kube/testdata/manifests/pods.json,../../internal/kube/testdata/manifests/rendered %s`, suite.envName, suite.defaultKosliArguments),
+			golden: "[warning] image registry.example.com/sidecar:latest of Deployment prod/web is not pinned to a digest and is not reported\n" +
+				"[warning] image nginx:1.25 of Deployment default/unpinned is not pinned to a digest and is not reported\n" +
+				"[5] pods were reported to environment snapshot-k8s-env\n",
+		},
 	}
 
 	runTestCmd(suite.T(), tests)
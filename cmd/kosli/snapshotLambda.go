@@ -14,7 +14,7 @@ import (
 const snapshotLambdaShortDesc = `Report a snapshot of artifacts deployed as one or more AWS Lambda functions and their digests to Kosli.`
 
 const snapshotLambdaLongDesc = snapshotLambdaShortDesc + `  
-Skip ^--function-names^ and ^--function-names-regex^ to report all functions in a given AWS account. Or use ^--exclude^ and/or ^--exclude-regex^ to report all functions excluding some.` + awsAuthDesc
+Skip ^--function-names^ and ^--function-names-regex^ to report all functions in a given AWS account. Or use ^--exclude^ and/or ^--exclude-regex^ to report all functions excluding some.` + snapshotWatchDesc + awsAuthDesc
 
 const snapshotLambdaExample = `
 # report all Lambda functions running in an AWS account (AWS auth provided in env variables):
@@ -81,12 +81,14 @@ type snapshotLambdaOptions struct {
 	functionVersion string
 	filter          *filters.ResourceFilterOptions
 	awsStaticCreds  *aws.AWSStaticCreds
+	watch           *snapshotWatchOptions
 }
 
 func newSnapshotLambdaCmd(out io.Writer) *cobra.Command {
 	o := new(snapshotLambdaOptions)
 	o.awsStaticCreds = new(aws.AWSStaticCreds)
 	o.filter = new(filters.ResourceFilterOptions)
+	o.watch = new(snapshotWatchOptions)
 	cmd := &cobra.Command{
 		Use:     "lambda ENVIRONMENT-NAME",
 		Short:   snapshotLambdaShortDesc,
@@ -119,7 +121,7 @@ func newSnapshotLambdaCmd(out io.Writer) *cobra.Command {
 				return err
 			}
 
-			return nil
+			return validateSnapshotWatchFlags(cmd, o.watch)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return o.run(args)
@@ -133,6 +135,7 @@ func newSnapshotLambdaCmd(out io.Writer) *cobra.Command {
 	cmd.Flags().StringSliceVar(&o.filter.ExcludeNames, "exclude", []string{}, excludeFlag)
 	cmd.Flags().StringSliceVar(&o.filter.ExcludeNamesRegex, "exclude-regex", []string{}, excludeRegexFlag)
 	addAWSAuthFlags(cmd, o.awsStaticCreds)
+	addSnapshotWatchFlags(cmd, o.watch)
 	addDryRunFlag(cmd)
 
 	err := DeprecateFlags(cmd, map[string]string{
@@ -148,27 +151,29 @@ func newSnapshotLambdaCmd(out io.Writer) *cobra.Command {
 
 func (o *snapshotLambdaOptions) run(args []string) error {
 	envName := args[0]
-
 	url := fmt.Sprintf("%s/api/v2/environments/%s/%s/report/lambda", global.Host, global.Org, envName)
-	lambdaData, err := o.awsStaticCreds.GetLambdaPackageData(o.filter)
-	if err != nil {
-		return err
-	}
 
-	payload := &aws.LambdaEnvRequest{
-		Artifacts: lambdaData,
-	}
-
-	reqParams := &requests.RequestParams{
-		Method:  http.MethodPut,
-		URL:     url,
-		Payload: payload,
-		DryRun:  global.DryRun,
-		Token:   global.ApiToken,
-	}
-	_, err = kosliClient.Do(reqParams)
-	if err == nil && !global.DryRun {
-		logger.Info("%d lambda functions were reported to environment %s", len(lambdaData), envName)
-	}
-	return err
+	return o.watch.run(envName, snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			lambdaData, err := o.awsStaticCreds.GetLambdaPackageData(o.filter)
+			if err != nil {
+				return nil, nil, err
+			}
+			return &aws.LambdaEnvRequest{Artifacts: lambdaData}, lambdaData, nil
+		},
+		report: func(payload interface{}) error {
+			reqParams := &requests.RequestParams{
+				Method:  http.MethodPut,
+				URL:     url,
+				Payload: payload,
+				DryRun:  global.DryRun,
+				Token:   global.ApiToken,
+			}
+			_, err := kosliClient.Do(reqParams)
+			if err == nil && !global.DryRun {
+				logger.Info("%d lambda functions were reported to environment %s", len(payload.(*aws.LambdaEnvRequest).Artifacts), envName)
+			}
+			return err
+		},
+	})
 }
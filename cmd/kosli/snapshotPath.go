@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/kosli-dev/cli/internal/digest"
 	"github.com/kosli-dev/cli/internal/requests"
 	"github.com/kosli-dev/cli/internal/server"
 	"github.com/spf13/cobra"
@@ -38,9 +39,10 @@ kosli snapshot path yourEnvironmentName \
 `
 
 type snapshotPathOptions struct {
-	path         string
-	artifactName string
-	exclude      []string
+	path             string
+	artifactName     string
+	exclude          []string
+	fingerprintCache string
 }
 
 func newSnapshotPathCmd(out io.Writer) *cobra.Command {
@@ -67,6 +69,7 @@ func newSnapshotPathCmd(out io.Writer) *cobra.Command {
 	cmd.Flags().StringVar(&o.path, "path", "", snapshotPathPathFlag)
 	cmd.Flags().StringVar(&o.artifactName, "name", "", snapshotPathArtifactNameFlag)
 	cmd.Flags().StringSliceVarP(&o.exclude, "exclude", "x", []string{}, snapshotPathExcludeFlag)
+	addFingerprintCacheFlag(cmd, &o.fingerprintCache)
 	addDryRunFlag(cmd)
 
 	if err := RequireFlags(cmd, []string{"path", "name"}); err != nil {
@@ -92,10 +95,17 @@ func (o *snapshotPathOptions) run(args []string) error {
 		},
 	}
 
-	artifacts, err := server.CreatePathsArtifactsData(ps, logger)
+	cache, err := digest.LoadFileDigestCache(o.fingerprintCache)
 	if err != nil {
 		return err
 	}
+	artifacts, err := server.CreatePathsArtifactsData(ps, cache, logger)
+	if err != nil {
+		return err
+	}
+	if err := cache.Save(); err != nil {
+		logger.Warning("%v", err)
+	}
 	payload := &server.ServerEnvRequest{
 		Artifacts: artifacts,
 	}
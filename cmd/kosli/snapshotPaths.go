@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/kosli-dev/cli/internal/digest"
 	"github.com/kosli-dev/cli/internal/requests"
 	"github.com/kosli-dev/cli/internal/server"
 	"github.com/spf13/cobra"
@@ -52,7 +53,8 @@ kosli snapshot paths yourEnvironmentName \
 `
 
 type snapshotPathsOptions struct {
-	pathSpecFile string
+	pathSpecFile     string
+	fingerprintCache string
 }
 
 func newSnapshotPathsCmd(out io.Writer) *cobra.Command {
@@ -77,6 +79,7 @@ func newSnapshotPathsCmd(out io.Writer) *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&o.pathSpecFile, "paths-file", "", pathsSpecFileFlag)
+	addFingerprintCacheFlag(cmd, &o.fingerprintCache)
 	addDryRunFlag(cmd)
 
 	if err := RequireFlags(cmd, []string{"paths-file"}); err != nil {
@@ -97,10 +100,17 @@ func (o *snapshotPathsOptions) run(args []string) error {
 		return err
 	}
 
-	artifacts, err := server.CreatePathsArtifactsData(ps, logger)
+	cache, err := digest.LoadFileDigestCache(o.fingerprintCache)
 	if err != nil {
 		return err
 	}
+	artifacts, err := server.CreatePathsArtifactsData(ps, cache, logger)
+	if err != nil {
+		return err
+	}
+	if err := cache.Save(); err != nil {
+		logger.Warning("%v", err)
+	}
 	payload := &server.ServerEnvRequest{
 		Artifacts: artifacts,
 	}
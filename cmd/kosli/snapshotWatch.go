@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const snapshotWatchDesc = `
+
+Use ^--watch^ to keep running and report a snapshot only when the set of running artifacts changes.
+The environment is checked every ^--watch-interval^ (and on every change for Kubernetes).
+Use ^--health-address^ to serve a health endpoint at ^/healthz^ (e.g. for a Kubernetes liveness probe).
+It responds with 200 when the last check succeeded and with 503 otherwise.`
+
+// snapshotWatchDebounce is how long to wait after a change event before checking the
+// environment, so that a burst of events (e.g. a rollout) results in a single check
+const snapshotWatchDebounce = 2 * time.Second
+
+type snapshotWatchOptions struct {
+	watch         bool
+	interval      time.Duration
+	healthAddress string
+}
+
+// snapshotFuncs are the environment specific parts of taking and reporting a snapshot
+type snapshotFuncs struct {
+	// collect returns the payload to report and the artifacts slice in it.
+	// The artifacts are used to detect if the snapshot changed.
+	collect func() (payload interface{}, artifacts interface{}, err error)
+	// report sends the payload to Kosli
+	report func(payload interface{}) error
+	// watchChanges, if set, calls onChange whenever the environment might have changed
+	// until the context is cancelled
+	watchChanges func(ctx context.Context, onChange func()) error
+	// checked, if set, is called with the result of every check in watch mode
+	checked func(result snapshotCheckResult)
+}
+
+// snapshotCheckResult is the result of checking an environment in watch mode
+type snapshotCheckResult struct {
+	// Artifacts is the number of artifacts in the snapshot
+	Artifacts int
+	// Reported is true when the snapshot changed and was reported
+	Reported bool
+	Err      error
+}
+
+func addSnapshotWatchFlags(cmd *cobra.Command, o *snapshotWatchOptions) {
+	cmd.Flags().BoolVar(&o.watch, "watch", false, snapshotWatchFlag)
+	cmd.Flags().DurationVar(&o.interval, "watch-interval", time.Minute, snapshotWatchIntervalFlag)
+	cmd.Flags().StringVar(&o.healthAddress, "health-address", "", snapshotHealthAddressFlag)
+}
+
+// validateSnapshotWatchFlags checks the watch flags are only used in watch mode
+func validateSnapshotWatchFlags(cmd *cobra.Command, o *snapshotWatchOptions) error {
+	if !o.watch {
+		for _, name := range []string{"watch-interval", "health-address"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s is only allowed with --watch", name)
+			}
+		}
+		return nil
+	}
+	if o.interval <= 0 {
+		return fmt.Errorf("--watch-interval must be positive")
+	}
+	return nil
+}
+
+// run takes and reports a snapshot once, or keeps watching the environment in watch mode
+func (o *snapshotWatchOptions) run(envName string, funcs snapshotFuncs) error {
+	if !o.watch {
+		payload, _, err := funcs.collect()
+		if err != nil {
+			return err
+		}
+		return funcs.report(payload)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return newSnapshotWatcher(envName, o.interval, funcs).run(ctx, o.healthAddress)
+}
+
+type snapshotWatcher struct {
+	envName  string
+	interval time.Duration
+	funcs    snapshotFuncs
+	// lastReported is the digest of the artifacts in the last reported snapshot
+	lastReported string
+	health       *snapshotWatchHealth
+}
+
+func newSnapshotWatcher(envName string, interval time.Duration, funcs snapshotFuncs) *snapshotWatcher {
+	return &snapshotWatcher{
+		envName:  envName,
+		interval: interval,
+		funcs:    funcs,
+		health:   &snapshotWatchHealth{staleAfter: 3 * interval},
+	}
+}
+
+// run checks the environment until the context is cancelled
+func (w *snapshotWatcher) run(ctx context.Context, healthAddress string) error {
+	if healthAddress != "" {
+		server := &http.Server{Addr: healthAddress, Handler: w.health.handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Warning("health endpoint stopped: %v", err)
+			}
+		}()
+		defer server.Close()
+		logger.Info("serving health endpoint on %s/healthz", healthAddress)
+	}
+
+	changes := make(chan struct{}, 1)
+	if w.funcs.watchChanges != nil {
+		err := w.funcs.watchChanges(ctx, func() {
+			// non-blocking, one pending change is enough to trigger a check
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Info("watching environment %s", w.envName)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	w.check()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("stopped watching environment %s", w.envName)
+			return nil
+		case <-ticker.C:
+		case <-changes:
+			select {
+			case <-ctx.Done():
+				continue
+			case <-time.After(snapshotWatchDebounce):
+			}
+		}
+		w.check()
+	}
+}
+
+// check takes a snapshot and reports it if the artifacts changed since the last report
+func (w *snapshotWatcher) check() {
+	result := w.checkSnapshot()
+	w.health.update(result.Err, result.Reported)
+	if w.funcs.checked != nil {
+		w.funcs.checked(result)
+	}
+}
+
+func (w *snapshotWatcher) checkSnapshot() snapshotCheckResult {
+	payload, artifacts, err := w.funcs.collect()
+	if err != nil {
+		logger.Warning("failed to take a snapshot of environment %s: %v", w.envName, err)
+		return snapshotCheckResult{Err: err}
+	}
+	digest, err := artifactsDigest(artifacts)
+	if err != nil {
+		logger.Warning("failed to compare the snapshot of environment %s: %v", w.envName, err)
+		return snapshotCheckResult{Err: err}
+	}
+	count := reflect.ValueOf(artifacts).Len()
+	if digest == w.lastReported {
+		logger.Debug("no changes in environment %s", w.envName)
+		return snapshotCheckResult{Artifacts: count}
+	}
+	err = w.funcs.report(payload)
+	if err != nil {
+		logger.Warning("failed to report a snapshot of environment %s: %v", w.envName, err)
+		return snapshotCheckResult{Artifacts: count, Err: err}
+	}
+	w.lastReported = digest
+	return snapshotCheckResult{Artifacts: count, Reported: true}
+}
+
+// artifactsDigest returns a digest of a slice of artifacts that does not depend on their order
+func artifactsDigest(artifacts interface{}) (string, error) {
+	value := reflect.ValueOf(artifacts)
+	if value.Kind() != reflect.Slice {
+		return "", fmt.Errorf("artifacts must be a slice, got %T", artifacts)
+	}
+	items := make([]string, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		content, err := json.Marshal(value.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		items[i] = string(content)
+	}
+	sort.Strings(items)
+	hash := sha256.New()
+	for _, item := range items {
+		hash.Write([]byte(item))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// snapshotWatchHealth is the state served by the health endpoint
+type snapshotWatchHealth struct {
+	mu         sync.Mutex
+	staleAfter time.Duration
+	lastCheck  time.Time
+	lastReport time.Time
+	lastError  string
+	reports    int
+}
+
+type snapshotWatchHealthStatus struct {
+	Status     string `json:"status"`
+	LastCheck  string `json:"last_check,omitempty"`
+	LastReport string `json:"last_report,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+	Reports    int    `json:"reports"`
+}
+
+func (h *snapshotWatchHealth) update(err error, reported bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCheck = time.Now()
+	h.lastError = ""
+	if err != nil {
+		h.lastError = err.Error()
+	}
+	if reported {
+		h.lastReport = h.lastCheck
+		h.reports++
+	}
+}
+
+// status returns the health status and if it is healthy. It is unhealthy before the first
+// check, when the last check failed, or when no check happened for a while.
+func (h *snapshotWatchHealth) status() (snapshotWatchHealthStatus, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := snapshotWatchHealthStatus{LastError: h.lastError, Reports: h.reports}
+	if !h.lastCheck.IsZero() {
+		status.LastCheck = h.lastCheck.UTC().Format(time.RFC3339)
+	}
+	if !h.lastReport.IsZero() {
+		status.LastReport = h.lastReport.UTC().Format(time.RFC3339)
+	}
+	switch {
+	case h.lastCheck.IsZero():
+		status.Status = "starting"
+	case h.lastError != "":
+		status.Status = "failing"
+	case time.Since(h.lastCheck) > h.staleAfter:
+		status.Status = "stale"
+	default:
+		status.Status = "ok"
+		return status, true
+	}
+	return status, false
+}
+
+func (h *snapshotWatchHealth) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status, healthy := h.status()
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	return mux
+}
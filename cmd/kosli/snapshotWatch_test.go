@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type SnapshotWatchTestSuite struct {
+	suite.Suite
+}
+
+type watchTestArtifact struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+func (suite *SnapshotWatchTestSuite) TestArtifactsDigestDoesNotDependOnOrder() {
+	first, err := artifactsDigest([]*watchTestArtifact{{"a", "1"}, {"b", "2"}})
+	require.NoError(suite.T(), err)
+	second, err := artifactsDigest([]*watchTestArtifact{{"b", "2"}, {"a", "1"}})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), first, second)
+
+	changed, err := artifactsDigest([]*watchTestArtifact{{"a", "1"}, {"b", "3"}})
+	require.NoError(suite.T(), err)
+	require.NotEqual(suite.T(), first, changed)
+
+	_, err = artifactsDigest("not a slice")
+	require.Error(suite.T(), err)
+}
+
+func (suite *SnapshotWatchTestSuite) TestCheckOnlyReportsChanges() {
+	snapshots := [][]*watchTestArtifact{
+		{{"a", "1"}, {"b", "2"}},
+		{{"b", "2"}, {"a", "1"}},
+		{{"a", "1"}, {"b", "3"}},
+		{{"a", "1"}, {"b", "3"}},
+	}
+	var (
+		collected  int
+		reported   [][]*watchTestArtifact
+		collectErr error
+		reportErr  error
+	)
+	watcher := newSnapshotWatcher("test-env", time.Minute, snapshotFuncs{
+		collect: func() (interface{}, interface{}, error) {
+			if collectErr != nil {
+				return nil, nil, collectErr
+			}
+			artifacts := snapshots[collected]
+			collected++
+			return artifacts, artifacts, nil
+		},
+		report: func(payload interface{}) error {
+			if reportErr != nil {
+				return reportErr
+			}
+			reported = append(reported, payload.([]*watchTestArtifact))
+			return nil
+		},
+	})
+
+	status, healthy := watcher.health.status()
+	require.False(suite.T(), healthy)
+	require.Equal(suite.T(), "starting", status.Status)
+
+	// the first snapshot is always reported
+	watcher.check()
+	require.Len(suite.T(), reported, 1)
+	// the same artifacts in a different order are not reported
+	watcher.check()
+	require.Len(suite.T(), reported, 1)
+
+	// a failed report is retried on the next check
+	reportErr = errors.New("host unreachable")
+	watcher.check()
+	require.Len(suite.T(), reported, 1)
+	status, healthy = watcher.health.status()
+	require.False(suite.T(), healthy)
+	require.Equal(suite.T(), "failing", status.Status)
+	require.Equal(suite.T(), "host unreachable", status.LastError)
+
+	reportErr = nil
+	watcher.check()
+	require.Len(suite.T(), reported, 2)
+	require.Equal(suite.T(), snapshots[3], reported[1])
+	status, healthy = watcher.health.status()
+	require.True(suite.T(), healthy)
+	require.Equal(suite.T(), 2, status.Reports)
+
+	// a failed snapshot makes the watcher unhealthy without reporting
+	collectErr = errors.New("cluster unreachable")
+	watcher.check()
+	require.Len(suite.T(), reported, 2)
+	_, healthy = watcher.health.status()
+	require.False(suite.T(), healthy)
+}
+
+func (suite *SnapshotWatchTestSuite) TestHealthEndpoint() {
+	health := &snapshotWatchHealth{staleAfter: time.Minute}
+	server := httptest.NewServer(health.handler())
+	defer server.Close()
+
+	getStatus := func() (int, snapshotWatchHealthStatus) {
+		resp, err := http.Get(server.URL + "/healthz")
+		require.NoError(suite.T(), err)
+		defer resp.Body.Close()
+		status := snapshotWatchHealthStatus{}
+		require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&status))
+		return resp.StatusCode, status
+	}
+
+	code, status := getStatus()
+	require.Equal(suite.T(), http.StatusServiceUnavailable, code)
+	require.Equal(suite.T(), "starting", status.Status)
+
+	health.update(nil, true)
+	code, status = getStatus()
+	require.Equal(suite.T(), http.StatusOK, code)
+	require.Equal(suite.T(), "ok", status.Status)
+	require.Equal(suite.T(), 1, status.Reports)
+	require.NotEmpty(suite.T(), status.LastReport)
+
+	health.lastCheck = time.Now().Add(-2 * time.Minute)
+	code, status = getStatus()
+	require.Equal(suite.T(), http.StatusServiceUnavailable, code)
+	require.Equal(suite.T(), "stale", status.Status)
+}
+
+func (suite *SnapshotWatchTestSuite) TestSnapshotWatchFlags() {
+	tests := []cmdTestCase{
+		{
+			wantError: true,
+			name:      "--watch-interval is only allowed with --watch",
+			cmd:       "snapshot docker foo --watch-interval 10s --org foo --api-token secret",
+			golden:    "Error: --watch-interval is only allowed with --watch\n",
+		},
+		{
+			wantError: true,
+			name:      "--health-address is only allowed with --watch",
+			cmd:       "snapshot ecs foo --health-address :8080 --org foo --api-token secret",
+			golden:    "Error: --health-address is only allowed with --watch\n",
+		},
+		{
+			wantError: true,
+			name:      "--watch-interval must be positive",
+			cmd:       "snapshot lambda foo --watch --watch-interval 0s --org foo --api-token secret",
+			golden:    "Error: --watch-interval must be positive\n",
+		},
+		{
+			wantError: true,
+			name:      "--watch can't be used with --manifests",
+			cmd:       "snapshot k8s foo --watch --manifests pods.json --org foo --api-token secret",
+			golden:    "Error: only one of --manifests, --watch is allowed\n",
+		},
+	}
+
+	runTestCmd(suite.T(), tests)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSnapshotWatchTestSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotWatchTestSuite))
+}
@@ -0,0 +1,192 @@
+package aws
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/kosli-dev/cli/internal/digest"
+	"github.com/kosli-dev/cli/internal/logger"
+)
+
+const (
+	// DefaultAmiFingerprintTag is the AMI tag holding the fingerprint of the AMI when none is configured
+	DefaultAmiFingerprintTag = "kosli_fingerprint"
+	// autoScalingGroupTag is the tag AWS sets on instances launched by an Auto Scaling group
+	autoScalingGroupTag = "aws:autoscaling:groupName"
+	// launchTemplateIdTag and launchTemplateVersionTag are the tags AWS sets on instances
+	// launched from a launch template
+	launchTemplateIdTag      = "aws:ec2launchtemplate:id"
+	launchTemplateVersionTag = "aws:ec2launchtemplate:version"
+	// describeImagesBatchSize is the number of AMIs described in one request
+	describeImagesBatchSize = 100
+)
+
+// Ec2EnvRequest represents the PUT request body to be sent to kosli from EC2
+type Ec2EnvRequest struct {
+	Artifacts []*Ec2InstanceData `json:"artifacts"`
+}
+
+// Ec2InstanceData represents the harvested data of a running EC2 instance
+type Ec2InstanceData struct {
+	InstanceId            string            `json:"instanceId"`
+	ImageId               string            `json:"imageId"`
+	AutoScalingGroup      string            `json:"autoScalingGroup,omitempty"`
+	LaunchTemplateId      string            `json:"launchTemplateId,omitempty"`
+	LaunchTemplateVersion string            `json:"launchTemplateVersion,omitempty"`
+	Digests               map[string]string `json:"digests"`
+	LaunchTime            int64             `json:"creationTimestamp"`
+}
+
+// Ec2FilterOptions selects the EC2 instances to report
+type Ec2FilterOptions struct {
+	// Tags the instances must have, with their values
+	Tags map[string]string
+	// AutoScalingGroups the instances must belong to one of
+	AutoScalingGroups []string
+	// FingerprintTag is the AMI tag holding the fingerprint of the AMI
+	FingerprintTag string
+}
+
+// ec2DescribeAPI is the part of the EC2 API used to snapshot instances
+type ec2DescribeAPI interface {
+	ec2.DescribeInstancesAPIClient
+	DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+}
+
+// NewEC2Client returns a new EC2 API client
+func (staticCreds *AWSStaticCreds) NewEC2Client() (*ec2.Client, error) {
+	cfg, err := staticCreds.NewAWSConfigFromEnvOrFlags()
+	if err != nil {
+		return nil, err
+	}
+	return ec2.NewFromConfig(cfg), nil
+}
+
+// GetEc2InstancesData returns the data of the running EC2 instances selected by the filter.
+// Instances whose AMI does not have the fingerprint tag are not reported.
+func (staticCreds *AWSStaticCreds) GetEc2InstancesData(filter *Ec2FilterOptions, logger *logger.Logger) ([]*Ec2InstanceData, error) {
+	client, err := staticCreds.NewEC2Client()
+	if err != nil {
+		return nil, err
+	}
+	return getEc2InstancesData(client, filter, logger)
+}
+
+func getEc2InstancesData(client ec2DescribeAPI, filter *Ec2FilterOptions, logger *logger.Logger) ([]*Ec2InstanceData, error) {
+	instances, err := getRunningInstances(client, filter)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("found %d running EC2 instances", len(instances))
+
+	imageIds := []string{}
+	for _, instance := range instances {
+		imageId := aws.ToString(instance.ImageId)
+		if !slices.Contains(imageIds, imageId) {
+			imageIds = append(imageIds, imageId)
+		}
+	}
+	fingerprints, err := getAmiFingerprints(client, imageIds, filter.FingerprintTag, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	instancesData := []*Ec2InstanceData{}
+	for _, instance := range instances {
+		imageId := aws.ToString(instance.ImageId)
+		fingerprint, ok := fingerprints[imageId]
+		if !ok {
+			logger.Warning("AMI %s of EC2 instance %s has no %s tag and is not reported", imageId, aws.ToString(instance.InstanceId), filter.FingerprintTag)
+			continue
+		}
+		instancesData = append(instancesData, newEc2InstanceData(instance, fingerprint))
+	}
+	return instancesData, nil
+}
+
+// getRunningInstances returns the running instances with the filter tags and auto scaling groups
+func getRunningInstances(client ec2.DescribeInstancesAPIClient, filter *Ec2FilterOptions) ([]ec2Types.Instance, error) {
+	ec2Filters := []ec2Types.Filter{
+		{Name: aws.String("instance-state-name"), Values: []string{"running"}},
+	}
+	// sorted, to send the same request every time
+	tagKeys := make([]string, 0, len(filter.Tags))
+	for key := range filter.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	for _, key := range tagKeys {
+		ec2Filters = append(ec2Filters, ec2Types.Filter{Name: aws.String("tag:" + key), Values: []string{filter.Tags[key]}})
+	}
+	if len(filter.AutoScalingGroups) > 0 {
+		ec2Filters = append(ec2Filters, ec2Types.Filter{Name: aws.String("tag:" + autoScalingGroupTag), Values: filter.AutoScalingGroups})
+	}
+
+	instances := []ec2Types.Instance{}
+	paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{Filters: ec2Filters})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, reservation := range output.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
+	}
+	return instances, nil
+}
+
+// getAmiFingerprints returns the fingerprints in the fingerprint tag of AMIs, by AMI ID.
+// AMIs without the tag (or that are no longer available) are not included.
+func getAmiFingerprints(client ec2DescribeAPI, imageIds []string, fingerprintTag string, logger *logger.Logger) (map[string]string, error) {
+	fingerprints := map[string]string{}
+	for start := 0; start < len(imageIds); start += describeImagesBatchSize {
+		end := min(start+describeImagesBatchSize, len(imageIds))
+		output, err := client.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{ImageIds: imageIds[start:end]})
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range output.Images {
+			for _, tag := range image.Tags {
+				if aws.ToString(tag.Key) != fingerprintTag {
+					continue
+				}
+				fingerprint := strings.ToLower(strings.TrimPrefix(aws.ToString(tag.Value), "sha256:"))
+				if err := digest.ValidateDigest(fingerprint); err != nil {
+					logger.Warning("the %s tag of AMI %s is invalid: %v", fingerprintTag, aws.ToString(image.ImageId), err)
+					break
+				}
+				fingerprints[aws.ToString(image.ImageId)] = fingerprint
+			}
+		}
+	}
+	return fingerprints, nil
+}
+
+// newEc2InstanceData creates an Ec2InstanceData from an instance and the fingerprint of its AMI
+func newEc2InstanceData(instance ec2Types.Instance, fingerprint string) *Ec2InstanceData {
+	data := &Ec2InstanceData{
+		InstanceId: aws.ToString(instance.InstanceId),
+		ImageId:    aws.ToString(instance.ImageId),
+		Digests:    map[string]string{aws.ToString(instance.ImageId): fingerprint},
+	}
+	if instance.LaunchTime != nil {
+		data.LaunchTime = instance.LaunchTime.Unix()
+	}
+	for _, tag := range instance.Tags {
+		switch aws.ToString(tag.Key) {
+		case autoScalingGroupTag:
+			data.AutoScalingGroup = aws.ToString(tag.Value)
+		case launchTemplateIdTag:
+			data.LaunchTemplateId = aws.ToString(tag.Value)
+		case launchTemplateVersionTag:
+			data.LaunchTemplateVersion = aws.ToString(tag.Value)
+		}
+	}
+	return data
+}
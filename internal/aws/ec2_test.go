@@ -0,0 +1,212 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeEC2Client serves instances and images from memory. It supports the tag
+// and instance state filters and returns one reservation per page.
+type fakeEC2Client struct {
+	instances []ec2Types.Instance
+	images    []ec2Types.Image
+	requests  []*ec2.DescribeInstancesInput
+}
+
+func (c *fakeEC2Client) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	c.requests = append(c.requests, params)
+	matching := []ec2Types.Instance{}
+	for _, instance := range c.instances {
+		if fakeEC2InstanceMatches(instance, params.Filters) {
+			matching = append(matching, instance)
+		}
+	}
+	start := 0
+	if params.NextToken != nil {
+		fmt.Sscanf(*params.NextToken, "%d", &start)
+	}
+	output := &ec2.DescribeInstancesOutput{}
+	if start < len(matching) {
+		output.Reservations = []ec2Types.Reservation{{Instances: matching[start : start+1]}}
+	}
+	if start+1 < len(matching) {
+		output.NextToken = aws.String(fmt.Sprint(start + 1))
+	}
+	return output, nil
+}
+
+func (c *fakeEC2Client) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	output := &ec2.DescribeImagesOutput{}
+	for _, image := range c.images {
+		if slices.Contains(params.ImageIds, *image.ImageId) {
+			output.Images = append(output.Images, image)
+		}
+	}
+	return output, nil
+}
+
+func fakeEC2InstanceMatches(instance ec2Types.Instance, filters []ec2Types.Filter) bool {
+	for _, filter := range filters {
+		name := aws.ToString(filter.Name)
+		if name == "instance-state-name" {
+			if !slices.Contains(filter.Values, string(instance.State.Name)) {
+				return false
+			}
+			continue
+		}
+		key := strings.TrimPrefix(name, "tag:")
+		found := false
+		for _, tag := range instance.Tags {
+			if aws.ToString(tag.Key) == key && slices.Contains(filter.Values, aws.ToString(tag.Value)) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func fakeEC2Instance(id, imageId string, state ec2Types.InstanceStateName, tags map[string]string) ec2Types.Instance {
+	instance := ec2Types.Instance{
+		InstanceId: aws.String(id),
+		ImageId:    aws.String(imageId),
+		LaunchTime: aws.Time(time.Date(2024, 10, 1, 10, 0, 0, 0, time.UTC)),
+		State:      &ec2Types.InstanceState{Name: state},
+	}
+	for key, value := range tags {
+		instance.Tags = append(instance.Tags, ec2Types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return instance
+}
+
+func fakeEC2Image(id string, tags map[string]string) ec2Types.Image {
+	image := ec2Types.Image{ImageId: aws.String(id)}
+	for key, value := range tags {
+		image.Tags = append(image.Tags, ec2Types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return image
+}
+
+type EC2TestSuite struct {
+	suite.Suite
+	client *fakeEC2Client
+}
+
+func (suite *EC2TestSuite) SetupTest() {
+	asgTags := map[string]string{
+		"env":                           "prod",
+		"aws:autoscaling:groupName":     "web-asg",
+		"aws:ec2launchtemplate:id":      "lt-0123",
+		"aws:ec2launchtemplate:version": "7",
+	}
+	suite.client = &fakeEC2Client{
+		instances: []ec2Types.Instance{
+			fakeEC2Instance("i-web-1", "ami-web", ec2Types.InstanceStateNameRunning, asgTags),
+			fakeEC2Instance("i-web-2", "ami-web", ec2Types.InstanceStateNameRunning, asgTags),
+			fakeEC2Instance("i-web-old", "ami-web-old", ec2Types.InstanceStateNameTerminated, asgTags),
+			fakeEC2Instance("i-worker", "ami-worker", ec2Types.InstanceStateNameRunning, map[string]string{"env": "prod"}),
+			fakeEC2Instance("i-bastion", "ami-bastion", ec2Types.InstanceStateNameRunning, map[string]string{"env": "prod"}),
+			fakeEC2Instance("i-dev", "ami-web", ec2Types.InstanceStateNameRunning, map[string]string{"env": "dev"}),
+		},
+		images: []ec2Types.Image{
+			fakeEC2Image("ami-web", map[string]string{"kosli_fingerprint": strings.Repeat("a", 64), "packer": "true"}),
+			fakeEC2Image("ami-worker", map[string]string{"kosli_fingerprint": "sha256:" + strings.Repeat("B", 64), "sha": strings.Repeat("c", 64)}),
+			fakeEC2Image("ami-bastion", map[string]string{}),
+		},
+	}
+}
+
+func (suite *EC2TestSuite) TestGetEc2InstancesData() {
+	launchTime := time.Date(2024, 10, 1, 10, 0, 0, 0, time.UTC).Unix()
+	web := func(id string) *Ec2InstanceData {
+		return &Ec2InstanceData{InstanceId: id, ImageId: "ami-web", AutoScalingGroup: "web-asg", LaunchTemplateId: "lt-0123",
+			LaunchTemplateVersion: "7", Digests: map[string]string{"ami-web": strings.Repeat("a", 64)}, LaunchTime: launchTime}
+	}
+	worker := &Ec2InstanceData{InstanceId: "i-worker", ImageId: "ami-worker",
+		Digests: map[string]string{"ami-worker": strings.Repeat("b", 64)}, LaunchTime: launchTime}
+	dev := &Ec2InstanceData{InstanceId: "i-dev", ImageId: "ami-web",
+		Digests: map[string]string{"ami-web": strings.Repeat("a", 64)}, LaunchTime: launchTime}
+
+	for _, t := range []struct {
+		name         string
+		filter       *Ec2FilterOptions
+		want         []*Ec2InstanceData
+		wantWarnings []string
+	}{
+		{
+			name:   "all running instances with a fingerprinted AMI are reported",
+			filter: &Ec2FilterOptions{FingerprintTag: DefaultAmiFingerprintTag},
+			want:   []*Ec2InstanceData{web("i-web-1"), web("i-web-2"), worker, dev},
+			wantWarnings: []string{
+				"[warning] AMI ami-bastion of EC2 instance i-bastion has no kosli_fingerprint tag and is not reported",
+			},
+		},
+		{
+			name:   "instances can be filtered by tags",
+			filter: &Ec2FilterOptions{FingerprintTag: DefaultAmiFingerprintTag, Tags: map[string]string{"env": "dev"}},
+			want:   []*Ec2InstanceData{dev},
+		},
+		{
+			name:   "instances can be filtered by auto scaling group",
+			filter: &Ec2FilterOptions{FingerprintTag: DefaultAmiFingerprintTag, AutoScalingGroups: []string{"web-asg", "api-asg"}},
+			want:   []*Ec2InstanceData{web("i-web-1"), web("i-web-2")},
+		},
+		{
+			name:   "the fingerprint tag is configurable and must be a valid fingerprint",
+			filter: &Ec2FilterOptions{FingerprintTag: "packer", Tags: map[string]string{"env": "prod"}},
+			want:   []*Ec2InstanceData{},
+			wantWarnings: []string{
+				"[warning] the packer tag of AMI ami-web is invalid: true is not a valid SHA256 fingerprint.",
+				"[warning] AMI ami-web of EC2 instance i-web-1 has no packer tag and is not reported",
+				"[warning] AMI ami-worker of EC2 instance i-worker has no packer tag and is not reported",
+			},
+		},
+	} {
+		suite.Run(t.name, func() {
+			var out bytes.Buffer
+			data, err := getEc2InstancesData(suite.client, t.filter, logger.NewLogger(&out, &out, false))
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.want, data)
+			for _, warning := range t.wantWarnings {
+				require.Contains(suite.T(), out.String(), warning)
+			}
+			if len(t.wantWarnings) == 0 {
+				require.Empty(suite.T(), out.String())
+			}
+		})
+	}
+}
+
+func (suite *EC2TestSuite) TestGetRunningInstancesFilters() {
+	_, err := getRunningInstances(suite.client, &Ec2FilterOptions{
+		Tags:              map[string]string{"team": "web", "env": "prod"},
+		AutoScalingGroups: []string{"web-asg"},
+	})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), []ec2Types.Filter{
+		{Name: aws.String("instance-state-name"), Values: []string{"running"}},
+		{Name: aws.String("tag:env"), Values: []string{"prod"}},
+		{Name: aws.String("tag:team"), Values: []string{"web"}},
+		{Name: aws.String("tag:aws:autoscaling:groupName"), Values: []string{"web-asg"}},
+	}, suite.client.requests[0].Filters)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestEC2TestSuite(t *testing.T) {
+	suite.Run(t, new(EC2TestSuite))
+}
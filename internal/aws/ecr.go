@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// ecrRegistryPattern matches the host of a private ECR registry and captures its account and region
+var ecrRegistryPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// ecrAuthorizationAPI is the part of the ECR API used to authenticate to a registry
+type ecrAuthorizationAPI interface {
+	GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+}
+
+// IsECRRegistry returns true if the registry host is a private ECR registry
+func IsECRRegistry(registryHost string) bool {
+	return ecrRegistryPattern.MatchString(registryHost)
+}
+
+// ECRCredentials returns the username and password to read images from a private ECR registry,
+// exchanging the AWS credentials for an ECR authorization token of the registry region
+func (staticCreds *AWSStaticCreds) ECRCredentials(registryHost string) (string, string, error) {
+	match := ecrRegistryPattern.FindStringSubmatch(registryHost)
+	if match == nil {
+		return "", "", fmt.Errorf("%s is not an ECR registry", registryHost)
+	}
+	regionCreds := *staticCreds
+	regionCreds.Region = match[2]
+	cfg, err := regionCreds.NewAWSConfigFromEnvOrFlags()
+	if err != nil {
+		return "", "", err
+	}
+	return ecrCredentials(ecr.NewFromConfig(cfg), match[1])
+}
+
+// ecrCredentials returns the username and password in the ECR authorization token of an account
+func ecrCredentials(client ecrAuthorizationAPI, accountID string) (string, string, error) {
+	output, err := client.GetAuthorizationToken(context.Background(), &ecr.GetAuthorizationTokenInput{
+		RegistryIds: []string{accountID},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get ECR authorization token: %v", err)
+	}
+	if len(output.AuthorizationData) == 0 || output.AuthorizationData[0].AuthorizationToken == nil {
+		return "", "", fmt.Errorf("no ECR authorization token was returned for account %s", accountID)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(*output.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid ECR authorization token: %v", err)
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid ECR authorization token: expected username:password")
+	}
+	return username, password, nil
+}
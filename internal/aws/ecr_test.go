@@ -0,0 +1,72 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeECRClient returns the authorization tokens of accounts from memory
+type fakeECRClient struct {
+	tokens map[string]string
+}
+
+func (c *fakeECRClient) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	output := &ecr.GetAuthorizationTokenOutput{}
+	if token, ok := c.tokens[params.RegistryIds[0]]; ok {
+		output.AuthorizationData = []ecrTypes.AuthorizationData{{AuthorizationToken: aws.String(token)}}
+	}
+	return output, nil
+}
+
+type ECRTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ECRTestSuite) TestIsECRRegistry() {
+	for host, want := range map[string]bool{
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com":      true,
+		"123456789012.dkr.ecr-fips.us-east-1.amazonaws.com": true,
+		"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn":  true,
+		"public.ecr.aws":                  false,
+		"dkr.ecr.eu-west-1.amazonaws.com": false,
+		"docker.io":                       false,
+	} {
+		require.Equal(suite.T(), want, IsECRRegistry(host), host)
+	}
+}
+
+func (suite *ECRTestSuite) TestECRCredentials() {
+	client := &fakeECRClient{tokens: map[string]string{
+		"123456789012": base64.StdEncoding.EncodeToString([]byte("AWS:ecr-password")),
+		"210987654321": "not base64",
+	}}
+
+	username, password, err := ecrCredentials(client, "123456789012")
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "AWS", username)
+	require.Equal(suite.T(), "ecr-password", password)
+
+	_, _, err = ecrCredentials(client, "210987654321")
+	require.ErrorContains(suite.T(), err, "invalid ECR authorization token")
+
+	_, _, err = ecrCredentials(client, "000000000000")
+	require.EqualError(suite.T(), err, "no ECR authorization token was returned for account 000000000000")
+}
+
+func (suite *ECRTestSuite) TestECRCredentialsFailsForOtherRegistries() {
+	_, _, err := new(AWSStaticCreds).ECRCredentials("docker.io")
+	require.EqualError(suite.T(), err, "docker.io is not an ECR registry")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestECRTestSuite(t *testing.T) {
+	suite.Run(t, new(ECRTestSuite))
+}
@@ -0,0 +1,85 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+const (
+	// acrRegistrySuffix is the host suffix of Azure container registries
+	acrRegistrySuffix = ".azurecr.io"
+	// acrTokenUsername is the username used with ACR refresh tokens
+	acrTokenUsername = "00000000-0000-0000-0000-000000000000"
+	// azureManagementScope is the scope of the Azure AD token exchanged for an ACR refresh token
+	azureManagementScope = "https://management.azure.com/.default"
+)
+
+// IsACRRegistry returns true if the registry host is an Azure container registry
+func IsACRRegistry(registryHost string) bool {
+	return strings.HasSuffix(registryHost, acrRegistrySuffix)
+}
+
+// ACRCredentials returns the username and password to read images from an Azure container registry,
+// exchanging an Azure AD token for an ACR refresh token. The service principal credentials are used
+// if provided, otherwise the default Azure credentials of the environment (e.g. a managed identity).
+func (staticCreds *AzureStaticCredentials) ACRCredentials(registryHost string) (string, string, error) {
+	var (
+		credential azcore.TokenCredential
+		err        error
+	)
+	if staticCreds.ClientId != "" && staticCreds.ClientSecret != "" {
+		credential, err = azidentity.NewClientSecretCredential(staticCreds.TenantId, staticCreds.ClientId, staticCreds.ClientSecret, nil)
+	} else {
+		credential, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	token, err := credential.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{azureManagementScope}})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get Azure AD token: %v", err)
+	}
+	refreshToken, err := exchangeACRRefreshToken(http.DefaultClient, "https://"+registryHost, registryHost, staticCreds.TenantId, token.Token)
+	if err != nil {
+		return "", "", err
+	}
+	return acrTokenUsername, refreshToken, nil
+}
+
+// exchangeACRRefreshToken exchanges an Azure AD access token for an ACR refresh token
+func exchangeACRRefreshToken(client *http.Client, endpoint, registryHost, tenantId, accessToken string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {registryHost},
+		"access_token": {accessToken},
+	}
+	if tenantId != "" {
+		form.Set("tenant", tenantId)
+	}
+	response, err := client.PostForm(endpoint+"/oauth2/exchange", form)
+	if err != nil {
+		return "", fmt.Errorf("failed to get ACR refresh token: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get ACR refresh token for %s: %s", registryHost, response.Status)
+	}
+	var result struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to get ACR refresh token: %v", err)
+	}
+	if result.RefreshToken == "" {
+		return "", fmt.Errorf("no ACR refresh token was returned for %s", registryHost)
+	}
+	return result.RefreshToken, nil
+}
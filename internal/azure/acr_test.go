@@ -0,0 +1,51 @@
+package azure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// All methods that begin with "Test" are run as tests within a
+// suite.
+type ACRTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ACRTestSuite) TestIsACRRegistry() {
+	require.True(suite.T(), IsACRRegistry("myregistry.azurecr.io"))
+	require.False(suite.T(), IsACRRegistry("azurecr.io.example.com"))
+	require.False(suite.T(), IsACRRegistry("docker.io"))
+}
+
+func (suite *ACRTestSuite) TestExchangeACRRefreshToken() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(suite.T(), "/oauth2/exchange", r.URL.Path)
+		require.NoError(suite.T(), r.ParseForm())
+		if r.PostForm.Get("access_token") != "aad-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.Equal(suite.T(), "access_token", r.PostForm.Get("grant_type"))
+		require.Equal(suite.T(), "myregistry.azurecr.io", r.PostForm.Get("service"))
+		require.Equal(suite.T(), "tenant-id", r.PostForm.Get("tenant"))
+		_, _ = w.Write([]byte(`{"refresh_token": "acr-refresh-token"}`))
+	}))
+	defer server.Close()
+
+	refreshToken, err := exchangeACRRefreshToken(server.Client(), server.URL, "myregistry.azurecr.io", "tenant-id", "aad-token")
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "acr-refresh-token", refreshToken)
+
+	_, err = exchangeACRRefreshToken(server.Client(), server.URL, "myregistry.azurecr.io", "tenant-id", "wrong-token")
+	require.EqualError(suite.T(), err, "failed to get ACR refresh token for myregistry.azurecr.io: 401 Unauthorized")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestACRTestSuite(t *testing.T) {
+	suite.Run(t, new(ACRTestSuite))
+}
@@ -2,13 +2,19 @@ package azure
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/kosli-dev/cli/internal/ratelimit"
 	"github.com/kosli-dev/cli/internal/types"
 	"github.com/microsoft/azure-devops-go-api/azuredevops"
 	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
 )
 
 type AzureConfig struct {
@@ -46,7 +52,11 @@ func extractRepoName(fullRepositoryName string) string {
 func NewAzureClientFromToken(ctx context.Context, azToken, orgURL string) (git.Client, error) {
 	// Create a connection to your organization
 	connection := azuredevops.NewPatConnection(orgURL, azToken)
-	gitClient, err := git.NewClient(ctx, connection)
+	var gitClient git.Client
+	err := rateLimited(func() (err error) {
+		gitClient, err = git.NewClient(ctx, connection)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +64,37 @@ func NewAzureClientFromToken(ctx context.Context, azToken, orgURL string) (git.C
 	return gitClient, nil
 }
 
+// rateLimited calls f, and calls it again when Azure DevOps rejects the call because the rate limit is exceeded.
+// The Azure DevOps client does not expose the response headers which tell when the rate limit resets,
+// so ratelimit.DefaultWait is waited between calls.
+func rateLimited(f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if !isRateLimitError(err) {
+			return err
+		}
+		if attempt == ratelimit.MaxAttempts {
+			return fmt.Errorf("Azure DevOps rate limit exceeded after %d attempts: %v", attempt, err)
+		}
+		err = ratelimit.Wait("Azure DevOps", time.Now().Add(ratelimit.DefaultWait))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isRateLimitError returns true if err is an Azure DevOps error with status 429
+func isRateLimitError(err error) bool {
+	var wrappedError azuredevops.WrappedError
+	var wrappedErrorPtr *azuredevops.WrappedError
+	if errors.As(err, &wrappedErrorPtr) && wrappedErrorPtr != nil {
+		wrappedError = *wrappedErrorPtr
+	} else if !errors.As(err, &wrappedError) {
+		return false
+	}
+	return wrappedError.StatusCode != nil && *wrappedError.StatusCode == http.StatusTooManyRequests
+}
+
 func (c *AzureConfig) PREvidenceForCommit(commit string) ([]*types.PREvidence, error) {
 	pullRequestsEvidence := []*types.PREvidence{}
 	prs, err := c.PullRequestsForCommit(commit)
@@ -81,14 +122,130 @@ func (c *AzureConfig) newPRAzureEvidence(pr git.GitPullRequest) (*types.PREviden
 		MergeCommit: *(pr.LastMergeCommit.CommitId),
 		State:       string(*pr.Status),
 	}
+	if pr.CreatedBy != nil {
+		evidence.Author = identityName(*pr.CreatedBy)
+	}
 	approvers, err := c.GetPullRequestApprovers(*pr.PullRequestId)
 	if err != nil {
 		return evidence, err
 	}
 	evidence.Approvers = approvers
+
+	threads, err := c.GetPullRequestThreads(*pr.PullRequestId)
+	if err != nil {
+		return evidence, err
+	}
+	evidence.Reviewers = reviewersFromThreads(threads)
+
+	iterations, err := c.GetPullRequestIterations(*pr.PullRequestId)
+	if err != nil {
+		return evidence, err
+	}
+	headSHA := ""
+	if iteration := lastIteration(iterations); iteration != nil {
+		if iteration.SourceRefCommit != nil && iteration.SourceRefCommit.CommitId != nil {
+			headSHA = *iteration.SourceRefCommit.CommitId
+		}
+		if iteration.CreatedDate != nil {
+			evidence.LastCommitPushedTimestamp = iteration.CreatedDate.Time.Unix()
+		}
+	}
+
+	lastCommit, err := c.GetPullRequestLastCommit(*pr.PullRequestId, headSHA)
+	if err != nil {
+		return evidence, err
+	}
+	if lastCommit != nil {
+		evidence.LastCommit = *lastCommit.CommitId
+		evidence.LastCommitter = committerName(lastCommit.Committer)
+		evidence.LastCommitTimestamp = lastCommit.Committer.Date.Time.Unix()
+	}
+	evidence.CheckFourEyes()
 	return evidence, nil
 }
 
+// reviewersFromThreads returns the reviewers of a pull request from the threads
+// which Azure adds when a reviewer votes on it
+func reviewersFromThreads(threads []git.GitPullRequestCommentThread) []*types.PRReviewer {
+	reviewers := []*types.PRReviewer{}
+	for _, thread := range threads {
+		if threadProperty(thread.Properties, "CodeReviewThreadType") != "VoteUpdate" {
+			continue
+		}
+		if thread.Comments == nil || len(*thread.Comments) == 0 || (*thread.Comments)[0].Author == nil {
+			continue
+		}
+		username := identityName(*(*thread.Comments)[0].Author)
+		if username == "" {
+			continue
+		}
+		reviewer := &types.PRReviewer{
+			Username: username,
+			State:    voteState(threadProperty(thread.Properties, "CodeReviewVoteResult")),
+		}
+		if thread.PublishedDate != nil {
+			reviewer.Timestamp = thread.PublishedDate.Time.Unix()
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	return reviewers
+}
+
+// identityName returns the unique name of an Azure identity, which is the email of users,
+// or its display name if it has no unique name
+func identityName(identity webapi.IdentityRef) string {
+	if identity.UniqueName != nil && *identity.UniqueName != "" {
+		return *identity.UniqueName
+	}
+	if identity.DisplayName != nil {
+		return *identity.DisplayName
+	}
+	return ""
+}
+
+// committerName returns the email of a git committer, which is the unique name of their Azure user,
+// or their git name if the commit has no committer email
+func committerName(committer *git.GitUserDate) string {
+	if committer == nil {
+		return ""
+	}
+	if committer.Email != nil && *committer.Email != "" {
+		return *committer.Email
+	}
+	if committer.Name != nil {
+		return *committer.Name
+	}
+	return ""
+}
+
+// threadProperty returns the value of a property of a thread,
+// e.g. {"CodeReviewThreadType": {"$type": "System.String", "$value": "VoteUpdate"}}
+func threadProperty(properties interface{}, name string) string {
+	propertiesMap, ok := properties.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	property, ok := propertiesMap[name].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, _ := property["$value"].(string)
+	return value
+}
+
+// voteState returns the review state of an Azure vote:
+// 10 - approved, 5 - approved with suggestions, 0 - no vote, -5 - waiting for author, -10 - rejected
+func voteState(vote string) string {
+	switch vote {
+	case "10", "5":
+		return types.ReviewApproved
+	case "-5", "-10":
+		return types.ReviewChangesRequested
+	default:
+		return types.ReviewDismissed
+	}
+}
+
 // PullRequestsForCommit returns a list of pull requests for a specific commit
 func (c *AzureConfig) PullRequestsForCommit(commit string) ([]git.GitPullRequest, error) {
 	ctx := context.Background()
@@ -97,17 +254,21 @@ func (c *AzureConfig) PullRequestsForCommit(commit string) ([]git.GitPullRequest
 		return []git.GitPullRequest{}, err
 	}
 
-	prQuery, err := client.GetPullRequestQuery(ctx, git.GetPullRequestQueryArgs{
-		Queries: &git.GitPullRequestQuery{
-			Queries: &[]git.GitPullRequestQueryInput{
-				{
-					Items: &[]string{commit},
-					Type:  &git.GitPullRequestQueryTypeValues.LastMergeCommit,
+	var prQuery *git.GitPullRequestQuery
+	err = rateLimited(func() (err error) {
+		prQuery, err = client.GetPullRequestQuery(ctx, git.GetPullRequestQueryArgs{
+			Queries: &git.GitPullRequestQuery{
+				Queries: &[]git.GitPullRequestQueryInput{
+					{
+						Items: &[]string{commit},
+						Type:  &git.GitPullRequestQueryTypeValues.LastMergeCommit,
+					},
 				},
 			},
-		},
-		RepositoryId: &c.Repository,
-		Project:      &c.Project,
+			RepositoryId: &c.Repository,
+			Project:      &c.Project,
+		})
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -132,10 +293,14 @@ func (c *AzureConfig) GetPullRequestApprovers(number int) ([]string, error) {
 		return approvers, err
 	}
 
-	reviewers, err := client.GetPullRequestReviewers(ctx, git.GetPullRequestReviewersArgs{
-		RepositoryId:  &c.Repository,
-		PullRequestId: &number,
-		Project:       &c.Project,
+	var reviewers *[]git.IdentityRefWithVote
+	err = rateLimited(func() (err error) {
+		reviewers, err = client.GetPullRequestReviewers(ctx, git.GetPullRequestReviewersArgs{
+			RepositoryId:  &c.Repository,
+			PullRequestId: &number,
+			Project:       &c.Project,
+		})
+		return err
 	})
 	if err != nil {
 		return approvers, err
@@ -148,3 +313,100 @@ func (c *AzureConfig) GetPullRequestApprovers(number int) ([]string, error) {
 	}
 	return approvers, nil
 }
+
+// GetPullRequestThreads returns the comment threads of a given pull request
+func (c *AzureConfig) GetPullRequestThreads(number int) ([]git.GitPullRequestCommentThread, error) {
+	ctx := context.Background()
+	client, err := NewAzureClientFromToken(ctx, c.Token, c.OrgURL)
+	if err != nil {
+		return nil, err
+	}
+	var threads *[]git.GitPullRequestCommentThread
+	err = rateLimited(func() (err error) {
+		threads, err = client.GetThreads(ctx, git.GetThreadsArgs{
+			RepositoryId:  &c.Repository,
+			PullRequestId: &number,
+			Project:       &c.Project,
+		})
+		return err
+	})
+	if err != nil || threads == nil {
+		return nil, err
+	}
+	return *threads, nil
+}
+
+// GetPullRequestIterations returns the iterations of a given pull request.
+// Azure creates an iteration each time commits are pushed to the source branch of a pull request.
+func (c *AzureConfig) GetPullRequestIterations(number int) ([]git.GitPullRequestIteration, error) {
+	ctx := context.Background()
+	client, err := NewAzureClientFromToken(ctx, c.Token, c.OrgURL)
+	if err != nil {
+		return nil, err
+	}
+	var iterations *[]git.GitPullRequestIteration
+	err = rateLimited(func() (err error) {
+		iterations, err = client.GetPullRequestIterations(ctx, git.GetPullRequestIterationsArgs{
+			RepositoryId:  &c.Repository,
+			PullRequestId: &number,
+			Project:       &c.Project,
+		})
+		return err
+	})
+	if err != nil || iterations == nil {
+		return nil, err
+	}
+	return *iterations, nil
+}
+
+// lastIteration returns the latest iteration of a pull request, or nil if it has none
+func lastIteration(iterations []git.GitPullRequestIteration) *git.GitPullRequestIteration {
+	var last *git.GitPullRequestIteration
+	for i, iteration := range iterations {
+		if iteration.Id != nil && (last == nil || *iteration.Id > *last.Id) {
+			last = &iterations[i]
+		}
+	}
+	return last
+}
+
+// GetPullRequestLastCommit returns the head commit of a given pull request, or its most recently
+// committed commit if the head is not known, or nil if it has no commits
+func (c *AzureConfig) GetPullRequestLastCommit(number int, headSHA string) (*git.GitCommitRef, error) {
+	ctx := context.Background()
+	client, err := NewAzureClientFromToken(ctx, c.Token, c.OrgURL)
+	if err != nil {
+		return nil, err
+	}
+	var lastCommit *git.GitCommitRef
+	args := git.GetPullRequestCommitsArgs{
+		RepositoryId:  &c.Repository,
+		PullRequestId: &number,
+		Project:       &c.Project,
+	}
+	for {
+		var commits *git.GetPullRequestCommitsResponseValue
+		err = rateLimited(func() (err error) {
+			commits, err = client.GetPullRequestCommits(ctx, args)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i, commit := range commits.Value {
+			if commit.CommitId == nil || commit.Committer == nil || commit.Committer.Date == nil {
+				continue
+			}
+			if headSHA != "" && *commit.CommitId == headSHA {
+				return &commits.Value[i], nil
+			}
+			if lastCommit == nil || commit.Committer.Date.Time.After(lastCommit.Committer.Date.Time) {
+				lastCommit = &commits.Value[i]
+			}
+		}
+		if commits.ContinuationToken == "" {
+			return lastCommit, nil
+		}
+		args.ContinuationToken = &commits.ContinuationToken
+	}
+}
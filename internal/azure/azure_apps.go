@@ -245,7 +245,7 @@ func (azureClient *AzureClient) fingerprintZipService(app *armappservice.Site, l
 		},
 	}
 
-	artifacts, err := server.CreatePathsArtifactsData(ps, logger)
+	artifacts, err := server.CreatePathsArtifactsData(ps, nil, logger)
 	if err != nil {
 		return AppData{}, err
 	}
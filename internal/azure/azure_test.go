@@ -2,10 +2,17 @@ package azure
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/kosli-dev/cli/internal/ratelimit"
 	"github.com/kosli-dev/cli/internal/testHelpers"
+	"github.com/kosli-dev/cli/internal/types"
+	"github.com/microsoft/azure-devops-go-api/azuredevops"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/git"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/webapi"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -212,6 +219,148 @@ func (suite *AzureTestSuite) TestExtractRepoName() {
 	}
 }
 
+func (suite *AzureTestSuite) TestReviewersFromThreads() {
+	publishedDate := &azuredevops.Time{Time: time.Unix(1700000000, 0)}
+	voteThread := func(author webapi.IdentityRef, vote string) git.GitPullRequestCommentThread {
+		return git.GitPullRequestCommentThread{
+			Comments: &[]git.Comment{{Author: &author}},
+			Properties: map[string]interface{}{
+				"CodeReviewThreadType": map[string]interface{}{"$type": "System.String", "$value": "VoteUpdate"},
+				"CodeReviewVoteResult": map[string]interface{}{"$type": "System.String", "$value": vote},
+			},
+			PublishedDate: publishedDate,
+		}
+	}
+	bob := webapi.IdentityRef{DisplayName: stringPtr("Bob"), UniqueName: stringPtr("bob@example.com")}
+	carol := webapi.IdentityRef{DisplayName: stringPtr("Carol")}
+	reviewers := reviewersFromThreads([]git.GitPullRequestCommentThread{
+		voteThread(bob, "10"),
+		voteThread(carol, "-5"),
+		voteThread(bob, "0"),
+		{Properties: map[string]interface{}{}},
+	})
+	require.Equal(suite.T(), []*types.PRReviewer{
+		{Username: "bob@example.com", State: types.ReviewApproved, Timestamp: 1700000000},
+		{Username: "Carol", State: types.ReviewChangesRequested, Timestamp: 1700000000},
+		{Username: "bob@example.com", State: types.ReviewDismissed, Timestamp: 1700000000},
+	}, reviewers)
+}
+
+func (suite *AzureTestSuite) TestCommitterName() {
+	require.Equal(suite.T(), "", committerName(nil))
+	require.Equal(suite.T(), "Alice Smith", committerName(&git.GitUserDate{Name: stringPtr("Alice Smith")}))
+
+	// the git committer name differs from the display name of the reviewer who pushed the last commit
+	committer := &git.GitUserDate{Name: stringPtr("Robert Jones"), Email: stringPtr("bob@example.com")}
+	require.Equal(suite.T(), "bob@example.com", committerName(committer))
+
+	publishedDate := &azuredevops.Time{Time: time.Unix(1700000000, 0)}
+	bob := webapi.IdentityRef{DisplayName: stringPtr("Bob"), UniqueName: stringPtr("bob@example.com")}
+	evidence := &types.PREvidence{
+		Author:        "alice@example.com",
+		LastCommitter: committerName(committer),
+		Reviewers: reviewersFromThreads([]git.GitPullRequestCommentThread{{
+			Comments: &[]git.Comment{{Author: &bob}},
+			Properties: map[string]interface{}{
+				"CodeReviewThreadType": map[string]interface{}{"$type": "System.String", "$value": "VoteUpdate"},
+				"CodeReviewVoteResult": map[string]interface{}{"$type": "System.String", "$value": "10"},
+			},
+			PublishedDate: publishedDate,
+		}}),
+	}
+	evidence.CheckFourEyes()
+	require.True(suite.T(), evidence.SelfApproved)
+	require.False(suite.T(), evidence.FourEyes())
+}
+
+func (suite *AzureTestSuite) TestLastIteration() {
+	require.Nil(suite.T(), lastIteration(nil))
+
+	iteration := func(id int, commitID string, createdDate time.Time) git.GitPullRequestIteration {
+		return git.GitPullRequestIteration{
+			Id:              &id,
+			SourceRefCommit: &git.GitCommitRef{CommitId: &commitID},
+			CreatedDate:     &azuredevops.Time{Time: createdDate},
+		}
+	}
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	last := lastIteration([]git.GitPullRequestIteration{
+		iteration(1, "abc", day),
+		iteration(3, "ghi", day.Add(3*time.Hour)),
+		iteration(2, "def", day.Add(time.Hour)),
+	})
+	require.Equal(suite.T(), "ghi", *last.SourceRefCommit.CommitId)
+
+	// the last commit is dated before the approval, e.g. because it was rebased, but was pushed after it
+	evidence := &types.PREvidence{
+		Author:                    "alice@example.com",
+		LastCommit:                "ghi",
+		LastCommitTimestamp:       day.Unix(),
+		LastCommitPushedTimestamp: last.CreatedDate.Time.Unix(),
+		Reviewers: []*types.PRReviewer{
+			{Username: "bob@example.com", State: types.ReviewApproved, Timestamp: day.Add(2 * time.Hour).Unix()},
+		},
+	}
+	evidence.CheckFourEyes()
+	require.False(suite.T(), evidence.FourEyes())
+}
+
+func (suite *AzureTestSuite) TestRateLimited() {
+	defaultWait := ratelimit.DefaultWait
+	ratelimit.DefaultWait = time.Millisecond
+	defer func() { ratelimit.DefaultWait = defaultWait }()
+
+	tooManyRequests := http.StatusTooManyRequests
+	notFound := http.StatusNotFound
+	for _, t := range []struct {
+		name      string
+		errors    []error
+		wantCalls int
+		wantError string
+	}{
+		{
+			name:      "a call which is not rate limited is made once",
+			errors:    []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "a rate-limited call is made again",
+			errors:    []error{&azuredevops.WrappedError{StatusCode: &tooManyRequests}, azuredevops.WrappedError{StatusCode: &tooManyRequests}, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "other errors are returned",
+			errors:    []error{azuredevops.WrappedError{StatusCode: &notFound}},
+			wantCalls: 1,
+			wantError: "REST call returned status code 404",
+		},
+		{
+			name:      "a call which is rate limited too many times fails",
+			errors:    []error{azuredevops.WrappedError{StatusCode: &tooManyRequests}},
+			wantCalls: 3,
+			wantError: "Azure DevOps rate limit exceeded after 3 attempts: REST call returned status code 429",
+		},
+	} {
+		suite.Run(t.name, func() {
+			calls := 0
+			err := rateLimited(func() error {
+				calls++
+				return t.errors[min(calls, len(t.errors))-1]
+			})
+			require.Equal(suite.T(), t.wantCalls, calls)
+			if t.wantError != "" {
+				require.EqualError(suite.T(), err, t.wantError)
+			} else {
+				require.NoError(suite.T(), err)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestAzureTestSuite(t *testing.T) {
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/kosli-dev/cli/internal/logger"
 	"github.com/kosli-dev/cli/internal/requests"
@@ -11,61 +13,88 @@ import (
 )
 
 type Config struct {
-	Username    string
-	Password    string
-	Workspace   string
-	Repository  string
+	Username   string
+	Password   string
+	Workspace  string
+	Repository string
+	// ServerURL is the URL of a Bitbucket Data Center (or Server) installation.
+	// Bitbucket Cloud is used when it is empty.
+	ServerURL string
+	// AccessToken is a Bitbucket Data Center HTTP access token
+	AccessToken string
 	Logger      *logger.Logger
 	KosliClient *requests.Client
 	Assert      bool
+	// apiURL is the URL of the Bitbucket Cloud API. It is only set in tests.
+	apiURL string
+}
+
+// cloudAPIURL returns the URL of the Bitbucket Cloud API
+func (c *Config) cloudAPIURL() string {
+	if c.apiURL != "" {
+		return c.apiURL
+	}
+	return "https://api.bitbucket.org/2.0"
 }
 
 func (c *Config) PREvidenceForCommit(commit string) ([]*types.PREvidence, error) {
+	if c.ServerURL != "" {
+		return c.getPullRequestsFromBitbucketDataCenterApi(commit)
+	}
 	return c.getPullRequestsFromBitbucketApi(commit)
 }
 
 func (c *Config) getPullRequestsFromBitbucketApi(commit string) ([]*types.PREvidence, error) {
 	pullRequestsEvidence := []*types.PREvidence{}
 
-	url := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/%s/commit/%s/pullrequests", c.Workspace, c.Repository, commit)
-	c.Logger.Debug("getting pull requests from " + url)
+	// pull requests are paged and each page links to the next one
+	url := fmt.Sprintf("%s/repositories/%s/%s/commit/%s/pullrequests", c.cloudAPIURL(), c.Workspace, c.Repository, commit)
+	for url != "" {
+		c.Logger.Debug("getting pull requests from " + url)
 
-	reqParams := &requests.RequestParams{
-		Method:   http.MethodGet,
-		URL:      url,
-		Username: c.Username,
-		Password: c.Password,
-	}
-	response, err := c.KosliClient.Do(reqParams)
-	if err != nil {
-		return pullRequestsEvidence, err
-	}
-	if response.Resp.StatusCode == 200 {
-		pullRequestsEvidence, err = c.parseBitbucketResponse(commit, response)
+		reqParams := &requests.RequestParams{
+			Method:      http.MethodGet,
+			URL:         url,
+			Username:    c.Username,
+			Password:    c.Password,
+			RateLimited: true,
+		}
+		response, err := c.KosliClient.Do(reqParams)
 		if err != nil {
 			return pullRequestsEvidence, err
 		}
-	} else if response.Resp.StatusCode == 202 {
-		return pullRequestsEvidence, fmt.Errorf("repository pull requests are still being indexed, please retry")
-	} else if response.Resp.StatusCode == 404 {
-		return pullRequestsEvidence, fmt.Errorf("repository does not exist or pull requests are not indexed." +
-			"Please make sure Pull Request Commit Links app is installed")
-	} else {
-		return pullRequestsEvidence, fmt.Errorf("failed to get pull requests from Bitbucket: %v", response.Body)
+		if response.Resp.StatusCode == 200 {
+			var pageEvidence []*types.PREvidence
+			pageEvidence, url, err = c.parseBitbucketResponse(commit, response)
+			if err != nil {
+				return pullRequestsEvidence, err
+			}
+			pullRequestsEvidence = append(pullRequestsEvidence, pageEvidence...)
+		} else if response.Resp.StatusCode == 202 {
+			return pullRequestsEvidence, fmt.Errorf("repository pull requests are still being indexed, please retry")
+		} else if response.Resp.StatusCode == 404 {
+			return pullRequestsEvidence, fmt.Errorf("repository does not exist or pull requests are not indexed." +
+				"Please make sure Pull Request Commit Links app is installed")
+		} else {
+			return pullRequestsEvidence, fmt.Errorf("failed to get pull requests from Bitbucket: %v", response.Body)
+		}
 	}
 	return pullRequestsEvidence, nil
 }
 
-func (c *Config) parseBitbucketResponse(commit string, response *requests.HTTPResponse) ([]*types.PREvidence, error) {
+// parseBitbucketResponse returns the evidence of the pull requests in a page of pull requests,
+// and the URL of the next page, which is empty for the last page
+func (c *Config) parseBitbucketResponse(commit string, response *requests.HTTPResponse) ([]*types.PREvidence, string, error) {
 	pullRequestsEvidence := []*types.PREvidence{}
 	var responseData map[string]interface{}
 	err := json.Unmarshal([]byte(response.Body), &responseData)
 	if err != nil {
-		return pullRequestsEvidence, err
+		return pullRequestsEvidence, "", err
 	}
+	next, _ := responseData["next"].(string)
 	pullRequests, ok := responseData["values"].([]interface{})
 	if !ok {
-		return pullRequestsEvidence, nil
+		return pullRequestsEvidence, next, nil
 	}
 	for _, prInterface := range pullRequests {
 		pr := prInterface.(map[string]interface{})
@@ -74,12 +103,12 @@ func (c *Config) parseBitbucketResponse(commit string, response *requests.HTTPRe
 		htmlLinkMap := linksInterface["html"].(map[string]interface{})
 		evidence, err := c.getPullRequestDetailsFromBitbucket(apiLinkMap["href"].(string), htmlLinkMap["href"].(string), commit)
 		if err != nil {
-			return pullRequestsEvidence, err
+			return pullRequestsEvidence, "", err
 		}
 		pullRequestsEvidence = append(pullRequestsEvidence, evidence)
 	}
 
-	return pullRequestsEvidence, nil
+	return pullRequestsEvidence, next, nil
 }
 
 func (c *Config) getPullRequestDetailsFromBitbucket(prApiUrl, prHtmlLink, commit string) (*types.PREvidence, error) {
@@ -87,10 +116,11 @@ func (c *Config) getPullRequestDetailsFromBitbucket(prApiUrl, prHtmlLink, commit
 	evidence := &types.PREvidence{}
 
 	reqParams := &requests.RequestParams{
-		Method:   http.MethodGet,
-		URL:      prApiUrl,
-		Username: c.Username,
-		Password: c.Password,
+		Method:      http.MethodGet,
+		URL:         prApiUrl,
+		Username:    c.Username,
+		Password:    c.Password,
+		RateLimited: true,
 	}
 	response, err := c.KosliClient.Do(reqParams)
 	if err != nil {
@@ -106,59 +136,182 @@ func (c *Config) getPullRequestDetailsFromBitbucket(prApiUrl, prHtmlLink, commit
 		evidence.URL = prHtmlLink
 		evidence.MergeCommit = commit
 		evidence.State = responseData["state"].(string)
+		if author, ok := responseData["author"].(map[string]interface{}); ok {
+			evidence.Author, _ = author["display_name"].(string)
+		}
 		participants := responseData["participants"].([]interface{})
 		approvers := []string{}
+		reviewers := []*types.PRReviewer{}
 
 		if len(participants) > 0 {
 			for _, participantInterface := range participants {
 				p := participantInterface.(map[string]interface{})
+				user := p["user"].(map[string]interface{})
 				if p["approved"].(bool) {
-					user := p["user"].(map[string]interface{})
 					approvers = append(approvers, user["display_name"].(string))
 				}
+				if reviewer := participantReview(p); reviewer != nil {
+					reviewers = append(reviewers, reviewer)
+				}
 			}
 		} else {
 			c.Logger.Debug("no approvers found")
 		}
 		evidence.Approvers = approvers
-		// prID := int(responseData["id"].(float64))
-		// evidence.LastCommit, evidence.LastCommitter, err = getBitbucketPRLastCommit(workspace, repository, username, password, prID)
-		// if err != nil {
-		// 	return evidence, err
-		// }
-		// if utils.Contains(approvers, evidence.LastCommitter) {
-		// 	evidence.SelfApproved = true
-		// }
+		evidence.Reviewers = reviewers
+
+		prID := int(responseData["id"].(float64))
+		evidence.LastCommit, evidence.LastCommitter, evidence.LastCommitTimestamp, err = c.getPullRequestLastCommit(prID)
+		if err != nil {
+			return evidence, err
+		}
+		evidence.LastCommitPushedTimestamp, err = c.getPullRequestCommitPushTime(prID, evidence.LastCommit)
+		if err != nil {
+			return evidence, err
+		}
+		if evidence.LastCommitPushedTimestamp == 0 {
+			// the pull request was created with its last commit
+			if createdOn, err := time.Parse(time.RFC3339Nano, fmt.Sprint(responseData["created_on"])); err == nil {
+				evidence.LastCommitPushedTimestamp = createdOn.Unix()
+			}
+		}
+		evidence.CheckFourEyes()
 	} else {
 		return evidence, fmt.Errorf("failed to get PR details, got HTTP status %d. Please review repository permissions", response.Resp.StatusCode)
 	}
 	return evidence, nil
 }
 
-// func getBitbucketPRLastCommit(workspace, repository, username, password string, prID int) (string, string, error) {
-// 	url := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/%s/pullrequests/%d/commits", workspace, repository, prID)
-// 	log.Debug("Getting pull requests commits from" + url)
-// 	response, err := requests.SendPayload([]byte{}, url, username, password,
-// 		global.MaxAPIRetries, false, http.MethodGet, log)
-// 	if err != nil {
-// 		return "", "", err
-// 	}
-
-// 	if response.Resp.StatusCode == 200 {
-// 		var responseData map[string]interface{}
-// 		err := json.Unmarshal([]byte(response.Body), &responseData)
-// 		if err != nil {
-// 			return "", "", err
-// 		}
-// 		prCommits := responseData["values"].([]interface{})
-
-// 		// the first commit is the merge commit
-// 		// TODO: is it safe to always to get the second commit?
-// 		lastCommit := prCommits[1].(map[string]interface{})
-// 		lastAuthor := lastCommit["author"].(map[string]interface{})
-// 		return lastCommit["hash"].(string), lastAuthor["user"].(map[string]interface{})["display_name"].(string), nil
-
-// 	} else {
-// 		return "", "", fmt.Errorf("failed to get PR commits, got HTTP status %d", response.Resp.StatusCode)
-// 	}
-// }
+// participantReview returns the review of a pull request participant, or nil if the participant
+// did not approve or request changes. Bitbucket only records when a participant last participated,
+// which is used as the time of the review.
+func participantReview(participant map[string]interface{}) *types.PRReviewer {
+	state, _ := participant["state"].(string)
+	if approved, _ := participant["approved"].(bool); approved {
+		state = "approved"
+	}
+	switch state {
+	case "approved":
+		state = types.ReviewApproved
+	case "changes_requested":
+		state = types.ReviewChangesRequested
+	default:
+		return nil
+	}
+	user := participant["user"].(map[string]interface{})
+	reviewer := &types.PRReviewer{
+		Username: user["display_name"].(string),
+		State:    state,
+	}
+	if participatedOn, ok := participant["participated_on"].(string); ok {
+		timestamp, err := time.Parse(time.RFC3339Nano, participatedOn)
+		if err == nil {
+			reviewer.Timestamp = timestamp.Unix()
+		}
+	}
+	return reviewer
+}
+
+// getPullRequestLastCommit returns the hash, the author and the unix timestamp of the last commit of a pull request.
+// Bitbucket lists pull request commits from the newest to the oldest.
+func (c *Config) getPullRequestLastCommit(prID int) (string, string, int64, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/commits?pagelen=1", c.cloudAPIURL(), c.Workspace, c.Repository, prID)
+	c.Logger.Debug("getting pull request commits from " + url)
+
+	reqParams := &requests.RequestParams{
+		Method:      http.MethodGet,
+		URL:         url,
+		Username:    c.Username,
+		Password:    c.Password,
+		RateLimited: true,
+	}
+	response, err := c.KosliClient.Do(reqParams)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if response.Resp.StatusCode != 200 {
+		return "", "", 0, fmt.Errorf("failed to get PR commits, got HTTP status %d. Please review repository permissions", response.Resp.StatusCode)
+	}
+
+	var commits struct {
+		Values []struct {
+			Hash   string    `json:"hash"`
+			Date   time.Time `json:"date"`
+			Author struct {
+				Raw  string `json:"raw"`
+				User *struct {
+					DisplayName string `json:"display_name"`
+				} `json:"user"`
+			} `json:"author"`
+		} `json:"values"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &commits)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if len(commits.Values) == 0 {
+		return "", "", 0, nil
+	}
+	lastCommit := commits.Values[0]
+	// commits of authors who are not Bitbucket users only have a raw author, e.g. "Jane Doe <jane@example.com>"
+	committer := strings.TrimSpace(strings.Split(lastCommit.Author.Raw, "<")[0])
+	if lastCommit.Author.User != nil {
+		committer = lastCommit.Author.User.DisplayName
+	}
+	return lastCommit.Hash, committer, lastCommit.Date.Unix(), nil
+}
+
+// getPullRequestCommitPushTime returns the unix timestamp of when a commit was pushed as the head of a pull request,
+// or 0 if the pull request activity does not show it. Bitbucket lists the activity from the newest to the oldest,
+// and records an update each time the pull request changes, with the head commit at that time (as a short hash).
+func (c *Config) getPullRequestCommitPushTime(prID int, commit string) (int64, error) {
+	url := fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/activity", c.cloudAPIURL(), c.Workspace, c.Repository, prID)
+	var pushedAt int64
+	headFound := false
+	for url != "" {
+		c.Logger.Debug("getting pull request activity from " + url)
+		reqParams := &requests.RequestParams{
+			Method:      http.MethodGet,
+			URL:         url,
+			Username:    c.Username,
+			Password:    c.Password,
+			RateLimited: true,
+		}
+		response, err := c.KosliClient.Do(reqParams)
+		if err != nil {
+			return 0, err
+		}
+		var activity struct {
+			Values []struct {
+				Update *struct {
+					Date   time.Time `json:"date"`
+					Source struct {
+						Commit struct {
+							Hash string `json:"hash"`
+						} `json:"commit"`
+					} `json:"source"`
+				} `json:"update"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		err = json.Unmarshal([]byte(response.Body), &activity)
+		if err != nil {
+			return 0, err
+		}
+		for _, value := range activity.Values {
+			if value.Update == nil {
+				continue
+			}
+			hash := value.Update.Source.Commit.Hash
+			if hash != "" && strings.HasPrefix(commit, hash) {
+				// the oldest of the latest updates with the commit is when it was pushed
+				headFound = true
+				pushedAt = value.Update.Date.Unix()
+			} else if headFound {
+				return pushedAt, nil
+			}
+		}
+		url = activity.Next
+	}
+	return pushedAt, nil
+}
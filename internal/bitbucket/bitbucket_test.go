@@ -0,0 +1,191 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/kosli-dev/cli/internal/ratelimit"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/types"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const cloudCommitWithPRs = "2492011ef04a9da09d35be706cf6a4c5bc6f1e69"
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type BitbucketTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	client *requests.Client
+	// rateLimitReset is sent in the rate-limited response to the first request for pull requests
+	rateLimitReset time.Time
+	// pushedAt is when the last commit of the pull requests was pushed, according to their activity
+	pushedAt string
+}
+
+func (suite *BitbucketTestSuite) SetupTest() {
+	var err error
+	suite.pushedAt = "2024-05-01T12:30:00.000000+00:00"
+	suite.client, err = requests.NewKosliClient("", 1, false, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	pullRequestLinks := func(id int) map[string]interface{} {
+		return map[string]interface{}{
+			"self": map[string]string{"href": fmt.Sprintf("%s/repositories/kosli-dev/cli/pullrequests/%d", suite.server.URL, id)},
+			"html": map[string]string{"href": fmt.Sprintf("https://bitbucket.org/kosli-dev/cli/pull-requests/%d", id)},
+		}
+	}
+	rateLimited := false
+	mux := http.NewServeMux()
+	// pull requests are served in two pages, after a rate-limited response
+	mux.HandleFunc("/repositories/kosli-dev/cli/commit/"+cloudCommitWithPRs+"/pullrequests", func(w http.ResponseWriter, r *http.Request) {
+		if !rateLimited {
+			rateLimited = true
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(suite.rateLimitReset.Unix()))
+			w.WriteHeader(http.StatusTooManyRequests)
+			writeJSON(w, map[string]string{"message": "Rate limit for this resource has been exceeded"})
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			writeJSON(w, map[string]interface{}{
+				"values": []map[string]interface{}{{"id": 2, "links": pullRequestLinks(2)}},
+			})
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"values": []map[string]interface{}{{"id": 1, "links": pullRequestLinks(1)}},
+			"next":   suite.server.URL + r.URL.Path + "?page=2",
+		})
+	})
+	for _, id := range []int{1, 2} {
+		mux.HandleFunc(fmt.Sprintf("/repositories/kosli-dev/cli/pullrequests/%d", id), func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]interface{}{
+				"id":     id,
+				"state":  "MERGED",
+				"author": map[string]string{"display_name": "Alice"},
+				"participants": []map[string]interface{}{{
+					"user":            map[string]string{"display_name": "Bob"},
+					"approved":        true,
+					"state":           "approved",
+					"participated_on": "2024-05-01T13:00:00.000000+00:00",
+				}},
+			})
+		})
+		// the activity lists the updates from the newest to the oldest, with short commit hashes
+		mux.HandleFunc(fmt.Sprintf("/repositories/kosli-dev/cli/pullrequests/%d/activity", id), func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]interface{}{
+				"values": []map[string]interface{}{
+					{"approval": map[string]interface{}{"date": "2024-05-01T13:00:00.000000+00:00"}},
+					{"update": map[string]interface{}{
+						"date":   suite.pushedAt,
+						"source": map[string]interface{}{"commit": map[string]string{"hash": fmt.Sprintf("%040d", id)[:12]}},
+					}},
+					{"update": map[string]interface{}{
+						"date":   "2024-05-01T11:00:00.000000+00:00",
+						"source": map[string]interface{}{"commit": map[string]string{"hash": "abcdef123456"}},
+					}},
+				},
+			})
+		})
+		mux.HandleFunc(fmt.Sprintf("/repositories/kosli-dev/cli/pullrequests/%d/commits", id), func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]interface{}{
+				"values": []map[string]interface{}{{
+					"hash":   fmt.Sprintf("%040d", id),
+					"date":   "2024-05-01T12:00:00+00:00",
+					"author": map[string]interface{}{"raw": "Alice <alice@example.com>"},
+				}},
+			})
+		})
+	}
+	suite.server = httptest.NewServer(mux)
+}
+
+func (suite *BitbucketTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *BitbucketTestSuite) TestPREvidenceForCommitFollowsPaginationAndRateLimits() {
+	suite.rateLimitReset = time.Now()
+	config := &Config{
+		Workspace:   "kosli-dev",
+		Repository:  "cli",
+		Logger:      logger.NewStandardLogger(),
+		KosliClient: suite.client,
+		apiURL:      suite.server.URL,
+	}
+	evidence, err := config.PREvidenceForCommit(cloudCommitWithPRs)
+	require.NoError(suite.T(), err)
+
+	approvedAt := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC).Unix()
+	committedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix()
+	pushedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC).Unix()
+	wantEvidence := []*types.PREvidence{}
+	for _, id := range []int{1, 2} {
+		wantEvidence = append(wantEvidence, &types.PREvidence{
+			URL:                       fmt.Sprintf("https://bitbucket.org/kosli-dev/cli/pull-requests/%d", id),
+			MergeCommit:               cloudCommitWithPRs,
+			State:                     "MERGED",
+			Author:                    "Alice",
+			Approvers:                 []string{"Bob"},
+			Reviewers:                 []*types.PRReviewer{{Username: "Bob", State: types.ReviewApproved, Timestamp: approvedAt}},
+			LastCommit:                fmt.Sprintf("%040d", id),
+			LastCommitter:             "Alice",
+			LastCommitTimestamp:       committedAt,
+			LastCommitPushedTimestamp: pushedAt,
+			ApprovedAfterLastCommit:   true,
+		})
+	}
+	require.Equal(suite.T(), wantEvidence, evidence)
+}
+
+func (suite *BitbucketTestSuite) TestPREvidenceForCommitIgnoresApprovalsBeforeTheLastCommitWasPushed() {
+	suite.rateLimitReset = time.Now()
+	// the last commit is dated before the approval, but was pushed after it
+	suite.pushedAt = "2024-05-01T15:00:00.000000+00:00"
+	config := &Config{
+		Workspace:   "kosli-dev",
+		Repository:  "cli",
+		Logger:      logger.NewStandardLogger(),
+		KosliClient: suite.client,
+		apiURL:      suite.server.URL,
+	}
+	evidence, err := config.PREvidenceForCommit(cloudCommitWithPRs)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, pr := range evidence {
+		require.Equal(suite.T(), time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC).Unix(), pr.LastCommitPushedTimestamp)
+		require.False(suite.T(), pr.ApprovedAfterLastCommit)
+	}
+}
+
+func (suite *BitbucketTestSuite) TestPREvidenceForCommitFailsWhenRateLimitResetsTooLate() {
+	suite.rateLimitReset = time.Now().Add(ratelimit.MaxWait + time.Hour)
+	config := &Config{
+		Workspace:   "kosli-dev",
+		Repository:  "cli",
+		Logger:      logger.NewStandardLogger(),
+		KosliClient: suite.client,
+		apiURL:      suite.server.URL,
+	}
+	_, err := config.PREvidenceForCommit(cloudCommitWithPRs)
+	require.EqualError(suite.T(), err, fmt.Sprintf("%s rate limit exceeded. It resets at %s, please retry after that",
+		suite.server.Listener.Addr().String(), suite.rateLimitReset.Format(time.RFC3339)))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestBitbucketTestSuite(t *testing.T) {
+	suite.Run(t, new(BitbucketTestSuite))
+}
@@ -0,0 +1,299 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/types"
+)
+
+// dataCenterUser is a Bitbucket Data Center user. The committer of a commit is only a user,
+// with a slug, when Bitbucket links its email to one, and a git name and email otherwise.
+type dataCenterUser struct {
+	Name         string `json:"name"`
+	DisplayName  string `json:"displayName"`
+	EmailAddress string `json:"emailAddress"`
+	Slug         string `json:"slug"`
+}
+
+// dataCenterParticipant is a reviewer or a participant of a Bitbucket Data Center pull request
+type dataCenterParticipant struct {
+	User     dataCenterUser `json:"user"`
+	Approved bool           `json:"approved"`
+	Status   string         `json:"status"`
+}
+
+// dataCenterPullRequest is a Bitbucket Data Center pull request
+type dataCenterPullRequest struct {
+	ID     int    `json:"id"`
+	State  string `json:"state"`
+	Author struct {
+		User dataCenterUser `json:"user"`
+	} `json:"author"`
+	Reviewers    []dataCenterParticipant `json:"reviewers"`
+	Participants []dataCenterParticipant `json:"participants"`
+	Links        struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+// dataCenterActivity is an activity of a Bitbucket Data Center pull request, e.g. an approval.
+// FromHash is the head commit of the pull request after it was rescoped.
+type dataCenterActivity struct {
+	Action      string         `json:"action"`
+	CreatedDate int64          `json:"createdDate"`
+	User        dataCenterUser `json:"user"`
+	FromHash    string         `json:"fromHash"`
+}
+
+// dataCenterCommit is a commit of a Bitbucket Data Center pull request
+type dataCenterCommit struct {
+	ID                 string          `json:"id"`
+	CommitterTimestamp int64           `json:"committerTimestamp"`
+	Committer          *dataCenterUser `json:"committer"`
+}
+
+// dataCenterRepoURL returns the REST API URL of the repository in Bitbucket Data Center.
+// The workspace is the key of the project which the repository belongs to.
+func (c *Config) dataCenterRepoURL() string {
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s", strings.TrimSuffix(c.ServerURL, "/"), c.Workspace, c.Repository)
+}
+
+func (c *Config) getPullRequestsFromBitbucketDataCenterApi(commit string) ([]*types.PREvidence, error) {
+	pullRequestsEvidence := []*types.PREvidence{}
+
+	url := fmt.Sprintf("%s/commits/%s/pull-requests", c.dataCenterRepoURL(), commit)
+	c.Logger.Debug("getting pull requests from " + url)
+
+	pullRequests := []*dataCenterPullRequest{}
+	err := c.getDataCenterPages(url, func(values json.RawMessage) error {
+		page := []*dataCenterPullRequest{}
+		err := json.Unmarshal(values, &page)
+		pullRequests = append(pullRequests, page...)
+		return err
+	})
+	if err != nil {
+		return pullRequestsEvidence, fmt.Errorf("failed to get pull requests from Bitbucket Data Center: %v", err)
+	}
+
+	for _, pr := range pullRequests {
+		evidence, err := c.newDataCenterPREvidence(pr, commit)
+		if err != nil {
+			return pullRequestsEvidence, err
+		}
+		pullRequestsEvidence = append(pullRequestsEvidence, evidence)
+	}
+	return pullRequestsEvidence, nil
+}
+
+func (c *Config) newDataCenterPREvidence(pr *dataCenterPullRequest, commit string) (*types.PREvidence, error) {
+	evidence := &types.PREvidence{
+		MergeCommit: commit,
+		State:       pr.State,
+		Author:      pr.Author.User.Name,
+		Approvers:   dataCenterApprovers(pr),
+	}
+	if len(pr.Links.Self) > 0 {
+		evidence.URL = pr.Links.Self[0].Href
+	}
+
+	activities := []*dataCenterActivity{}
+	err := c.getDataCenterPages(fmt.Sprintf("%s/pull-requests/%d/activities", c.dataCenterRepoURL(), pr.ID), func(values json.RawMessage) error {
+		page := []*dataCenterActivity{}
+		err := json.Unmarshal(values, &page)
+		activities = append(activities, page...)
+		return err
+	})
+	if err != nil {
+		return evidence, fmt.Errorf("failed to get PR activities from Bitbucket Data Center: %v", err)
+	}
+	evidence.Reviewers = reviewersFromActivities(activities)
+
+	lastCommit, err := c.getDataCenterPullRequestLastCommit(pr.ID)
+	if err != nil {
+		return evidence, err
+	}
+	if lastCommit != nil {
+		evidence.LastCommit = lastCommit.ID
+		evidence.LastCommitter = dataCenterCommitter(lastCommit.Committer, pr, activities)
+		evidence.LastCommitTimestamp = lastCommit.CommitterTimestamp / 1000
+		evidence.LastCommitPushedTimestamp = dataCenterCommitPushTime(activities, lastCommit.ID)
+	}
+	evidence.CheckFourEyes()
+	return evidence, nil
+}
+
+// dataCenterApprovers returns the users who approved a pull request. Users who approve a pull request
+// without being added as reviewers are listed as its participants.
+func dataCenterApprovers(pr *dataCenterPullRequest) []string {
+	approvers := []string{}
+	for _, p := range slices.Concat(pr.Reviewers, pr.Participants) {
+		if (p.Approved || p.Status == "APPROVED") && !slices.Contains(approvers, p.User.Name) {
+			approvers = append(approvers, p.User.Name)
+		}
+	}
+	return approvers
+}
+
+// dataCenterCommitter returns the username of the committer of a pull request commit. A committer whose email
+// Bitbucket does not link to a user is matched by email with the users of the pull request, as its git name
+// is not a username.
+func dataCenterCommitter(committer *dataCenterUser, pr *dataCenterPullRequest, activities []*dataCenterActivity) string {
+	if committer == nil {
+		return ""
+	}
+	if committer.Slug != "" || committer.EmailAddress == "" {
+		return committer.Name
+	}
+	users := []dataCenterUser{pr.Author.User}
+	for _, p := range slices.Concat(pr.Reviewers, pr.Participants) {
+		users = append(users, p.User)
+	}
+	for _, activity := range activities {
+		users = append(users, activity.User)
+	}
+	for _, user := range users {
+		if strings.EqualFold(user.EmailAddress, committer.EmailAddress) {
+			return user.Name
+		}
+	}
+	return committer.Name
+}
+
+// dataCenterCommitPushTime returns the unix timestamp of when a commit was pushed as the head of a pull request.
+// Bitbucket Data Center lists activities from the newest to the oldest, and records a rescoped activity when
+// commits are pushed to a pull request. A commit which was never pushed after the pull request was opened was
+// pushed when it was opened. When the activities do not show the commit, the last time that the pull request
+// was rescoped or opened is returned.
+func dataCenterCommitPushTime(activities []*dataCenterActivity, commit string) int64 {
+	var pushedAt, lastRescopedAt int64
+	for _, activity := range activities {
+		switch activity.Action {
+		case "RESCOPED":
+			if activity.FromHash == commit {
+				// the oldest of the latest rescopes to the commit is when it was pushed
+				pushedAt = activity.CreatedDate / 1000
+			} else if pushedAt != 0 {
+				return pushedAt
+			} else if lastRescopedAt == 0 {
+				lastRescopedAt = activity.CreatedDate / 1000
+			}
+		case "OPENED":
+			if pushedAt != 0 {
+				return pushedAt
+			}
+			if lastRescopedAt != 0 {
+				return lastRescopedAt
+			}
+			return activity.CreatedDate / 1000
+		}
+	}
+	return max(pushedAt, lastRescopedAt)
+}
+
+// reviewersFromActivities returns the reviewers of a pull request from its activities. Bitbucket Data Center
+// records an activity when a pull request is approved, unapproved or marked as needing work.
+func reviewersFromActivities(activities []*dataCenterActivity) []*types.PRReviewer {
+	reviewers := []*types.PRReviewer{}
+	for _, activity := range activities {
+		var state string
+		switch activity.Action {
+		case "APPROVED":
+			state = types.ReviewApproved
+		case "UNAPPROVED":
+			state = types.ReviewDismissed
+		case "REVIEWED":
+			state = types.ReviewChangesRequested
+		default:
+			continue
+		}
+		reviewers = append(reviewers, &types.PRReviewer{
+			Username:  activity.User.Name,
+			State:     state,
+			Timestamp: activity.CreatedDate / 1000,
+		})
+	}
+	return reviewers
+}
+
+// getDataCenterPullRequestLastCommit returns the last commit of a pull request, or nil if it has no commits.
+// Bitbucket Data Center lists pull request commits from the newest to the oldest.
+func (c *Config) getDataCenterPullRequestLastCommit(prID int) (*dataCenterCommit, error) {
+	url := fmt.Sprintf("%s/pull-requests/%d/commits?limit=1", c.dataCenterRepoURL(), prID)
+	c.Logger.Debug("getting pull request commits from " + url)
+
+	response, err := c.getFromDataCenter(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PR commits from Bitbucket Data Center: %v", err)
+	}
+	var commits struct {
+		Values []*dataCenterCommit `json:"values"`
+	}
+	err = json.Unmarshal([]byte(response.Body), &commits)
+	if err != nil || len(commits.Values) == 0 {
+		return nil, err
+	}
+	return commits.Values[0], nil
+}
+
+// getDataCenterPages calls handle with the values of each page of a paged Bitbucket Data Center API
+func (c *Config) getDataCenterPages(pagedURL string, handle func(values json.RawMessage) error) error {
+	start := 0
+	for {
+		u, err := url.Parse(pagedURL)
+		if err != nil {
+			return err
+		}
+		query := u.Query()
+		query.Set("start", fmt.Sprint(start))
+		u.RawQuery = query.Encode()
+
+		response, err := c.getFromDataCenter(u.String())
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Values        json.RawMessage `json:"values"`
+			IsLastPage    bool            `json:"isLastPage"`
+			NextPageStart int             `json:"nextPageStart"`
+		}
+		err = json.Unmarshal([]byte(response.Body), &page)
+		if err != nil {
+			return err
+		}
+		if len(page.Values) > 0 {
+			err = handle(page.Values)
+			if err != nil {
+				return err
+			}
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			return nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// getFromDataCenter makes a GET request to Bitbucket Data Center, authenticated with an HTTP access token
+// if one is given, and with the username and password otherwise
+func (c *Config) getFromDataCenter(url string) (*requests.HTTPResponse, error) {
+	reqParams := &requests.RequestParams{
+		Method:      http.MethodGet,
+		URL:         url,
+		RateLimited: true,
+	}
+	if c.AccessToken != "" {
+		reqParams.Token = c.AccessToken
+	} else {
+		reqParams.Username = c.Username
+		reqParams.Password = c.Password
+	}
+	return c.KosliClient.Do(reqParams)
+}
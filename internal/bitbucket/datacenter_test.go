@@ -0,0 +1,279 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/kosli-dev/cli/internal/requests"
+	"github.com/kosli-dev/cli/internal/types"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const dataCenterCommitWithPR = "fd54040fc90e7e83f7b152619bfa18917b72c34f"
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type DataCenterTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	client *requests.Client
+}
+
+func (suite *DataCenterTestSuite) SetupTest() {
+	var err error
+	suite.client, err = requests.NewKosliClient("", 1, false, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	repoPath := "/rest/api/1.0/projects/KOS/repos/cli"
+	mux := http.NewServeMux()
+	mux.HandleFunc(repoPath+"/commits/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]interface{}{"errors": []map[string]string{{"message": "Authentication failed"}}})
+			return
+		}
+		if r.URL.Path != repoPath+"/commits/"+dataCenterCommitWithPR+"/pull-requests" {
+			writeJSON(w, map[string]interface{}{"values": []interface{}{}, "isLastPage": true})
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"isLastPage": true,
+			"values": []map[string]interface{}{{
+				"id":     7,
+				"state":  "MERGED",
+				"author": map[string]interface{}{"user": map[string]string{"name": "alice", "emailAddress": "alice@example.com"}},
+				"reviewers": []map[string]interface{}{
+					{"user": map[string]string{"name": "bob"}, "approved": true, "status": "APPROVED"},
+					{"user": map[string]string{"name": "carol"}, "approved": false, "status": "NEEDS_WORK"},
+				},
+				"participants": []map[string]interface{}{
+					{"user": map[string]string{"name": "dave"}, "approved": true, "status": "APPROVED"},
+				},
+				"links": map[string]interface{}{
+					"self": []map[string]string{{"href": "https://bitbucket.example.com/projects/KOS/repos/cli/pull-requests/7"}},
+				},
+			}},
+		})
+	})
+	// activities are served in two pages, from the newest to the oldest
+	mux.HandleFunc(repoPath+"/pull-requests/7/activities", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start") == "0" {
+			writeJSON(w, map[string]interface{}{
+				"isLastPage":    false,
+				"nextPageStart": 2,
+				"values": []map[string]interface{}{
+					{"action": "APPROVED", "createdDate": 1714575600000, "user": map[string]string{"name": "bob"}},
+					{"action": "RESCOPED", "createdDate": 1714573800000, "user": map[string]string{"name": "alice"},
+						"fromHash": "1bd0c5d6a9b8e0f7d2c3b4a5968778695a4b3c2d"},
+				},
+			})
+			return
+		}
+		require.Equal(suite.T(), "2", r.URL.Query().Get("start"))
+		writeJSON(w, map[string]interface{}{
+			"isLastPage": true,
+			"values": []map[string]interface{}{
+				{"action": "REVIEWED", "createdDate": 1714568400000, "user": map[string]string{"name": "carol"}},
+				{"action": "OPENED", "createdDate": 1714564800000, "user": map[string]string{"name": "alice"}},
+			},
+		})
+	})
+	mux.HandleFunc(repoPath+"/pull-requests/7/commits", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(suite.T(), "1", r.URL.Query().Get("limit"))
+		writeJSON(w, map[string]interface{}{
+			"isLastPage": false,
+			"values": []map[string]interface{}{{
+				"id":                 "1bd0c5d6a9b8e0f7d2c3b4a5968778695a4b3c2d",
+				"committer":          map[string]string{"name": "Alice Smith", "emailAddress": "alice@example.com"},
+				"committerTimestamp": 1714572000000,
+			}},
+		})
+	})
+	suite.server = httptest.NewServer(mux)
+}
+
+func (suite *DataCenterTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *DataCenterTestSuite) TestPREvidenceForCommit() {
+	for _, t := range []struct {
+		name         string
+		accessToken  string
+		commit       string
+		wantError    string
+		wantEvidence []*types.PREvidence
+	}{
+		{
+			name:        "invalid access token causes an error",
+			accessToken: "wrong-token",
+			commit:      dataCenterCommitWithPR,
+			wantError:   "failed to get pull requests from Bitbucket Data Center: map[errors:[map[message:Authentication failed]]]",
+		},
+		{
+			name:         "a commit without a pull request returns no evidence",
+			accessToken:  "access-token",
+			commit:       "3dce097040987c4693d2e4be817474d9d0063c93",
+			wantEvidence: []*types.PREvidence{},
+		},
+		{
+			name:        "a commit with a pull request returns its approvers, reviewers and last commit",
+			accessToken: "access-token",
+			commit:      dataCenterCommitWithPR,
+			wantEvidence: []*types.PREvidence{{
+				URL:         "https://bitbucket.example.com/projects/KOS/repos/cli/pull-requests/7",
+				MergeCommit: dataCenterCommitWithPR,
+				State:       "MERGED",
+				Author:      "alice",
+				Approvers:   []string{"bob", "dave"},
+				Reviewers: []*types.PRReviewer{
+					{Username: "bob", State: types.ReviewApproved, Timestamp: time.UnixMilli(1714575600000).Unix()},
+					{Username: "carol", State: types.ReviewChangesRequested, Timestamp: time.UnixMilli(1714568400000).Unix()},
+				},
+				LastCommit:                "1bd0c5d6a9b8e0f7d2c3b4a5968778695a4b3c2d",
+				LastCommitter:             "alice",
+				LastCommitTimestamp:       time.UnixMilli(1714572000000).Unix(),
+				LastCommitPushedTimestamp: time.UnixMilli(1714573800000).Unix(),
+				ApprovedAfterLastCommit:   true,
+			}},
+		},
+	} {
+		suite.Run(t.name, func() {
+			config := &Config{
+				Workspace:   "KOS",
+				Repository:  "cli",
+				ServerURL:   suite.server.URL + "/",
+				AccessToken: t.accessToken,
+				Logger:      logger.NewStandardLogger(),
+				KosliClient: suite.client,
+			}
+			evidence, err := config.PREvidenceForCommit(t.commit)
+			if t.wantError != "" {
+				require.EqualError(suite.T(), err, t.wantError)
+				return
+			}
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.wantEvidence, evidence)
+		})
+	}
+}
+
+func (suite *DataCenterTestSuite) TestDataCenterCommitter() {
+	pr := &dataCenterPullRequest{}
+	pr.Author.User = dataCenterUser{Name: "alice", EmailAddress: "alice@example.com"}
+	pr.Reviewers = []dataCenterParticipant{{User: dataCenterUser{Name: "bob", EmailAddress: "bob@example.com"}, Approved: true}}
+	for _, t := range []struct {
+		name      string
+		committer *dataCenterUser
+		want      string
+	}{
+		{
+			name:      "a committer linked to a user is that user",
+			committer: &dataCenterUser{Name: "bob", Slug: "bob", EmailAddress: "robert@example.com"},
+			want:      "bob",
+		},
+		{
+			name:      "a committer is matched by email with the users of the pull request",
+			committer: &dataCenterUser{Name: "Robert Jones", EmailAddress: "Bob@example.com"},
+			want:      "bob",
+		},
+		{
+			name:      "a committer with an unknown email keeps their git name",
+			committer: &dataCenterUser{Name: "Robert Jones", EmailAddress: "robert@example.com"},
+			want:      "Robert Jones",
+		},
+		{
+			name: "a commit without a committer has no committer",
+		},
+	} {
+		suite.Run(t.name, func() {
+			require.Equal(suite.T(), t.want, dataCenterCommitter(t.committer, pr, nil))
+		})
+	}
+
+	// the git committer name differs from the username of the reviewer who pushed the last commit
+	evidence := &types.PREvidence{
+		Author:        "alice",
+		LastCommitter: dataCenterCommitter(&dataCenterUser{Name: "Robert Jones", EmailAddress: "bob@example.com"}, pr, nil),
+		Reviewers:     []*types.PRReviewer{{Username: "bob", State: types.ReviewApproved, Timestamp: 1714575600}},
+	}
+	evidence.CheckFourEyes()
+	require.True(suite.T(), evidence.SelfApproved)
+	require.False(suite.T(), evidence.FourEyes())
+}
+
+func (suite *DataCenterTestSuite) TestDataCenterCommitPushTime() {
+	activity := func(action string, createdAt int64, fromHash string) *dataCenterActivity {
+		return &dataCenterActivity{Action: action, CreatedDate: createdAt * 1000, FromHash: fromHash}
+	}
+	for _, t := range []struct {
+		name       string
+		activities []*dataCenterActivity
+		want       int64
+	}{
+		{
+			name: "a commit which was pushed after the pull request was opened was pushed when it was rescoped to it",
+			activities: []*dataCenterActivity{
+				activity("APPROVED", 400, ""),
+				activity("RESCOPED", 300, "def"),
+				activity("RESCOPED", 250, "def"),
+				activity("RESCOPED", 200, "abc"),
+				activity("OPENED", 100, ""),
+			},
+			want: 250,
+		},
+		{
+			name: "a commit which was never pushed after the pull request was opened was pushed when it was opened",
+			activities: []*dataCenterActivity{
+				activity("APPROVED", 400, ""),
+				activity("OPENED", 100, ""),
+			},
+			want: 100,
+		},
+		{
+			name: "a commit which is not in the activities was pushed at the latest when the pull request was last rescoped",
+			activities: []*dataCenterActivity{
+				activity("RESCOPED", 300, "ghi"),
+				activity("RESCOPED", 200, "abc"),
+				activity("OPENED", 100, ""),
+			},
+			want: 300,
+		},
+	} {
+		suite.Run(t.name, func() {
+			require.Equal(suite.T(), t.want, dataCenterCommitPushTime(t.activities, "def"))
+		})
+	}
+
+	// the last commit is dated before the approval, e.g. because it was rebased, but was pushed after it
+	evidence := &types.PREvidence{
+		Author:              "alice",
+		LastCommit:          "def",
+		LastCommitter:       "alice",
+		LastCommitTimestamp: 100,
+		LastCommitPushedTimestamp: dataCenterCommitPushTime([]*dataCenterActivity{
+			activity("RESCOPED", 300, "def"),
+			activity("APPROVED", 200, ""),
+			activity("OPENED", 100, ""),
+		}, "def"),
+		Reviewers: []*types.PRReviewer{{Username: "bob", State: types.ReviewApproved, Timestamp: 200}},
+	}
+	evidence.CheckFourEyes()
+	require.False(suite.T(), evidence.FourEyes())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestDataCenterTestSuite(t *testing.T) {
+	suite.Run(t, new(DataCenterTestSuite))
+}
@@ -0,0 +1,515 @@
+package digest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/logger"
+)
+
+// archiveRoot is the (virtual) directory archives are fingerprinted as if they were extracted to.
+// Exclude patterns are matched against the archive entries under this directory.
+const archiveRoot = "/archive"
+
+// maxSymlinkHops is the maximum number of symlinks followed to resolve a symlink in an archive
+const maxSymlinkHops = 40
+
+// archiveNode is a file, directory or symlink in an archive
+type archiveNode struct {
+	isDir         bool
+	isSymlink     bool
+	children      map[string]*archiveNode
+	contentSha256 string
+	// linkTarget is the target of a symlink, as stored in the archive
+	linkTarget string
+	// content is only kept for the .kosli_ignore file at the root of the archive
+	content []byte
+}
+
+// archiveTree is the tree of files of an archive, rooted at "/" with archiveRoot as its only child.
+// It answers the filesystem queries made when fingerprinting a directory as if the archive
+// was extracted to archiveRoot.
+type archiveTree struct {
+	root *archiveNode
+}
+
+// ArchiveSha256 returns the sha256 digest of a tar (optionally gzipped) or zip (e.g. jar, wheel) archive.
+// The digest is the one DirSha256 gives for the directory the archive is extracted to,
+// and is calculated without extracting the archive.
+func ArchiveSha256(archivePath string, excludePaths []string, logger *logger.Logger) (string, error) {
+	fingerprint, _, _, err := fingerprintArchive(archivePath, excludePaths, logger)
+	return fingerprint, err
+}
+
+// fingerprintArchive calculates the fingerprint of an archive and returns it with the
+// entries it is calculated from and the paths that were excluded
+func fingerprintArchive(archivePath string, excludePaths []string, logger *logger.Logger) (string, []*fingerprintEntry, []ExcludedPath, error) {
+	logger.Debug("calculating fingerprint for archive [%s] -- excluding paths: %s", archivePath, excludePaths)
+	tree, err := readArchiveTree(archivePath)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to read archive %s: %v", archivePath, err)
+	}
+
+	ignoredPaths := []string{}
+	if ignoreFile := tree.lstat(path.Join(archiveRoot, ".kosli_ignore")); ignoreFile != nil && ignoreFile.content != nil {
+		ignoredPaths = excludePathsFromReader(bytes.NewReader(ignoreFile.content))
+		logger.Debug("  -> ignore file used %s -- excluding paths: %s", ".kosli_ignore", ignoredPaths)
+	}
+
+	entries, excluded, err := tree.listFingerprintEntries(excludePaths, ignoredPaths, logger)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return entriesSha256(entries, logger), entries, excluded, nil
+}
+
+// listFingerprintEntries lists the entries of the archive that are not excluded, and the paths
+// that were excluded, in the same way as for an extracted archive
+func (t *archiveTree) listFingerprintEntries(excludePaths, ignoredPaths []string, logger *logger.Logger) ([]*fingerprintEntry, []ExcludedPath, error) {
+	pathsToExclude := map[string]ExcludedPath{}
+	for _, patterns := range []struct {
+		source   string
+		patterns []string
+	}{
+		{ExcludedByExcludePaths, excludePaths},
+		{ExcludedByIgnoreFile, ignoredPaths},
+	} {
+		for _, p := range patterns.patterns {
+			found, err := t.glob(path.Join(archiveRoot, p))
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, path := range found {
+				if _, ok := pathsToExclude[path]; !ok {
+					pathsToExclude[path] = ExcludedPath{Pattern: p, Source: patterns.source}
+				}
+			}
+		}
+	}
+
+	entries := []*fingerprintEntry{}
+	excluded := []ExcludedPath{}
+	var walk func(dirPath string, dir *archiveNode) error
+	walk = func(dirPath string, dir *archiveNode) error {
+		for _, name := range dir.sortedNames() {
+			entryPath := path.Join(dirPath, name)
+			node := dir.children[name]
+			if excludedPath, ok := pathsToExclude[entryPath]; ok {
+				excludedPath.Path = manifestPath(archiveRoot, entryPath)
+				excluded = append(excluded, excludedPath)
+				if node.isDir {
+					logger.Debug("skipping dir %s (and its contents) as it matches excluded paths", entryPath)
+				} else {
+					logger.Debug("skipping %s as it matches excluded paths", entryPath)
+				}
+				continue
+			}
+
+			entry := &fingerprintEntry{path: entryPath, name: name, isDir: node.isDir, contentSha256: node.contentSha256}
+			entries = append(entries, entry)
+			if node.isSymlink {
+				// symlinks to files are fingerprinted with the content of the file, as when hashing an extracted archive
+				target, err := t.resolveSymlink(entryPath)
+				if err != nil {
+					return err
+				}
+				entry.contentSha256 = target.contentSha256
+			}
+			if node.isDir {
+				if err := walk(entryPath, node); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err := walk(archiveRoot, t.lstat(archiveRoot))
+	return entries, excluded, err
+}
+
+// readArchiveTree reads the tree of files of a tar, tar.gz or zip archive
+func readArchiveTree(archivePath string) (*archiveTree, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", archivePath)
+	}
+
+	// the archive format is detected from its first bytes rather than its file extension
+	reader := bufio.NewReaderSize(file, 1024)
+	header, _ := reader.Peek(512)
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		return readTarTree(gzipReader)
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		zipReader, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		return readZipTree(zipReader)
+	case isTarHeader(header):
+		return readTarTree(reader)
+	default:
+		return nil, fmt.Errorf("unsupported archive format. Supported formats are: tar, tar.gz, zip (e.g. jar, wheel)")
+	}
+}
+
+// isTarHeader checks for the ustar magic of the first header of a tar archive
+func isTarHeader(header []byte) bool {
+	return len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar"))
+}
+
+// readTarTree reads the tree of files of a tar archive, hashing files as they are read
+func readTarTree(reader io.Reader) (*archiveTree, error) {
+	tree := newArchiveTree()
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return tree, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = tree.add(header.Name, &archiveNode{isDir: true})
+		case tar.TypeReg:
+			err = tree.addFile(header.Name, tarReader)
+		case tar.TypeSymlink:
+			err = tree.add(header.Name, &archiveNode{isSymlink: true, linkTarget: header.Linkname})
+		case tar.TypeLink:
+			// hard links share the content of a file earlier in the archive
+			target := tree.lstat(path.Join(archiveRoot, cleanArchivePath(header.Linkname)))
+			if target == nil || target.isDir || target.isSymlink {
+				return nil, fmt.Errorf("hard link %s points to %s which is not a file in the archive", header.Name, header.Linkname)
+			}
+			err = tree.add(header.Name, &archiveNode{contentSha256: target.contentSha256, content: target.content})
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return nil, fmt.Errorf("%s has an unsupported tar entry type: %c", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readZipTree reads the tree of files of a zip archive
+func readZipTree(zipReader *zip.Reader) (*archiveTree, error) {
+	tree := newArchiveTree()
+	for _, file := range zipReader.File {
+		var err error
+		switch {
+		case file.FileInfo().IsDir():
+			err = tree.add(file.Name, &archiveNode{isDir: true})
+		case file.Mode()&fs.ModeSymlink != 0:
+			var target []byte
+			target, err = readZipFile(file)
+			if err == nil {
+				err = tree.add(file.Name, &archiveNode{isSymlink: true, linkTarget: string(target)})
+			}
+		default:
+			var content io.ReadCloser
+			content, err = file.Open()
+			if err == nil {
+				err = tree.addFile(file.Name, content)
+				content.Close()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// readZipFile reads the content of a (small) file in a zip archive
+func readZipFile(file *zip.File) ([]byte, error) {
+	content, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(content)
+}
+
+func newArchiveTree() *archiveTree {
+	root := &archiveNode{isDir: true, children: map[string]*archiveNode{}}
+	root.children[strings.TrimPrefix(archiveRoot, "/")] = &archiveNode{isDir: true, children: map[string]*archiveNode{}}
+	return &archiveTree{root: root}
+}
+
+// cleanArchivePath returns the path of an archive entry relative to the archive root
+func cleanArchivePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// addFile hashes the content of a file and adds it to the tree
+func (t *archiveTree) addFile(name string, content io.Reader) error {
+	node := &archiveNode{}
+	hasher := sha256.New()
+	var reader io.Reader = content
+	// keep the ignore file content to read the paths it excludes
+	var ignoreFileContent bytes.Buffer
+	if cleanArchivePath(name) == ".kosli_ignore" {
+		reader = io.TeeReader(content, &ignoreFileContent)
+	}
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return err
+	}
+	node.contentSha256 = hex.EncodeToString(hasher.Sum(nil))
+	if cleanArchivePath(name) == ".kosli_ignore" {
+		node.content = ignoreFileContent.Bytes()
+	}
+	return t.add(name, node)
+}
+
+// add adds an archive entry to the tree, creating its parent directories if they are not in the archive
+func (t *archiveTree) add(name string, node *archiveNode) error {
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return fmt.Errorf("archive entry %s is outside of the archive root", name)
+		}
+	}
+	relPath := cleanArchivePath(name)
+	if relPath == "" {
+		// the archive root itself, e.g. "./"
+		return nil
+	}
+
+	dir := t.lstat(archiveRoot)
+	elements := strings.Split(relPath, "/")
+	for _, element := range elements[:len(elements)-1] {
+		child, ok := dir.children[element]
+		if !ok {
+			child = &archiveNode{isDir: true, children: map[string]*archiveNode{}}
+			dir.children[element] = child
+		}
+		if !child.isDir {
+			return fmt.Errorf("archive entry %s is inside %s which is not a directory", name, element)
+		}
+		dir = child
+	}
+
+	name = elements[len(elements)-1]
+	existing, ok := dir.children[name]
+	switch {
+	case !ok:
+		if node.isDir {
+			node.children = map[string]*archiveNode{}
+		}
+		dir.children[name] = node
+	case existing.isDir && node.isDir:
+		// a directory entry for a directory created for earlier entries
+	case existing.isDir || node.isDir:
+		return fmt.Errorf("archive entry %s is both a directory and a file", relPath)
+	default:
+		// later entries replace earlier ones, as when extracting the archive
+		dir.children[name] = node
+	}
+	return nil
+}
+
+// sortedNames returns the names of the children of a directory in the order they are walked
+func (n *archiveNode) sortedNames() []string {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lstat returns the node at an absolute path, without following symlinks, or nil if there is none.
+// Like os.Lstat, it accepts repeated and trailing separators, "." and "..".
+func (t *archiveTree) lstat(p string) *archiveNode {
+	if !strings.HasPrefix(p, "/") {
+		return nil
+	}
+	node := t.root
+	parents := []*archiveNode{}
+	for _, element := range strings.Split(p[1:], "/") {
+		if !node.isDir {
+			return nil
+		}
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			if len(parents) > 0 {
+				node = parents[len(parents)-1]
+				parents = parents[:len(parents)-1]
+			}
+			continue
+		}
+		child, ok := node.children[element]
+		if !ok {
+			return nil
+		}
+		parents = append(parents, node)
+		node = child
+	}
+	return node
+}
+
+// resolveSymlink returns the file a symlink in the archive points to
+func (t *archiveTree) resolveSymlink(linkPath string) (*archiveNode, error) {
+	p := linkPath
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		node := t.lstat(p)
+		switch {
+		case node == nil:
+			return nil, fmt.Errorf("symlink %s points to %s which is not in the archive", manifestPath(archiveRoot, linkPath), manifestPath(archiveRoot, p))
+		case node.isDir:
+			return nil, fmt.Errorf("read %s: is a directory", manifestPath(archiveRoot, linkPath))
+		case !node.isSymlink:
+			return node, nil
+		}
+		if path.IsAbs(node.linkTarget) {
+			// absolute targets point outside of the archive once it is extracted
+			return nil, fmt.Errorf("symlink %s points to %s which is not in the archive", manifestPath(archiveRoot, linkPath), node.linkTarget)
+		}
+		p = path.Join(path.Dir(p), node.linkTarget)
+	}
+	return nil, fmt.Errorf("symlink %s has too many levels of symbolic links", manifestPath(archiveRoot, linkPath))
+}
+
+// glob returns the paths in the tree matching a pattern, with "**" matching zero or more directories.
+// It matches the same paths as filepathx.Glob would in the extracted archive.
+func (t *archiveTree) glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return t.globWithoutDoubleStar(pattern, 0)
+	}
+
+	matches := []string{""}
+	for _, glob := range strings.Split(pattern, "**") {
+		hits := []string{}
+		hitMap := map[string]bool{}
+		for _, match := range matches {
+			paths, err := t.globWithoutDoubleStar(match+glob, 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range paths {
+				t.walk(p, func(p string) {
+					if !hitMap[p] {
+						hits = append(hits, p)
+						hitMap[p] = true
+					}
+				})
+			}
+		}
+		matches = hits
+	}
+	return matches, nil
+}
+
+// globWithoutDoubleStar returns the paths in the tree matching a pattern, as filepath.Glob does
+func (t *archiveTree) globWithoutDoubleStar(pattern string, depth int) ([]string, error) {
+	// the same limit as filepath.Glob, to prevent stack exhaustion
+	if depth == 10000 {
+		return nil, path.ErrBadPattern
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasGlobMeta(pattern) {
+		if t.lstat(pattern) == nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	switch dir {
+	case "":
+		dir = "."
+	case "/":
+	default:
+		dir = dir[:len(dir)-1]
+	}
+	if !hasGlobMeta(dir) {
+		return t.globDir(dir, file, nil)
+	}
+	if dir == pattern {
+		return nil, path.ErrBadPattern
+	}
+
+	dirs, err := t.globWithoutDoubleStar(dir, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches, err = t.globDir(d, file, matches)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// globDir appends the children of a directory matching a pattern to matches
+func (t *archiveTree) globDir(dir, pattern string, matches []string) ([]string, error) {
+	node := t.lstat(dir)
+	if node == nil || !node.isDir {
+		return matches, nil
+	}
+	for _, name := range node.sortedNames() {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, path.Join(dir, name))
+		}
+	}
+	return matches, nil
+}
+
+// walk calls fn for a path and, if it is a directory, for everything under it, as filepath.Walk does
+func (t *archiveTree) walk(p string, fn func(string)) {
+	node := t.lstat(p)
+	if node == nil {
+		return
+	}
+	fn(p)
+	if node.isDir {
+		for _, name := range node.sortedNames() {
+			t.walk(path.Join(p, name), fn)
+		}
+	}
+}
+
+// hasGlobMeta reports whether a path contains any of the magic characters recognized by path.Match
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
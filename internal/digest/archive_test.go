@@ -0,0 +1,217 @@
+package digest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type ArchiveTestSuite struct {
+	suite.Suite
+	tmpDir string
+}
+
+// archiveTestEntry is a file (or a symlink if link is set) in a test archive
+type archiveTestEntry struct {
+	content string
+	link    string
+}
+
+func (suite *ArchiveTestSuite) SetupTest() {
+	suite.tmpDir = suite.T().TempDir()
+}
+
+// createDir creates the extracted form of a test archive
+func (suite *ArchiveTestSuite) createDir(name string, entries map[string]archiveTestEntry) string {
+	dirPath := filepath.Join(suite.tmpDir, name)
+	for path, entry := range entries {
+		path = filepath.Join(dirPath, path)
+		require.NoError(suite.T(), os.MkdirAll(filepath.Dir(path), 0755))
+		if entry.link != "" {
+			require.NoError(suite.T(), os.Symlink(entry.link, path))
+		} else {
+			require.NoError(suite.T(), os.WriteFile(path, []byte(entry.content), 0644))
+		}
+	}
+	return dirPath
+}
+
+// createTar creates a tar archive with the entries (only files, without directory entries)
+func (suite *ArchiveTestSuite) createTar(name string, gzipped bool, entries map[string]archiveTestEntry) string {
+	archivePath := filepath.Join(suite.tmpDir, name)
+	file, err := os.Create(archivePath)
+	require.NoError(suite.T(), err)
+	defer file.Close()
+	var writer io.Writer = file
+	if gzipped {
+		gzipWriter := gzip.NewWriter(file)
+		defer gzipWriter.Close()
+		writer = gzipWriter
+	}
+	tarWriter := tar.NewWriter(writer)
+	defer tarWriter.Close()
+
+	for _, path := range sortedArchiveTestPaths(entries) {
+		entry := entries[path]
+		header := &tar.Header{Name: "./" + path, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		if entry.link != "" {
+			header = &tar.Header{Name: "./" + path, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: entry.link}
+		}
+		require.NoError(suite.T(), tarWriter.WriteHeader(header))
+		_, err := tarWriter.Write([]byte(entry.content))
+		require.NoError(suite.T(), err)
+	}
+	return archivePath
+}
+
+// createZip creates a zip archive with the entries and their directories
+func (suite *ArchiveTestSuite) createZip(name string, entries map[string]archiveTestEntry) string {
+	archivePath := filepath.Join(suite.tmpDir, name)
+	file, err := os.Create(archivePath)
+	require.NoError(suite.T(), err)
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	defer zipWriter.Close()
+
+	dirs := map[string]bool{}
+	for _, path := range sortedArchiveTestPaths(entries) {
+		for dir := filepath.Dir(path); dir != "." && !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+			_, err := zipWriter.Create(dir + "/")
+			require.NoError(suite.T(), err)
+		}
+		entry := entries[path]
+		header := &zip.FileHeader{Name: path, Method: zip.Deflate}
+		content := entry.content
+		if entry.link != "" {
+			header.SetMode(os.ModeSymlink | 0777)
+			content = entry.link
+		}
+		writer, err := zipWriter.CreateHeader(header)
+		require.NoError(suite.T(), err)
+		_, err = writer.Write([]byte(content))
+		require.NoError(suite.T(), err)
+	}
+	return archivePath
+}
+
+func sortedArchiveTestPaths(entries map[string]archiveTestEntry) []string {
+	paths := []string{}
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (suite *ArchiveTestSuite) TestArchiveSha256IsTheExtractedDirSha256() {
+	entries := map[string]archiveTestEntry{
+		".kosli_ignore":        {content: "# ignore logs\n**/*.log\n"},
+		"a.txt":                {content: "a"},
+		"a/b.txt":              {content: "b"},
+		"a/c/d.md":             {content: "d"},
+		"a.b/x":                {content: "x"},
+		"logs/app.log":         {content: "log"},
+		"logs/sub/app.log":     {content: "log"},
+		"docs/README.md":       {content: "readme"},
+		"docs/guide/README.md": {content: "guide"},
+		"a/link":               {link: "../a.txt"},
+		"a/link-to-link":       {link: "link"},
+	}
+	dirPath := suite.createDir("extracted", entries)
+	archives := []string{
+		suite.createTar("artifact.tar", false, entries),
+		suite.createTar("artifact.tar.gz", true, entries),
+		suite.createZip("artifact.zip", entries),
+	}
+
+	for _, excludePaths := range [][]string{
+		{},
+		{"a"},
+		{"a/**"},
+		{"**/*.md"},
+		{"docs/**/README.md"},
+		{"*"},
+		{"a*", "logs"},
+		{"a/link", "./a.txt"},
+	} {
+		want, err := DirSha256(dirPath, excludePaths, logger.NewStandardLogger())
+		require.NoError(suite.T(), err)
+		for _, archivePath := range archives {
+			got, err := ArchiveSha256(archivePath, excludePaths, logger.NewStandardLogger())
+			require.NoError(suite.T(), err)
+			require.Equalf(suite.T(), want, got, "fingerprint of %s excluding %v", filepath.Base(archivePath), excludePaths)
+		}
+	}
+}
+
+func (suite *ArchiveTestSuite) TestArchiveManifestSha256() {
+	archivePath := suite.createZip("artifact.zip", map[string]archiveTestEntry{
+		".kosli_ignore": {content: "logs\n"},
+		"app/main":      {content: "binary"},
+		"logs/app.log":  {content: "log"},
+	})
+
+	manifest, err := ArchiveManifestSha256(archivePath, []string{}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), manifest.entriesSha256(), manifest.Fingerprint)
+	require.Equal(suite.T(), []DirManifestEntry{
+		{Path: ".kosli_ignore", Type: "file", NameDigest: sha256String(".kosli_ignore"), ContentDigest: sha256String("logs\n")},
+		{Path: "app", Type: "dir", NameDigest: sha256String("app")},
+		{Path: "app/main", Type: "file", NameDigest: sha256String("main"), ContentDigest: sha256String("binary")},
+	}, manifest.Entries)
+	require.Equal(suite.T(), []ExcludedPath{{Path: "logs", Pattern: "logs", Source: ExcludedByIgnoreFile}}, manifest.Excluded)
+}
+
+func (suite *ArchiveTestSuite) TestArchiveSha256Errors() {
+	notAnArchive := suite.createDir("files", map[string]archiveTestEntry{"file.txt": {content: "not an archive"}})
+	for _, t := range []struct {
+		name        string
+		archivePath string
+		wantError   string
+	}{
+		{
+			name:        "a file that is not an archive is not supported",
+			archivePath: filepath.Join(notAnArchive, "file.txt"),
+			wantError:   "unsupported archive format. Supported formats are: tar, tar.gz, zip (e.g. jar, wheel)",
+		},
+		{
+			name:        "entries outside of the archive root are not supported",
+			archivePath: suite.createTar("outside.tar", false, map[string]archiveTestEntry{"../etc/passwd": {content: "x"}}),
+			wantError:   "archive entry ./../etc/passwd is outside of the archive root",
+		},
+		{
+			name:        "symlinks must point to files in the archive",
+			archivePath: suite.createTar("absolute-link.tar", false, map[string]archiveTestEntry{"link": {link: "/etc/passwd"}}),
+			wantError:   "symlink link points to /etc/passwd which is not in the archive",
+		},
+		{
+			name:        "symlinks to directories cannot be fingerprinted",
+			archivePath: suite.createTar("dir-link.tar", false, map[string]archiveTestEntry{"dir/file": {content: "x"}, "link": {link: "dir"}}),
+			wantError:   "read link: is a directory",
+		},
+	} {
+		suite.Run(t.name, func() {
+			_, err := ArchiveSha256(t.archivePath, []string{}, logger.NewStandardLogger())
+			require.ErrorContains(suite.T(), err, t.wantError)
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestArchiveTestSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveTestSuite))
+}
@@ -0,0 +1,175 @@
+package digest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileDigestCacheVersion is the version of the cache file format.
+// Cache files with a different version are ignored.
+const fileDigestCacheVersion = 2
+
+// fileDigestCacheMaxAge is how long the digest of a file is kept in the cache after it was last used
+var fileDigestCacheMaxAge = 30 * 24 * time.Hour
+
+// FileDigestCache stores the content digests of files so that files that did not
+// change (same size, modification time and inode) are not hashed again.
+// The cache file can be shared by several runs (e.g. snapshots of different environments),
+// and the digests of files that were not used for fileDigestCacheMaxAge are dropped.
+// A nil *FileDigestCache is valid and hashes every file.
+type FileDigestCache struct {
+	path  string
+	mutex sync.Mutex
+	// entries loaded from the cache file
+	loaded map[string]fileDigestCacheEntry
+	// entries used (or added) since the cache was loaded
+	used map[string]fileDigestCacheEntry
+}
+
+// fileDigestCacheEntry is the cached content digest of a file
+type fileDigestCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+	Inode   uint64 `json:"inode"`
+	Digest  string `json:"digest"`
+	// UsedAt is when the digest was last used, as a unix timestamp
+	UsedAt int64 `json:"usedAt"`
+}
+
+// fileDigestCacheFile is the content of a cache file
+type fileDigestCacheFile struct {
+	Version int                             `json:"version"`
+	Files   map[string]fileDigestCacheEntry `json:"files"`
+}
+
+// LoadFileDigestCache loads the digest cache stored at path.
+// A missing or unreadable cache file gives an empty cache.
+// An empty path gives a nil cache (i.e. no caching).
+func LoadFileDigestCache(path string) (*FileDigestCache, error) {
+	if path == "" {
+		return nil, nil
+	}
+	files, err := readFileDigestCacheFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDigestCache{
+		path:   path,
+		loaded: files,
+		used:   map[string]fileDigestCacheEntry{},
+	}, nil
+}
+
+// readFileDigestCacheFile returns the entries of a cache file.
+// A missing cache file, or one that is corrupt or has another version, has no entries.
+func readFileDigestCacheFile(path string) (map[string]fileDigestCacheEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]fileDigestCacheEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to read fingerprint cache: %v", err)
+	}
+	var cacheFile fileDigestCacheFile
+	if json.Unmarshal(content, &cacheFile) != nil || cacheFile.Version != fileDigestCacheVersion || cacheFile.Files == nil {
+		return map[string]fileDigestCacheEntry{}, nil
+	}
+	return cacheFile.Files, nil
+}
+
+// FileSha256 returns the sha256 digest of a file, from the cache if the file did not change
+// since it was cached.
+func (c *FileDigestCache) FileSha256(path string) (string, error) {
+	if c == nil {
+		return FileSha256(path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// symlinks are followed, as when hashing the file
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	entry := fileDigestCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Inode:   fileInode(info),
+		UsedAt:  time.Now().Unix(),
+	}
+
+	c.mutex.Lock()
+	cached, ok := c.loaded[absPath]
+	c.mutex.Unlock()
+	if ok && cached.Size == entry.Size && cached.ModTime == entry.ModTime && cached.Inode == entry.Inode {
+		entry.Digest = cached.Digest
+	} else {
+		entry.Digest, err = FileSha256(path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	c.mutex.Lock()
+	c.used[absPath] = entry
+	c.mutex.Unlock()
+	return entry.Digest, nil
+}
+
+// Save writes the files used since the cache was loaded to the cache file, merged with the files in
+// the cache file (which may have been saved by another run in the meantime). Files that were not used
+// for fileDigestCacheMaxAge are dropped.
+func (c *FileDigestCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	files, err := readFileDigestCacheFile(c.path)
+	if err != nil {
+		files = map[string]fileDigestCacheEntry{}
+	}
+	oldest := time.Now().Add(-fileDigestCacheMaxAge).Unix()
+	for path, entry := range files {
+		if entry.UsedAt < oldest {
+			delete(files, path)
+		}
+	}
+	c.mutex.Lock()
+	for path, entry := range c.used {
+		files[path] = entry
+	}
+	c.mutex.Unlock()
+	content, err := json.Marshal(fileDigestCacheFile{Version: fileDigestCacheVersion, Files: files})
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file first so that an interrupted save does not leave a truncated cache
+	dir := filepath.Dir(c.path)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to save fingerprint cache: %v", err)
+	}
+	tmpFile, err := os.CreateTemp(dir, ".kosli-fingerprint-cache-*")
+	if err != nil {
+		return fmt.Errorf("failed to save fingerprint cache: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), c.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save fingerprint cache: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package digest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type FileDigestCacheTestSuite struct {
+	suite.Suite
+	tmpDir    string
+	cachePath string
+}
+
+func (suite *FileDigestCacheTestSuite) SetupTest() {
+	suite.tmpDir = suite.T().TempDir()
+	suite.cachePath = filepath.Join(suite.tmpDir, "cache", "fingerprints.json")
+	for path, content := range map[string]string{
+		"artifact/file1":          "content1",
+		"artifact/sub/file2":      "content2",
+		"artifact/sub/deep/file3": "content3",
+		"other/file4":             "content4",
+	} {
+		path = filepath.Join(suite.tmpDir, path)
+		require.NoError(suite.T(), os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(suite.T(), os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func (suite *FileDigestCacheTestSuite) TestDirSha256WithCacheGivesTheSameFingerprint() {
+	artifactPath := filepath.Join(suite.tmpDir, "artifact")
+	want, err := DirSha256(artifactPath, []string{}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+
+	defer func(concurrency int) { dirSha256Concurrency = concurrency }(dirSha256Concurrency)
+	for _, concurrency := range []int{1, 2, 16} {
+		dirSha256Concurrency = concurrency
+		cache, err := LoadFileDigestCache(suite.cachePath)
+		require.NoError(suite.T(), err)
+
+		got, err := DirSha256WithCache(artifactPath, []string{}, cache, logger.NewStandardLogger())
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), want, got)
+		require.NoError(suite.T(), cache.Save())
+	}
+}
+
+func (suite *FileDigestCacheTestSuite) TestUnchangedFilesAreNotHashedAgain() {
+	file1 := filepath.Join(suite.tmpDir, "artifact", "file1")
+	cache, err := LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	_, err = DirSha256WithCache(filepath.Join(suite.tmpDir, "artifact"), []string{}, cache, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), cache.Save())
+
+	// a cached digest is used as long as the file does not change
+	cache, err = LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), cache.loaded, 3)
+	entry := cache.loaded[file1]
+	entry.Digest = "cached-digest"
+	cache.loaded[file1] = entry
+	digest, err := cache.FileSha256(file1)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "cached-digest", digest)
+
+	// a changed file is hashed again
+	require.NoError(suite.T(), os.WriteFile(file1, []byte("changed content"), 0644))
+	digest, err = cache.FileSha256(file1)
+	require.NoError(suite.T(), err)
+	want, err := FileSha256(file1)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), want, digest)
+}
+
+func (suite *FileDigestCacheTestSuite) TestSaveMergesTheFilesOfOtherRuns() {
+	// two runs sharing the cache file, e.g. snapshots of two environments
+	artifactCache, err := LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	otherCache, err := LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+
+	_, err = DirSha256WithCache(filepath.Join(suite.tmpDir, "artifact"), []string{}, artifactCache, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	_, err = DirSha256WithCache(filepath.Join(suite.tmpDir, "other"), []string{}, otherCache, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), artifactCache.Save())
+	require.NoError(suite.T(), otherCache.Save())
+
+	cache, err := LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), cache.loaded, 4)
+	require.Contains(suite.T(), cache.loaded, filepath.Join(suite.tmpDir, "artifact", "file1"))
+	require.Contains(suite.T(), cache.loaded, filepath.Join(suite.tmpDir, "other", "file4"))
+}
+
+func (suite *FileDigestCacheTestSuite) TestSaveDropsFilesNotUsedForMaxAge() {
+	cache, err := LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	_, err = DirSha256WithCache(filepath.Join(suite.tmpDir, "artifact"), []string{}, cache, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), cache.Save())
+
+	defer func(maxAge time.Duration) { fileDigestCacheMaxAge = maxAge }(fileDigestCacheMaxAge)
+	fileDigestCacheMaxAge = -time.Hour
+	cache, err = LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	_, err = DirSha256WithCache(filepath.Join(suite.tmpDir, "other"), []string{}, cache, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), cache.Save())
+
+	cache, err = LoadFileDigestCache(suite.cachePath)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), cache.loaded, 1)
+	require.Contains(suite.T(), cache.loaded, filepath.Join(suite.tmpDir, "other", "file4"))
+}
+
+func (suite *FileDigestCacheTestSuite) TestLoadFileDigestCache() {
+	cache, err := LoadFileDigestCache("")
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), cache)
+	file1 := filepath.Join(suite.tmpDir, "artifact", "file1")
+	digest, err := cache.FileSha256(file1)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "d0b425e00e15a0d36b9b361f02bab63563aed6cb4665083905386c55d5b679fa", digest)
+	require.NoError(suite.T(), cache.Save())
+
+	for name, content := range map[string]string{
+		"a corrupt cache file gives an empty cache":              "{not json",
+		"a cache file with another version gives an empty cache": `{"version": 0, "files": {"/a": {"digest": "x"}}}`,
+	} {
+		suite.Run(name, func() {
+			require.NoError(suite.T(), os.MkdirAll(filepath.Dir(suite.cachePath), 0755))
+			require.NoError(suite.T(), os.WriteFile(suite.cachePath, []byte(content), 0644))
+			cache, err := LoadFileDigestCache(suite.cachePath)
+			require.NoError(suite.T(), err)
+			require.Empty(suite.T(), cache.loaded)
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFileDigestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(FileDigestCacheTestSuite))
+}
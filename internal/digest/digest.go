@@ -13,14 +13,14 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/containers/image/v5/docker"
-	"github.com/containers/image/v5/types"
 	"github.com/docker/docker/client"
 	"github.com/kosli-dev/cli/internal/logger"
 	"github.com/kosli-dev/cli/internal/requests"
-	"github.com/kosli-dev/cli/internal/utils"
 	"github.com/yargevad/filepathx"
 )
 
@@ -32,52 +32,73 @@ var (
 
 // DirSha256 returns sha256 digest of a directory
 func DirSha256(dirPath string, excludePaths []string, logger *logger.Logger) (string, error) {
+	return DirSha256WithCache(dirPath, excludePaths, nil, logger)
+}
+
+// DirSha256WithCache returns sha256 digest of a directory.
+// The content digests of files that did not change since they were added to the cache
+// are taken from the cache instead of hashing the files again. The cache can be nil.
+func DirSha256WithCache(dirPath string, excludePaths []string, cache *FileDigestCache, logger *logger.Logger) (string, error) {
+	fingerprint, _, _, err := fingerprintDir(dirPath, excludePaths, cache, logger)
+	return fingerprint, err
+}
+
+// fingerprintDir calculates the fingerprint of a directory and returns it with the
+// entries it is calculated from and the paths that were excluded
+func fingerprintDir(dirPath string, excludePaths []string, cache *FileDigestCache, logger *logger.Logger) (string, []*fingerprintEntry, []ExcludedPath, error) {
 	logger.Debug("calculating fingerprint for path [%s] -- excluding paths: %s", dirPath, excludePaths)
 	info, err := os.Stat(dirPath)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 	if !info.IsDir() {
-		return "", fmt.Errorf("%s is not a directory", dirPath)
-	}
-
-	tmpDir, err := os.MkdirTemp("", "*")
-	if err != nil {
-		return "", err
+		return "", nil, nil, fmt.Errorf("%s is not a directory", dirPath)
 	}
-	defer os.RemoveAll(tmpDir)
 
-	digestsFile, err := os.Create(filepath.Join(tmpDir, "digests"))
-	if err != nil {
-		return "", err
-	}
-	defer digestsFile.Close()
 	ignoreFilePath := filepath.Join(dirPath, ".kosli_ignore")
 	ignoredPaths, err := excludePathsFromFile(ignoreFilePath)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
 	if len(ignoredPaths) > 0 {
 		logger.Debug("  -> ignore file used %s -- excluding paths: %s", ignoreFilePath, ignoredPaths)
 	}
-	excludePaths = append(excludePaths, ignoredPaths...)
-	err = calculateDirContentSha256(digestsFile, dirPath, tmpDir, excludePaths, logger)
+	entries, excluded, err := listFingerprintEntries(dirPath, excludePaths, ignoredPaths, logger)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
+	}
+	err = hashFileContents(entries, cache)
+	if err != nil {
+		return "", nil, nil, err
 	}
+	return entriesSha256(entries, logger), entries, excluded, nil
+}
 
-	return FileSha256(digestsFile.Name())
+// entriesSha256 calculates a directory fingerprint from its entries (in walk order) with their content digests.
+// The fingerprint is the digest of the name digest of each entry, each followed by the content digest for files.
+func entriesSha256(entries []*fingerprintEntry, logger *logger.Logger) string {
+	hasher := sha256.New()
+	for _, entry := range entries {
+		entry.nameSha256 = sha256String(entry.name)
+		hasher.Write([]byte(entry.nameSha256))
+		if entry.isDir {
+			logger.Debug("dir path: %s -- dirname digest: %v", entry.path, entry.nameSha256)
+		} else {
+			logger.Debug("file path: %s -- filename digest: %s", entry.path, entry.nameSha256)
+			logger.Debug("filename: %s -- content digest: %s", entry.path, entry.contentSha256)
+			hasher.Write([]byte(entry.contentSha256))
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
 // OciSha256 gets the digest of a docker/OCI image from its registry
 func OciSha256(artifactName string, registryUsername string, registryPassword string) (string, error) {
 	imageName := fmt.Sprintf("//%s", artifactName)
 	ctx := context.Background()
-	sysCtx := &types.SystemContext{
-		DockerAuthConfig: &types.DockerAuthConfig{
-			Username: registryUsername,
-			Password: registryPassword,
-		},
+	sysCtx, err := RegistrySystemContext(artifactName, registryUsername, registryPassword)
+	if err != nil {
+		return "", err
 	}
 
 	// Parse image reference
@@ -94,18 +115,46 @@ func OciSha256(artifactName string, registryUsername string, registryPassword st
 	return strings.Split(digest.String(), "sha256:")[1], nil
 }
 
-// calculateDirContentSha256 calculates a sha256 digest for a directory content
-func calculateDirContentSha256(digestsFile *os.File, dirPath, tmpDir string, excludePaths []string, logger *logger.Logger) error {
-	pathsToExclude := []string{}
-	for _, p := range excludePaths {
-		found, err := filepathx.Glob(filepath.Join(dirPath, p))
-		if err != nil {
-			return err
+// dirSha256Concurrency is the number of files hashed in parallel when fingerprinting a directory
+var dirSha256Concurrency = runtime.NumCPU()
+
+// fingerprintEntry is a file or directory included in a directory fingerprint
+type fingerprintEntry struct {
+	path          string
+	name          string
+	isDir         bool
+	nameSha256    string
+	contentSha256 string
+}
+
+// listFingerprintEntries lists the entries of a directory (recursively and in lexical order) that are not excluded
+// by excludePaths or by ignoredPaths (from the .kosli_ignore file), and the paths that were excluded
+func listFingerprintEntries(dirPath string, excludePaths, ignoredPaths []string, logger *logger.Logger) ([]*fingerprintEntry, []ExcludedPath, error) {
+	// the first pattern matching a path is reported as the reason for excluding it
+	pathsToExclude := map[string]ExcludedPath{}
+	for _, patterns := range []struct {
+		source   string
+		patterns []string
+	}{
+		{ExcludedByExcludePaths, excludePaths},
+		{ExcludedByIgnoreFile, ignoredPaths},
+	} {
+		for _, p := range patterns.patterns {
+			found, err := filepathx.Glob(filepath.Join(dirPath, p))
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, path := range found {
+				if _, ok := pathsToExclude[path]; !ok {
+					pathsToExclude[path] = ExcludedPath{Pattern: p, Source: patterns.source}
+				}
+			}
 		}
-		pathsToExclude = append(pathsToExclude, found...)
 	}
 
-	return filepath.WalkDir(dirPath, func(path string, info fs.DirEntry, err error) error {
+	entries := []*fingerprintEntry{}
+	excluded := []ExcludedPath{}
+	err := filepath.WalkDir(dirPath, func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -116,7 +165,9 @@ func calculateDirContentSha256(digestsFile *os.File, dirPath, tmpDir string, exc
 			return nil
 		}
 
-		if utils.Contains(pathsToExclude, path) {
+		if excludedPath, ok := pathsToExclude[path]; ok {
+			excludedPath.Path = manifestPath(dirPath, path)
+			excluded = append(excluded, excludedPath)
 			if info.IsDir() {
 				logger.Debug("skipping dir %s (and its contents) as it matches excluded paths", path)
 				return fs.SkipDir
@@ -125,45 +176,63 @@ func calculateDirContentSha256(digestsFile *os.File, dirPath, tmpDir string, exc
 			return nil
 		}
 
-		nameSha256, err := addNameDigest(tmpDir, info.Name(), digestsFile)
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			logger.Debug("dir path: %s -- dirname digest: %v", path, nameSha256)
-		} else {
-			logger.Debug("file path: %s -- filename digest: %s", path, nameSha256)
-			fileContentSha256, err := FileSha256(path)
-			if err != nil {
-				return err
-			}
-			logger.Debug("filename: %s -- content digest: %s", path, fileContentSha256)
-			if _, err := digestsFile.Write([]byte(fileContentSha256)); err != nil {
-				return err
-			}
-		}
-
+		entries = append(entries, &fingerprintEntry{path: path, name: info.Name(), isDir: info.IsDir()})
 		return nil
 	})
+	return entries, excluded, err
 }
 
-// addNameDigest calculates the sha256 digest of the filename and adds it to the digests file
-func addNameDigest(tmpDir string, filename string, digestsFile *os.File) (string, error) {
-	nameFilePath := filepath.Join(tmpDir, "name")
-	err := utils.CreateFileWithContent(nameFilePath, filename)
-	if err != nil {
-		return "", err
+// hashFileContents sets the content digest of the file entries, hashing up to
+// dirSha256Concurrency files at a time. If hashing fails, the error of the first
+// failing entry is returned.
+func hashFileContents(entries []*fingerprintEntry, cache *FileDigestCache) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make([]error, len(entries))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < max(dirSha256Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				var err error
+				entries[index].contentSha256, err = cache.FileSha256(entries[index].path)
+				if err != nil {
+					errs[index] = err
+					cancel()
+				}
+			}
+		}()
 	}
 
-	nameSha256, err := FileSha256(nameFilePath)
-	if err != nil {
-		return "", err
+send:
+	for index, entry := range entries {
+		if entry.isDir {
+			continue
+		}
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break send
+		}
 	}
-	if _, err := digestsFile.Write([]byte(nameSha256)); err != nil {
-		return "", err
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
 	}
-	return nameSha256, nil
+	return nil
+}
+
+// sha256String returns the sha256 digest of a string
+func sha256String(content string) string {
+	digest := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(digest[:])
 }
 
 // FileSha256 returns a sha256 digest of a file.
@@ -300,23 +369,28 @@ func excludePathsFromFile(path string) ([]string, error) {
 	file, err := os.Open(path)
 	if err == nil {
 		defer file.Close()
-		var excludes = []string{}
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			line = removeComments(line)
-			line = strings.TrimSpace(line)
-			if len(line) > 0 {
-				excludes = append(excludes, line)
-			}
-		}
-		return excludes, nil
+		return excludePathsFromReader(file), nil
 	} else if errors.Is(err, fs.ErrNotExist) {
 		return []string{}, nil
 	}
 	return nil, err
 }
 
+// excludePathsFromReader reads exclude paths from the content of an ignore file
+func excludePathsFromReader(reader io.Reader) []string {
+	var excludes = []string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		line = removeComments(line)
+		line = strings.TrimSpace(line)
+		if len(line) > 0 {
+			excludes = append(excludes, line)
+		}
+	}
+	return excludes
+}
+
 func removeComments(line string) string {
 	parts := strings.SplitN(line, "#", 2)
 	return strings.TrimRight(parts[0], " ")
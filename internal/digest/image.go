@@ -0,0 +1,488 @@
+package digest
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/logger"
+)
+
+const (
+	// ociRefNameAnnotation and containerdImageNameAnnotation are the index annotations naming the images of a layout
+	ociRefNameAnnotation          = "org.opencontainers.image.ref.name"
+	containerdImageNameAnnotation = "io.containerd.image.name"
+
+	dockerManifestMediaType          = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerConfigMediaType            = "application/vnd.docker.container.image.v1+json"
+	dockerGzipLayerMediaType         = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	dockerUncompressedLayerMediaType = "application/vnd.docker.image.rootfs.diff.tar"
+	ociUncompressedLayerMediaType    = "application/vnd.oci.image.layer.v1.tar"
+
+	// files of OCI image layouts and docker archives
+	imageLayoutVersionFile    = "oci-layout"
+	imageLayoutIndexFile      = "index.json"
+	imageLayoutBlobsDir       = "blobs"
+	dockerArchiveManifestFile = "manifest.json"
+
+	// imageLayoutSupportedAlgorithm is the only digest algorithm supported for blobs
+	imageLayoutSupportedAlgorithm = "sha256"
+	// maxImageMetadataFileSize is the maximum size of the manifests, indexes and configs of images
+	maxImageMetadataFileSize = 4 * 1024 * 1024
+)
+
+// imageDescriptor is a descriptor of a blob in an image layout.
+// The field order matches the serialization used by registries and image tools.
+type imageDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Size        int64             `json:"size"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// imageManifest is an image manifest or an image index, as read from an image layout
+type imageManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        *imageDescriptor  `json:"config,omitempty"`
+	Layers        []imageDescriptor `json:"layers,omitempty"`
+	Manifests     []imageDescriptor `json:"manifests,omitempty"`
+}
+
+// dockerSchema2Manifest is a Docker image manifest (schema 2) as serialized when pushing a docker archive
+// with compressed layers (e.g. created by kaniko or crane)
+type dockerSchema2Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        imageDescriptor   `json:"config"`
+	Layers        []imageDescriptor `json:"layers"`
+}
+
+// dockerArchiveImage is an image in the manifest.json of a docker archive
+type dockerArchiveImage struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// imageFile is the metadata of a file of an image layout or archive
+type imageFile struct {
+	size    int64
+	sha256  string
+	gzipped bool
+	// content is only kept for small (metadata) files
+	content []byte
+}
+
+// imageFiles gives access to the files of an image layout dir or archive
+type imageFiles interface {
+	stat(name string) (*imageFile, error)
+	read(name string) ([]byte, error)
+}
+
+// OciDirSha256 returns the digest of the image manifest (or index) in an OCI image layout directory,
+// which is the digest a registry reports for the image once it is pushed.
+// The layout path can be followed by ":REF" to select an image by its reference name when
+// the layout contains several images.
+func OciDirSha256(layoutPath string, logger *logger.Logger) (string, error) {
+	layoutPath, ref := splitImageReference(layoutPath)
+	info, err := os.Stat(layoutPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", layoutPath)
+	}
+	files := &imageDirFiles{dirPath: layoutPath}
+	if _, err := files.stat(imageLayoutVersionFile); err != nil {
+		return "", fmt.Errorf("%s is not an OCI image layout: %v", layoutPath, err)
+	}
+	descriptor, err := selectIndexImage(files, ref)
+	if err != nil {
+		return "", err
+	}
+	return imageDescriptorDigest(files, descriptor, logger)
+}
+
+// DockerArchiveSha256 returns the digest the registry reports for an image saved in a docker
+// archive (e.g. with "docker save", optionally gzipped) once the image is pushed.
+// The archive path can be followed by ":REF" to select an image by its tag when the archive
+// contains several images. OCI image layout archives are supported too.
+func DockerArchiveSha256(archivePath string, logger *logger.Logger) (string, error) {
+	archivePath, ref := splitImageReference(archivePath)
+	files, err := readImageArchive(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read docker archive %s: %v", archivePath, err)
+	}
+
+	if _, err := files.stat(dockerArchiveManifestFile); err != nil {
+		// an OCI image layout archive
+		descriptor, err := selectIndexImage(files, ref)
+		if err != nil {
+			return "", err
+		}
+		return imageDescriptorDigest(files, descriptor, logger)
+	}
+
+	image, err := selectDockerArchiveImage(files, ref)
+	if err != nil {
+		return "", err
+	}
+	config, err := files.stat(image.Config)
+	if err != nil {
+		return "", err
+	}
+	configDigest := imageLayoutSupportedAlgorithm + ":" + config.sha256
+
+	// since docker 25, archives also contain the OCI index and manifests of their images
+	if _, err := files.stat(imageLayoutIndexFile); err == nil {
+		descriptor, err := findIndexImageByConfig(files, configDigest)
+		if err != nil {
+			return "", err
+		}
+		if descriptor != nil {
+			return imageDescriptorDigest(files, descriptor, logger)
+		}
+	}
+
+	// without the manifest, it is the one pushed for the archive layers as they are,
+	// which is only possible when the layers are already compressed
+	manifest := dockerSchema2Manifest{
+		SchemaVersion: 2,
+		MediaType:     dockerManifestMediaType,
+		Config:        imageDescriptor{MediaType: dockerConfigMediaType, Size: config.size, Digest: configDigest},
+		Layers:        []imageDescriptor{},
+	}
+	for _, layerPath := range image.Layers {
+		layer, err := files.stat(layerPath)
+		if err != nil {
+			return "", err
+		}
+		if !layer.gzipped {
+			return "", fmt.Errorf("the image in %s has uncompressed layers (e.g. %s): its manifest digest depends on how the layers "+
+				"are compressed when pushed, so it can only be known after the push", archivePath, layerPath)
+		}
+		manifest.Layers = append(manifest.Layers, imageDescriptor{
+			MediaType: dockerGzipLayerMediaType,
+			Size:      layer.size,
+			Digest:    imageLayoutSupportedAlgorithm + ":" + layer.sha256,
+		})
+	}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	return sha256String(string(content)), nil
+}
+
+// splitImageReference splits "PATH:REF" into the path and the reference (which can contain ":" too,
+// e.g. "registry:5000/app:1.0"). A path that exists has no reference.
+func splitImageReference(name string) (string, string) {
+	if _, err := os.Stat(name); err == nil {
+		return name, ""
+	}
+	for index := strings.Index(name, ":"); index > 0; {
+		if _, err := os.Stat(name[:index]); err == nil {
+			return name[:index], name[index+1:]
+		}
+		next := strings.Index(name[index+1:], ":")
+		if next < 0 {
+			break
+		}
+		index += next + 1
+	}
+	return name, ""
+}
+
+// selectIndexImage selects an image in the index of an image layout, by reference name if ref is set
+func selectIndexImage(files imageFiles, ref string) (*imageDescriptor, error) {
+	index, err := readImageManifest(files, imageLayoutIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []imageDescriptor{}
+	refs := []string{}
+	for _, descriptor := range index.Manifests {
+		refName := descriptor.Annotations[ociRefNameAnnotation]
+		if refName != "" {
+			refs = append(refs, refName)
+		}
+		if ref == "" || ref == refName || ref == descriptor.Annotations[containerdImageNameAnnotation] {
+			candidates = append(candidates, descriptor)
+		}
+	}
+	switch {
+	case len(candidates) == 0 && ref != "":
+		return nil, fmt.Errorf("no image with reference %s found. Available references: [%s]", ref, strings.Join(refs, ", "))
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("no image found in %s", imageLayoutIndexFile)
+	case len(candidates) > 1:
+		return nil, fmt.Errorf("found %d images. Select one by appending :REF to the path, where REF is one of: [%s]", len(candidates), strings.Join(refs, ", "))
+	}
+	return &candidates[0], nil
+}
+
+// selectDockerArchiveImage selects an image in the manifest.json of a docker archive, by tag if ref is set
+func selectDockerArchiveImage(files imageFiles, ref string) (*dockerArchiveImage, error) {
+	content, err := files.read(dockerArchiveManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	images := []dockerArchiveImage{}
+	err = json.Unmarshal(content, &images)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", dockerArchiveManifestFile, err)
+	}
+	tags := []string{}
+	candidates := []dockerArchiveImage{}
+	for _, image := range images {
+		tags = append(tags, image.RepoTags...)
+		if ref == "" {
+			candidates = append(candidates, image)
+			continue
+		}
+		for _, tag := range image.RepoTags {
+			if tag == ref {
+				candidates = append(candidates, image)
+				break
+			}
+		}
+	}
+	switch {
+	case len(candidates) == 0 && ref != "":
+		return nil, fmt.Errorf("no image with tag %s found. Available tags: [%s]", ref, strings.Join(tags, ", "))
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("no image found in %s", dockerArchiveManifestFile)
+	case len(candidates) > 1:
+		return nil, fmt.Errorf("found %d images. Select one by appending :TAG to the path, where TAG is one of: [%s]", len(candidates), strings.Join(tags, ", "))
+	}
+	return &candidates[0], nil
+}
+
+// findIndexImageByConfig returns the descriptor in the index of an image layout of the image
+// (or the image index containing the image) with the given config digest, or nil if there is none
+func findIndexImageByConfig(files imageFiles, configDigest string) (*imageDescriptor, error) {
+	index, err := readImageManifest(files, imageLayoutIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	for i, descriptor := range index.Manifests {
+		found, err := imageHasConfig(files, descriptor, configDigest, 0)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return &index.Manifests[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// imageHasConfig checks if a manifest, or one of the manifests of an index, has the given config digest.
+// Manifests missing from the layout (e.g. of other platforms) are skipped.
+func imageHasConfig(files imageFiles, descriptor imageDescriptor, configDigest string, depth int) (bool, error) {
+	blobPath, err := imageBlobPath(descriptor.Digest)
+	if err != nil || depth > 8 {
+		return false, err
+	}
+	if _, err := files.stat(blobPath); err != nil {
+		return false, nil
+	}
+	manifest, err := readImageManifest(files, blobPath)
+	if err != nil {
+		return false, err
+	}
+	if manifest.Config != nil && manifest.Config.Digest == configDigest {
+		return true, nil
+	}
+	for _, child := range manifest.Manifests {
+		found, err := imageHasConfig(files, child, configDigest, depth+1)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// imageDescriptorDigest checks that the blob of a descriptor is in the layout and returns its digest
+func imageDescriptorDigest(files imageFiles, descriptor *imageDescriptor, logger *logger.Logger) (string, error) {
+	blobPath, err := imageBlobPath(descriptor.Digest)
+	if err != nil {
+		return "", err
+	}
+	blob, err := files.stat(blobPath)
+	if err != nil {
+		return "", err
+	}
+	algorithm, encoded, _ := strings.Cut(descriptor.Digest, ":")
+	if blob.sha256 != encoded {
+		return "", fmt.Errorf("the digest of %s does not match its %s digest %s", blobPath, algorithm, encoded)
+	}
+
+	manifest, err := readImageManifest(files, blobPath)
+	if err != nil {
+		return "", err
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == ociUncompressedLayerMediaType || layer.MediaType == dockerUncompressedLayerMediaType {
+			logger.Warning("the image has uncompressed layers. Tools that compress layers when pushing (e.g. docker push) " +
+				"push a different manifest, with a different digest")
+			break
+		}
+	}
+	return encoded, nil
+}
+
+// imageBlobPath returns the path of a blob in an image layout
+func imageBlobPath(digest string) (string, error) {
+	algorithm, encoded, found := strings.Cut(digest, ":")
+	if !found || algorithm != imageLayoutSupportedAlgorithm || ValidateDigest(encoded) != nil {
+		return "", fmt.Errorf("unsupported digest: %s", digest)
+	}
+	return path.Join(imageLayoutBlobsDir, algorithm, encoded), nil
+}
+
+// readImageManifest reads an image manifest or index from a layout
+func readImageManifest(files imageFiles, name string) (*imageManifest, error) {
+	content, err := files.read(name)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &imageManifest{}
+	err = json.Unmarshal(content, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return manifest, nil
+}
+
+// imageDirFiles are the files of an image layout directory
+type imageDirFiles struct {
+	dirPath string
+}
+
+func (d *imageDirFiles) stat(name string) (*imageFile, error) {
+	file, err := os.Open(filepath.Join(d.dirPath, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return newImageFile(file, false)
+}
+
+func (d *imageDirFiles) read(name string) ([]byte, error) {
+	return readImageMetadataFile(filepath.Join(d.dirPath, filepath.FromSlash(name)))
+}
+
+// imageArchiveFiles are the files of an image archive, read in one pass
+type imageArchiveFiles struct {
+	files map[string]*imageFile
+}
+
+func (a *imageArchiveFiles) stat(name string) (*imageFile, error) {
+	file, ok := a.files[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	return file, nil
+}
+
+func (a *imageArchiveFiles) read(name string) ([]byte, error) {
+	file, err := a.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if file.content == nil {
+		return nil, fmt.Errorf("%s is too large to be image metadata", name)
+	}
+	return file.content, nil
+}
+
+// readImageArchive reads a (optionally gzipped) tar archive of an image, hashing its files
+func readImageArchive(archivePath string) (*imageArchiveFiles, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var tarReader *tar.Reader
+	if header, _ := reader.Peek(2); bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		tarReader = tar.NewReader(gzipReader)
+	} else {
+		tarReader = tar.NewReader(reader)
+	}
+
+	files := &imageArchiveFiles{files: map[string]*imageFile{}}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		files.files[path.Clean(header.Name)], err = newImageFile(tarReader, header.Size <= maxImageMetadataFileSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// newImageFile hashes the content of a file, keeping the content if keepContent is set
+func newImageFile(reader io.Reader, keepContent bool) (*imageFile, error) {
+	file := &imageFile{}
+	hasher := sha256.New()
+	var content bytes.Buffer
+	writer := io.Writer(hasher)
+	if keepContent {
+		writer = io.MultiWriter(hasher, &content)
+	}
+	bufferedReader := bufio.NewReader(reader)
+	if header, _ := bufferedReader.Peek(2); bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		file.gzipped = true
+	}
+	size, err := io.Copy(writer, bufferedReader)
+	if err != nil {
+		return nil, err
+	}
+	file.size = size
+	file.sha256 = hex.EncodeToString(hasher.Sum(nil))
+	if keepContent {
+		file.content = content.Bytes()
+	}
+	return file, nil
+}
+
+// readImageMetadataFile reads a (small) metadata file of an image layout directory
+func readImageMetadataFile(filePath string) ([]byte, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxImageMetadataFileSize {
+		return nil, errors.New(filePath + " is too large to be image metadata")
+	}
+	return os.ReadFile(filePath)
+}
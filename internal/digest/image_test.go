@@ -0,0 +1,191 @@
+package digest
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// the manifest digests of the images in testdata/images, as reported by go-containerregistry
+const (
+	appImageDigest   = "75e87de596bf82eb0333216852fbd50ca30592e5be63789e42827e84449dc7a3"
+	otherImageDigest = "ee6441d915dd8b9c30c3a5a53404a66b87a5c1f23681d124afb38bbe3a39b616"
+	multiIndexDigest = "d4ff9d435326163e39b1cc00bb62b3a3cbb73569304535026ec5afc2c8f2cd61"
+	imagesTestdata   = "testdata/images"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type ImageTestSuite struct {
+	suite.Suite
+	logger *logger.Logger
+}
+
+func (suite *ImageTestSuite) SetupTest() {
+	suite.logger = logger.NewStandardLogger()
+}
+
+func (suite *ImageTestSuite) TestOciDirSha256() {
+	for _, t := range []struct {
+		name        string
+		layoutPath  string
+		want        string
+		wantErrorRe string
+	}{
+		{
+			name:       "a layout with an image index returns the index digest",
+			layoutPath: "multi",
+			want:       multiIndexDigest,
+		},
+		{
+			name:       "an image is selected by its ref name",
+			layoutPath: "layout:1.0",
+			want:       appImageDigest,
+		},
+		{
+			name:       "another image is selected by its ref name",
+			layoutPath: "layout:2.0",
+			want:       otherImageDigest,
+		},
+		{
+			name:        "a layout with several images requires a ref name",
+			layoutPath:  "layout",
+			wantErrorRe: `found 2 images.*\[1\.0, 2\.0\]`,
+		},
+		{
+			name:        "an unknown ref name fails",
+			layoutPath:  "layout:3.0",
+			wantErrorRe: `no image with reference 3\.0 found`,
+		},
+		{
+			name:        "a dir which is not an OCI image layout fails",
+			layoutPath:  ".",
+			wantErrorRe: `is not an OCI image layout`,
+		},
+		{
+			name:        "a file fails",
+			layoutPath:  "one.tar",
+			wantErrorRe: `is not a directory`,
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := OciDirSha256(filepath.Join(imagesTestdata, t.layoutPath), suite.logger)
+			if t.wantErrorRe != "" {
+				require.Error(suite.T(), err)
+				require.Regexp(suite.T(), t.wantErrorRe, err.Error())
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+func (suite *ImageTestSuite) TestDockerArchiveSha256() {
+	for _, t := range []struct {
+		name        string
+		archivePath string
+		want        string
+		wantErrorRe string
+	}{
+		{
+			name:        "an archive with one image returns its manifest digest",
+			archivePath: "one.tar",
+			want:        appImageDigest,
+		},
+		{
+			name:        "an image is selected by its tag",
+			archivePath: "two.tar:example.com/app:1.0",
+			want:        appImageDigest,
+		},
+		{
+			name:        "another image is selected by its tag",
+			archivePath: "two.tar:example.com/other:2.0",
+			want:        otherImageDigest,
+		},
+		{
+			name:        "an archive with several images requires a tag",
+			archivePath: "two.tar",
+			wantErrorRe: `found 2 images.*\[example\.com/app:1\.0, example\.com/other:2\.0\]`,
+		},
+		{
+			name:        "an unknown tag fails",
+			archivePath: "two.tar:example.com/app:2.0",
+			wantErrorRe: `example\.com/app:2\.0`,
+		},
+		{
+			name:        "an OCI image layout archive is supported",
+			archivePath: "layout.tar:2.0",
+			want:        otherImageDigest,
+		},
+		{
+			name:        "a missing archive fails",
+			archivePath: "missing.tar",
+			wantErrorRe: `failed to read docker archive`,
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := DockerArchiveSha256(filepath.Join(imagesTestdata, t.archivePath), suite.logger)
+			if t.wantErrorRe != "" {
+				require.Error(suite.T(), err)
+				require.Regexp(suite.T(), t.wantErrorRe, err.Error())
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+func (suite *ImageTestSuite) TestDockerArchiveSha256FailsForUncompressedLayers() {
+	archivePath := filepath.Join(suite.T().TempDir(), "image.tar")
+	file, err := os.Create(archivePath)
+	require.NoError(suite.T(), err)
+	writer := tar.NewWriter(file)
+	for name, content := range map[string]string{
+		"manifest.json": `[{"Config":"config.json","RepoTags":["app:1.0"],"Layers":["layer.tar"]}]`,
+		"config.json":   `{"architecture":"amd64","os":"linux"}`,
+		"layer.tar":     "not gzipped",
+	} {
+		require.NoError(suite.T(), writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := writer.Write([]byte(content))
+		require.NoError(suite.T(), err)
+	}
+	require.NoError(suite.T(), writer.Close())
+	require.NoError(suite.T(), file.Close())
+
+	_, err = DockerArchiveSha256(archivePath, suite.logger)
+	require.Error(suite.T(), err)
+	require.Contains(suite.T(), err.Error(), "has uncompressed layers (e.g. layer.tar)")
+}
+
+func (suite *ImageTestSuite) TestSplitImageReference() {
+	for _, t := range []struct {
+		name     string
+		wantPath string
+		wantRef  string
+	}{
+		{name: "layout", wantPath: "layout"},
+		{name: "layout:1.0", wantPath: "layout", wantRef: "1.0"},
+		{name: "two.tar:localhost:5000/app:1.0", wantPath: "two.tar", wantRef: "localhost:5000/app:1.0"},
+		{name: "missing:1.0", wantPath: "missing:1.0"},
+	} {
+		suite.Run(t.name, func() {
+			path, ref := splitImageReference(filepath.Join(imagesTestdata, t.name))
+			require.Equal(suite.T(), filepath.Join(imagesTestdata, t.wantPath), path)
+			require.Equal(suite.T(), t.wantRef, ref)
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestImageTestSuite(t *testing.T) {
+	suite.Run(t, new(ImageTestSuite))
+}
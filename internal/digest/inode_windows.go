@@ -0,0 +1,11 @@
+//go:build windows
+
+package digest
+
+import "io/fs"
+
+// fileInode returns 0 as inode numbers are not available from fs.FileInfo on windows.
+// Cached digests are then checked against the file size and modification time only.
+func fileInode(info fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,187 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/logger"
+)
+
+const (
+	// ExcludedByExcludePaths is the source of paths excluded by the exclude paths passed to the fingerprint calculation
+	ExcludedByExcludePaths = "exclude"
+	// ExcludedByIgnoreFile is the source of paths excluded by the .kosli_ignore file of a directory
+	ExcludedByIgnoreFile = ".kosli_ignore"
+	// DirManifestEntryTypeDir and DirManifestEntryTypeFile are the types of directory manifest entries
+	DirManifestEntryTypeDir  = "dir"
+	DirManifestEntryTypeFile = "file"
+)
+
+// DirManifest explains the fingerprint of a directory with the digests it is calculated from
+type DirManifest struct {
+	Fingerprint string             `json:"fingerprint"`
+	Entries     []DirManifestEntry `json:"entries"`
+	Excluded    []ExcludedPath     `json:"excluded"`
+}
+
+// DirManifestEntry is a file or directory included in a directory fingerprint.
+// Paths are relative to the fingerprinted directory and use forward slashes.
+type DirManifestEntry struct {
+	Path          string `json:"path"`
+	Type          string `json:"type"`
+	NameDigest    string `json:"nameDigest"`
+	ContentDigest string `json:"contentDigest,omitempty"`
+}
+
+// ExcludedPath is a file or directory excluded from a directory fingerprint,
+// with the pattern that excluded it and where the pattern comes from
+type ExcludedPath struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`
+}
+
+// DirManifestChange is a path included in two manifests with different digests
+type DirManifestChange struct {
+	Path   string           `json:"path"`
+	Entry1 DirManifestEntry `json:"entry1"`
+	Entry2 DirManifestEntry `json:"entry2"`
+}
+
+// DirManifestDiff lists the paths that make the fingerprints of two directory manifests differ
+type DirManifestDiff struct {
+	Fingerprint1 string              `json:"fingerprint1"`
+	Fingerprint2 string              `json:"fingerprint2"`
+	OnlyIn1      []DirManifestEntry  `json:"onlyIn1"`
+	OnlyIn2      []DirManifestEntry  `json:"onlyIn2"`
+	Changed      []DirManifestChange `json:"changed"`
+}
+
+// DirManifestSha256 returns the manifest of a directory fingerprint, i.e. the fingerprint
+// with every included path and its digests, and every excluded path
+func DirManifestSha256(dirPath string, excludePaths []string, logger *logger.Logger) (*DirManifest, error) {
+	fingerprint, entries, excluded, err := fingerprintDir(dirPath, excludePaths, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+	return newDirManifest(dirPath, fingerprint, entries, excluded), nil
+}
+
+// ArchiveManifestSha256 returns the manifest of an archive fingerprint, i.e. the manifest of
+// the directory fingerprint of the extracted archive
+func ArchiveManifestSha256(archivePath string, excludePaths []string, logger *logger.Logger) (*DirManifest, error) {
+	fingerprint, entries, excluded, err := fingerprintArchive(archivePath, excludePaths, logger)
+	if err != nil {
+		return nil, err
+	}
+	return newDirManifest(archiveRoot, fingerprint, entries, excluded), nil
+}
+
+// newDirManifest creates the manifest of the fingerprint of a directory from its entries
+func newDirManifest(dirPath, fingerprint string, entries []*fingerprintEntry, excluded []ExcludedPath) *DirManifest {
+	manifest := &DirManifest{
+		Fingerprint: fingerprint,
+		Entries:     make([]DirManifestEntry, 0, len(entries)),
+		Excluded:    excluded,
+	}
+	for _, entry := range entries {
+		manifestEntry := DirManifestEntry{
+			Path:       manifestPath(dirPath, entry.path),
+			Type:       DirManifestEntryTypeFile,
+			NameDigest: entry.nameSha256,
+		}
+		if entry.isDir {
+			manifestEntry.Type = DirManifestEntryTypeDir
+		} else {
+			manifestEntry.ContentDigest = entry.contentSha256
+		}
+		manifest.Entries = append(manifest.Entries, manifestEntry)
+	}
+	return manifest
+}
+
+// LoadDirManifest loads a directory manifest from a JSON file and checks that
+// its fingerprint matches its entries
+func LoadDirManifest(path string) (*DirManifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &DirManifest{}
+	err = json.Unmarshal(content, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid fingerprint manifest: %v", path, err)
+	}
+	if fingerprint := manifest.entriesSha256(); fingerprint != manifest.Fingerprint {
+		return nil, fmt.Errorf("%s is not a valid fingerprint manifest: its entries give fingerprint %s instead of %s",
+			path, fingerprint, manifest.Fingerprint)
+	}
+	return manifest, nil
+}
+
+// entriesSha256 calculates the fingerprint from the manifest entries
+func (m *DirManifest) entriesSha256() string {
+	hasher := sha256.New()
+	for _, entry := range m.Entries {
+		hasher.Write([]byte(entry.NameDigest))
+		hasher.Write([]byte(entry.ContentDigest))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// ExcludedBy returns how a path (or one of its parent directories) was excluded
+// from the manifest, or nil if it was not excluded
+func (m *DirManifest) ExcludedBy(path string) *ExcludedPath {
+	for i, excluded := range m.Excluded {
+		if path == excluded.Path || strings.HasPrefix(path, excluded.Path+"/") {
+			return &m.Excluded[i]
+		}
+	}
+	return nil
+}
+
+// DiffDirManifests returns the paths that are only in one of the manifests or have different digests
+func DiffDirManifests(manifest1, manifest2 *DirManifest) *DirManifestDiff {
+	diff := &DirManifestDiff{
+		Fingerprint1: manifest1.Fingerprint,
+		Fingerprint2: manifest2.Fingerprint,
+		OnlyIn1:      []DirManifestEntry{},
+		OnlyIn2:      []DirManifestEntry{},
+		Changed:      []DirManifestChange{},
+	}
+	entries2 := make(map[string]DirManifestEntry, len(manifest2.Entries))
+	for _, entry := range manifest2.Entries {
+		entries2[entry.Path] = entry
+	}
+	paths1 := make(map[string]bool, len(manifest1.Entries))
+	for _, entry1 := range manifest1.Entries {
+		paths1[entry1.Path] = true
+		entry2, ok := entries2[entry1.Path]
+		if !ok {
+			diff.OnlyIn1 = append(diff.OnlyIn1, entry1)
+		} else if entry1 != entry2 {
+			diff.Changed = append(diff.Changed, DirManifestChange{Path: entry1.Path, Entry1: entry1, Entry2: entry2})
+		}
+	}
+	for _, entry2 := range manifest2.Entries {
+		if !paths1[entry2.Path] {
+			diff.OnlyIn2 = append(diff.OnlyIn2, entry2)
+		}
+	}
+	return diff
+}
+
+// manifestPath returns the path of an entry relative to the fingerprinted directory, with forward slashes,
+// so that manifests created on different operating systems can be compared
+func manifestPath(dirPath, path string) string {
+	relPath, err := filepath.Rel(dirPath, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(relPath)
+}
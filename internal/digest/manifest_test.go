@@ -0,0 +1,106 @@
+package digest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type DirManifestTestSuite struct {
+	suite.Suite
+	tmpDir string
+}
+
+func (suite *DirManifestTestSuite) SetupTest() {
+	suite.tmpDir = suite.T().TempDir()
+}
+
+func (suite *DirManifestTestSuite) createDir(name string, files map[string]string) string {
+	dirPath := filepath.Join(suite.tmpDir, name)
+	for path, content := range files {
+		path = filepath.Join(dirPath, path)
+		require.NoError(suite.T(), os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(suite.T(), os.WriteFile(path, []byte(content), 0644))
+	}
+	return dirPath
+}
+
+func (suite *DirManifestTestSuite) TestDirManifestSha256() {
+	dirPath := suite.createDir("artifact", map[string]string{
+		".kosli_ignore":     "**/*.log\n",
+		"app/main":          "binary",
+		"app/debug.log":     "log",
+		"docs/README.md":    "readme",
+		"docs/guide/one.md": "one",
+	})
+
+	manifest, err := DirManifestSha256(dirPath, []string{"docs"}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	fingerprint, err := DirSha256(dirPath, []string{"docs"}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+
+	require.Equal(suite.T(), fingerprint, manifest.Fingerprint)
+	require.Equal(suite.T(), fingerprint, manifest.entriesSha256())
+	require.Equal(suite.T(), []DirManifestEntry{
+		{Path: ".kosli_ignore", Type: "file", NameDigest: sha256String(".kosli_ignore"), ContentDigest: sha256String("**/*.log\n")},
+		{Path: "app", Type: "dir", NameDigest: sha256String("app")},
+		{Path: "app/main", Type: "file", NameDigest: sha256String("main"), ContentDigest: sha256String("binary")},
+	}, manifest.Entries)
+	require.Equal(suite.T(), []ExcludedPath{
+		{Path: "app/debug.log", Pattern: "**/*.log", Source: ExcludedByIgnoreFile},
+		{Path: "docs", Pattern: "docs", Source: ExcludedByExcludePaths},
+	}, manifest.Excluded)
+
+	require.Equal(suite.T(), &manifest.Excluded[1], manifest.ExcludedBy("docs/guide/one.md"))
+	require.Nil(suite.T(), manifest.ExcludedBy("docsy"))
+}
+
+func (suite *DirManifestTestSuite) TestDiffDirManifests() {
+	dirPath1 := suite.createDir("artifact1", map[string]string{"a.txt": "a", "b/c.txt": "c", "d": "d", "e.txt": "e"})
+	dirPath2 := suite.createDir("artifact2", map[string]string{"a.txt": "a", "b/c.txt": "changed", "d/f": "f", "g.txt": "g"})
+	manifest1, err := DirManifestSha256(dirPath1, []string{}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	manifest2, err := DirManifestSha256(dirPath2, []string{}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+
+	diff := DiffDirManifests(manifest1, manifest2)
+	require.Equal(suite.T(), manifest1.Fingerprint, diff.Fingerprint1)
+	require.Equal(suite.T(), manifest2.Fingerprint, diff.Fingerprint2)
+	require.Equal(suite.T(), []DirManifestEntry{
+		{Path: "e.txt", Type: "file", NameDigest: sha256String("e.txt"), ContentDigest: sha256String("e")},
+	}, diff.OnlyIn1)
+	require.Equal(suite.T(), []DirManifestEntry{
+		{Path: "d/f", Type: "file", NameDigest: sha256String("f"), ContentDigest: sha256String("f")},
+		{Path: "g.txt", Type: "file", NameDigest: sha256String("g.txt"), ContentDigest: sha256String("g")},
+	}, diff.OnlyIn2)
+	require.Equal(suite.T(), []DirManifestChange{
+		{
+			Path:   "b/c.txt",
+			Entry1: DirManifestEntry{Path: "b/c.txt", Type: "file", NameDigest: sha256String("c.txt"), ContentDigest: sha256String("c")},
+			Entry2: DirManifestEntry{Path: "b/c.txt", Type: "file", NameDigest: sha256String("c.txt"), ContentDigest: sha256String("changed")},
+		},
+		{
+			Path:   "d",
+			Entry1: DirManifestEntry{Path: "d", Type: "file", NameDigest: sha256String("d"), ContentDigest: sha256String("d")},
+			Entry2: DirManifestEntry{Path: "d", Type: "dir", NameDigest: sha256String("d")},
+		},
+	}, diff.Changed)
+
+	diff = DiffDirManifests(manifest1, manifest1)
+	require.Empty(suite.T(), diff.OnlyIn1)
+	require.Empty(suite.T(), diff.OnlyIn2)
+	require.Empty(suite.T(), diff.Changed)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestDirManifestTestSuite(t *testing.T) {
+	suite.Run(t, new(DirManifestTestSuite))
+}
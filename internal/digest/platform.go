@@ -0,0 +1,134 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+)
+
+// unknownPlatform is the platform of the entries of an image index which are not images for a platform
+// (e.g. the attestation manifests added by docker buildx)
+const unknownPlatform = "unknown/unknown"
+
+// ImagePlatformDigest is the manifest digest of one platform of a multi-platform image
+type ImagePlatformDigest struct {
+	// Platform is "os/architecture" or "os/architecture/variant", e.g. linux/arm64/v8
+	Platform string `json:"platform"`
+	Digest   string `json:"fingerprint"`
+}
+
+// OciPlatformSha256s returns the digest of the image index (manifest list) of a multi-platform image
+// in a registry, and the manifest digests of its platforms, in the order of the index.
+// The index digest is the one returned by OciSha256, while the platform digests are the ones
+// reported by the nodes that pulled the image (e.g. in K8S and ECS).
+func OciPlatformSha256s(artifactName string, registryUsername string, registryPassword string) (string, []ImagePlatformDigest, error) {
+	imageName := fmt.Sprintf("//%s", artifactName)
+	ctx := context.Background()
+	sysCtx, err := RegistrySystemContext(artifactName, registryUsername, registryPassword)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ref, err := docker.ParseReference(imageName)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse image reference for %s: %w", imageName, err)
+	}
+
+	source, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get manifest for %s: %w", imageName, err)
+	}
+	defer source.Close()
+
+	blob, mimeType, err := source.GetManifest(ctx, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get manifest for %s: %w", imageName, err)
+	}
+	if !manifest.MIMETypeIsMultiImage(mimeType) {
+		return "", nil, fmt.Errorf("%s is not a multi-platform image: its fingerprint is the digest of its only manifest", artifactName)
+	}
+	indexDigest, err := manifest.Digest(blob)
+	if err != nil {
+		return "", nil, err
+	}
+
+	platformDigests, err := imagePlatformDigests(blob, mimeType)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read the manifest list of %s: %w", imageName, err)
+	}
+	return indexDigest.Encoded(), platformDigests, nil
+}
+
+// PlatformImageReference returns the reference of the image of one platform of a multi-platform image
+// by its manifest digest, e.g. docker.io/library/nginx@sha256:<digest> for nginx:latest
+func PlatformImageReference(artifactName string, platformDigest string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(artifactName)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image reference for %s: %w", artifactName, err)
+	}
+	return fmt.Sprintf("%s@sha256:%s", reference.TrimNamed(named).String(), platformDigest), nil
+}
+
+// imagePlatformDigests returns the platform manifest digests of an image index (manifest list),
+// skipping the entries which are not images for a platform
+func imagePlatformDigests(blob []byte, mimeType string) ([]ImagePlatformDigest, error) {
+	list, err := manifest.ListFromBlob(blob, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	platformDigests := []ImagePlatformDigest{}
+	for _, instanceDigest := range list.Instances() {
+		instance, err := list.Instance(instanceDigest)
+		if err != nil {
+			return nil, err
+		}
+		platform := instance.ReadOnly.Platform
+		if platform == nil {
+			continue
+		}
+		name := platform.OS + "/" + platform.Architecture
+		if platform.Variant != "" {
+			name += "/" + platform.Variant
+		}
+		if name == unknownPlatform {
+			continue
+		}
+		platformDigests = append(platformDigests, ImagePlatformDigest{Platform: name, Digest: instanceDigest.Encoded()})
+	}
+	return platformDigests, nil
+}
+
+// SelectImagePlatforms returns the digests of the requested platforms ("os/architecture[/variant]").
+// A platform without a variant matches any variant of its architecture, as long as there is only one.
+func SelectImagePlatforms(platformDigests []ImagePlatformDigest, platforms []string) ([]ImagePlatformDigest, error) {
+	available := []string{}
+	for _, platformDigest := range platformDigests {
+		available = append(available, platformDigest.Platform)
+	}
+
+	selected := []ImagePlatformDigest{}
+	for _, platform := range platforms {
+		matches := []ImagePlatformDigest{}
+		for _, platformDigest := range platformDigests {
+			if platformDigest.Platform == platform {
+				matches = []ImagePlatformDigest{platformDigest}
+				break
+			}
+			if strings.HasPrefix(platformDigest.Platform, platform+"/") {
+				matches = append(matches, platformDigest)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("the image has no platform %s. Available platforms: [%s]", platform, strings.Join(available, ", "))
+		}
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("platform %s is ambiguous, specify its variant. Available platforms: [%s]", platform, strings.Join(available, ", "))
+		}
+		selected = append(selected, matches[0])
+	}
+	return selected, nil
+}
@@ -0,0 +1,182 @@
+package digest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// a docker manifest list with an arm variant and a buildx attestation manifest
+const dockerManifestList = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+   "manifests": [
+      {
+         "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+         "size": 746,
+         "digest": "sha256:75e87de596bf82eb0333216852fbd50ca30592e5be63789e42827e84449dc7a3",
+         "platform": {"architecture": "amd64", "os": "linux"}
+      },
+      {
+         "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+         "size": 583,
+         "digest": "sha256:ee6441d915dd8b9c30c3a5a53404a66b87a5c1f23681d124afb38bbe3a39b616",
+         "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}
+      },
+      {
+         "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+         "size": 583,
+         "digest": "sha256:0b9f4d2b5b1e07e0f0f6a49ad0b7ffb1c1b1ad1e4b2c0de4e65a1b6e87fd1c2a",
+         "platform": {"architecture": "arm", "os": "linux", "variant": "v6"}
+      },
+      {
+         "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+         "size": 566,
+         "digest": "sha256:3f1e6c47b6f0ea3c5e1e7ad41e52b4c1c6a7d1e1e0f9e27f7f0c2f6d5a0b8c9d",
+         "platform": {"architecture": "unknown", "os": "unknown"}
+      }
+   ]
+}`
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type PlatformTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PlatformTestSuite) TestImagePlatformDigests() {
+	ociIndex, err := os.ReadFile(filepath.Join(imagesTestdata, "multi", "blobs", "sha256", multiIndexDigest))
+	require.NoError(suite.T(), err)
+
+	for _, t := range []struct {
+		name     string
+		blob     []byte
+		mimeType string
+		want     []ImagePlatformDigest
+	}{
+		{
+			name:     "the platforms of an OCI image index are listed in order",
+			blob:     ociIndex,
+			mimeType: "application/vnd.oci.image.index.v1+json",
+			want: []ImagePlatformDigest{
+				{Platform: "linux/amd64", Digest: appImageDigest},
+				{Platform: "linux/arm64", Digest: otherImageDigest},
+			},
+		},
+		{
+			name:     "the platforms of a docker manifest list include variants and skip unknown platforms",
+			blob:     []byte(dockerManifestList),
+			mimeType: "application/vnd.docker.distribution.manifest.list.v2+json",
+			want: []ImagePlatformDigest{
+				{Platform: "linux/amd64", Digest: appImageDigest},
+				{Platform: "linux/arm/v7", Digest: otherImageDigest},
+				{Platform: "linux/arm/v6", Digest: "0b9f4d2b5b1e07e0f0f6a49ad0b7ffb1c1b1ad1e4b2c0de4e65a1b6e87fd1c2a"},
+			},
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := imagePlatformDigests(t.blob, t.mimeType)
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.want, actual)
+		})
+	}
+}
+
+func (suite *PlatformTestSuite) TestSelectImagePlatforms() {
+	platformDigests := []ImagePlatformDigest{
+		{Platform: "linux/amd64", Digest: "1"},
+		{Platform: "linux/arm64/v8", Digest: "2"},
+		{Platform: "linux/arm/v7", Digest: "3"},
+		{Platform: "linux/arm/v6", Digest: "4"},
+	}
+	for _, t := range []struct {
+		name        string
+		platforms   []string
+		want        []ImagePlatformDigest
+		wantErrorRe string
+	}{
+		{
+			name:      "platforms are selected in the requested order",
+			platforms: []string{"linux/arm/v6", "linux/amd64"},
+			want:      []ImagePlatformDigest{platformDigests[3], platformDigests[0]},
+		},
+		{
+			name:      "a platform without a variant matches its only variant",
+			platforms: []string{"linux/arm64"},
+			want:      []ImagePlatformDigest{platformDigests[1]},
+		},
+		{
+			name:        "a platform without a variant is ambiguous when it has several variants",
+			platforms:   []string{"linux/arm"},
+			wantErrorRe: `platform linux/arm is ambiguous, specify its variant`,
+		},
+		{
+			name:        "a missing platform fails with the available platforms",
+			platforms:   []string{"windows/amd64"},
+			wantErrorRe: `no platform windows/amd64\. Available platforms: \[linux/amd64, linux/arm64/v8, linux/arm/v7, linux/arm/v6\]`,
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := SelectImagePlatforms(platformDigests, t.platforms)
+			if t.wantErrorRe != "" {
+				require.Error(suite.T(), err)
+				require.Regexp(suite.T(), t.wantErrorRe, err.Error())
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+func (suite *PlatformTestSuite) TestPlatformImageReference() {
+	platformDigest := "6a3b3f2c5b6f6c1a1e1c2f0b9b5b8f3d3d0c4c5e4b2a1f0e9d8c7b6a5f4e3d2c"
+	for _, t := range []struct {
+		name      string
+		imageName string
+		want      string
+		wantError bool
+	}{
+		{
+			name:      "the tag of the image is replaced with the platform digest",
+			imageName: "ghcr.io/kosli-dev/cli:v2.11.0",
+			want:      "ghcr.io/kosli-dev/cli@sha256:" + platformDigest,
+		},
+		{
+			name:      "docker hub images are normalized",
+			imageName: "nginx:latest",
+			want:      "docker.io/library/nginx@sha256:" + platformDigest,
+		},
+		{
+			name:      "the index digest of the image is replaced with the platform digest",
+			imageName: "registry.example.com:5000/app@sha256:" + strings.Repeat("0", 64),
+			want:      "registry.example.com:5000/app@sha256:" + platformDigest,
+		},
+		{
+			name:      "an invalid image name fails",
+			imageName: "Invalid:Image:Name",
+			wantError: true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := PlatformImageReference(t.imageName, platformDigest)
+			if t.wantError {
+				require.Error(suite.T(), err)
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPlatformTestSuite(t *testing.T) {
+	suite.Run(t, new(PlatformTestSuite))
+}
@@ -0,0 +1,188 @@
+package digest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+)
+
+const (
+	// dockerHubRegistry is the registry host of images without a registry in their name
+	dockerHubRegistry = "docker.io"
+	// dockerHubConfigKey is the key of Docker Hub in the docker CLI config file
+	dockerHubConfigKey = "https://index.docker.io/v1/"
+	// identityTokenUsername is the username returned by credential helpers for identity tokens
+	identityTokenUsername = "<token>"
+)
+
+// RegistryCredentials are the credentials used to read images from a container registry
+type RegistryCredentials struct {
+	Username string
+	Password string
+	// IdentityToken is an OAuth refresh token used instead of the username and password
+	IdentityToken string
+}
+
+// dockerConfigFile is the part of the docker CLI config file used to authenticate to registries
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+	CredsStore  string                      `json:"credsStore"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// credentialHelperProgram returns the program of a docker credential helper
+var credentialHelperProgram = func(helper string) client.ProgramFunc {
+	return client.NewShellProgramFunc("docker-credential-" + helper)
+}
+
+// ImageRegistryHost returns the host of the registry of an image (e.g. docker.io for nginx:latest)
+func ImageRegistryHost(imageName string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse image reference for %s: %w", imageName, err)
+	}
+	return reference.Domain(named), nil
+}
+
+// DockerConfigCredentials returns the credentials of a registry host from the docker CLI config file
+// ($DOCKER_CONFIG/config.json or ~/.docker/config.json), in the same order as the docker CLI: the credential
+// helper of the registry in credHelpers, the credsStore helper, then the credentials in auths.
+// It returns nil when there are no credentials for the registry.
+func DockerConfigCredentials(registryHost string) (*RegistryCredentials, error) {
+	configPath, err := dockerConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config dockerConfigFile
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config file %s: %v", configPath, err)
+	}
+
+	configKey := registryHost
+	if registryHost == dockerHubRegistry {
+		configKey = dockerHubConfigKey
+	}
+
+	if helper, ok := config.CredHelpers[registryHost]; ok {
+		return credentialHelperCredentials(helper, configKey)
+	}
+	if config.CredsStore != "" {
+		creds, err := credentialHelperCredentials(config.CredsStore, configKey)
+		if err != nil || creds != nil {
+			return creds, err
+		}
+	}
+	for key, auth := range config.Auths {
+		if dockerConfigRegistryHost(key) == registryHost || key == configKey {
+			return auth.credentials(key)
+		}
+	}
+	return nil, nil
+}
+
+// dockerConfigPath returns the path of the docker CLI config file
+func dockerConfigPath() (string, error) {
+	if dockerConfig := os.Getenv("DOCKER_CONFIG"); dockerConfig != "" {
+		return filepath.Join(dockerConfig, "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+// dockerConfigRegistryHost returns the registry host of a key of auths in the docker CLI config file,
+// which can be a URL (e.g. https://index.docker.io/v1/)
+func dockerConfigRegistryHost(key string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	if host == "index.docker.io" || host == "registry-1.docker.io" {
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// credentialHelperCredentials returns the credentials of a registry from a docker credential helper,
+// or nil when the helper has no credentials for it
+func credentialHelperCredentials(helper, serverURL string) (*RegistryCredentials, error) {
+	creds, err := client.Get(credentialHelperProgram(helper), serverURL)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for %s from docker credential helper %s: %v", serverURL, helper, err)
+	}
+	if creds.Username == identityTokenUsername {
+		return &RegistryCredentials{IdentityToken: creds.Secret}, nil
+	}
+	return &RegistryCredentials{Username: creds.Username, Password: creds.Secret}, nil
+}
+
+// credentials returns the credentials of an auths entry of the docker CLI config file
+func (auth dockerConfigAuth) credentials(key string) (*RegistryCredentials, error) {
+	creds := &RegistryCredentials{Username: auth.Username, Password: auth.Password, IdentityToken: auth.IdentityToken}
+	if auth.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth for %s in docker config file: %v", key, err)
+		}
+		var ok bool
+		creds.Username, creds.Password, ok = strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid auth for %s in docker config file: expected username:password", key)
+		}
+	}
+	if *creds == (RegistryCredentials{}) {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// RegistrySystemContext returns the context to read an image from its registry, authenticated with the
+// registry username and password if provided, or with the credentials of the docker CLI config file otherwise
+func RegistrySystemContext(imageName, registryUsername, registryPassword string) (*types.SystemContext, error) {
+	authConfig := &types.DockerAuthConfig{
+		Username: registryUsername,
+		Password: registryPassword,
+	}
+	if registryUsername == "" {
+		registryHost, err := ImageRegistryHost(imageName)
+		if err != nil {
+			return nil, err
+		}
+		creds, err := DockerConfigCredentials(registryHost)
+		if err != nil {
+			return nil, err
+		}
+		if creds != nil {
+			authConfig = &types.DockerAuthConfig{
+				Username:      creds.Username,
+				Password:      creds.Password,
+				IdentityToken: creds.IdentityToken,
+			}
+		}
+	}
+	return &types.SystemContext{DockerAuthConfig: authConfig}, nil
+}
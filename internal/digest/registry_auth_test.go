@@ -0,0 +1,203 @@
+package digest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeCredentialHelper is a docker credential helper serving credentials from memory
+type fakeCredentialHelper struct {
+	credentials map[string][2]string
+	serverURL   string
+}
+
+func (h *fakeCredentialHelper) Input(in io.Reader) {
+	content, _ := io.ReadAll(in)
+	h.serverURL = string(content)
+}
+
+func (h *fakeCredentialHelper) Output() ([]byte, error) {
+	creds, ok := h.credentials[h.serverURL]
+	if !ok {
+		return []byte("credentials not found in native keychain"), errors.New("exit status 1")
+	}
+	return json.Marshal(map[string]string{"ServerURL": h.serverURL, "Username": creds[0], "Secret": creds[1]})
+}
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type RegistryAuthTestSuite struct {
+	suite.Suite
+	configDir       string
+	helpers         map[string]*fakeCredentialHelper
+	originalProgram func(string) client.ProgramFunc
+}
+
+func (suite *RegistryAuthTestSuite) SetupTest() {
+	suite.configDir = suite.T().TempDir()
+	suite.T().Setenv("DOCKER_CONFIG", suite.configDir)
+	suite.helpers = map[string]*fakeCredentialHelper{
+		"ecr-login": {credentials: map[string][2]string{
+			"123456789012.dkr.ecr.eu-west-1.amazonaws.com": {"AWS", "ecr-password"},
+		}},
+		"desktop": {credentials: map[string][2]string{
+			"https://index.docker.io/v1/": {"hub-user", "hub-password"},
+			"ghcr.io":                     {"<token>", "identity-token"},
+		}},
+	}
+	suite.originalProgram = credentialHelperProgram
+	credentialHelperProgram = func(helper string) client.ProgramFunc {
+		return func(args ...string) client.Program {
+			return suite.helpers[helper]
+		}
+	}
+}
+
+func (suite *RegistryAuthTestSuite) TearDownTest() {
+	credentialHelperProgram = suite.originalProgram
+}
+
+func (suite *RegistryAuthTestSuite) writeConfig(config string) {
+	err := os.WriteFile(filepath.Join(suite.configDir, "config.json"), []byte(config), 0600)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *RegistryAuthTestSuite) TestDockerConfigCredentials() {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass:word"))
+	config := `{
+		"auths": {
+			"registry.example.com": {"auth": "` + auth + `"},
+			"https://index.docker.io/v1/": {"auth": "` + auth + `"},
+			"https://token.example.com/v2/": {"identitytoken": "refresh-token"},
+			"empty.example.com": {}
+		},
+		"credHelpers": {"123456789012.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"},
+		"credsStore": "desktop"
+	}`
+	for _, t := range []struct {
+		name         string
+		config       string
+		registryHost string
+		want         *RegistryCredentials
+	}{
+		{
+			name:         "the registry credential helper is used first",
+			config:       config,
+			registryHost: "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+			want:         &RegistryCredentials{Username: "AWS", Password: "ecr-password"},
+		},
+		{
+			name:         "the credentials store is used before auths, with the Docker Hub key",
+			config:       config,
+			registryHost: "docker.io",
+			want:         &RegistryCredentials{Username: "hub-user", Password: "hub-password"},
+		},
+		{
+			name:         "identity tokens of the credentials store are supported",
+			config:       config,
+			registryHost: "ghcr.io",
+			want:         &RegistryCredentials{IdentityToken: "identity-token"},
+		},
+		{
+			name:         "auths are used when the credentials store has no credentials",
+			config:       config,
+			registryHost: "registry.example.com",
+			want:         &RegistryCredentials{Username: "user", Password: "pass:word"},
+		},
+		{
+			name:         "auths keys can be URLs",
+			config:       config,
+			registryHost: "token.example.com",
+			want:         &RegistryCredentials{IdentityToken: "refresh-token"},
+		},
+		{
+			name:         "Docker Hub auths are found without a credentials store",
+			config:       `{"auths": {"https://index.docker.io/v1/": {"auth": "` + auth + `"}}}`,
+			registryHost: "docker.io",
+			want:         &RegistryCredentials{Username: "user", Password: "pass:word"},
+		},
+		{
+			name:         "empty auths have no credentials",
+			config:       config,
+			registryHost: "empty.example.com",
+		},
+		{
+			name:         "unknown registries have no credentials",
+			config:       config,
+			registryHost: "unknown.example.com",
+		},
+	} {
+		suite.Run(t.name, func() {
+			suite.writeConfig(t.config)
+			actual, err := DockerConfigCredentials(t.registryHost)
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.want, actual)
+		})
+	}
+}
+
+func (suite *RegistryAuthTestSuite) TestDockerConfigCredentialsWithoutConfigFile() {
+	actual, err := DockerConfigCredentials("docker.io")
+	require.NoError(suite.T(), err)
+	require.Nil(suite.T(), actual)
+}
+
+func (suite *RegistryAuthTestSuite) TestDockerConfigCredentialsFailsForInvalidConfig() {
+	suite.writeConfig(`{"auths": {"registry.example.com": {"auth": "bm8tY29sb24="}}}`)
+	_, err := DockerConfigCredentials("registry.example.com")
+	require.EqualError(suite.T(), err, "invalid auth for registry.example.com in docker config file: expected username:password")
+
+	suite.writeConfig(`{"auths": `)
+	_, err = DockerConfigCredentials("registry.example.com")
+	require.ErrorContains(suite.T(), err, "failed to parse docker config file")
+}
+
+func (suite *RegistryAuthTestSuite) TestRegistrySystemContext() {
+	suite.writeConfig(`{"credsStore": "desktop"}`)
+
+	sysCtx, err := RegistrySystemContext("library/alpine:latest", "", "")
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "hub-user", sysCtx.DockerAuthConfig.Username)
+	require.Equal(suite.T(), "hub-password", sysCtx.DockerAuthConfig.Password)
+
+	sysCtx, err = RegistrySystemContext("library/alpine:latest", "flag-user", "flag-password")
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "flag-user", sysCtx.DockerAuthConfig.Username)
+	require.Equal(suite.T(), "flag-password", sysCtx.DockerAuthConfig.Password)
+
+	sysCtx, err = RegistrySystemContext("quay.io/org/app:1.0", "", "")
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), sysCtx.DockerAuthConfig.Username)
+}
+
+func (suite *RegistryAuthTestSuite) TestImageRegistryHost() {
+	for imageName, want := range map[string]string{
+		"nginx:latest": "docker.io",
+		"library/alpine@sha256:e15947432b813e8ffa90165da919953e2ce850bef511a0ad1287d7cb86de84b5": "docker.io",
+		"ghcr.io/kosli-dev/cli:v2":           "ghcr.io",
+		"localhost:5001/app:1.0":             "localhost:5001",
+		"myregistry.azurecr.io/team/app:1.0": "myregistry.azurecr.io",
+	} {
+		actual, err := ImageRegistryHost(imageName)
+		require.NoError(suite.T(), err)
+		require.Equal(suite.T(), want, actual, imageName)
+	}
+	_, err := ImageRegistryHost("Invalid:Name")
+	require.Error(suite.T(), err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestRegistryAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryAuthTestSuite))
+}
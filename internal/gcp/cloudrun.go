@@ -0,0 +1,313 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	"github.com/kosli-dev/cli/internal/logger"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// cloudPlatformScope is the OAuth scope needed to read Cloud Run resources
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// globalEndpoint is the Cloud Run admin API endpoint that lists resources in all regions
+	globalEndpoint = "https://run.googleapis.com"
+	// locationLabel is the label holding the region of a Cloud Run resource
+	locationLabel = "cloud.googleapis.com/location"
+	// knativeAPIPath is the path of the Knative serving API of Cloud Run
+	knativeAPIPath = "/apis/serving.knative.dev/v1/namespaces"
+)
+
+// GCPStaticCredentials represents the GCP options provided by the user
+type GCPStaticCredentials struct {
+	// CredentialsFile is a service account key or authorized user JSON file.
+	// Application Default Credentials are used when it is empty.
+	CredentialsFile string
+	Projects        []string
+	// Regions limits the snapshot to some regions. All regions are included when it is empty.
+	Regions []string
+	// Endpoint overrides the Cloud Run admin API endpoint for all requests
+	Endpoint string
+}
+
+// CloudRunEnvRequest represents the PUT request body to be sent to kosli from Cloud Run
+type CloudRunEnvRequest struct {
+	Artifacts []*CloudRunRevisionData `json:"artifacts"`
+}
+
+// CloudRunRevisionData represents the harvested data of a Cloud Run revision serving traffic
+type CloudRunRevisionData struct {
+	Project   string            `json:"project"`
+	Region    string            `json:"region"`
+	Service   string            `json:"service"`
+	Revision  string            `json:"revision"`
+	Digests   map[string]string `json:"digests"`
+	StartedAt int64             `json:"creationTimestamp"`
+}
+
+// CloudRunClient reads Cloud Run services and revisions using the Knative serving API
+type CloudRunClient struct {
+	HTTPClient *http.Client
+	// Endpoint overrides the regional endpoints when set
+	Endpoint string
+}
+
+// knativeObjectMeta is the subset of Knative object metadata used in a snapshot
+type knativeObjectMeta struct {
+	Name              string            `json:"name"`
+	Labels            map[string]string `json:"labels"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+}
+
+type knativeCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+type knativeService struct {
+	Metadata knativeObjectMeta `json:"metadata"`
+	Status   struct {
+		Traffic []struct {
+			RevisionName string `json:"revisionName"`
+			Percent      int    `json:"percent"`
+		} `json:"traffic"`
+	} `json:"status"`
+}
+
+type knativeServiceList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []knativeService `json:"items"`
+}
+
+type knativeContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+type knativeRevision struct {
+	Metadata knativeObjectMeta `json:"metadata"`
+	Spec     struct {
+		Containers []knativeContainer `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		ImageDigest       string `json:"imageDigest"`
+		ContainerStatuses []struct {
+			Name        string `json:"name"`
+			ImageDigest string `json:"imageDigest"`
+		} `json:"containerStatuses"`
+		Conditions []knativeCondition `json:"conditions"`
+	} `json:"status"`
+}
+
+// credentials returns the credentials of the credentials file if provided,
+// or Application Default Credentials otherwise
+func (staticCreds *GCPStaticCredentials) credentials(ctx context.Context) (*google.Credentials, error) {
+	var (
+		credentials *google.Credentials
+		err         error
+	)
+	if staticCreds.CredentialsFile != "" {
+		var content []byte
+		content, err = os.ReadFile(staticCreds.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GCP credentials file: %v", err)
+		}
+		credentials, err = google.CredentialsFromJSON(ctx, content, cloudPlatformScope)
+	} else {
+		credentials, err = google.FindDefaultCredentials(ctx, cloudPlatformScope)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GCP credentials: %v", err)
+	}
+	return credentials, nil
+}
+
+// NewCloudRunClient returns a Cloud Run client authenticated with the credentials file
+// if provided, or with Application Default Credentials otherwise
+func (staticCreds *GCPStaticCredentials) NewCloudRunClient(ctx context.Context) (*CloudRunClient, error) {
+	credentials, err := staticCreds.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudRunClient{
+		HTTPClient: oauth2.NewClient(ctx, credentials.TokenSource),
+		Endpoint:   staticCreds.Endpoint,
+	}, nil
+}
+
+// GetCloudRunData returns the data of the Cloud Run revisions serving traffic in the configured
+// projects and regions, for the services selected by the filter
+func (staticCreds *GCPStaticCredentials) GetCloudRunData(filter *filters.ResourceFilterOptions, logger *logger.Logger) ([]*CloudRunRevisionData, error) {
+	client, err := staticCreds.NewCloudRunClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return client.GetCloudRunData(staticCreds.Projects, staticCreds.Regions, filter, logger)
+}
+
+// GetCloudRunData returns the data of the Cloud Run revisions serving traffic in the given
+// projects and regions (or all regions if empty), for the services selected by the filter
+func (client *CloudRunClient) GetCloudRunData(projects, regions []string, filter *filters.ResourceFilterOptions, logger *logger.Logger) ([]*CloudRunRevisionData, error) {
+	revisionsData := []*CloudRunRevisionData{}
+	for _, project := range projects {
+		services, err := client.listServices(project)
+		if err != nil {
+			return nil, err
+		}
+		logger.Debug("found %d Cloud Run services in project %s", len(services), project)
+
+		for _, service := range services {
+			region := service.Metadata.Labels[locationLabel]
+			if len(regions) > 0 && !slices.Contains(regions, region) {
+				continue
+			}
+			include, err := filter.ShouldInclude(service.Metadata.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !include {
+				continue
+			}
+
+			for _, revisionName := range servingRevisions(service) {
+				revision, err := client.getRevision(project, region, revisionName)
+				if err != nil {
+					return nil, err
+				}
+				data, err := newCloudRunRevisionData(project, region, service.Metadata.Name, revision)
+				if err != nil {
+					return nil, err
+				}
+				if len(data.Digests) == 0 {
+					logger.Debug("revision %s of service %s has no image digests, skipping from report", revisionName, service.Metadata.Name)
+					continue
+				}
+				revisionsData = append(revisionsData, data)
+			}
+		}
+	}
+	return revisionsData, nil
+}
+
+// listServices lists the Cloud Run services of a project in all regions
+func (client *CloudRunClient) listServices(project string) ([]knativeService, error) {
+	services := []knativeService{}
+	base := client.Endpoint
+	if base == "" {
+		base = globalEndpoint
+	}
+	continueToken := ""
+	for {
+		query := url.Values{}
+		if continueToken != "" {
+			query.Set("continue", continueToken)
+		}
+		list := &knativeServiceList{}
+		err := client.get(fmt.Sprintf("%s%s/%s/services?%s", base, knativeAPIPath, url.PathEscape(project), query.Encode()), list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Cloud Run services in project %s: %v", project, err)
+		}
+		services = append(services, list.Items...)
+		if list.Metadata.Continue == "" {
+			return services, nil
+		}
+		continueToken = list.Metadata.Continue
+	}
+}
+
+// getRevision gets a Cloud Run revision from its regional endpoint
+func (client *CloudRunClient) getRevision(project, region, name string) (*knativeRevision, error) {
+	base := client.Endpoint
+	if base == "" {
+		base = fmt.Sprintf("https://%s-run.googleapis.com", region)
+	}
+	revision := &knativeRevision{}
+	err := client.get(fmt.Sprintf("%s%s/%s/revisions/%s", base, knativeAPIPath, url.PathEscape(project), url.PathEscape(name)), revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Cloud Run revision %s in project %s: %v", name, project, err)
+	}
+	return revision, nil
+}
+
+func (client *CloudRunClient) get(url string, result interface{}) error {
+	resp, err := client.HTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// servingRevisions returns the names of the revisions of a service receiving traffic
+func servingRevisions(service knativeService) []string {
+	revisions := []string{}
+	for _, target := range service.Status.Traffic {
+		if target.Percent > 0 && target.RevisionName != "" && !slices.Contains(revisions, target.RevisionName) {
+			revisions = append(revisions, target.RevisionName)
+		}
+	}
+	return revisions
+}
+
+// newCloudRunRevisionData creates a CloudRunRevisionData from a revision. The digests are
+// keyed by container image, and the creation timestamp is when the revision became ready
+// to serve, or when it was created if that is unknown.
+func newCloudRunRevisionData(project, region, service string, revision *knativeRevision) (*CloudRunRevisionData, error) {
+	imageDigests := map[string]string{}
+	for _, status := range revision.Status.ContainerStatuses {
+		imageDigests[status.Name] = status.ImageDigest
+	}
+
+	digests := map[string]string{}
+	for _, container := range revision.Spec.Containers {
+		imageDigest := imageDigests[container.Name]
+		if imageDigest == "" && len(revision.Spec.Containers) == 1 {
+			imageDigest = revision.Status.ImageDigest
+		}
+		if imageDigest == "" {
+			continue
+		}
+		_, sha256, found := strings.Cut(imageDigest, "@sha256:")
+		if !found {
+			return nil, fmt.Errorf("invalid image digest %s of revision %s", imageDigest, revision.Metadata.Name)
+		}
+		digests[container.Image] = sha256
+	}
+
+	startedAt := revision.Metadata.CreationTimestamp
+	for _, condition := range revision.Status.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" && !condition.LastTransitionTime.IsZero() {
+			startedAt = condition.LastTransitionTime
+		}
+	}
+
+	return &CloudRunRevisionData{
+		Project:   project,
+		Region:    region,
+		Service:   service,
+		Revision:  revision.Metadata.Name,
+		Digests:   digests,
+		StartedAt: startedAt.Unix(),
+	}, nil
+}
@@ -0,0 +1,233 @@
+package gcp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	webDigestV1   = strings.Repeat("1", 64)
+	webDigestV2   = strings.Repeat("2", 64)
+	apiDigest     = strings.Repeat("3", 64)
+	workerDigest  = strings.Repeat("4", 64)
+	sidecarDigest = strings.Repeat("5", 64)
+)
+
+// fakeCloudRunResponses are the responses of the fake Cloud Run admin API by request URI
+var fakeCloudRunResponses = map[string]string{
+	"/apis/serving.knative.dev/v1/namespaces/kosli-test/services?": `{
+		"metadata": {"continue": "page2"},
+		"items": [
+			{
+				"metadata": {"name": "web", "labels": {"cloud.googleapis.com/location": "europe-west1"}},
+				"status": {"traffic": [
+					{"revisionName": "web-00002", "percent": 90},
+					{"revisionName": "web-00001", "percent": 10},
+					{"revisionName": "web-00002", "percent": 0, "tag": "latest"}
+				]}
+			},
+			{
+				"metadata": {"name": "api", "labels": {"cloud.googleapis.com/location": "us-central1"}},
+				"status": {"traffic": [{"revisionName": "api-00003", "percent": 100}]}
+			}
+		]
+	}`,
+	"/apis/serving.knative.dev/v1/namespaces/kosli-test/services?continue=page2": `{
+		"metadata": {},
+		"items": [
+			{
+				"metadata": {"name": "worker", "labels": {"cloud.googleapis.com/location": "europe-west1"}},
+				"status": {"traffic": [{"revisionName": "worker-00001", "percent": 100}]}
+			},
+			{
+				"metadata": {"name": "broken", "labels": {"cloud.googleapis.com/location": "europe-west1"}},
+				"status": {}
+			}
+		]
+	}`,
+	"/apis/serving.knative.dev/v1/namespaces/kosli-test/revisions/web-00001": fakeRevision("web-00001",
+		`[{"name": "web", "image": "europe-docker.pkg.dev/kosli-test/apps/web:1.0"}]`,
+		`"imageDigest": "europe-docker.pkg.dev/kosli-test/apps/web@sha256:`+webDigestV1+`"`),
+	"/apis/serving.knative.dev/v1/namespaces/kosli-test/revisions/web-00002": fakeRevision("web-00002",
+		`[{"name": "web", "image": "europe-docker.pkg.dev/kosli-test/apps/web:2.0"}]`,
+		`"imageDigest": "europe-docker.pkg.dev/kosli-test/apps/web@sha256:`+webDigestV2+`"`),
+	"/apis/serving.knative.dev/v1/namespaces/kosli-test/revisions/api-00003": fakeRevision("api-00003",
+		`[{"name": "api", "image": "us-docker.pkg.dev/kosli-test/apps/api@sha256:`+apiDigest+`"}]`,
+		`"imageDigest": "us-docker.pkg.dev/kosli-test/apps/api@sha256:`+apiDigest+`"`),
+	"/apis/serving.knative.dev/v1/namespaces/kosli-test/revisions/worker-00001": fakeRevision("worker-00001",
+		`[{"name": "worker", "image": "europe-docker.pkg.dev/kosli-test/apps/worker:1.0"}, {"name": "proxy", "image": "envoyproxy/envoy:v1.31"}]`,
+		`"containerStatuses": [
+			{"name": "worker", "imageDigest": "europe-docker.pkg.dev/kosli-test/apps/worker@sha256:`+workerDigest+`"},
+			{"name": "proxy", "imageDigest": "docker.io/envoyproxy/envoy@sha256:`+sidecarDigest+`"}
+		]`),
+}
+
+// fakeRevision returns a revision that was created at 2024-10-01T10:00:00Z and became ready a minute later
+func fakeRevision(name, containers, status string) string {
+	return fmt.Sprintf(`{
+		"metadata": {"name": "%s", "creationTimestamp": "2024-10-01T10:00:00Z"},
+		"spec": {"containers": %s},
+		"status": {
+			%s,
+			"conditions": [
+				{"type": "ContainerHealthy", "status": "True", "lastTransitionTime": "2024-10-01T10:00:30Z"},
+				{"type": "Ready", "status": "True", "lastTransitionTime": "2024-10-01T10:01:00Z"}
+			]
+		}
+	}`, name, containers, status)
+}
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type CloudRunTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	client *CloudRunClient
+	logger *logger.Logger
+}
+
+func (suite *CloudRunTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := fakeCloudRunResponses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error": {"code": 404, "message": "%s not found"}}`, r.URL.Path)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	suite.client = &CloudRunClient{HTTPClient: http.DefaultClient, Endpoint: suite.server.URL}
+	suite.logger = logger.NewStandardLogger()
+}
+
+func (suite *CloudRunTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *CloudRunTestSuite) TestGetCloudRunData() {
+	readyAt := int64(1727776860) // 2024-10-01T10:01:00Z
+	web1 := &CloudRunRevisionData{Project: "kosli-test", Region: "europe-west1", Service: "web", Revision: "web-00001", StartedAt: readyAt,
+		Digests: map[string]string{"europe-docker.pkg.dev/kosli-test/apps/web:1.0": webDigestV1}}
+	web2 := &CloudRunRevisionData{Project: "kosli-test", Region: "europe-west1", Service: "web", Revision: "web-00002", StartedAt: readyAt,
+		Digests: map[string]string{"europe-docker.pkg.dev/kosli-test/apps/web:2.0": webDigestV2}}
+	api := &CloudRunRevisionData{Project: "kosli-test", Region: "us-central1", Service: "api", Revision: "api-00003", StartedAt: readyAt,
+		Digests: map[string]string{"us-docker.pkg.dev/kosli-test/apps/api@sha256:" + apiDigest: apiDigest}}
+	worker := &CloudRunRevisionData{Project: "kosli-test", Region: "europe-west1", Service: "worker", Revision: "worker-00001", StartedAt: readyAt,
+		Digests: map[string]string{
+			"europe-docker.pkg.dev/kosli-test/apps/worker:1.0": workerDigest,
+			"envoyproxy/envoy:v1.31":                           sidecarDigest,
+		}}
+
+	for _, t := range []struct {
+		name     string
+		projects []string
+		regions  []string
+		filter   *filters.ResourceFilterOptions
+		want     []*CloudRunRevisionData
+		wantErr  string
+	}{
+		{
+			name:     "all revisions serving traffic in all regions are reported",
+			projects: []string{"kosli-test"},
+			filter:   &filters.ResourceFilterOptions{},
+			want:     []*CloudRunRevisionData{web2, web1, api, worker},
+		},
+		{
+			name:     "regions limit the reported services",
+			projects: []string{"kosli-test"},
+			regions:  []string{"us-central1"},
+			filter:   &filters.ResourceFilterOptions{},
+			want:     []*CloudRunRevisionData{api},
+		},
+		{
+			name:     "services can be included by name and regex",
+			projects: []string{"kosli-test"},
+			filter:   &filters.ResourceFilterOptions{IncludeNames: []string{"api"}, IncludeNamesRegex: []string{"^wor"}},
+			want:     []*CloudRunRevisionData{api, worker},
+		},
+		{
+			name:     "services can be excluded by name and regex",
+			projects: []string{"kosli-test"},
+			filter:   &filters.ResourceFilterOptions{ExcludeNames: []string{"api"}, ExcludeNamesRegex: []string{"^wor"}},
+			want:     []*CloudRunRevisionData{web2, web1},
+		},
+		{
+			name:     "an unknown project fails",
+			projects: []string{"kosli-test", "unknown"},
+			filter:   &filters.ResourceFilterOptions{},
+			wantErr:  "failed to list Cloud Run services in project unknown: 404 Not Found",
+		},
+	} {
+		suite.Run(t.name, func() {
+			data, err := suite.client.GetCloudRunData(t.projects, t.regions, t.filter, suite.logger)
+			if t.wantErr != "" {
+				require.ErrorContains(suite.T(), err, t.wantErr)
+				return
+			}
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.want, data)
+		})
+	}
+}
+
+func (suite *CloudRunTestSuite) TestNewCloudRunClientUsesCredentialsFile() {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "gcp-token", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	defer tokenServer.Close()
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gcp-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"metadata": {}, "items": []}`)
+	}))
+	defer apiServer.Close()
+
+	credentialsFile := filepath.Join(suite.T().TempDir(), "credentials.json")
+	err := os.WriteFile(credentialsFile, []byte(fmt.Sprintf(`{
+		"type": "authorized_user",
+		"client_id": "id",
+		"client_secret": "secret",
+		"refresh_token": "refresh",
+		"token_uri": "%s/token"
+	}`, tokenServer.URL)), 0600)
+	require.NoError(suite.T(), err)
+
+	staticCreds := &GCPStaticCredentials{CredentialsFile: credentialsFile, Projects: []string{"kosli-test"}, Endpoint: apiServer.URL}
+	data, err := staticCreds.GetCloudRunData(&filters.ResourceFilterOptions{}, suite.logger)
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), data)
+
+	staticCreds.CredentialsFile = filepath.Join(suite.T().TempDir(), "missing.json")
+	_, err = staticCreds.GetCloudRunData(&filters.ResourceFilterOptions{}, suite.logger)
+	require.ErrorContains(suite.T(), err, "failed to read GCP credentials file")
+}
+
+func (suite *CloudRunTestSuite) TestNewCloudRunRevisionDataRejectsInvalidDigest() {
+	revision := &knativeRevision{}
+	revision.Metadata.Name = "web-00003"
+	revision.Spec.Containers = []knativeContainer{{Name: "web", Image: "web:3.0"}}
+	revision.Status.ImageDigest = "web:3.0"
+
+	_, err := newCloudRunRevisionData("kosli-test", "europe-west1", "web", revision)
+	require.EqualError(suite.T(), err, "invalid image digest web:3.0 of revision web-00003")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestCloudRunTestSuite(t *testing.T) {
+	suite.Run(t, new(CloudRunTestSuite))
+}
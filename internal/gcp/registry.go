@@ -0,0 +1,36 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// registryTokenUsername is the username used with OAuth access tokens by GCR and Artifact Registry
+	registryTokenUsername = "oauth2accesstoken"
+	// artifactRegistrySuffix is the host suffix of Artifact Registry docker repositories
+	artifactRegistrySuffix = "-docker.pkg.dev"
+)
+
+// IsGCPRegistry returns true if the registry host is a Container Registry (gcr.io)
+// or an Artifact Registry host
+func IsGCPRegistry(registryHost string) bool {
+	return registryHost == "gcr.io" || strings.HasSuffix(registryHost, ".gcr.io") ||
+		strings.HasSuffix(registryHost, artifactRegistrySuffix)
+}
+
+// RegistryCredentials returns the username and password to read images from Container Registry
+// or Artifact Registry, using an access token of the credentials file if provided, or of
+// Application Default Credentials otherwise
+func (staticCreds *GCPStaticCredentials) RegistryCredentials() (string, string, error) {
+	credentials, err := staticCreds.credentials(context.Background())
+	if err != nil {
+		return "", "", err
+	}
+	token, err := credentials.TokenSource.Token()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get GCP access token: %v", err)
+	}
+	return registryTokenUsername, token.AccessToken, nil
+}
@@ -0,0 +1,40 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type RegistryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RegistryTestSuite) TestIsGCPRegistry() {
+	for host, want := range map[string]bool{
+		"gcr.io":                      true,
+		"eu.gcr.io":                   true,
+		"europe-west1-docker.pkg.dev": true,
+		"europe-west1-python.pkg.dev": false,
+		"gcr.io.example.com":          false,
+		"docker.io":                   false,
+	} {
+		require.Equal(suite.T(), want, IsGCPRegistry(host), host)
+	}
+}
+
+func (suite *RegistryTestSuite) TestRegistryCredentialsFailsForMissingCredentialsFile() {
+	creds := &GCPStaticCredentials{CredentialsFile: "missing.json"}
+	_, _, err := creds.RegistryCredentials()
+	require.ErrorContains(suite.T(), err, "failed to read GCP credentials file")
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
@@ -0,0 +1,337 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/ratelimit"
+	"github.com/kosli-dev/cli/internal/types"
+)
+
+// pageSize is the number of items requested per page. Gitea caps it with its MAX_RESPONSE_ITEMS setting,
+// which defaults to 50, so pages may have fewer items.
+const pageSize = 50
+
+type GiteaConfig struct {
+	Token      string
+	BaseURL    string
+	Org        string
+	Repository string
+	HTTPClient *http.Client
+}
+
+type GiteaFlagsTempValueHolder struct {
+	Token      string
+	BaseURL    string
+	Org        string
+	Repository string
+}
+
+// NewGiteaConfig returns a new GiteaConfig
+func NewGiteaConfig(token, baseURL, org, repository string) *GiteaConfig {
+	return &GiteaConfig{
+		Token:   token,
+		BaseURL: strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/api/v1"),
+		Org:     org,
+		// repository name must be extracted if a user is using default value from ${GITHUB_REPOSITORY}
+		// because the value is in the format of "owner/repository"
+		Repository: extractRepoName(repository),
+		HTTPClient: &http.Client{Transport: &ratelimit.Transport{Service: "Gitea"}},
+	}
+}
+
+// extractRepoName returns repository name from 'owner/repository_name' string
+func extractRepoName(fullRepositoryName string) string {
+	repoNameParts := strings.Split(fullRepositoryName, "/")
+	return repoNameParts[len(repoNameParts)-1]
+}
+
+// User is a Gitea user
+type User struct {
+	Login string `json:"login"`
+}
+
+// PullRequest is a Gitea pull request
+type PullRequest struct {
+	Number         int    `json:"number"`
+	HTMLURL        string `json:"html_url"`
+	State          string `json:"state"`
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	User           *User  `json:"user"`
+	Head           struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+// Review is a review of a Gitea pull request
+type Review struct {
+	User        *User     `json:"user"`
+	State       string    `json:"state"`
+	Dismissed   bool      `json:"dismissed"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	CommitID    string    `json:"commit_id"`
+}
+
+// Commit is a commit of a Gitea pull request
+type Commit struct {
+	SHA       string `json:"sha"`
+	Committer *User  `json:"committer"`
+	Author    *User  `json:"author"`
+	Commit    struct {
+		Committer struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+func (c *GiteaConfig) PREvidenceForCommit(commit string) ([]*types.PREvidence, error) {
+	pullRequestsEvidence := []*types.PREvidence{}
+	prs, err := c.PullRequestsForCommit(commit)
+	if err != nil {
+		return pullRequestsEvidence, err
+	}
+	for _, pr := range prs {
+		evidence, err := c.newPRGiteaEvidence(pr)
+		if err != nil {
+			return pullRequestsEvidence, err
+		}
+		pullRequestsEvidence = append(pullRequestsEvidence, evidence)
+	}
+	return pullRequestsEvidence, nil
+}
+
+func (c *GiteaConfig) newPRGiteaEvidence(pr *PullRequest) (*types.PREvidence, error) {
+	evidence := &types.PREvidence{
+		URL:         pr.HTMLURL,
+		MergeCommit: pr.MergeCommitSHA,
+		State:       pr.State,
+	}
+	if pr.User != nil {
+		evidence.Author = pr.User.Login
+	}
+	reviews, err := c.GetPullRequestReviews(pr.Number)
+	if err != nil {
+		return evidence, err
+	}
+	evidence.Approvers, evidence.Reviewers = reviewersFromReviews(reviews)
+
+	lastCommit, err := c.GetPullRequestLastCommit(pr.Number, pr.Head.SHA)
+	if err != nil {
+		return evidence, err
+	}
+	if lastCommit != nil {
+		evidence.LastCommit = lastCommit.SHA
+		evidence.LastCommitter = committerLogin(lastCommit)
+		evidence.LastCommitTimestamp = lastCommit.Commit.Committer.Date.Unix()
+	}
+	evidence.CheckFourEyes()
+	return evidence, nil
+}
+
+// reviewersFromReviews returns the approvers and the reviewers of a pull request from its reviews.
+// Pending reviews and review requests are skipped.
+func reviewersFromReviews(reviews []*Review) ([]string, []*types.PRReviewer) {
+	approvers := []string{}
+	reviewers := []*types.PRReviewer{}
+	for _, r := range reviews {
+		var state string
+		switch r.State {
+		case "APPROVED":
+			state = types.ReviewApproved
+		case "REQUEST_CHANGES":
+			state = types.ReviewChangesRequested
+		case "COMMENT":
+			state = types.ReviewCommented
+		default:
+			continue
+		}
+		if r.User == nil {
+			continue
+		}
+		if r.Dismissed {
+			state = types.ReviewDismissed
+		}
+		if state == types.ReviewApproved {
+			approvers = append(approvers, r.User.Login)
+		}
+		reviewers = append(reviewers, &types.PRReviewer{
+			Username:  r.User.Login,
+			State:     state,
+			Timestamp: r.SubmittedAt.Unix(),
+			CommitID:  r.CommitID,
+		})
+	}
+	return approvers, reviewers
+}
+
+// committerLogin returns the Gitea login of the committer of a commit, falling back to its author
+// and then to the git committer name for commits which are not linked to a Gitea user
+func committerLogin(commit *Commit) string {
+	if commit.Committer != nil && commit.Committer.Login != "" {
+		return commit.Committer.Login
+	}
+	if commit.Author != nil && commit.Author.Login != "" {
+		return commit.Author.Login
+	}
+	return commit.Commit.Committer.Name
+}
+
+// PullRequestsForCommit returns the pull request which merged a commit, or an empty list if there is none
+func (c *GiteaConfig) PullRequestsForCommit(commit string) ([]*PullRequest, error) {
+	pr := &PullRequest{}
+	found, err := c.get(fmt.Sprintf("/repos/%s/%s/commits/%s/pull", c.Org, c.Repository, commit), nil, pr)
+	if err != nil || !found {
+		return []*PullRequest{}, err
+	}
+	return []*PullRequest{pr}, nil
+}
+
+// GetPullRequestApprovers returns a list of approvers for a given pull request
+func (c *GiteaConfig) GetPullRequestApprovers(number int) ([]string, error) {
+	reviews, err := c.GetPullRequestReviews(number)
+	if err != nil {
+		return []string{}, err
+	}
+	approvers, _ := reviewersFromReviews(reviews)
+	return approvers, nil
+}
+
+// GetPullRequestReviews returns all reviews of a given pull request
+func (c *GiteaConfig) GetPullRequestReviews(number int) ([]*Review, error) {
+	allReviews := []*Review{}
+	err := c.getPages(fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", c.Org, c.Repository, number), url.Values{},
+		func(values json.RawMessage) (int, error) {
+			reviews := []*Review{}
+			err := json.Unmarshal(values, &reviews)
+			allReviews = append(allReviews, reviews...)
+			return len(reviews), err
+		})
+	return allReviews, err
+}
+
+// GetPullRequestLastCommit returns the head commit of a given pull request, or its most recently committed
+// commit if the head is not known, or nil if it has no commits
+func (c *GiteaConfig) GetPullRequestLastCommit(number int, headSHA string) (*Commit, error) {
+	var lastCommit, headCommit *Commit
+	// skip the expensive parts of the response which are not needed
+	query := url.Values{"verification": []string{"false"}, "files": []string{"false"}}
+	err := c.getPages(fmt.Sprintf("/repos/%s/%s/pulls/%d/commits", c.Org, c.Repository, number), query,
+		func(values json.RawMessage) (int, error) {
+			commits := []*Commit{}
+			err := json.Unmarshal(values, &commits)
+			for _, commit := range commits {
+				if headSHA != "" && commit.SHA == headSHA {
+					headCommit = commit
+				}
+				if lastCommit == nil || commit.Commit.Committer.Date.After(lastCommit.Commit.Committer.Date) {
+					lastCommit = commit
+				}
+			}
+			return len(commits), err
+		})
+	if err != nil {
+		return nil, err
+	}
+	if headCommit != nil {
+		return headCommit, nil
+	}
+	return lastCommit, nil
+}
+
+// getPages calls handle with each page of a paged Gitea API, and handle returns the number of items in the page.
+// It fails if the API responds with 404, e.g. for the reviews of a deleted pull request.
+// Gitea caps the page size with its MAX_RESPONSE_ITEMS setting, so the next page is found from the Link
+// or X-Total-Count headers rather than by comparing the number of items with the requested limit.
+func (c *GiteaConfig) getPages(path string, query url.Values, handle func(values json.RawMessage) (int, error)) error {
+	total := 0
+	for page := 1; ; page++ {
+		query.Set("page", fmt.Sprint(page))
+		query.Set("limit", fmt.Sprint(pageSize))
+		var values json.RawMessage
+		header, found, err := c.getWithHeader(path, query, &values)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("GET %s%s: not found", c.BaseURL+"/api/v1", path)
+		}
+		count, err := handle(values)
+		if err != nil {
+			return err
+		}
+		total += count
+		if !hasNextPage(header, count, total) {
+			return nil
+		}
+	}
+}
+
+// hasNextPage returns true if there is a page after a page of count items, when total items have been read
+func hasNextPage(header http.Header, count, total int) bool {
+	if link := header.Get("Link"); link != "" {
+		return strings.Contains(link, `rel="next"`)
+	}
+	if totalCount, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+		return count > 0 && total < totalCount
+	}
+	// without pagination headers, pages are read until an empty one
+	return count > 0
+}
+
+// get calls a Gitea API endpoint and decodes its JSON response into result.
+// It returns false without an error if the endpoint responds with 404.
+func (c *GiteaConfig) get(path string, query url.Values, result interface{}) (bool, error) {
+	_, found, err := c.getWithHeader(path, query, result)
+	return found, err
+}
+
+// getWithHeader is like get, and also returns the headers of the response
+func (c *GiteaConfig) getWithHeader(path string, query url.Values, result interface{}) (http.Header, bool, error) {
+	reqURL := c.BaseURL + "/api/v1" + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.Header, false, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiError) == nil && apiError.Message != "" {
+			return nil, false, fmt.Errorf("GET %s: %s %s", reqURL, resp.Status, apiError.Message)
+		}
+		return nil, false, fmt.Errorf("GET %s: %s", reqURL, resp.Status)
+	}
+	return resp.Header, true, json.Unmarshal(body, result)
+}
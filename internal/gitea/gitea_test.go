@@ -0,0 +1,343 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/types"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	commitWithPR    = "5f61be8f00a01c84e491922a630c9a418c684c7a"
+	commitWithoutPR = "19aab7f063147614451c88969602a10afbabb43d"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type GiteaTestSuite struct {
+	suite.Suite
+	// maxResponseItems emulates the MAX_RESPONSE_ITEMS setting of the fake Gitea when it is set
+	maxResponseItems int
+	// headSHA is the head commit of the pull request of the fake Gitea when it is set
+	headSHA string
+}
+
+func (suite *GiteaTestSuite) SetupTest() {
+	suite.maxResponseItems = 0
+	suite.headSHA = ""
+}
+
+// newFakeGitea returns a fake Gitea API serving one pull request with the given reviews and commits
+func (suite *GiteaTestSuite) newFakeGitea(reviews []map[string]interface{}, commits []map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/repos/kosli/cli/commits/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token gitea-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "user does not exist"}`))
+			return
+		}
+		if r.URL.Path != fmt.Sprintf("/api/v1/repos/kosli/cli/commits/%s/pull", commitWithPR) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"number":           3,
+			"html_url":         "https://gitea.example.com/kosli/cli/pulls/3",
+			"state":            "closed",
+			"merge_commit_sha": commitWithPR,
+			"user":             map[string]string{"login": "alice"},
+			"head":             map[string]string{"sha": suite.headSHA},
+		})
+	})
+	mux.HandleFunc("/api/v1/repos/kosli/cli/pulls/3/reviews", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, suite.paginate(w, r, reviews, true))
+	})
+	mux.HandleFunc("/api/v1/repos/kosli/cli/pulls/3/commits", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(suite.T(), "false", r.URL.Query().Get("files"))
+		writeJSON(w, suite.paginate(w, r, commits, false))
+	})
+	return httptest.NewServer(mux)
+}
+
+// paginate returns a page of items, like Gitea, with the X-Total-Count header and optionally the Link header
+func (suite *GiteaTestSuite) paginate(w http.ResponseWriter, r *http.Request, items []map[string]interface{}, withLink bool) []map[string]interface{} {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if suite.maxResponseItems > 0 {
+		limit = min(limit, suite.maxResponseItems)
+	}
+	start := min((page-1)*limit, len(items))
+	end := min(start+limit, len(items))
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	if withLink {
+		link := fmt.Sprintf(`<http://%s%s?page=1>; rel="first"`, r.Host, r.URL.Path)
+		if end < len(items) {
+			link = fmt.Sprintf(`<http://%s%s?page=%d>; rel="next",`, r.Host, r.URL.Path, page+1) + link
+		}
+		w.Header().Set("Link", link)
+	}
+	return items[start:end]
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func review(login, state string, submittedAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"user":         map[string]string{"login": login},
+		"state":        state,
+		"submitted_at": submittedAt.Format(time.RFC3339),
+	}
+}
+
+func commit(sha, login string, date time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"sha":       sha,
+		"committer": map[string]string{"login": login},
+		"commit": map[string]interface{}{
+			"committer": map[string]interface{}{"name": login, "date": date.Format(time.RFC3339)},
+		},
+	}
+}
+
+func (suite *GiteaTestSuite) TestNewGiteaConfig() {
+	config := NewGiteaConfig("token", "https://gitea.example.com/api/v1/", "kosli", "kosli/cli")
+	require.Equal(suite.T(), "https://gitea.example.com", config.BaseURL)
+	require.Equal(suite.T(), "kosli", config.Org)
+	require.Equal(suite.T(), "cli", config.Repository)
+}
+
+func (suite *GiteaTestSuite) TestPREvidenceForCommit() {
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	commits := []map[string]interface{}{
+		commit("aaa", "alice", day),
+		commit("bbb", "alice", day.Add(time.Hour)),
+	}
+	// more reviews than a page to check that all pages are read
+	reviews := []map[string]interface{}{}
+	for i := 0; i < pageSize; i++ {
+		reviews = append(reviews, review("carol", "COMMENT", day.Add(time.Minute)))
+	}
+	reviews = append(reviews,
+		review("bob", "APPROVED", day.Add(2*time.Hour)),
+		review("dave", "PENDING", day.Add(3*time.Hour)),
+	)
+	server := suite.newFakeGitea(reviews, commits)
+	defer server.Close()
+
+	for _, t := range []struct {
+		name         string
+		token        string
+		commit       string
+		wantError    string
+		wantEvidence []*types.PREvidence
+	}{
+		{
+			name:      "invalid token causes an error",
+			token:     "wrong-token",
+			commit:    commitWithPR,
+			wantError: fmt.Sprintf("GET %s/api/v1/repos/kosli/cli/commits/%s/pull: 401 Unauthorized user does not exist", server.URL, commitWithPR),
+		},
+		{
+			name:         "a commit without a pull request returns no evidence",
+			token:        "gitea-token",
+			commit:       commitWithoutPR,
+			wantEvidence: []*types.PREvidence{},
+		},
+		{
+			name:   "a commit with a pull request returns its approvers, reviewers and last commit",
+			token:  "gitea-token",
+			commit: commitWithPR,
+			wantEvidence: []*types.PREvidence{{
+				URL:                     "https://gitea.example.com/kosli/cli/pulls/3",
+				MergeCommit:             commitWithPR,
+				State:                   "closed",
+				Author:                  "alice",
+				Approvers:               []string{"bob"},
+				LastCommit:              "bbb",
+				LastCommitter:           "alice",
+				LastCommitTimestamp:     day.Add(time.Hour).Unix(),
+				ApprovedAfterLastCommit: true,
+			}},
+		},
+	} {
+		suite.Run(t.name, func() {
+			config := NewGiteaConfig(t.token, server.URL, "kosli", "cli")
+			evidence, err := config.PREvidenceForCommit(t.commit)
+			if t.wantError != "" {
+				require.EqualError(suite.T(), err, t.wantError)
+				return
+			}
+			require.NoError(suite.T(), err)
+			for _, e := range evidence {
+				require.Len(suite.T(), e.Reviewers, pageSize+1)
+				e.Reviewers = nil
+			}
+			require.Equal(suite.T(), t.wantEvidence, evidence)
+		})
+	}
+}
+
+func (suite *GiteaTestSuite) TestPREvidenceForCommitReadsAllPagesWhenGiteaCapsThePageSize() {
+	suite.maxResponseItems = 10
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	commits := []map[string]interface{}{}
+	for i := 0; i < 15; i++ {
+		commits = append(commits, commit(fmt.Sprintf("%03d", i), "alice", day.Add(time.Duration(i)*time.Minute)))
+	}
+	reviews := []map[string]interface{}{}
+	for i := 0; i < 15; i++ {
+		reviews = append(reviews, review("carol", "COMMENT", day.Add(time.Hour)))
+	}
+	reviews = append(reviews, review("bob", "APPROVED", day.Add(2*time.Hour)))
+	server := suite.newFakeGitea(reviews, commits)
+	defer server.Close()
+
+	config := NewGiteaConfig("gitea-token", server.URL, "kosli", "cli")
+	evidence, err := config.PREvidenceForCommit(commitWithPR)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 1)
+	require.Len(suite.T(), evidence[0].Reviewers, 16)
+	require.Equal(suite.T(), []string{"bob"}, evidence[0].Approvers)
+	require.Equal(suite.T(), "014", evidence[0].LastCommit)
+}
+
+func (suite *GiteaTestSuite) TestPREvidenceForCommitIgnoresApprovalsOfEarlierCommits() {
+	// the head commit is dated before the approval, e.g. because it was rebased, but was pushed after it
+	suite.headSHA = "aaa"
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	commits := []map[string]interface{}{
+		commit("bbb", "alice", day.Add(time.Hour)),
+		commit("aaa", "alice", day),
+	}
+	approval := review("bob", "APPROVED", day.Add(2*time.Hour))
+	approval["commit_id"] = "bbb"
+	server := suite.newFakeGitea([]map[string]interface{}{approval}, commits)
+	defer server.Close()
+
+	config := NewGiteaConfig("gitea-token", server.URL, "kosli", "cli")
+	evidence, err := config.PREvidenceForCommit(commitWithPR)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 1)
+	require.Equal(suite.T(), "aaa", evidence[0].LastCommit)
+	require.Equal(suite.T(), []string{"bob"}, evidence[0].Approvers)
+	require.False(suite.T(), evidence[0].FourEyes())
+
+	approval["commit_id"] = "aaa"
+	evidence, err = config.PREvidenceForCommit(commitWithPR)
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), evidence[0].FourEyes())
+}
+
+func (suite *GiteaTestSuite) TestGetPullRequestReviewsOfAMissingPullRequest() {
+	server := suite.newFakeGitea(nil, nil)
+	defer server.Close()
+
+	config := NewGiteaConfig("gitea-token", server.URL, "kosli", "cli")
+	_, err := config.GetPullRequestReviews(4)
+	require.EqualError(suite.T(), err, fmt.Sprintf("GET %s/api/v1/repos/kosli/cli/pulls/4/reviews: not found", server.URL))
+	_, err = config.GetPullRequestLastCommit(4, "")
+	require.EqualError(suite.T(), err, fmt.Sprintf("GET %s/api/v1/repos/kosli/cli/pulls/4/commits: not found", server.URL))
+}
+
+func (suite *GiteaTestSuite) TestHasNextPage() {
+	for _, t := range []struct {
+		name   string
+		header http.Header
+		count  int
+		total  int
+		want   bool
+	}{
+		{
+			name:   "a Link header with a next page",
+			header: http.Header{"Link": []string{`<https://gitea.example.com/api/v1/x?page=2>; rel="next"`}},
+			count:  10,
+			total:  10,
+			want:   true,
+		},
+		{
+			name:   "a Link header without a next page",
+			header: http.Header{"Link": []string{`<https://gitea.example.com/api/v1/x?page=1>; rel="first"`}, "X-Total-Count": []string{"20"}},
+			count:  10,
+			total:  10,
+			want:   false,
+		},
+		{
+			name:   "fewer items read than the total count",
+			header: http.Header{"X-Total-Count": []string{"20"}},
+			count:  10,
+			total:  10,
+			want:   true,
+		},
+		{
+			name:   "all items read",
+			header: http.Header{"X-Total-Count": []string{"20"}},
+			count:  10,
+			total:  20,
+			want:   false,
+		},
+		{
+			name:   "a non-empty page without pagination headers",
+			header: http.Header{},
+			count:  10,
+			total:  10,
+			want:   true,
+		},
+		{
+			name:   "an empty page without pagination headers",
+			header: http.Header{},
+			count:  0,
+			total:  10,
+			want:   false,
+		},
+	} {
+		suite.Run(t.name, func() {
+			require.Equal(suite.T(), t.want, hasNextPage(t.header, t.count, t.total))
+		})
+	}
+}
+
+func (suite *GiteaTestSuite) TestReviewersFromReviews() {
+	submittedAt := time.Unix(1714564800, 0)
+	approvers, reviewers := reviewersFromReviews([]*Review{
+		{User: &User{Login: "bob"}, State: "APPROVED", SubmittedAt: submittedAt},
+		{User: &User{Login: "carol"}, State: "APPROVED", Dismissed: true, SubmittedAt: submittedAt},
+		{User: &User{Login: "dave"}, State: "REQUEST_CHANGES", SubmittedAt: submittedAt},
+		{User: &User{Login: "erin"}, State: "PENDING", SubmittedAt: submittedAt},
+		{User: &User{Login: "frank"}, State: "REQUEST_REVIEW"},
+	})
+	require.Equal(suite.T(), []string{"bob"}, approvers)
+	require.Equal(suite.T(), []*types.PRReviewer{
+		{Username: "bob", State: types.ReviewApproved, Timestamp: submittedAt.Unix()},
+		{Username: "carol", State: types.ReviewDismissed, Timestamp: submittedAt.Unix()},
+		{Username: "dave", State: types.ReviewChangesRequested, Timestamp: submittedAt.Unix()},
+	}, reviewers)
+}
+
+func (suite *GiteaTestSuite) TestCommitterLogin() {
+	withCommitter := &Commit{Committer: &User{Login: "alice"}, Author: &User{Login: "bob"}}
+	require.Equal(suite.T(), "alice", committerLogin(withCommitter))
+
+	withAuthor := &Commit{Author: &User{Login: "bob"}}
+	require.Equal(suite.T(), "bob", committerLogin(withAuthor))
+
+	withoutUsers := &Commit{}
+	withoutUsers.Commit.Committer.Name = "Jane Doe"
+	require.Equal(suite.T(), "Jane Doe", committerLogin(withoutUsers))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestGiteaTestSuite(t *testing.T) {
+	suite.Run(t, new(GiteaTestSuite))
+}
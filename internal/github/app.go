@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	gh "github.com/google/go-github/v42/github"
+)
+
+// installationTokenRefreshMargin is how long before it expires an installation token is replaced by a new one
+const installationTokenRefreshMargin = time.Minute
+
+// token returns the token used to call the Github API. When Github App credentials are given,
+// it is an installation token which is created once and reused until it is about to expire.
+func (c *GithubConfig) token(ctx context.Context) (string, error) {
+	if c.AppID == 0 {
+		return c.Token, nil
+	}
+	if c.installationToken != nil && time.Until(c.installationToken.GetExpiresAt()) > installationTokenRefreshMargin {
+		return c.installationToken.GetToken(), nil
+	}
+	installationToken, err := c.newInstallationToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.installationToken = installationToken
+	return installationToken.GetToken(), nil
+}
+
+// newClient returns a Github client authenticated with a token or as a Github App installation
+func (c *GithubConfig) newClient(ctx context.Context) (*gh.Client, error) {
+	token, err := c.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewGithubClientFromToken(ctx, token, c.BaseURL)
+}
+
+// newInstallationToken exchanges a JWT signed with the Github App private key for an installation token
+// which can access the repository. The installation of the app on the repository is looked up when no
+// installation ID is given.
+func (c *GithubConfig) newInstallationToken(ctx context.Context) (*gh.InstallationToken, error) {
+	key, err := parseAppPrivateKey(c.AppPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	appToken, err := appJWT(c.AppID, key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewGithubClientFromToken(ctx, appToken, c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	installationID := c.AppInstallationID
+	if installationID == 0 {
+		installation, _, err := client.Apps.FindRepositoryInstallation(ctx, c.Org, c.Repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the installation of Github App %d for repository %s/%s: %v", c.AppID, c.Org, c.Repository, err)
+		}
+		installationID = installation.GetID()
+	}
+	installationToken, _, err := client.Apps.CreateInstallationToken(ctx, installationID, &gh.InstallationTokenOptions{
+		Repositories: []string{c.Repository},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a token for installation %d of Github App %d: %v", installationID, c.AppID, err)
+	}
+	return installationToken, nil
+}
+
+// appJWT returns a JWT which authenticates as a Github App. It is issued a minute in the past
+// to allow for clock drift, and expires before the 10 minutes limit set by Github.
+func appJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(appID, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}
+	appToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign a JWT for Github App %d: %v", appID, err)
+	}
+	return appToken, nil
+}
+
+// parseAppPrivateKey parses a Github App private key given either as PEM contents or as a path to a PEM file
+func parseAppPrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	pemBytes := []byte(privateKey)
+	if !strings.Contains(privateKey, "-----BEGIN") {
+		var err error
+		pemBytes, err = os.ReadFile(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Github App private key: %v", err)
+		}
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Github App private key: %v", err)
+	}
+	return key, nil
+}
@@ -2,9 +2,12 @@ package github
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"strings"
 
 	gh "github.com/google/go-github/v42/github"
+	"github.com/kosli-dev/cli/internal/ratelimit"
 	"github.com/kosli-dev/cli/internal/types"
 
 	"golang.org/x/oauth2"
@@ -15,13 +18,23 @@ type GithubConfig struct {
 	BaseURL    string
 	Org        string
 	Repository string
+	// AppID, AppPrivateKey and AppInstallationID authenticate as a Github App installation
+	// instead of with a token. The installation is looked up when AppInstallationID is not set.
+	AppID             int64
+	AppPrivateKey     string
+	AppInstallationID int64
+	// installationToken is the cached token of the Github App installation
+	installationToken *gh.InstallationToken
 }
 
 type GithubFlagsTempValueHolder struct {
-	Token      string
-	BaseURL    string
-	Org        string
-	Repository string
+	Token             string
+	BaseURL           string
+	Org               string
+	Repository        string
+	AppID             int64
+	AppPrivateKey     string
+	AppInstallationID int64
 }
 
 // NewGithubConfig returns a new GithubConfig
@@ -43,11 +56,13 @@ func extractRepoName(fullRepositoryName string) string {
 	return repository
 }
 
-// NewGithubClientFromToken returns Github client with a token and context
+// NewGithubClientFromToken returns Github client with a token and context.
+// The client waits for the Github rate limit to reset when it is exceeded.
 func NewGithubClientFromToken(ctx context.Context, ghToken string, baseURL string) (*gh.Client, error) {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: ghToken},
 	)
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: &ratelimit.Transport{Service: "Github"}})
 	tc := oauth2.NewClient(ctx, ts)
 	if baseURL != "" {
 		client, err := gh.NewEnterpriseClient(baseURL, baseURL, tc)
@@ -80,44 +95,164 @@ func (c *GithubConfig) newPRGithubEvidence(pr *gh.PullRequest) (*types.PREvidenc
 		URL:         pr.GetHTMLURL(),
 		MergeCommit: pr.GetMergeCommitSHA(),
 		State:       pr.GetState(),
+		Author:      pr.GetUser().GetLogin(),
 	}
-	approvers, err := c.GetPullRequestApprovers(pr.GetNumber())
+	reviews, err := c.GetPullRequestReviews(pr.GetNumber())
 	if err != nil {
 		return evidence, err
 	}
-	evidence.Approvers = approvers
+	evidence.Approvers, evidence.Reviewers = reviewersFromReviews(reviews)
+
+	lastCommit, err := c.GetPullRequestLastCommit(pr.GetNumber())
+	if err != nil {
+		return evidence, err
+	}
+	if lastCommit != nil {
+		evidence.LastCommit = lastCommit.GetSHA()
+		evidence.LastCommitter = committerLogin(lastCommit)
+		evidence.LastCommitTimestamp = lastCommit.GetCommit().GetCommitter().GetDate().Unix()
+	}
+	evidence.CheckFourEyes()
 	return evidence, nil
 }
 
+// reviewersFromReviews returns the approvers and the reviewers of a pull request from its reviews
+func reviewersFromReviews(reviews []*gh.PullRequestReview) ([]string, []*types.PRReviewer) {
+	approvers := []string{}
+	reviewers := []*types.PRReviewer{}
+	for _, r := range reviews {
+		// pending reviews are not submitted yet
+		if r.GetState() == "PENDING" {
+			continue
+		}
+		if r.GetState() == types.ReviewApproved {
+			approvers = append(approvers, r.GetUser().GetLogin())
+		}
+		reviewers = append(reviewers, &types.PRReviewer{
+			Username:  r.GetUser().GetLogin(),
+			State:     r.GetState(),
+			Timestamp: r.GetSubmittedAt().Unix(),
+			CommitID:  r.GetCommitID(),
+		})
+	}
+	return approvers, reviewers
+}
+
+// committerLogin returns the Github login of the committer of a commit. Commits made in the Github UI
+// are committed by web-flow, so their author is returned instead. The git committer name is returned
+// for commits which are not linked to a Github user.
+func committerLogin(commit *gh.RepositoryCommit) string {
+	if login := commit.GetCommitter().GetLogin(); login != "" && login != "web-flow" {
+		return login
+	}
+	if login := commit.GetAuthor().GetLogin(); login != "" {
+		return login
+	}
+	return commit.GetCommit().GetCommitter().GetName()
+}
+
+// rateLimited calls f, and calls it again after the rate limit resets if the Github client rejected the call
+// because the rate limit was exceeded by a previous call
+func rateLimited(f func() (*gh.Response, error)) (*gh.Response, error) {
+	resp, err := f()
+	var rateLimitErr *gh.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		err = ratelimit.Wait("Github", rateLimitErr.Rate.Reset.Time)
+		if err != nil {
+			return resp, err
+		}
+		return f()
+	}
+	return resp, err
+}
+
 // PullRequestsForCommit returns a list of pull requests for a specific commit
 func (c *GithubConfig) PullRequestsForCommit(commit string) ([]*gh.PullRequest, error) {
+	allPullRequests := []*gh.PullRequest{}
 	ctx := context.Background()
-	client, err := NewGithubClientFromToken(ctx, c.Token, c.BaseURL)
+	client, err := c.newClient(ctx)
 	if err != nil {
-		return []*gh.PullRequest{}, err
+		return allPullRequests, err
+	}
+	opts := &gh.PullRequestListOptions{ListOptions: gh.ListOptions{PerPage: 100}}
+	for {
+		var pullRequests []*gh.PullRequest
+		resp, err := rateLimited(func() (resp *gh.Response, err error) {
+			pullRequests, resp, err = client.PullRequests.ListPullRequestsWithCommit(ctx, c.Org, c.Repository, commit, opts)
+			return resp, err
+		})
+		if err != nil {
+			return allPullRequests, err
+		}
+		allPullRequests = append(allPullRequests, pullRequests...)
+		if resp.NextPage == 0 {
+			return allPullRequests, nil
+		}
+		opts.Page = resp.NextPage
 	}
-
-	pullrequests, _, err := client.PullRequests.ListPullRequestsWithCommit(ctx, c.Org, c.Repository,
-		commit, &gh.PullRequestListOptions{})
-	return pullrequests, err
 }
 
 // GetPullRequestApprovers returns a list of approvers for a given pull request
 func (c *GithubConfig) GetPullRequestApprovers(number int) ([]string, error) {
-	approvers := []string{}
+	reviews, err := c.GetPullRequestReviews(number)
+	if err != nil {
+		return []string{}, err
+	}
+	approvers, _ := reviewersFromReviews(reviews)
+	return approvers, nil
+}
+
+// GetPullRequestReviews returns all reviews of a given pull request
+func (c *GithubConfig) GetPullRequestReviews(number int) ([]*gh.PullRequestReview, error) {
+	allReviews := []*gh.PullRequestReview{}
 	ctx := context.Background()
-	client, err := NewGithubClientFromToken(ctx, c.Token, c.BaseURL)
+	client, err := c.newClient(ctx)
 	if err != nil {
-		return approvers, err
+		return allReviews, err
 	}
-	reviews, _, err := client.PullRequests.ListReviews(ctx, c.Org, c.Repository, number, &gh.ListOptions{})
+	opts := &gh.ListOptions{PerPage: 100}
+	for {
+		var reviews []*gh.PullRequestReview
+		resp, err := rateLimited(func() (resp *gh.Response, err error) {
+			reviews, resp, err = client.PullRequests.ListReviews(ctx, c.Org, c.Repository, number, opts)
+			return resp, err
+		})
+		if err != nil {
+			return allReviews, err
+		}
+		allReviews = append(allReviews, reviews...)
+		if resp.NextPage == 0 {
+			return allReviews, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetPullRequestLastCommit returns the last commit of a given pull request, or nil if it has no commits
+func (c *GithubConfig) GetPullRequestLastCommit(number int) (*gh.RepositoryCommit, error) {
+	ctx := context.Background()
+	client, err := c.newClient(ctx)
 	if err != nil {
-		return approvers, err
+		return nil, err
 	}
-	for _, r := range reviews {
-		if r.GetState() == "APPROVED" {
-			approvers = append(approvers, r.GetUser().GetLogin())
+	// commits are listed from the oldest to the newest
+	var lastCommit *gh.RepositoryCommit
+	opts := &gh.ListOptions{PerPage: 100}
+	for {
+		var commits []*gh.RepositoryCommit
+		resp, err := rateLimited(func() (resp *gh.Response, err error) {
+			commits, resp, err = client.PullRequests.ListCommits(ctx, c.Org, c.Repository, number, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
 		}
+		if len(commits) > 0 {
+			lastCommit = commits[len(commits)-1]
+		}
+		if resp.NextPage == 0 {
+			return lastCommit, nil
+		}
+		opts.Page = resp.NextPage
 	}
-	return approvers, nil
 }
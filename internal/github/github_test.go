@@ -2,10 +2,18 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
+	gh "github.com/google/go-github/v42/github"
 	"github.com/kosli-dev/cli/internal/testHelpers"
+	"github.com/kosli-dev/cli/internal/types"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -243,6 +251,167 @@ func (suite *GithubTestSuite) TestExtractRepoName() {
 	}
 }
 
+func (suite *GithubTestSuite) TestReviewersFromReviews() {
+	submittedAt := time.Unix(1700000000, 0)
+	reviews := []*gh.PullRequestReview{
+		{User: &gh.User{Login: gh.String("bob")}, State: gh.String("COMMENTED"), SubmittedAt: &submittedAt},
+		{User: &gh.User{Login: gh.String("bob")}, State: gh.String("APPROVED"), SubmittedAt: &submittedAt, CommitID: gh.String("def")},
+		{User: &gh.User{Login: gh.String("carol")}, State: gh.String("PENDING")},
+	}
+	approvers, reviewers := reviewersFromReviews(reviews)
+	require.Equal(suite.T(), []string{"bob"}, approvers)
+	require.Equal(suite.T(), []*types.PRReviewer{
+		{Username: "bob", State: types.ReviewCommented, Timestamp: 1700000000},
+		{Username: "bob", State: types.ReviewApproved, Timestamp: 1700000000, CommitID: "def"},
+	}, reviewers)
+}
+
+func (suite *GithubTestSuite) TestCommitterLogin() {
+	for _, t := range []struct {
+		name   string
+		commit *gh.RepositoryCommit
+		want   string
+	}{
+		{
+			name: "the committer login is returned",
+			commit: &gh.RepositoryCommit{
+				Author:    &gh.User{Login: gh.String("alice")},
+				Committer: &gh.User{Login: gh.String("bob")},
+			},
+			want: "bob",
+		},
+		{
+			name: "the author login is returned for commits committed by web-flow",
+			commit: &gh.RepositoryCommit{
+				Author:    &gh.User{Login: gh.String("alice")},
+				Committer: &gh.User{Login: gh.String("web-flow")},
+			},
+			want: "alice",
+		},
+		{
+			name: "the git committer name is returned for commits not linked to Github users",
+			commit: &gh.RepositoryCommit{
+				Commit: &gh.Commit{Committer: &gh.CommitAuthor{Name: gh.String("Bob Builder")}},
+			},
+			want: "Bob Builder",
+		},
+	} {
+		suite.Run(t.name, func() {
+			require.Equal(suite.T(), t.want, committerLogin(t.commit))
+		})
+	}
+}
+
+// newFakeGithub returns a fake Github Enterprise API which lists two pull requests for a commit and
+// two pages of reviews for each of them. The first request is rejected because the rate limit is exceeded
+// until rateLimitReset. The pull requests are approved on approvedCommit, while their last commit is def.
+func newFakeGithub(rateLimitReset time.Time, approvedCommit string) *httptest.Server {
+	rateLimited := true
+	writeJSON := func(w http.ResponseWriter, r *http.Request, v interface{}, nextPage int) {
+		if nextPage > 0 {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, r.Host, r.URL.Path, nextPage))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/kosli/cli/commits/abc/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if rateLimited {
+			rateLimited = false
+			w.Header().Set("X-RateLimit-Limit", "60")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(rateLimitReset.Unix()))
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+			return
+		}
+		if r.URL.Query().Get("page") == "2" {
+			writeJSON(w, r, []map[string]interface{}{{"number": 2, "user": map[string]string{"login": "alice"}}}, 0)
+			return
+		}
+		writeJSON(w, r, []map[string]interface{}{{"number": 1, "user": map[string]string{"login": "alice"}}}, 2)
+	})
+	for _, number := range []int{1, 2} {
+		mux.HandleFunc(fmt.Sprintf("/api/v3/repos/kosli/cli/pulls/%d/reviews", number), func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				writeJSON(w, r, []map[string]interface{}{
+					{"state": "APPROVED", "user": map[string]string{"login": "bob"}, "submitted_at": "2024-05-01T14:00:00Z", "commit_id": approvedCommit},
+				}, 0)
+				return
+			}
+			writeJSON(w, r, []map[string]interface{}{
+				{"state": "COMMENTED", "user": map[string]string{"login": "carol"}, "submitted_at": "2024-05-01T13:00:00Z"},
+			}, 2)
+		})
+		mux.HandleFunc(fmt.Sprintf("/api/v3/repos/kosli/cli/pulls/%d/commits", number), func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, r, []map[string]interface{}{{
+				"sha":       "def",
+				"committer": map[string]string{"login": "alice"},
+				"commit":    map[string]interface{}{"committer": map[string]string{"date": "2024-05-01T12:00:00Z"}},
+			}}, 0)
+		})
+	}
+	return httptest.NewServer(mux)
+}
+
+func (suite *GithubTestSuite) TestPREvidenceForCommitFollowsPaginationAndRateLimits() {
+	server := newFakeGithub(time.Now(), "def")
+	defer server.Close()
+
+	config := NewGithubConfig("token", server.URL, "kosli", "cli")
+	evidence, err := config.PREvidenceForCommit("abc")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, e := range evidence {
+		require.Equal(suite.T(), []string{"bob"}, e.Approvers)
+		require.Len(suite.T(), e.Reviewers, 2)
+		require.True(suite.T(), e.FourEyes())
+	}
+}
+
+func (suite *GithubTestSuite) TestPREvidenceForCommitIgnoresApprovalsOfEarlierCommits() {
+	// the last commit is dated before the approval, but was pushed after it
+	server := newFakeGithub(time.Now(), "abc")
+	defer server.Close()
+
+	config := NewGithubConfig("token", server.URL, "kosli", "cli")
+	evidence, err := config.PREvidenceForCommit("abc")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, e := range evidence {
+		require.Equal(suite.T(), []string{"bob"}, e.Approvers)
+		require.Less(suite.T(), e.LastCommitTimestamp, e.Reviewers[1].Timestamp)
+		require.False(suite.T(), e.FourEyes())
+	}
+}
+
+func (suite *GithubTestSuite) TestPREvidenceForCommitFailsWhenRateLimitResetsTooLate() {
+	reset := time.Now().Add(time.Hour)
+	server := newFakeGithub(reset, "def")
+	defer server.Close()
+
+	config := NewGithubConfig("token", server.URL, "kosli", "cli")
+	_, err := config.PREvidenceForCommit("abc")
+	require.ErrorContains(suite.T(), err, fmt.Sprintf("Github rate limit exceeded. It resets at %s, please retry after that", reset.Format(time.RFC3339)))
+}
+
+func (suite *GithubTestSuite) TestRateLimited() {
+	calls := 0
+	rateLimitErr := &gh.RateLimitError{
+		Rate:     gh.Rate{Reset: gh.Timestamp{Time: time.Now()}},
+		Response: &http.Response{Request: &http.Request{Method: http.MethodGet, URL: &url.URL{}}},
+	}
+	_, err := rateLimited(func() (*gh.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, rateLimitErr
+		}
+		return &gh.Response{}, nil
+	})
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), 2, calls)
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestGithubTestSuite(t *testing.T) {
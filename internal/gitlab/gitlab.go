@@ -2,7 +2,12 @@ package gitlab
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/kosli-dev/cli/internal/ratelimit"
 	"github.com/kosli-dev/cli/internal/types"
 	"github.com/xanzy/go-gitlab"
 )
@@ -14,9 +19,12 @@ type GitlabConfig struct {
 	Repository string
 }
 
-// GetClientOptFns creates a list of ClientOptionFunc
+// GetClientOptFns creates a list of ClientOptionFunc.
+// The client waits for the Gitlab rate limit to reset when it is exceeded.
 func (c *GitlabConfig) GetClientOptFns() []gitlab.ClientOptionFunc {
-	clientOptFns := []gitlab.ClientOptionFunc{}
+	clientOptFns := []gitlab.ClientOptionFunc{
+		gitlab.WithHTTPClient(&http.Client{Transport: &ratelimit.Transport{Service: "Gitlab"}}),
+	}
 	if c.BaseURL != "" {
 		clientOptFns = append(clientOptFns, gitlab.WithBaseURL(c.BaseURL))
 	}
@@ -60,27 +68,110 @@ func (c *GitlabConfig) newPRGitlabEvidence(mr *gitlab.MergeRequest) (*types.PREv
 		MergeCommit: mr.MergeCommitSHA,
 		State:       mr.State,
 	}
+	if mr.Author != nil {
+		evidence.Author = mr.Author.Username
+	}
 	approvers, err := c.GetMergeRequestApprovers(mr.IID)
 	if err != nil {
 		return evidence, err
 	}
 	evidence.Approvers = approvers
+
+	notes, err := c.GetMergeRequestSystemNotes(mr.IID)
+	if err != nil {
+		return evidence, err
+	}
+	evidence.Reviewers = reviewersFromNotes(notes)
+
+	lastCommit, err := c.GetMergeRequestLastCommit(mr.IID)
+	if err != nil {
+		return evidence, err
+	}
+	if lastCommit != nil {
+		evidence.LastCommit = lastCommit.ID
+		evidence.LastCommitter = lastCommit.CommitterName
+		// the git committer name is not a Gitlab username, so it cannot be compared with the approvers
+		username, err := c.GetUsernameByEmail(lastCommit.CommitterEmail)
+		if err != nil {
+			return evidence, err
+		}
+		if username != "" {
+			evidence.LastCommitter = username
+		}
+		if lastCommit.CommittedDate != nil {
+			evidence.LastCommitTimestamp = lastCommit.CommittedDate.Unix()
+		}
+		pushedAt, err := c.GetMergeRequestCommitPushTime(mr.IID, lastCommit.ID)
+		if err != nil {
+			return evidence, err
+		}
+		if pushedAt != nil {
+			evidence.LastCommitPushedTimestamp = pushedAt.Unix()
+		}
+	}
+	evidence.CheckFourEyes()
 	return evidence, nil
 }
 
+// reviewersFromNotes returns the reviewers of an MR from the system notes which
+// Gitlab adds when an MR is approved, unapproved or when changes are requested
+func reviewersFromNotes(notes []*gitlab.Note) []*types.PRReviewer {
+	reviewers := []*types.PRReviewer{}
+	for _, note := range notes {
+		var state string
+		switch note.Body {
+		case "approved this merge request":
+			state = types.ReviewApproved
+		case "unapproved this merge request":
+			state = types.ReviewDismissed
+		case "requested changes":
+			state = types.ReviewChangesRequested
+		default:
+			continue
+		}
+		reviewer := &types.PRReviewer{
+			Username: note.Author.Username,
+			State:    state,
+		}
+		if note.CreatedAt != nil {
+			reviewer.Timestamp = note.CreatedAt.Unix()
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	return reviewers
+}
+
 // MergeRequestsForCommit returns a list of MRs for a given commit
 func (c *GitlabConfig) MergeRequestsForCommit(commit string) ([]*gitlab.MergeRequest, error) {
-	mrs := []*gitlab.MergeRequest{}
+	allMRs := []*gitlab.MergeRequest{}
 	client, err := c.NewGitlabClientFromToken()
 	if err != nil {
-		return mrs, err
+		return allMRs, err
+	}
+	page := 1
+	for {
+		mrs, resp, err := client.Commits.ListMergeRequestsByCommit(c.ProjectID(), commit, withPage(page))
+		if err != nil {
+			return allMRs, err
+		}
+		allMRs = append(allMRs, mrs...)
+		if resp.NextPage == 0 {
+			return allMRs, nil
+		}
+		page = resp.NextPage
 	}
+}
 
-	mrs, _, err = client.Commits.ListMergeRequestsByCommit(c.ProjectID(), commit)
-	if err != nil {
-		return mrs, err
+// withPage requests a page of 100 items from Gitlab APIs whose
+// methods do not take list options
+func withPage(page int) gitlab.RequestOptionFunc {
+	return func(req *retryablehttp.Request) error {
+		query := req.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", "100")
+		req.URL.RawQuery = query.Encode()
+		return nil
 	}
-	return mrs, nil
 }
 
 // GetMergeRequestApprovers returns a list of users (name and username) who approved an MR
@@ -99,3 +190,84 @@ func (c *GitlabConfig) GetMergeRequestApprovers(mrIID int) ([]string, error) {
 	}
 	return approvers, nil
 }
+
+// GetMergeRequestSystemNotes returns the system notes of an MR, e.g. about its approvals
+func (c *GitlabConfig) GetMergeRequestSystemNotes(mrIID int) ([]*gitlab.Note, error) {
+	systemNotes := []*gitlab.Note{}
+	client, err := c.NewGitlabClientFromToken()
+	if err != nil {
+		return systemNotes, err
+	}
+	opts := &gitlab.ListMergeRequestNotesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		notes, resp, err := client.Notes.ListMergeRequestNotes(c.ProjectID(), mrIID, opts)
+		if err != nil {
+			return systemNotes, err
+		}
+		for _, note := range notes {
+			if note.System {
+				systemNotes = append(systemNotes, note)
+			}
+		}
+		if resp.NextPage == 0 {
+			return systemNotes, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetMergeRequestLastCommit returns the last commit of an MR, or nil if it has no commits.
+// Gitlab lists MR commits from the newest to the oldest.
+func (c *GitlabConfig) GetMergeRequestLastCommit(mrIID int) (*gitlab.Commit, error) {
+	client, err := c.NewGitlabClientFromToken()
+	if err != nil {
+		return nil, err
+	}
+	commits, _, err := client.MergeRequests.GetMergeRequestCommits(c.ProjectID(), mrIID, &gitlab.GetMergeRequestCommitsOptions{PerPage: 1})
+	if err != nil || len(commits) == 0 {
+		return nil, err
+	}
+	return commits[0], nil
+}
+
+// GetMergeRequestCommitPushTime returns when a commit was last pushed as the head of an MR, or nil if it never was.
+// Gitlab creates a new diff version of an MR each time its head changes, and lists them from the newest to the oldest.
+func (c *GitlabConfig) GetMergeRequestCommitPushTime(mrIID int, sha string) (*time.Time, error) {
+	client, err := c.NewGitlabClientFromToken()
+	if err != nil {
+		return nil, err
+	}
+	opts := &gitlab.GetMergeRequestDiffVersionsOptions{PerPage: 100}
+	for {
+		versions, resp, err := client.MergeRequests.GetMergeRequestDiffVersions(c.ProjectID(), mrIID, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if version.HeadCommitSHA == sha {
+				return version.CreatedAt, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetUsernameByEmail returns the username of the Gitlab user with an email, or an empty string if
+// there is no single such user. Gitlab only matches the public email of users unless the token belongs to an admin.
+func (c *GitlabConfig) GetUsernameByEmail(email string) (string, error) {
+	if email == "" {
+		return "", nil
+	}
+	client, err := c.NewGitlabClientFromToken()
+	if err != nil {
+		return "", err
+	}
+	users, _, err := client.Users.ListUsers(&gitlab.ListUsersOptions{Search: gitlab.String(email)})
+	if err != nil || len(users) != 1 {
+		return "", err
+	}
+	return users[0].Username, nil
+}
@@ -1,12 +1,19 @@
 package gitlab
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/kosli-dev/cli/internal/testHelpers"
+	"github.com/kosli-dev/cli/internal/types"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/xanzy/go-gitlab"
 )
 
 type GitlabTestSuite struct {
@@ -299,6 +306,161 @@ func (suite *GitlabTestSuite) TestGetMergeRequestApprovers() {
 	}
 }
 
+func (suite *GitlabTestSuite) TestReviewersFromNotes() {
+	createdAt := time.Unix(1700000000, 0)
+	note := func(username, body string) *gitlab.Note {
+		n := &gitlab.Note{Body: body, System: true, CreatedAt: &createdAt}
+		n.Author.Username = username
+		return n
+	}
+	reviewers := reviewersFromNotes([]*gitlab.Note{
+		note("bob", "approved this merge request"),
+		note("bob", "added 1 commit"),
+		note("bob", "unapproved this merge request"),
+		note("carol", "requested changes"),
+	})
+	require.Equal(suite.T(), []*types.PRReviewer{
+		{Username: "bob", State: types.ReviewApproved, Timestamp: 1700000000},
+		{Username: "bob", State: types.ReviewDismissed, Timestamp: 1700000000},
+		{Username: "carol", State: types.ReviewChangesRequested, Timestamp: 1700000000},
+	}, reviewers)
+}
+
+// newFakeGitlab returns a fake Gitlab API which lists two merge requests for a commit, in two pages,
+// and two pages of notes for each of them. The first request is rejected because the rate limit is
+// exceeded until rateLimitReset. The last commit of the merge requests is pushed at pushedAt, by
+// Alice Smith with committerEmail.
+func newFakeGitlab(rateLimitReset time.Time, pushedAt, committerEmail string) *httptest.Server {
+	rateLimited := true
+	writeJSON := func(w http.ResponseWriter, v interface{}, nextPage string) {
+		w.Header().Set("X-Next-Page", nextPage)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimited {
+			rateLimited = false
+			w.Header().Set("RateLimit-Reset", fmt.Sprint(rateLimitReset.Unix()))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		switch r.URL.Path {
+		case "/api/v4/projects/kosli/cli/repository/commits/abc/merge_requests":
+			if page == "2" {
+				writeJSON(w, []map[string]interface{}{{"iid": 2, "author": map[string]string{"username": "alice"}}}, "")
+				return
+			}
+			writeJSON(w, []map[string]interface{}{{"iid": 1, "author": map[string]string{"username": "alice"}}}, "2")
+		case "/api/v4/projects/kosli/cli/merge_requests/1/approvals", "/api/v4/projects/kosli/cli/merge_requests/2/approvals":
+			writeJSON(w, map[string]interface{}{
+				"approved_by": []map[string]interface{}{{"user": map[string]string{"name": "Bob", "username": "bob"}}},
+			}, "")
+		case "/api/v4/projects/kosli/cli/merge_requests/1/notes", "/api/v4/projects/kosli/cli/merge_requests/2/notes":
+			if page == "2" {
+				writeJSON(w, []map[string]interface{}{{
+					"system": true, "body": "approved this merge request",
+					"author": map[string]string{"username": "bob"}, "created_at": "2024-05-01T14:00:00Z",
+				}}, "")
+				return
+			}
+			writeJSON(w, []map[string]interface{}{{
+				"system": true, "body": "added 1 commit",
+				"author": map[string]string{"username": "alice"}, "created_at": "2024-05-01T12:00:00Z",
+			}}, "2")
+		case "/api/v4/projects/kosli/cli/merge_requests/1/commits", "/api/v4/projects/kosli/cli/merge_requests/2/commits":
+			writeJSON(w, []map[string]interface{}{{
+				"id": "def", "committer_name": "Alice Smith", "committer_email": committerEmail, "committed_date": "2024-05-01T12:00:00Z",
+			}}, "")
+		case "/api/v4/users":
+			usernames := map[string]string{"alice@example.com": "alice", "bob@example.com": "bob"}
+			users := []map[string]string{}
+			if username, ok := usernames[r.URL.Query().Get("search")]; ok {
+				users = append(users, map[string]string{"username": username})
+			}
+			writeJSON(w, users, "")
+		case "/api/v4/projects/kosli/cli/merge_requests/1/versions", "/api/v4/projects/kosli/cli/merge_requests/2/versions":
+			writeJSON(w, []map[string]interface{}{
+				{"id": 2, "head_commit_sha": "def", "created_at": pushedAt},
+				{"id": 1, "head_commit_sha": "cba", "created_at": "2024-05-01T11:00:00Z"},
+			}, "")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func (suite *GitlabTestSuite) TestPREvidenceForCommitFollowsPaginationAndRateLimits() {
+	server := newFakeGitlab(time.Now(), "2024-05-01T12:30:00Z", "alice@example.com")
+	defer server.Close()
+
+	config := &GitlabConfig{Token: "token", BaseURL: server.URL, Org: "kosli", Repository: "cli"}
+	evidence, err := config.PREvidenceForCommit("abc")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, e := range evidence {
+		require.Equal(suite.T(), []string{"Bob (@bob)"}, e.Approvers)
+		require.Len(suite.T(), e.Reviewers, 1)
+		require.Equal(suite.T(), "alice", e.LastCommitter)
+		require.True(suite.T(), e.FourEyes())
+	}
+}
+
+func (suite *GitlabTestSuite) TestPREvidenceForCommitDetectsApprovalsByTheLastCommitter() {
+	// the git committer name differs from the Gitlab username of the approver who pushed the last commit
+	server := newFakeGitlab(time.Now(), "2024-05-01T12:30:00Z", "bob@example.com")
+	defer server.Close()
+
+	config := &GitlabConfig{Token: "token", BaseURL: server.URL, Org: "kosli", Repository: "cli"}
+	evidence, err := config.PREvidenceForCommit("abc")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, e := range evidence {
+		require.Equal(suite.T(), "bob", e.LastCommitter)
+		require.True(suite.T(), e.SelfApproved)
+		require.False(suite.T(), e.FourEyes())
+	}
+}
+
+func (suite *GitlabTestSuite) TestPREvidenceForCommitKeepsTheCommitterNameOfUnknownEmails() {
+	server := newFakeGitlab(time.Now(), "2024-05-01T12:30:00Z", "someone@example.com")
+	defer server.Close()
+
+	config := &GitlabConfig{Token: "token", BaseURL: server.URL, Org: "kosli", Repository: "cli"}
+	evidence, err := config.PREvidenceForCommit("abc")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, e := range evidence {
+		require.Equal(suite.T(), "Alice Smith", e.LastCommitter)
+	}
+}
+
+func (suite *GitlabTestSuite) TestPREvidenceForCommitIgnoresApprovalsBeforeTheLastCommitWasPushed() {
+	// the last commit is dated before the approval, e.g. because it was rebased, but was pushed after it
+	server := newFakeGitlab(time.Now(), "2024-05-01T15:00:00Z", "alice@example.com")
+	defer server.Close()
+
+	config := &GitlabConfig{Token: "token", BaseURL: server.URL, Org: "kosli", Repository: "cli"}
+	evidence, err := config.PREvidenceForCommit("abc")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), evidence, 2)
+	for _, e := range evidence {
+		require.Less(suite.T(), e.LastCommitTimestamp, e.Reviewers[0].Timestamp)
+		require.Equal(suite.T(), time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC).Unix(), e.LastCommitPushedTimestamp)
+		require.False(suite.T(), e.FourEyes())
+	}
+}
+
+func (suite *GitlabTestSuite) TestPREvidenceForCommitFailsWhenRateLimitResetsTooLate() {
+	reset := time.Now().Add(time.Hour)
+	server := newFakeGitlab(reset, "2024-05-01T12:30:00Z", "alice@example.com")
+	defer server.Close()
+
+	config := &GitlabConfig{Token: "token", BaseURL: server.URL, Org: "kosli", Repository: "cli"}
+	_, err := config.PREvidenceForCommit("abc")
+	require.ErrorContains(suite.T(), err, fmt.Sprintf("Gitlab rate limit exceeded. It resets at %s, please retry after that", reset.Format(time.RFC3339)))
+}
+
 // In order for 'go test' to run this suite, we need to create
 // a normal test function and pass our suite to suite.Run
 func TestGitlabTestSuite(t *testing.T) {
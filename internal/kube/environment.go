@@ -0,0 +1,178 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// KosliEnvironmentGVR is the group, version and resource of the KosliEnvironment custom resource
+var KosliEnvironmentGVR = schema.GroupVersionResource{Group: "kosli.com", Version: "v1alpha1", Resource: "koslienvironments"}
+
+const (
+	// KosliEnvironmentReporting is the status phase of an environment whose last check succeeded
+	KosliEnvironmentReporting = "Reporting"
+	// KosliEnvironmentFailing is the status phase of an environment whose last check failed
+	KosliEnvironmentFailing = "Failing"
+	// defaultAPITokenSecretKey is the key of the API token in the secret when none is set
+	defaultAPITokenSecretKey = "token"
+)
+
+// KosliEnvironment is a custom resource describing a Kosli environment
+// to be reported from the cluster
+type KosliEnvironment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              KosliEnvironmentSpec   `json:"spec"`
+	Status            KosliEnvironmentStatus `json:"status,omitempty"`
+}
+
+// KosliEnvironmentSpec is the desired state of a KosliEnvironment
+type KosliEnvironmentSpec struct {
+	// EnvironmentName is the name of the Kosli environment to report to
+	EnvironmentName string `json:"environmentName"`
+	// Org is the Kosli org of the environment. It defaults to the org the operator is configured with
+	Org                    string   `json:"org,omitempty"`
+	Namespaces             []string `json:"namespaces,omitempty"`
+	NamespacesRegex        []string `json:"namespacesRegex,omitempty"`
+	ExcludeNamespaces      []string `json:"excludeNamespaces,omitempty"`
+	ExcludeNamespacesRegex []string `json:"excludeNamespacesRegex,omitempty"`
+	// APITokenSecretRef references the secret (in the namespace of the resource) holding the Kosli API token
+	APITokenSecretRef SecretKeyReference `json:"apiTokenSecretRef"`
+}
+
+// SecretKeyReference references a key of a secret
+type SecretKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+}
+
+// KosliEnvironmentStatus is the observed state of a KosliEnvironment
+type KosliEnvironmentStatus struct {
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	Phase              string       `json:"phase,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	LastCheckTime      *metav1.Time `json:"lastCheckTime,omitempty"`
+	LastReportTime     *metav1.Time `json:"lastReportTime,omitempty"`
+	ReportedPods       int          `json:"reportedPods"`
+}
+
+// Filter returns the namespaces filter of the environment
+func (spec *KosliEnvironmentSpec) Filter() *filters.ResourceFilterOptions {
+	return &filters.ResourceFilterOptions{
+		IncludeNames:      spec.Namespaces,
+		IncludeNamesRegex: spec.NamespacesRegex,
+		ExcludeNames:      spec.ExcludeNamespaces,
+		ExcludeNamesRegex: spec.ExcludeNamespacesRegex,
+	}
+}
+
+// Validate checks the spec is complete and the namespaces filter is consistent
+func (spec *KosliEnvironmentSpec) Validate() error {
+	if spec.EnvironmentName == "" {
+		return fmt.Errorf("spec.environmentName is required")
+	}
+	if spec.APITokenSecretRef.Name == "" {
+		return fmt.Errorf("spec.apiTokenSecretRef.name is required")
+	}
+	if (len(spec.Namespaces) > 0 || len(spec.NamespacesRegex) > 0) &&
+		(len(spec.ExcludeNamespaces) > 0 || len(spec.ExcludeNamespacesRegex) > 0) {
+		return fmt.Errorf("spec.namespaces and spec.namespacesRegex can't be used together with spec.excludeNamespaces or spec.excludeNamespacesRegex")
+	}
+	return nil
+}
+
+// APITokenSecretKey returns the key of the API token in the referenced secret
+func (spec *KosliEnvironmentSpec) APITokenSecretKey() string {
+	if spec.APITokenSecretRef.Key == "" {
+		return defaultAPITokenSecretKey
+	}
+	return spec.APITokenSecretRef.Key
+}
+
+// NewKosliEnvironmentFromUnstructured converts a KosliEnvironment from its unstructured form
+func NewKosliEnvironmentFromUnstructured(object *unstructured.Unstructured) (*KosliEnvironment, error) {
+	env := &KosliEnvironment{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.UnstructuredContent(), env)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert KosliEnvironment %s/%s: %v", object.GetNamespace(), object.GetName(), err)
+	}
+	return env, nil
+}
+
+// EnvironmentClient manages KosliEnvironment resources
+type EnvironmentClient struct {
+	dynamic.Interface
+}
+
+// NewEnvironmentClient creates a client for KosliEnvironment resources
+// if the kubeconfigPath is empty, it attempts to get an in-cluster client
+func NewEnvironmentClient(kubeconfigPath string) (*EnvironmentClient, error) {
+	config, err := newRestConfig(kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &EnvironmentClient{client}, nil
+}
+
+// WatchEnvironments calls the handler for KosliEnvironment resources in a namespace (or all
+// namespaces if empty) being added, updated or deleted until the context is cancelled
+func (client *EnvironmentClient) WatchEnvironments(ctx context.Context, namespace string, resync time.Duration, handler cache.ResourceEventHandler) error {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, resync, namespace, nil)
+	informer := factory.ForResource(KosliEnvironmentGVR).Informer()
+	if _, err := informer.AddEventHandler(handler); err != nil {
+		return fmt.Errorf("could not watch KosliEnvironment resources: %v", err)
+	}
+	factory.Start(ctx.Done())
+	for _, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("could not sync the KosliEnvironment informer")
+		}
+	}
+	return nil
+}
+
+// UpdateEnvironmentStatus writes the status of a KosliEnvironment, retrying on conflicts
+func (client *EnvironmentClient) UpdateEnvironmentStatus(ctx context.Context, namespace, name string, status KosliEnvironmentStatus) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+	resources := client.Resource(KosliEnvironmentGVR).Namespace(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		object, err := resources.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		object.Object["status"] = content
+		_, err = resources.UpdateStatus(ctx, object, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// GetSecretValue gets the value of a key in a secret
+func (clientset *K8SConnection) GetSecretValue(namespace, name, key string) (string, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %s on namespace %s: %v", name, namespace, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s on namespace %s has no key %s", name, namespace, key)
+	}
+	return string(value), nil
+}
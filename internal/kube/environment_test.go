@@ -0,0 +1,124 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type EnvironmentTestSuite struct {
+	suite.Suite
+}
+
+func (suite *EnvironmentTestSuite) TestValidate() {
+	for _, t := range []struct {
+		name    string
+		spec    KosliEnvironmentSpec
+		wantErr string
+	}{
+		{
+			name: "a spec with names and a secret is valid",
+			spec: KosliEnvironmentSpec{EnvironmentName: "prod", APITokenSecretRef: SecretKeyReference{Name: "token"}},
+		},
+		{
+			name: "a spec with included namespaces is valid",
+			spec: KosliEnvironmentSpec{EnvironmentName: "prod", Namespaces: []string{"a"}, NamespacesRegex: []string{"^b"},
+				APITokenSecretRef: SecretKeyReference{Name: "token"}},
+		},
+		{
+			name:    "the environment name is required",
+			spec:    KosliEnvironmentSpec{APITokenSecretRef: SecretKeyReference{Name: "token"}},
+			wantErr: "spec.environmentName is required",
+		},
+		{
+			name:    "the secret name is required",
+			spec:    KosliEnvironmentSpec{EnvironmentName: "prod"},
+			wantErr: "spec.apiTokenSecretRef.name is required",
+		},
+		{
+			name: "included and excluded namespaces can't be combined",
+			spec: KosliEnvironmentSpec{EnvironmentName: "prod", NamespacesRegex: []string{"^a"}, ExcludeNamespaces: []string{"b"},
+				APITokenSecretRef: SecretKeyReference{Name: "token"}},
+			wantErr: "spec.namespaces and spec.namespacesRegex can't be used together with spec.excludeNamespaces or spec.excludeNamespacesRegex",
+		},
+	} {
+		suite.Run(t.name, func() {
+			err := t.spec.Validate()
+			if t.wantErr == "" {
+				require.NoError(suite.T(), err)
+			} else {
+				require.EqualError(suite.T(), err, t.wantErr)
+			}
+		})
+	}
+}
+
+func (suite *EnvironmentTestSuite) TestAPITokenSecretKey() {
+	spec := KosliEnvironmentSpec{APITokenSecretRef: SecretKeyReference{Name: "kosli"}}
+	require.Equal(suite.T(), "token", spec.APITokenSecretKey())
+	spec.APITokenSecretRef.Key = "api-token"
+	require.Equal(suite.T(), "api-token", spec.APITokenSecretKey())
+}
+
+func (suite *EnvironmentTestSuite) TestUpdateEnvironmentStatus() {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&KosliEnvironment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kosli.com/v1alpha1", Kind: "KosliEnvironment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "kosli"},
+		Spec:       KosliEnvironmentSpec{EnvironmentName: "prod-k8s", APITokenSecretRef: SecretKeyReference{Name: "token"}},
+	})
+	require.NoError(suite.T(), err)
+	client := &EnvironmentClient{dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{KosliEnvironmentGVR: "KosliEnvironmentList"},
+		&unstructured.Unstructured{Object: content},
+	)}
+
+	err = client.UpdateEnvironmentStatus(context.Background(), "kosli", "prod",
+		KosliEnvironmentStatus{Phase: KosliEnvironmentReporting, ReportedPods: 3})
+	require.NoError(suite.T(), err)
+
+	object, err := client.Resource(KosliEnvironmentGVR).Namespace("kosli").Get(context.Background(), "prod", metav1.GetOptions{})
+	require.NoError(suite.T(), err)
+	env, err := NewKosliEnvironmentFromUnstructured(object)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "prod-k8s", env.Spec.EnvironmentName)
+	require.Equal(suite.T(), KosliEnvironmentStatus{Phase: KosliEnvironmentReporting, ReportedPods: 3}, env.Status)
+
+	err = client.UpdateEnvironmentStatus(context.Background(), "kosli", "missing", KosliEnvironmentStatus{})
+	require.Error(suite.T(), err)
+}
+
+func (suite *EnvironmentTestSuite) TestGetSecretValue() {
+	clientset := &K8SConnection{fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "kosli", Namespace: "kosli"},
+		Data:       map[string][]byte{"token": []byte("secret")},
+	})}
+
+	value, err := clientset.GetSecretValue("kosli", "kosli", "token")
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "secret", value)
+
+	_, err = clientset.GetSecretValue("kosli", "kosli", "api-token")
+	require.EqualError(suite.T(), err, "secret kosli on namespace kosli has no key api-token")
+
+	_, err = clientset.GetSecretValue("default", "kosli", "token")
+	require.Error(suite.T(), err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestEnvironmentTestSuite(t *testing.T) {
+	suite.Run(t, new(EnvironmentTestSuite))
+}
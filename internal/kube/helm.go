@@ -0,0 +1,314 @@
+package kube
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// helmReleaseSecretType is the type of the secrets in which Helm 3 stores releases
+	helmReleaseSecretType = "helm.sh/release.v1"
+	// helmDeployedReleasesSelector selects the secrets of the currently deployed revision of each release
+	helmDeployedReleasesSelector = "owner=helm,status=deployed"
+	// annotations Helm (3.2+) adds to every resource it creates
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// labels commonly set by Helm charts to the name of the release
+var helmReleaseLabels = []string{"app.kubernetes.io/instance", "release"}
+
+// gzip magic header, Helm compresses the release before encoding it
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// HelmReleaseData represents the Helm release that produced a pod
+type HelmReleaseData struct {
+	ReleaseName  string `json:"releaseName"`
+	Revision     int    `json:"revision"`
+	Chart        string `json:"chart"`
+	ChartVersion string `json:"chartVersion"`
+	AppVersion   string `json:"appVersion,omitempty"`
+}
+
+// HelmRelease is a deployed Helm release read from a release secret
+type HelmRelease struct {
+	HelmReleaseData
+	Namespace string
+	// resources contains the "Kind/name" of each resource in the release manifest
+	resources map[string]bool
+}
+
+// helmReleaseRecord is the subset of the Helm release record that is used
+type helmReleaseRecord struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Manifest  string `json:"manifest"`
+	Chart     struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// DecodeHelmReleaseSecret decodes a Helm release from the secret that Helm stores it in.
+// The release is base64 encoded and gzip compressed JSON in the "release" key of the secret.
+func DecodeHelmReleaseSecret(secret *corev1.Secret) (*HelmRelease, error) {
+	if secret.Type != helmReleaseSecretType {
+		return nil, fmt.Errorf("secret %s/%s is not a Helm release secret", secret.Namespace, secret.Name)
+	}
+	encoded, ok := secret.Data["release"]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s does not contain a Helm release", secret.Namespace, secret.Name)
+	}
+	content, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Helm release in secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+	if bytes.HasPrefix(content, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress Helm release in secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+		defer reader.Close()
+		content, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress Helm release in secret %s/%s: %v", secret.Namespace, secret.Name, err)
+		}
+	}
+
+	record := &helmReleaseRecord{}
+	if err := json.Unmarshal(content, record); err != nil {
+		return nil, fmt.Errorf("failed to parse Helm release in secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+
+	resources, err := manifestResources(record.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of Helm release %s: %v", record.Name, err)
+	}
+
+	namespace := record.Namespace
+	if namespace == "" {
+		namespace = secret.Namespace
+	}
+	return &HelmRelease{
+		HelmReleaseData: HelmReleaseData{
+			ReleaseName:  record.Name,
+			Revision:     record.Version,
+			Chart:        record.Chart.Metadata.Name,
+			ChartVersion: record.Chart.Metadata.Version,
+			AppVersion:   record.Chart.Metadata.AppVersion,
+		},
+		Namespace: namespace,
+		resources: resources,
+	}, nil
+}
+
+// manifestResources returns the "Kind/name" of each resource in a (multi-document) YAML manifest
+func manifestResources(manifest string) (map[string]bool, error) {
+	resources := make(map[string]bool)
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for {
+		object := &metav1.PartialObjectMetadata{}
+		err := decoder.Decode(object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return resources, err
+		}
+		if object.Kind != "" && object.Name != "" {
+			resources[object.Kind+"/"+object.Name] = true
+		}
+	}
+	return resources, nil
+}
+
+// GetHelmReleases gets the deployed Helm releases stored in a namespace
+func (clientset *K8SConnection) GetHelmReleases(namespace string) ([]*HelmRelease, error) {
+	releases := []*HelmRelease{}
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: helmDeployedReleasesSelector,
+	})
+	if err != nil {
+		return releases, fmt.Errorf("could not list Helm release secrets on namespace %s: %v ", namespace, err)
+	}
+	for i := range secrets.Items {
+		if secrets.Items[i].Type != helmReleaseSecretType {
+			continue
+		}
+		release, err := DecodeHelmReleaseSecret(&secrets.Items[i])
+		if err != nil {
+			return releases, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// AddHelmReleases reads the Helm releases in the namespaces of the given pods and
+// sets the release each pod belongs to. A pod is mapped to a release through
+// (in order of precedence):
+// - the Helm release annotations of the workload that controls it (e.g. its Deployment).
+// - the workload being a resource in the release manifest.
+// - the app.kubernetes.io/instance or release label of the pod.
+// Pods that do not belong to a release are left unchanged.
+func (clientset *K8SConnection) AddHelmReleases(podsData []*PodData, logger *logger.Logger) error {
+	releases := make(map[string][]*HelmRelease)
+	loadReleases := func(namespace string) error {
+		if _, ok := releases[namespace]; ok || namespace == "" {
+			return nil
+		}
+		namespaceReleases, err := clientset.GetHelmReleases(namespace)
+		if err != nil {
+			return err
+		}
+		logger.Debug("found [%d] Helm releases in namespace %s", len(namespaceReleases), namespace)
+		releases[namespace] = namespaceReleases
+		return nil
+	}
+
+	controllers := make(map[string]*metav1.PartialObjectMetadata)
+	for _, pod := range podsData {
+		controller, err := clientset.getTopLevelController(pod.Namespace, pod.Owners, controllers)
+		if err != nil {
+			return err
+		}
+		if err := loadReleases(pod.Namespace); err != nil {
+			return err
+		}
+		// a release can create resources outside of its own namespace
+		if controller != nil {
+			if err := loadReleases(controller.Annotations[helmReleaseNamespaceAnnotation]); err != nil {
+				return err
+			}
+		}
+		if release := findPodRelease(pod, controller, releases); release != nil {
+			data := release.HelmReleaseData
+			pod.HelmRelease = &data
+		}
+	}
+	return nil
+}
+
+// findPodRelease finds the release a pod belongs to given the workload that controls it (if any)
+func findPodRelease(pod *PodData, controller *metav1.PartialObjectMetadata, releases map[string][]*HelmRelease) *HelmRelease {
+	if controller != nil {
+		if name, ok := controller.Annotations[helmReleaseNameAnnotation]; ok {
+			namespace := controller.Annotations[helmReleaseNamespaceAnnotation]
+			if namespace == "" {
+				namespace = pod.Namespace
+			}
+			for _, release := range releases[namespace] {
+				if release.ReleaseName == name {
+					return release
+				}
+			}
+		}
+		for _, release := range releases[pod.Namespace] {
+			if release.resources[controller.Kind+"/"+controller.Name] {
+				return release
+			}
+		}
+	}
+	for _, label := range helmReleaseLabels {
+		name, ok := pod.labels[label]
+		if !ok {
+			continue
+		}
+		for _, release := range releases[pod.Namespace] {
+			if release.ReleaseName == name {
+				return release
+			}
+		}
+	}
+	return nil
+}
+
+// getTopLevelController follows the controller owner references of a pod to the workload
+// that manages it, e.g. Pod -> ReplicaSet -> Deployment or Pod -> Job -> CronJob.
+// It returns nil for pods without a controller. Looked up objects are cached in cache.
+func (clientset *K8SConnection) getTopLevelController(namespace string, owners []metav1.OwnerReference, cache map[string]*metav1.PartialObjectMetadata) (*metav1.PartialObjectMetadata, error) {
+	var controller *metav1.PartialObjectMetadata
+	for depth := 0; depth < 5; depth++ {
+		ref := controllerRef(owners)
+		if ref == nil {
+			return controller, nil
+		}
+		key := fmt.Sprintf("%s/%s/%s", namespace, ref.Kind, ref.Name)
+		object, ok := cache[key]
+		if !ok {
+			var err error
+			object, err = clientset.getOwnerObject(namespace, ref)
+			if err != nil {
+				return nil, err
+			}
+			cache[key] = object
+		}
+		controller = object
+		owners = object.OwnerReferences
+	}
+	return controller, nil
+}
+
+// controllerRef returns the owner reference of the controller of an object, if any
+func controllerRef(owners []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range owners {
+		if owners[i].Controller != nil && *owners[i].Controller {
+			return &owners[i]
+		}
+	}
+	return nil
+}
+
+// getOwnerObject gets the metadata of the object an owner reference points to.
+// Kinds that are not looked up (e.g. custom resources) are returned from the reference only.
+func (clientset *K8SConnection) getOwnerObject(namespace string, ref *metav1.OwnerReference) (*metav1.PartialObjectMetadata, error) {
+	ctx := context.Background()
+	var (
+		object metav1.Object
+		err    error
+	)
+	switch ref.Kind {
+	case "ReplicaSet":
+		object, err = clientset.AppsV1().ReplicaSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "Deployment":
+		object, err = clientset.AppsV1().Deployments(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "StatefulSet":
+		object, err = clientset.AppsV1().StatefulSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "DaemonSet":
+		object, err = clientset.AppsV1().DaemonSets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "Job":
+		object, err = clientset.BatchV1().Jobs(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	case "CronJob":
+		object, err = clientset.BatchV1().CronJobs(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	default:
+		object = &metav1.ObjectMeta{Name: ref.Name, Namespace: namespace}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s %s on namespace %s: %v ", ref.Kind, ref.Name, namespace, err)
+	}
+	return &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: ref.APIVersion, Kind: ref.Kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            object.GetName(),
+			Namespace:       object.GetNamespace(),
+			Annotations:     object.GetAnnotations(),
+			OwnerReferences: object.GetOwnerReferences(),
+		},
+	}, nil
+}
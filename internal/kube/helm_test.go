@@ -0,0 +1,207 @@
+package kube
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type HelmTestSuite struct {
+	suite.Suite
+}
+
+func (suite *HelmTestSuite) TestDecodeHelmReleaseSecret() {
+	for _, t := range []struct {
+		name    string
+		secret  *corev1.Secret
+		want    HelmReleaseData
+		wantErr bool
+	}{
+		{
+			name:   "decodes a compressed release",
+			secret: suite.releaseSecret("prod", "web", 3, "deployed", "web-chart", "1.2.3", "2.0.0", "", true),
+			want:   HelmReleaseData{ReleaseName: "web", Revision: 3, Chart: "web-chart", ChartVersion: "1.2.3", AppVersion: "2.0.0"},
+		},
+		{
+			name:   "decodes an uncompressed release",
+			secret: suite.releaseSecret("prod", "db", 1, "deployed", "postgresql", "15.0.1", "", "", false),
+			want:   HelmReleaseData{ReleaseName: "db", Revision: 1, Chart: "postgresql", ChartVersion: "15.0.1"},
+		},
+		{
+			name: "fails for a secret that is not a Helm release",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "prod"},
+				Type:       corev1.SecretTypeOpaque,
+			},
+			wantErr: true,
+		},
+		{
+			name: "fails for a release that is not base64 encoded",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "prod"},
+				Type:       helmReleaseSecretType,
+				Data:       map[string][]byte{"release": []byte("not base64!")},
+			},
+			wantErr: true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			release, err := DecodeHelmReleaseSecret(t.secret)
+			require.False(suite.T(), (err != nil) != t.wantErr, "DecodeHelmReleaseSecret() error = %v, wantErr %v", err, t.wantErr)
+			if !t.wantErr {
+				require.Equal(suite.T(), t.want, release.HelmReleaseData)
+				require.Equal(suite.T(), t.secret.Namespace, release.Namespace)
+			}
+		})
+	}
+}
+
+func (suite *HelmTestSuite) TestAddHelmReleases() {
+	controller := true
+	webManifest := "---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"
+	dbManifest := "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: db\n---\napiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: db-postgresql\n"
+
+	clientset := &K8SConnection{fake.NewSimpleClientset(
+		suite.releaseSecret("prod", "web", 2, "deployed", "web-chart", "1.2.3", "2.0.0", webManifest, true),
+		suite.releaseSecret("prod", "web", 1, "superseded", "web-chart", "1.2.2", "1.9.0", webManifest, true),
+		suite.releaseSecret("prod", "db", 1, "deployed", "postgresql", "15.0.1", "16.1", dbManifest, true),
+		suite.releaseSecret("prod", "cache", 4, "deployed", "redis", "18.0.0", "7.2", "", true),
+		suite.releaseSecret("infra", "agent", 1, "deployed", "agent", "0.1.0", "0.1.0", "", true),
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "prod",
+				Annotations: map[string]string{
+					helmReleaseNameAnnotation:      "web",
+					helmReleaseNamespaceAnnotation: "prod",
+				},
+			},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "web-5d4f8",
+				Namespace:       "prod",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}},
+			},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-postgresql", Namespace: "prod"},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "agent",
+				Namespace: "kube-system",
+				Annotations: map[string]string{
+					helmReleaseNameAnnotation:      "agent",
+					helmReleaseNamespaceAnnotation: "infra",
+				},
+			},
+		},
+	)}
+
+	podsData := []*PodData{
+		{
+			PodName:   "web-5d4f8-abcde",
+			Namespace: "prod",
+			Owners:    []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d4f8", Controller: &controller}},
+		},
+		{
+			PodName:   "db-postgresql-0",
+			Namespace: "prod",
+			Owners:    []metav1.OwnerReference{{Kind: "StatefulSet", Name: "db-postgresql", Controller: &controller}},
+		},
+		{
+			PodName:   "cache-master",
+			Namespace: "prod",
+			labels:    map[string]string{"app.kubernetes.io/instance": "cache"},
+		},
+		{
+			PodName:   "agent-xyz",
+			Namespace: "kube-system",
+			Owners:    []metav1.OwnerReference{{Kind: "DaemonSet", Name: "agent", Controller: &controller}},
+		},
+		{
+			PodName:   "standalone",
+			Namespace: "prod",
+			labels:    map[string]string{"app": "standalone"},
+		},
+	}
+
+	err := clientset.AddHelmReleases(podsData, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+
+	require.Equal(suite.T(), &HelmReleaseData{ReleaseName: "web", Revision: 2, Chart: "web-chart", ChartVersion: "1.2.3", AppVersion: "2.0.0"}, podsData[0].HelmRelease)
+	require.Equal(suite.T(), &HelmReleaseData{ReleaseName: "db", Revision: 1, Chart: "postgresql", ChartVersion: "15.0.1", AppVersion: "16.1"}, podsData[1].HelmRelease)
+	require.Equal(suite.T(), &HelmReleaseData{ReleaseName: "cache", Revision: 4, Chart: "redis", ChartVersion: "18.0.0", AppVersion: "7.2"}, podsData[2].HelmRelease)
+	require.Equal(suite.T(), &HelmReleaseData{ReleaseName: "agent", Revision: 1, Chart: "agent", ChartVersion: "0.1.0", AppVersion: "0.1.0"}, podsData[3].HelmRelease)
+	require.Nil(suite.T(), podsData[4].HelmRelease)
+}
+
+func (suite *HelmTestSuite) TestGetHelmReleasesOnlyReturnsDeployedRevisions() {
+	clientset := &K8SConnection{fake.NewSimpleClientset(
+		suite.releaseSecret("prod", "web", 2, "deployed", "web-chart", "1.2.3", "", "", true),
+		suite.releaseSecret("prod", "web", 1, "superseded", "web-chart", "1.2.2", "", "", true),
+	)}
+	releases, err := clientset.GetHelmReleases("prod")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), releases, 1)
+	require.Equal(suite.T(), 2, releases[0].Revision)
+}
+
+// releaseSecret creates a secret the way Helm stores a release revision in it
+func (suite *HelmTestSuite) releaseSecret(namespace, name string, revision int, status, chart, chartVersion, appVersion, manifest string, compress bool) *corev1.Secret {
+	record := map[string]interface{}{
+		"name":      name,
+		"namespace": namespace,
+		"version":   revision,
+		"info":      map[string]interface{}{"status": status},
+		"manifest":  manifest,
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": chart, "version": chartVersion, "appVersion": appVersion},
+		},
+	}
+	content, err := json.Marshal(record)
+	require.NoError(suite.T(), err)
+	if compress {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err = writer.Write(content)
+		require.NoError(suite.T(), err)
+		require.NoError(suite.T(), writer.Close())
+		content = buf.Bytes()
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    name,
+				"status":  status,
+				"version": fmt.Sprintf("%d", revision),
+			},
+		},
+		Type: helmReleaseSecretType,
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(content))},
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestHelmTestSuite(t *testing.T) {
+	suite.Run(t, new(HelmTestSuite))
+}
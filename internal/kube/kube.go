@@ -27,10 +27,12 @@ type PodData struct {
 	Digests           map[string]string       `json:"digests"`
 	CreationTimestamp int64                   `json:"creationTimestamp"`
 	Owners            []metav1.OwnerReference `json:"owners"`
+	HelmRelease       *HelmReleaseData        `json:"helmRelease,omitempty"`
+	labels            map[string]string
 }
 
 type K8SConnection struct {
-	*kubernetes.Clientset
+	kubernetes.Interface
 }
 
 // NewPodData creates a PodData object from a k8s pod
@@ -41,6 +43,10 @@ func NewPodData(pod *corev1.Pod) *PodData {
 	owners := pod.GetObjectMeta().GetOwnerReferences()
 	containers := pod.Status.ContainerStatuses
 	for _, cs := range containers {
+		// containers that are not started yet have no image ID
+		if len(cs.ImageID) < 64 {
+			continue
+		}
 		digests[cs.Image] = cs.ImageID[len(cs.ImageID)-64:]
 	}
 
@@ -50,25 +56,16 @@ func NewPodData(pod *corev1.Pod) *PodData {
 		Digests:           digests,
 		CreationTimestamp: creationTimestamp.Unix(),
 		Owners:            owners,
+		labels:            pod.GetLabels(),
 	}
 }
 
 // NewK8sClientSet creates a k8s clientset
 // if the kubeconfigPath is empty, it attempts to get an in-cluster client
 func NewK8sClientSet(kubeconfigPath string) (*K8SConnection, error) {
-	var config *rest.Config
-	var err error
-	if kubeconfigPath != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-		if err != nil {
-			return nil, fmt.Errorf("could not build config from flags: %v ", err)
-		}
-	} else {
-		// creates the in-cluster config
-		config, err = rest.InClusterConfig()
-		if err != nil {
-			return nil, fmt.Errorf("could not build config from inside the cluster: %v ", err)
-		}
+	config, err := newRestConfig(kubeconfigPath)
+	if err != nil {
+		return nil, err
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
@@ -79,6 +76,24 @@ func NewK8sClientSet(kubeconfigPath string) (*K8SConnection, error) {
 	return &K8SConnection{clientset}, nil
 }
 
+// newRestConfig creates a k8s client config from a kubeconfig file
+// if the kubeconfigPath is empty, it attempts to get an in-cluster config
+func newRestConfig(kubeconfigPath string) (*rest.Config, error) {
+	if kubeconfigPath != "" {
+		config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not build config from flags: %v ", err)
+		}
+		return config, nil
+	}
+	// creates the in-cluster config
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not build config from inside the cluster: %v ", err)
+	}
+	return config, nil
+}
+
 // GetPodsData lists pods in the target namespace(s) of a target cluster and creates a list of
 // PodData objects for them
 func (clientset *K8SConnection) GetPodsData(filter *filters.ResourceFilterOptions, logger *logger.Logger) ([]*PodData, error) {
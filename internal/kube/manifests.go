@@ -0,0 +1,279 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	"github.com/kosli-dev/cli/internal/logger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// StdinManifestsPath is the manifests path used to read manifests from stdin
+const StdinManifestsPath = "-"
+
+// manifestExtensions are the extensions of the files read from manifests directories
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// workloadManifest is the subset of a workload resource (Deployment, ReplicaSet, StatefulSet,
+// DaemonSet, Job or CronJob) that is needed to create PodData for it
+type workloadManifest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		Template    corev1.PodTemplateSpec `json:"template"`
+		JobTemplate struct {
+			Spec struct {
+				Template corev1.PodTemplateSpec `json:"template"`
+			} `json:"spec"`
+		} `json:"jobTemplate"`
+	} `json:"spec"`
+}
+
+// GetPodsDataFromManifests creates a list of PodData objects from exported or rendered resources
+// instead of a live cluster. Each path is a YAML/JSON file, a directory of such files or
+// StdinManifestsPath to read from stdin. Files can contain multiple documents and lists
+// (e.g. the output of 'kubectl get pods -A -o json').
+//
+// Pods with a status are processed like live pods. Pods without a status and workloads
+// (e.g. from a rendered Helm chart or kustomization) are reported by their pod template,
+// using the digests their images are pinned to. Images that are not pinned to a digest
+// are skipped with a warning. Resources without a namespace are in the "default" namespace.
+func GetPodsDataFromManifests(paths []string, stdin io.Reader, filter *filters.ResourceFilterOptions, logger *logger.Logger) ([]*PodData, error) {
+	podsData := []*PodData{}
+	now := time.Now()
+	for _, path := range paths {
+		documents, err := readManifests(path, stdin)
+		if err != nil {
+			return podsData, err
+		}
+		for _, document := range documents {
+			objects, err := decodeManifests(document.content)
+			if err != nil {
+				return podsData, fmt.Errorf("failed to parse manifests in %s: %v", document.source, err)
+			}
+			for _, object := range objects {
+				data, err := podDataFromManifest(object, now, logger)
+				if err != nil {
+					return podsData, fmt.Errorf("failed to process manifests in %s: %v", document.source, err)
+				}
+				if data == nil {
+					continue
+				}
+				include, err := filter.ShouldInclude(data.Namespace)
+				if err != nil {
+					return podsData, err
+				}
+				if include {
+					podsData = append(podsData, data)
+				}
+			}
+		}
+	}
+	return podsData, nil
+}
+
+type manifestDocument struct {
+	source  string
+	content []byte
+}
+
+// readManifests reads the manifest files in a path
+func readManifests(path string, stdin io.Reader) ([]manifestDocument, error) {
+	if path == StdinManifestsPath {
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests from stdin: %v", err)
+		}
+		return []manifestDocument{{source: "stdin", content: content}}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifests path %s: %v", path, err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = []string{}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isManifestFile(p) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests directory %s: %v", path, err)
+		}
+	}
+
+	documents := []manifestDocument{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifests file %s: %v", file, err)
+		}
+		documents = append(documents, manifestDocument{source: file, content: content})
+	}
+	return documents, nil
+}
+
+func isManifestFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range manifestExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeManifests decodes the (multi-document) YAML or JSON content into JSON objects.
+// The items of lists are returned as separate objects.
+func decodeManifests(content []byte) ([]json.RawMessage, error) {
+	objects := []json.RawMessage{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		object := json.RawMessage{}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return objects, err
+		}
+		if len(object) == 0 || string(object) == "null" {
+			// empty document
+			continue
+		}
+		items, err := listItems(object)
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, items...)
+	}
+	return objects, nil
+}
+
+// listItems returns the items of a list object (recursively), or the object itself
+func listItems(object json.RawMessage) ([]json.RawMessage, error) {
+	list := struct {
+		Kind  string            `json:"kind"`
+		Items []json.RawMessage `json:"items"`
+	}{}
+	if err := json.Unmarshal(object, &list); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(list.Kind, "List") {
+		return []json.RawMessage{object}, nil
+	}
+	items := []json.RawMessage{}
+	for _, item := range list.Items {
+		itemObjects, err := listItems(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, itemObjects...)
+	}
+	return items, nil
+}
+
+// podDataFromManifest creates a PodData object from a pod or workload manifest.
+// It returns nil for other kinds of resources and for pods that would not be reported
+// from a live cluster.
+func podDataFromManifest(object json.RawMessage, now time.Time, logger *logger.Logger) (*PodData, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(object, &typeMeta); err != nil {
+		return nil, err
+	}
+
+	switch typeMeta.Kind {
+	case "Pod":
+		pod := &corev1.Pod{}
+		if err := json.Unmarshal(object, pod); err != nil {
+			return nil, err
+		}
+		if len(pod.Status.ContainerStatuses) > 0 {
+			// an exported pod, only report running or failed pods as for live pods
+			if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodFailed {
+				return nil, nil
+			}
+			if pod.Namespace == "" {
+				pod.Namespace = metav1.NamespaceDefault
+			}
+			return NewPodData(pod), nil
+		}
+		return podDataFromTemplate(typeMeta.Kind, pod.ObjectMeta, pod.Spec, pod.Labels, now, logger), nil
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet", "Job":
+		workload := &workloadManifest{}
+		if err := json.Unmarshal(object, workload); err != nil {
+			return nil, err
+		}
+		template := workload.Spec.Template
+		return podDataFromTemplate(typeMeta.Kind, workload.ObjectMeta, template.Spec, template.Labels, now, logger), nil
+	case "CronJob":
+		workload := &workloadManifest{}
+		if err := json.Unmarshal(object, workload); err != nil {
+			return nil, err
+		}
+		template := workload.Spec.JobTemplate.Spec.Template
+		return podDataFromTemplate(typeMeta.Kind, workload.ObjectMeta, template.Spec, template.Labels, now, logger), nil
+	}
+	return nil, nil
+}
+
+// podDataFromTemplate creates a PodData object from the pod spec of a resource, using the
+// digests its images are pinned to. It returns nil when none of the images is pinned.
+func podDataFromTemplate(kind string, meta metav1.ObjectMeta, spec corev1.PodSpec, labels map[string]string, now time.Time, logger *logger.Logger) *PodData {
+	namespace := meta.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	digests := make(map[string]string)
+	for _, container := range spec.Containers {
+		digest, ok := imageDigest(container.Image)
+		if !ok {
+			logger.Warning("image %s of %s %s/%s is not pinned to a digest and is not reported", container.Image, kind, namespace, meta.Name)
+			continue
+		}
+		digests[container.Image] = digest
+	}
+	if len(digests) == 0 {
+		return nil
+	}
+
+	creationTimestamp := meta.CreationTimestamp.Unix()
+	if meta.CreationTimestamp.IsZero() {
+		creationTimestamp = now.Unix()
+	}
+	return &PodData{
+		PodName:           meta.Name,
+		Namespace:         namespace,
+		Digests:           digests,
+		CreationTimestamp: creationTimestamp,
+		Owners:            meta.OwnerReferences,
+		labels:            labels,
+	}
+}
+
+// imageDigest returns the sha256 digest an image reference is pinned to (e.g. nginx@sha256:...)
+func imageDigest(image string) (string, bool) {
+	_, digest, found := strings.Cut(image, "@sha256:")
+	if !found || len(digest) != 64 {
+		return "", false
+	}
+	return digest, true
+}
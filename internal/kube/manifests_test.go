@@ -0,0 +1,161 @@
+package kube
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type ManifestsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ManifestsTestSuite) TestGetPodsDataFromManifests() {
+	type comparablePodData struct {
+		podName   string
+		namespace string
+		digests   map[string]string
+	}
+	exportedPods := []comparablePodData{
+		{
+			podName:   "coredns-7db6d8ff4d-x2x9z",
+			namespace: "kube-system",
+			digests:   map[string]string{"registry.k8s.io/coredns/coredns:v1.11.1": strings.Repeat("c", 64)},
+		},
+		{
+			podName:   "web-5d4f8-abcde",
+			namespace: "prod",
+			digests: map[string]string{
+				"registry.example.com/web:1.0.0": strings.Repeat("a", 64),
+				"envoyproxy/envoy:v1.30.1":       strings.Repeat("b", 64),
+			},
+		},
+	}
+	renderedWorkloads := []comparablePodData{
+		{
+			podName:   "cleanup",
+			namespace: "prod",
+			digests:   map[string]string{"registry.example.com/cleanup:2.1@sha256:" + strings.Repeat("d", 64): strings.Repeat("d", 64)},
+		},
+		{
+			podName:   "db",
+			namespace: "default",
+			digests:   map[string]string{"postgres@sha256:" + strings.Repeat("e", 64): strings.Repeat("e", 64)},
+		},
+		{
+			podName:   "web",
+			namespace: "prod",
+			digests:   map[string]string{"registry.example.com/web@sha256:" + strings.Repeat("a", 64): strings.Repeat("a", 64)},
+		},
+	}
+
+	for _, t := range []struct {
+		name    string
+		paths   []string
+		stdin   string
+		filter  *filters.ResourceFilterOptions
+		want    []comparablePodData
+		wantErr bool
+	}{
+		{
+			name:   "exported pods list only includes running pods",
+			paths:  []string{"testdata/manifests/pods.json"},
+			filter: &filters.ResourceFilterOptions{},
+			want:   exportedPods,
+		},
+		{
+			name:   "rendered workloads in a directory are reported by their pinned images",
+			paths:  []string{"testdata/manifests/rendered"},
+			filter: &filters.ResourceFilterOptions{},
+			want:   renderedWorkloads,
+		},
+		{
+			name:   "multiple paths are combined",
+			paths:  []string{"testdata/manifests/pods.json", "testdata/manifests/rendered"},
+			filter: &filters.ResourceFilterOptions{},
+			want:   append(append([]comparablePodData{}, exportedPods...), renderedWorkloads...),
+		},
+		{
+			name:   "namespaces are filtered",
+			paths:  []string{"testdata/manifests/pods.json", "testdata/manifests/rendered"},
+			filter: &filters.ResourceFilterOptions{ExcludeNames: []string{"prod"}},
+			want:   []comparablePodData{exportedPods[0], renderedWorkloads[1]},
+		},
+		{
+			name:   "manifests can be read from stdin",
+			paths:  []string{StdinManifestsPath},
+			stdin:  "apiVersion: apps/v1\nkind: DaemonSet\nmetadata:\n  name: agent\n  namespace: infra\nspec:\n  template:\n    spec:\n      containers:\n        - name: agent\n          image: agent@sha256:" + strings.Repeat("f", 64) + "\n",
+			filter: &filters.ResourceFilterOptions{},
+			want: []comparablePodData{
+				{
+					podName:   "agent",
+					namespace: "infra",
+					digests:   map[string]string{"agent@sha256:" + strings.Repeat("f", 64): strings.Repeat("f", 64)},
+				},
+			},
+		},
+		{
+			name:    "fails when a path does not exist",
+			paths:   []string{"testdata/manifests/non-existing.yaml"},
+			filter:  &filters.ResourceFilterOptions{},
+			wantErr: true,
+		},
+		{
+			name:    "fails when a manifest is not valid",
+			paths:   []string{StdinManifestsPath},
+			stdin:   "kind: Pod\n  metadata: [",
+			filter:  &filters.ResourceFilterOptions{},
+			wantErr: true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			podsData, err := GetPodsDataFromManifests(t.paths, strings.NewReader(t.stdin), t.filter, logger.NewStandardLogger())
+			require.False(suite.T(), (err != nil) != t.wantErr, "GetPodsDataFromManifests() error = %v, wantErr %v", err, t.wantErr)
+			if t.wantErr {
+				return
+			}
+			actual := []comparablePodData{}
+			for _, data := range podsData {
+				require.NotZero(suite.T(), data.CreationTimestamp)
+				actual = append(actual, comparablePodData{podName: data.PodName, namespace: data.Namespace, digests: data.Digests})
+			}
+			sort.SliceStable(actual, func(i, j int) bool { return actual[i].podName < actual[j].podName })
+			want := append([]comparablePodData{}, t.want...)
+			sort.SliceStable(want, func(i, j int) bool { return want[i].podName < want[j].podName })
+			require.Equal(suite.T(), want, actual)
+		})
+	}
+}
+
+func (suite *ManifestsTestSuite) TestExportedPodKeepsOwnersAndTimestamp() {
+	podsData, err := GetPodsDataFromManifests([]string{"testdata/manifests/pods.json"}, nil, &filters.ResourceFilterOptions{IncludeNames: []string{"prod"}}, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), podsData, 1)
+	require.Equal(suite.T(), int64(1714557600), podsData[0].CreationTimestamp)
+	require.Len(suite.T(), podsData[0].Owners, 1)
+	require.Equal(suite.T(), "web-5d4f8", podsData[0].Owners[0].Name)
+}
+
+func (suite *ManifestsTestSuite) TestImageDigest() {
+	digest, ok := imageDigest("nginx:1.25@sha256:" + strings.Repeat("a", 64))
+	require.True(suite.T(), ok)
+	require.Equal(suite.T(), strings.Repeat("a", 64), digest)
+	_, ok = imageDigest("nginx:1.25")
+	require.False(suite.T(), ok)
+	_, ok = imageDigest("nginx@sha256:abc")
+	require.False(suite.T(), ok)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestManifestsTestSuite(t *testing.T) {
+	suite.Run(t, new(ManifestsTestSuite))
+}
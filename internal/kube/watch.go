@@ -0,0 +1,135 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podsInformerResync is how often the informers replay their cache. Resync events
+// do not change pods, so they are ignored.
+const podsInformerResync = 10 * time.Minute
+
+// PodWatcher watches the pods which would be reported for a filter with informers,
+// and keeps them in the informers cache to take snapshots without listing them again.
+type PodWatcher struct {
+	clientset *K8SConnection
+	filter    *filters.ResourceFilterOptions
+	listers   []corelisters.PodLister
+}
+
+// NewPodWatcher creates a PodWatcher for the pods included by a filter
+func (clientset *K8SConnection) NewPodWatcher(filter *filters.ResourceFilterOptions) *PodWatcher {
+	return &PodWatcher{clientset: clientset, filter: filter}
+}
+
+// Watch starts the informers and calls onChange whenever a pod that would be reported is added,
+// deleted or changes its phase or images, until the context is cancelled. It returns once the
+// informers cache is synced.
+// When the filter only includes namespaces by name, the informers are namespace scoped
+// so that cluster-wide permissions are not needed.
+func (w *PodWatcher) Watch(ctx context.Context, onChange func()) error {
+	filter := w.filter
+	namespaces := []string{corev1.NamespaceAll}
+	if len(filter.IncludeNames) > 0 && len(filter.IncludeNamesRegex) == 0 &&
+		len(filter.ExcludeNames) == 0 && len(filter.ExcludeNamesRegex) == 0 {
+		namespaces = filter.IncludeNames
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok && watchedPod(pod, filter) {
+				onChange()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			newPod, ok := newObj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			if (watchedPod(oldPod, filter) || watchedPod(newPod, filter)) && podChanged(oldPod, newPod) {
+				onChange()
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok && watchedPod(pod, filter) {
+				onChange()
+			}
+		},
+	}
+
+	listers := []corelisters.PodLister{}
+	for _, namespace := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(w.clientset, podsInformerResync, informers.WithNamespace(namespace))
+		pods := factory.Core().V1().Pods()
+		informer := pods.Informer()
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return fmt.Errorf("could not watch pods: %v ", err)
+		}
+		factory.Start(ctx.Done())
+		for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+			if !synced {
+				return fmt.Errorf("could not sync the %v informer for namespace %q", informerType, namespace)
+			}
+		}
+		listers = append(listers, pods.Lister())
+	}
+	w.listers = listers
+	return nil
+}
+
+// GetPodsData creates a list of PodData objects for the watched pods, from the informers cache
+func (w *PodWatcher) GetPodsData() ([]*PodData, error) {
+	if w.listers == nil {
+		return nil, fmt.Errorf("could not get pods from the cache: the pods are not watched")
+	}
+	list := &corev1.PodList{}
+	for _, lister := range w.listers {
+		pods, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, fmt.Errorf("could not get pods from the cache: %v ", err)
+		}
+		for _, pod := range pods {
+			include, err := w.filter.ShouldInclude(pod.Namespace)
+			if err != nil {
+				return nil, fmt.Errorf("could not filter namespaces: %v ", err)
+			}
+			if include {
+				list.Items = append(list.Items, *pod)
+			}
+		}
+	}
+	return processPods(list), nil
+}
+
+// watchedPod checks if a pod would be included in a snapshot
+func watchedPod(pod *corev1.Pod, filter *filters.ResourceFilterOptions) bool {
+	if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodFailed {
+		return false
+	}
+	include, err := filter.ShouldInclude(pod.Namespace)
+	return err == nil && include
+}
+
+// podChanged checks if a pod update changes what would be reported for it
+func podChanged(oldPod, newPod *corev1.Pod) bool {
+	if oldPod.Status.Phase != newPod.Status.Phase {
+		return true
+	}
+	return !maps.Equal(NewPodData(oldPod).Digests, NewPodData(newPod).Digests)
+}
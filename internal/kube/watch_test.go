@@ -0,0 +1,134 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/filters"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type WatchTestSuite struct {
+	suite.Suite
+}
+
+func (suite *WatchTestSuite) TestPodWatcherWatch() {
+	clientset := &K8SConnection{fake.NewSimpleClientset()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	err := clientset.NewPodWatcher(&filters.ResourceFilterOptions{IncludeNames: []string{"prod"}}).Watch(ctx, func() {
+		changes <- struct{}{}
+	})
+	require.NoError(suite.T(), err)
+
+	// a pending pod is not reported, so it is not a change
+	pod := watchTestPod("prod", corev1.PodPending, "")
+	pod, err = clientset.CoreV1().Pods("prod").Create(ctx, pod, metav1.CreateOptions{})
+	require.NoError(suite.T(), err)
+	suite.requireNoChange(changes)
+
+	// a pod in another namespace is not watched
+	_, err = clientset.CoreV1().Pods("dev").Create(ctx, watchTestPod("dev", corev1.PodRunning, strings.Repeat("a", 64)), metav1.CreateOptions{})
+	require.NoError(suite.T(), err)
+	suite.requireNoChange(changes)
+
+	// the pod starts running
+	pod.Status = watchTestPod("prod", corev1.PodRunning, strings.Repeat("a", 64)).Status
+	pod, err = clientset.CoreV1().Pods("prod").UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+	require.NoError(suite.T(), err)
+	suite.requireChange(changes)
+
+	// label changes do not change the snapshot
+	pod.Labels = map[string]string{"foo": "bar"}
+	_, err = clientset.CoreV1().Pods("prod").Update(ctx, pod, metav1.UpdateOptions{})
+	require.NoError(suite.T(), err)
+	suite.requireNoChange(changes)
+
+	err = clientset.CoreV1().Pods("prod").Delete(ctx, pod.Name, metav1.DeleteOptions{})
+	require.NoError(suite.T(), err)
+	suite.requireChange(changes)
+}
+
+func (suite *WatchTestSuite) TestPodWatcherGetPodsData() {
+	fakeClientset := fake.NewSimpleClientset()
+	clientset := &K8SConnection{fakeClientset}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pods := clientset.NewPodWatcher(&filters.ResourceFilterOptions{ExcludeNames: []string{"dev"}})
+	_, err := pods.GetPodsData()
+	require.EqualError(suite.T(), err, "could not get pods from the cache: the pods are not watched")
+
+	running := watchTestPod("prod", corev1.PodRunning, strings.Repeat("a", 64))
+	_, err = clientset.CoreV1().Pods("prod").Create(ctx, running, metav1.CreateOptions{})
+	require.NoError(suite.T(), err)
+	pending := watchTestPod("prod", corev1.PodPending, "")
+	pending.Name = "pending"
+	_, err = clientset.CoreV1().Pods("prod").Create(ctx, pending, metav1.CreateOptions{})
+	require.NoError(suite.T(), err)
+	_, err = clientset.CoreV1().Pods("dev").Create(ctx, watchTestPod("dev", corev1.PodRunning, strings.Repeat("b", 64)), metav1.CreateOptions{})
+	require.NoError(suite.T(), err)
+
+	err = pods.Watch(ctx, func() {})
+	require.NoError(suite.T(), err)
+	actions := len(fakeClientset.Actions())
+
+	// only the running pods in the included namespaces are reported, without calling the API again
+	podsData, err := pods.GetPodsData()
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), podsData, 1)
+	require.Equal(suite.T(), "prod", podsData[0].Namespace)
+	require.Equal(suite.T(), map[string]string{"nginx:1.25": strings.Repeat("a", 64)}, podsData[0].Digests)
+	require.Len(suite.T(), fakeClientset.Actions(), actions)
+}
+
+func (suite *WatchTestSuite) requireChange(changes chan struct{}) {
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		suite.T().Fatal("expected a change")
+	}
+}
+
+func (suite *WatchTestSuite) requireNoChange(changes chan struct{}) {
+	select {
+	case <-changes:
+		suite.T().Fatal("expected no change")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// watchTestPod creates a pod with one container in the given phase
+func watchTestPod(namespace string, phase corev1.PodPhase, digest string) *corev1.Pod {
+	imageID := ""
+	if digest != "" {
+		imageID = "docker.io/library/nginx@sha256:" + digest
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "web", Image: "nginx:1.25"}},
+		},
+		Status: corev1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "web", Image: "nginx:1.25", ImageID: imageID}},
+		},
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestWatchTestSuite(t *testing.T) {
+	suite.Run(t, new(WatchTestSuite))
+}
@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/itchyny/gojq"
+	"github.com/spf13/viper"
+)
+
+// Policy is a set of rules evaluated locally against the JSON data returned by Kosli
+type Policy struct {
+	Version int     `mapstructure:"version" validate:"required,oneof=1"`
+	Rules   []*Rule `mapstructure:"rules" validate:"required,min=1,dive"`
+}
+
+// Rule is a jq expression that must evaluate to true.
+// When ForEach is set, it is a jq expression selecting the items (e.g. attestations)
+// the rule is evaluated against, one item at a time.
+type Rule struct {
+	Name    string `mapstructure:"name"`
+	ForEach string `mapstructure:"for_each"`
+	JQ      string `mapstructure:"jq" validate:"required"`
+
+	query   *gojq.Code
+	forEach *gojq.Code
+}
+
+// Violation describes a rule that did not pass
+type Violation struct {
+	Rule    string
+	Subject string
+	Reason  string
+}
+
+func (v *Violation) String() string {
+	if v.Subject != "" {
+		return fmt.Sprintf("rule [%s] failed for %s: %s", v.Rule, v.Subject, v.Reason)
+	}
+	return fmt.Sprintf("rule [%s] failed: %s", v.Rule, v.Reason)
+}
+
+// LoadPolicyFile loads and compiles a policy from a YAML, JSON or TOML file
+func LoadPolicyFile(policyFile string) (*Policy, error) {
+	var p *Policy
+	v := viper.New()
+	v.SetConfigFile(policyFile)
+
+	if err := v.ReadInConfig(); err != nil {
+		return p, fmt.Errorf("failed to parse policy file [%s] : %v", policyFile, err)
+	}
+
+	if err := v.UnmarshalExact(&p); err != nil {
+		return p, fmt.Errorf("failed to unmarshal policy file [%s] : %v", policyFile, err)
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(p); err != nil {
+		return p, fmt.Errorf("policy file [%s] is invalid: %v", policyFile, err)
+	}
+
+	if err := p.compile(); err != nil {
+		return p, fmt.Errorf("policy file [%s] is invalid: %v", policyFile, err)
+	}
+	return p, nil
+}
+
+// NewPolicyFromRules creates a policy from a list of jq rules.
+// Each rule is named after its expression.
+func NewPolicyFromRules(rules []string) (*Policy, error) {
+	p := &Policy{Version: 1}
+	for _, rule := range rules {
+		p.Rules = append(p.Rules, &Rule{JQ: rule})
+	}
+	return p, p.compile()
+}
+
+// Merge returns a policy containing the rules of both policies.
+// Either policy can be nil.
+func Merge(a, b *Policy) *Policy {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &Policy{Version: a.Version, Rules: append(append([]*Rule{}, a.Rules...), b.Rules...)}
+}
+
+func (p *Policy) compile() error {
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			rule.Name = rule.JQ
+		}
+		query, err := compileJQ(rule.JQ)
+		if err != nil {
+			return fmt.Errorf("rule %d (%s) has an invalid jq expression: %v", i+1, rule.Name, err)
+		}
+		rule.query = query
+		if rule.ForEach != "" {
+			forEach, err := compileJQ(rule.ForEach)
+			if err != nil {
+				return fmt.Errorf("rule %d (%s) has an invalid for_each jq expression: %v", i+1, rule.Name, err)
+			}
+			rule.forEach = forEach
+		}
+	}
+	return nil
+}
+
+func compileJQ(expression string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query)
+}
+
+// Evaluate evaluates all the policy rules against data, which must be the
+// result of unmarshalling JSON into an interface{}.
+// It returns the list of violations, which is empty when all rules pass.
+func (p *Policy) Evaluate(data interface{}) []*Violation {
+	violations := []*Violation{}
+	for _, rule := range p.Rules {
+		if rule.forEach == nil {
+			if reason := evaluateRule(rule.query, data); reason != "" {
+				violations = append(violations, &Violation{Rule: rule.Name, Reason: reason})
+			}
+			continue
+		}
+
+		items, err := runJQ(rule.forEach, data)
+		if err != nil {
+			violations = append(violations, &Violation{Rule: rule.Name, Reason: fmt.Sprintf("for_each failed: %v", err)})
+			continue
+		}
+		for i, item := range items {
+			if reason := evaluateRule(rule.query, item); reason != "" {
+				violations = append(violations, &Violation{Rule: rule.Name, Subject: subjectName(item, i), Reason: reason})
+			}
+		}
+	}
+	return violations
+}
+
+// evaluateRule returns the reason why a rule does not pass for data,
+// or an empty string if it passes. A rule passes if all its results are true.
+func evaluateRule(query *gojq.Code, data interface{}) string {
+	results, err := runJQ(query, data)
+	if err != nil {
+		return err.Error()
+	}
+	if len(results) == 0 {
+		return "the rule returned no result"
+	}
+	for _, result := range results {
+		if result != true {
+			return fmt.Sprintf("the rule returned %s", gojq.Preview(result))
+		}
+	}
+	return ""
+}
+
+func runJQ(query *gojq.Code, data interface{}) ([]interface{}, error) {
+	results := []interface{}{}
+	iter := query.Run(data)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			return results, err
+		}
+		results = append(results, v)
+	}
+	return results, nil
+}
+
+// subjectName returns a human readable name for an item selected by for_each
+func subjectName(item interface{}, index int) string {
+	if m, ok := item.(map[string]interface{}); ok {
+		for _, key := range []string{"attestation_name", "name", "filename", "fingerprint"} {
+			if name, ok := m[key].(string); ok && name != "" {
+				if key == "attestation_name" {
+					return fmt.Sprintf("attestation [%s]", name)
+				}
+				return fmt.Sprintf("[%s]", name)
+			}
+		}
+	}
+	return fmt.Sprintf("item #%d", index+1)
+}
+
+// FormatViolations returns a multi-line description of the violations
+func FormatViolations(violations []*Violation) string {
+	lines := []string{}
+	for _, v := range violations {
+		lines = append(lines, "  - "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const artifactJSON = `{
+	"fingerprint": "fcf33337634c2577a5d86fd7ecb0a25a7c1bb5d89c14fd236f546a5759252c02",
+	"state": "COMPLIANT",
+	"attestations_statuses": [
+		{"attestation_name": "unit-tests", "attestation_type": "junit", "attestation_data": {"failures": 0}},
+		{"attestation_name": "integration-tests", "attestation_type": "junit", "attestation_data": {"failures": 2}},
+		{"attestation_name": "snyk-scan", "attestation_type": "snyk", "attestation_data": {"high_count": 0}}
+	]
+}`
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type PolicyTestSuite struct {
+	suite.Suite
+	data interface{}
+}
+
+func (suite *PolicyTestSuite) SetupSuite() {
+	err := json.Unmarshal([]byte(artifactJSON), &suite.data)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *PolicyTestSuite) writePolicyFile(content string) string {
+	path := filepath.Join(suite.T().TempDir(), "policy.yaml")
+	err := os.WriteFile(path, []byte(content), 0600)
+	require.NoError(suite.T(), err)
+	return path
+}
+
+func (suite *PolicyTestSuite) TestEvaluateRules() {
+	for _, t := range []struct {
+		name               string
+		rules              []string
+		wantErr            bool
+		expectedViolations []string
+	}{
+		{
+			name:  "passing rules have no violations",
+			rules: []string{`.state == "COMPLIANT"`, `[.attestations_statuses[] | select(.attestation_type == "snyk")] | length == 1`},
+		},
+		{
+			name:               "failing rule is reported with its expression",
+			rules:              []string{`.state == "NON-COMPLIANT"`},
+			expectedViolations: []string{`rule [.state == "NON-COMPLIANT"] failed: the rule returned false`},
+		},
+		{
+			name:               "rule with a non boolean result fails",
+			rules:              []string{`.state`},
+			expectedViolations: []string{`rule [.state] failed: the rule returned "COMPLIANT"`},
+		},
+		{
+			name:               "rule with no result fails",
+			rules:              []string{`empty`},
+			expectedViolations: []string{`rule [empty] failed: the rule returned no result`},
+		},
+		{
+			name:               "rule with a runtime error fails",
+			rules:              []string{`.state | keys`},
+			expectedViolations: []string{`rule [.state | keys] failed: keys cannot be applied to: string ("COMPLIANT")`},
+		},
+		{
+			name:    "invalid rule returns an error",
+			rules:   []string{`.state ==`},
+			wantErr: true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			p, err := NewPolicyFromRules(t.rules)
+			require.False(suite.T(), (err != nil) != t.wantErr, "NewPolicyFromRules() error = %v, wantErr %v", err, t.wantErr)
+			if t.wantErr {
+				return
+			}
+			violations := []string{}
+			for _, v := range p.Evaluate(suite.data) {
+				violations = append(violations, v.String())
+			}
+			if t.expectedViolations == nil {
+				t.expectedViolations = []string{}
+			}
+			require.Equal(suite.T(), t.expectedViolations, violations)
+		})
+	}
+}
+
+func (suite *PolicyTestSuite) TestLoadPolicyFile() {
+	for _, t := range []struct {
+		name               string
+		content            string
+		wantErr            bool
+		expectedViolations []string
+	}{
+		{
+			name: "rules with for_each report the failing attestation",
+			content: `version: 1
+rules:
+  - name: no-junit-failures
+    for_each: '.attestations_statuses[] | select(.attestation_type == "junit")'
+    jq: '.attestation_data.failures == 0'
+  - name: no-high-snyk-vulnerabilities
+    for_each: '.attestations_statuses[] | select(.attestation_type == "snyk")'
+    jq: '.attestation_data.high_count == 0'
+`,
+			expectedViolations: []string{"rule [no-junit-failures] failed for attestation [integration-tests]: the rule returned false"},
+		},
+		{
+			name: "rule without a name is named after its expression",
+			content: `version: 1
+rules:
+  - jq: '.attestations_statuses | length > 3'
+`,
+			expectedViolations: []string{"rule [.attestations_statuses | length > 3] failed: the rule returned false"},
+		},
+		{
+			name: "missing version is invalid",
+			content: `rules:
+  - jq: 'true'
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown rule field is invalid",
+			content: `version: 1
+rules:
+  - rego: 'true'
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid for_each expression is invalid",
+			content: `version: 1
+rules:
+  - for_each: '.attestations_statuses[] |'
+    jq: 'true'
+`,
+			wantErr: true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			p, err := LoadPolicyFile(suite.writePolicyFile(t.content))
+			require.False(suite.T(), (err != nil) != t.wantErr, "LoadPolicyFile() error = %v, wantErr %v", err, t.wantErr)
+			if t.wantErr {
+				return
+			}
+			violations := []string{}
+			for _, v := range p.Evaluate(suite.data) {
+				violations = append(violations, v.String())
+			}
+			if t.expectedViolations == nil {
+				t.expectedViolations = []string{}
+			}
+			require.Equal(suite.T(), t.expectedViolations, violations)
+		})
+	}
+}
+
+func (suite *PolicyTestSuite) TestMerge() {
+	a, err := NewPolicyFromRules([]string{"true"})
+	require.NoError(suite.T(), err)
+	b, err := NewPolicyFromRules([]string{"false"})
+	require.NoError(suite.T(), err)
+
+	require.Nil(suite.T(), Merge(nil, nil))
+	require.Equal(suite.T(), a, Merge(a, nil))
+	require.Equal(suite.T(), b, Merge(nil, b))
+	require.Len(suite.T(), Merge(a, b).Rules, 2)
+	require.Len(suite.T(), a.Rules, 1)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(PolicyTestSuite))
+}
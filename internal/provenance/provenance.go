@@ -0,0 +1,257 @@
+package provenance
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Predicate types of SLSA provenance
+const (
+	PredicateSLSAv1   = "https://slsa.dev/provenance/v1"
+	PredicateSLSAv0_2 = "https://slsa.dev/provenance/v0.2"
+)
+
+// dssePayloadType is the DSSE payload type of in-toto statements
+const dssePayloadType = "application/vnd.in-toto+json"
+
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Material is an input of a build (a SLSA v0.2 material or a SLSA v1 resolved dependency)
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type ProvenanceData struct {
+	SchemaVersion int        `json:"schema_version"`
+	StatementType string     `json:"statement_type"`
+	PredicateType string     `json:"predicate_type"`
+	Subjects      []Subject  `json:"subjects"`
+	BuilderID     string     `json:"builder_id"`
+	BuildType     string     `json:"build_type"`
+	InvocationID  string     `json:"invocation_id,omitempty"`
+	StartedOn     string     `json:"started_on,omitempty"`
+	FinishedOn    string     `json:"finished_on,omitempty"`
+	SourceRepo    string     `json:"source_repo,omitempty"`
+	SourceCommit  string     `json:"source_commit,omitempty"`
+	Materials     []Material `json:"materials"`
+}
+
+// statement is an in-toto statement, with the fields of SLSA v1 and v0.2 predicates used in ProvenanceData
+type statement struct {
+	Type          string    `json:"_type"`
+	PredicateType string    `json:"predicateType"`
+	Subject       []Subject `json:"subject"`
+	Predicate     struct {
+		// SLSA v1
+		BuildDefinition struct {
+			BuildType          string `json:"buildType"`
+			ExternalParameters struct {
+				Workflow struct {
+					Repository string `json:"repository"`
+					Ref        string `json:"ref"`
+				} `json:"workflow"`
+			} `json:"externalParameters"`
+			ResolvedDependencies []Material `json:"resolvedDependencies"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+			Metadata struct {
+				InvocationID string `json:"invocationId"`
+				StartedOn    string `json:"startedOn"`
+				FinishedOn   string `json:"finishedOn"`
+			} `json:"metadata"`
+		} `json:"runDetails"`
+		// SLSA v0.2
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		BuildType  string `json:"buildType"`
+		Invocation struct {
+			ConfigSource Material `json:"configSource"`
+		} `json:"invocation"`
+		Metadata struct {
+			BuildInvocationID string `json:"buildInvocationId"`
+			BuildStartedOn    string `json:"buildStartedOn"`
+			BuildFinishedOn   string `json:"buildFinishedOn"`
+		} `json:"metadata"`
+		Materials []Material `json:"materials"`
+	} `json:"predicate"`
+}
+
+// document is a JSON document of a provenance file: an in-toto statement, a DSSE envelope
+// or a sigstore bundle with a DSSE envelope
+type document struct {
+	statement
+	PayloadType  string        `json:"payloadType"`
+	Payload      string        `json:"payload"`
+	DsseEnvelope *dsseEnvelope `json:"dsseEnvelope"`
+}
+
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+}
+
+// ProcessProvenanceFile reads the SLSA provenance of an artifact from a file, which can hold
+// in-toto statements, DSSE envelopes (e.g. .intoto.jsonl files) or sigstore bundles,
+// one or more per file. It fails if no SLSA provenance statement has the artifact fingerprint in its subjects.
+func ProcessProvenanceFile(path, fingerprint string) (*ProvenanceData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	provenanceFound := false
+	decoder := json.NewDecoder(file)
+	for {
+		var doc document
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse provenance file [%s]: %v", path, err)
+		}
+		s, err := doc.inTotoStatement()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse provenance file [%s]: %v", path, err)
+		}
+		if s.PredicateType != PredicateSLSAv1 && s.PredicateType != PredicateSLSAv0_2 {
+			continue
+		}
+		provenanceFound = true
+		if s.hasSubject(fingerprint) {
+			return s.provenanceData(), nil
+		}
+	}
+	if !provenanceFound {
+		return nil, fmt.Errorf("no SLSA provenance statement found in provenance file [%s]. Supported predicate types are: %s, %s", path, PredicateSLSAv1, PredicateSLSAv0_2)
+	}
+	return nil, fmt.Errorf("the subjects of the provenance in file [%s] do not include the artifact fingerprint %s", path, fingerprint)
+}
+
+// inTotoStatement returns the statement of a document, decoding the payload of DSSE envelopes
+func (doc *document) inTotoStatement() (*statement, error) {
+	envelope := doc.DsseEnvelope
+	if envelope == nil && doc.Payload != "" {
+		envelope = &dsseEnvelope{PayloadType: doc.PayloadType, Payload: doc.Payload}
+	}
+	if envelope == nil {
+		if doc.Type == "" {
+			return nil, errors.New("not an in-toto statement, a DSSE envelope or a sigstore bundle")
+		}
+		return &doc.statement, nil
+	}
+	if envelope.PayloadType != dssePayloadType {
+		return nil, fmt.Errorf("unsupported DSSE payload type %s", envelope.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSSE payload: %v", err)
+	}
+	var s statement
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, fmt.Errorf("invalid in-toto statement: %v", err)
+	}
+	return &s, nil
+}
+
+func (s *statement) hasSubject(fingerprint string) bool {
+	for _, subject := range s.Subject {
+		if subject.Digest["sha256"] == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// provenanceData extracts the builder, the build and its source and materials from a SLSA provenance statement
+func (s *statement) provenanceData() *ProvenanceData {
+	p := s.Predicate
+	data := &ProvenanceData{
+		SchemaVersion: 1,
+		StatementType: s.Type,
+		PredicateType: s.PredicateType,
+		Subjects:      s.Subject,
+	}
+	if s.PredicateType == PredicateSLSAv1 {
+		data.BuilderID = p.RunDetails.Builder.ID
+		data.BuildType = p.BuildDefinition.BuildType
+		data.InvocationID = p.RunDetails.Metadata.InvocationID
+		data.StartedOn = p.RunDetails.Metadata.StartedOn
+		data.FinishedOn = p.RunDetails.Metadata.FinishedOn
+		data.Materials = p.BuildDefinition.ResolvedDependencies
+		data.SourceRepo, data.SourceCommit = sourceFromMaterials(data.Materials)
+		// GitHub Actions builds have the repository of the workflow in their external parameters
+		if repository := p.BuildDefinition.ExternalParameters.Workflow.Repository; repository != "" {
+			data.SourceRepo = repository
+		}
+	} else {
+		data.BuilderID = p.Builder.ID
+		data.BuildType = p.BuildType
+		data.InvocationID = p.Metadata.BuildInvocationID
+		data.StartedOn = p.Metadata.BuildStartedOn
+		data.FinishedOn = p.Metadata.BuildFinishedOn
+		data.Materials = p.Materials
+		if p.Invocation.ConfigSource.URI != "" {
+			data.SourceRepo, data.SourceCommit = gitSource(p.Invocation.ConfigSource)
+		} else {
+			data.SourceRepo, data.SourceCommit = sourceFromMaterials(data.Materials)
+		}
+	}
+	if data.Materials == nil {
+		data.Materials = []Material{}
+	}
+	return data
+}
+
+// sourceFromMaterials returns the repository and commit of the first git material
+func sourceFromMaterials(materials []Material) (string, string) {
+	for _, material := range materials {
+		if strings.HasPrefix(material.URI, "git+") {
+			return gitSource(material)
+		}
+	}
+	return "", ""
+}
+
+// gitSource returns the repository and commit of a git material,
+// e.g. git+https://github.com/org/repo@refs/heads/main with a sha1 or gitCommit digest
+func gitSource(material Material) (string, string) {
+	repo := strings.TrimPrefix(material.URI, "git+")
+	// the ref follows an @ in the path, while an @ in the host is for the user (e.g. ssh://git@github.com/org/repo)
+	_, afterScheme, _ := strings.Cut(repo, "://")
+	pathStart := len(repo) - len(afterScheme) + strings.Index(afterScheme, "/")
+	if i := strings.LastIndex(repo, "@"); strings.Contains(afterScheme, "/") && i > pathStart {
+		repo = repo[:i]
+	}
+	commit := material.Digest["gitCommit"]
+	if commit == "" {
+		commit = material.Digest["sha1"]
+	}
+	return repo, commit
+}
+
+// CheckBuilder returns true if the builder of the provenance is one of the allowed builder IDs.
+// An allowed builder ID without a version also allows the versions of the builder, e.g.
+// https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml
+// allows the builder at @refs/tags/v2.0.0
+func (data *ProvenanceData) CheckBuilder(allowedBuilderIDs []string) bool {
+	for _, allowed := range allowedBuilderIDs {
+		if data.BuilderID == allowed || strings.HasPrefix(data.BuilderID, allowed+"@") {
+			return true
+		}
+	}
+	return false
+}
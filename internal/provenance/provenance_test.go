@@ -0,0 +1,168 @@
+package provenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const fingerprint = "7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9"
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type ProvenanceTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ProvenanceTestSuite) TestProcessProvenanceFile() {
+	slsaV1 := &ProvenanceData{
+		SchemaVersion: 1,
+		StatementType: "https://in-toto.io/Statement/v1",
+		PredicateType: PredicateSLSAv1,
+		Subjects:      []Subject{{Name: "file1", Digest: map[string]string{"sha256": fingerprint}}},
+		BuilderID:     "https://github.com/actions/runner/github-hosted",
+		BuildType:     "https://actions.github.io/buildtypes/workflow/v1",
+		InvocationID:  "https://github.com/kosli-dev/cli/actions/runs/1234567890/attempts/1",
+		StartedOn:     "2024-05-01T10:00:00Z",
+		FinishedOn:    "2024-05-01T10:05:00Z",
+		SourceRepo:    "https://github.com/kosli-dev/cli",
+		SourceCommit:  "8f3a4b1b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f",
+		Materials: []Material{{
+			URI:    "git+https://github.com/kosli-dev/cli@refs/heads/main",
+			Digest: map[string]string{"gitCommit": "8f3a4b1b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f"},
+		}},
+	}
+	for _, t := range []struct {
+		name    string
+		file    string
+		want    *ProvenanceData
+		wantErr string
+	}{
+		{
+			name: "a SLSA v1 in-toto statement is parsed",
+			file: "testdata/slsa-v1.json",
+			want: slsaV1,
+		},
+		{
+			name: "a SLSA v1 statement in the DSSE envelope of a sigstore bundle is parsed",
+			file: "testdata/slsa-v1.sigstore.json",
+			want: slsaV1,
+		},
+		{
+			name: "a SLSA v0.2 statement in a DSSE envelope of an intoto.jsonl file is parsed",
+			file: "testdata/slsa-v0.2.intoto.jsonl",
+			want: &ProvenanceData{
+				SchemaVersion: 1,
+				StatementType: "https://in-toto.io/Statement/v0.1",
+				PredicateType: PredicateSLSAv0_2,
+				Subjects: []Subject{
+					{Name: "cli_linux_amd64.tar.gz", Digest: map[string]string{"sha256": "4f1cd1b5ba1e4a2d5bd0a4f4e5d0d4a1ad1a6f0a8bd0e8c2c2a0b1b3b4b5b6b7"}},
+					{Name: "file1", Digest: map[string]string{"sha256": fingerprint}},
+				},
+				BuilderID:    "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.9.0",
+				BuildType:    "https://github.com/slsa-framework/slsa-github-generator/generic@v1",
+				InvocationID: "1234567890-1",
+				SourceRepo:   "https://github.com/kosli-dev/cli",
+				SourceCommit: "2b8d5a4c6e3f7a9b1c0d2e4f6a8b0c1d3e5f7a9b",
+				Materials: []Material{{
+					URI:    "git+https://github.com/kosli-dev/cli@refs/tags/v2.11.0",
+					Digest: map[string]string{"sha1": "2b8d5a4c6e3f7a9b1c0d2e4f6a8b0c1d3e5f7a9b"},
+				}},
+			},
+		},
+		{
+			name:    "statements which are not SLSA provenance are rejected",
+			file:    "testdata/spdx-statement.json",
+			wantErr: "no SLSA provenance statement found in provenance file [testdata/spdx-statement.json]. Supported predicate types are: https://slsa.dev/provenance/v1, https://slsa.dev/provenance/v0.2",
+		},
+		{
+			name:    "files which are not JSON are rejected",
+			file:    "provenance.go",
+			wantErr: "failed to parse provenance file [provenance.go]: invalid character 'p' looking for beginning of value",
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := ProcessProvenanceFile(t.file, fingerprint)
+			if t.wantErr != "" {
+				require.EqualError(suite.T(), err, t.wantErr)
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+func (suite *ProvenanceTestSuite) TestProcessProvenanceFileFailsForOtherArtifacts() {
+	otherFingerprint := "0000000000000000000000000000000000000000000000000000000000000000"
+	_, err := ProcessProvenanceFile("testdata/slsa-v1.json", otherFingerprint)
+	require.EqualError(suite.T(), err, "the subjects of the provenance in file [testdata/slsa-v1.json] do not include the artifact fingerprint "+otherFingerprint)
+}
+
+func (suite *ProvenanceTestSuite) TestProcessProvenanceFileFailsForInvalidDocuments() {
+	dir := suite.T().TempDir()
+	for content, wantErr := range map[string]string{
+		`{"name": "not a statement"}`:                                     "not an in-toto statement, a DSSE envelope or a sigstore bundle",
+		`{"payloadType": "text/plain", "payload": "aGVsbG8="}`:            "unsupported DSSE payload type text/plain",
+		`{"payloadType": "application/vnd.in-toto+json", "payload": "!"}`: "invalid DSSE payload: illegal base64 data at input byte 0",
+	} {
+		path := filepath.Join(dir, "provenance.json")
+		require.NoError(suite.T(), os.WriteFile(path, []byte(content), 0600))
+		_, err := ProcessProvenanceFile(path, fingerprint)
+		require.EqualError(suite.T(), err, "failed to parse provenance file ["+path+"]: "+wantErr)
+	}
+}
+
+func (suite *ProvenanceTestSuite) TestGitSource() {
+	for uri, want := range map[string][2]string{
+		"git+https://github.com/org/repo@refs/heads/main": {"https://github.com/org/repo", "abc"},
+		"git+https://github.com/org/repo":                 {"https://github.com/org/repo", "abc"},
+		"git+ssh://git@github.com/org/repo":               {"ssh://git@github.com/org/repo", "abc"},
+		"git+ssh://git@github.com/org/repo@v1.0":          {"ssh://git@github.com/org/repo", "abc"},
+	} {
+		repo, commit := gitSource(Material{URI: uri, Digest: map[string]string{"sha1": "abc"}})
+		require.Equal(suite.T(), want, [2]string{repo, commit}, uri)
+	}
+}
+
+func (suite *ProvenanceTestSuite) TestCheckBuilder() {
+	data := &ProvenanceData{BuilderID: "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml@refs/tags/v1.9.0"}
+	for _, t := range []struct {
+		name    string
+		allowed []string
+		want    bool
+	}{
+		{
+			name:    "the exact builder ID is allowed",
+			allowed: []string{"https://github.com/actions/runner/github-hosted", data.BuilderID},
+			want:    true,
+		},
+		{
+			name:    "a builder ID without version allows all versions",
+			allowed: []string{"https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_generic_slsa3.yml"},
+			want:    true,
+		},
+		{
+			name:    "a prefix of a builder ID does not allow it",
+			allowed: []string{"https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator"},
+		},
+		{
+			name:    "no builder ID is allowed by an empty list",
+			allowed: []string{},
+		},
+	} {
+		suite.Run(t.name, func() {
+			require.Equal(suite.T(), t.want, data.CheckBuilder(t.allowed))
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestProvenanceTestSuite(t *testing.T) {
+	suite.Run(t, new(ProvenanceTestSuite))
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// MaxWait is the longest time to wait for a rate limit to reset. Requests fail
+	// if the rate limit resets later than that.
+	MaxWait = 2 * time.Minute
+	// DefaultWait is the time to wait when a service does not tell when its rate limit resets
+	DefaultWait = 10 * time.Second
+	// MaxAttempts is the number of times a rate-limited request is sent before giving up
+	MaxAttempts = 3
+)
+
+// ExceededError is returned when a request is still rate-limited after MaxAttempts,
+// or when a rate limit resets later than MaxWait
+type ExceededError struct {
+	Service  string
+	Attempts int
+	Reset    time.Time
+}
+
+func (e *ExceededError) Error() string {
+	if e.Attempts > 0 {
+		return fmt.Sprintf("%s rate limit exceeded after %d attempts", e.Service, e.Attempts)
+	}
+	return fmt.Sprintf("%s rate limit exceeded. It resets at %s, please retry after that", e.Service, e.Reset.Format(time.RFC3339))
+}
+
+// Transport is an http.RoundTripper which waits for the rate limit of a service to reset
+// and retries requests which are rejected because the rate limit is exceeded
+type Transport struct {
+	// Service is the name of the rate-limited service used in error messages. The host is used when it is empty.
+	Service string
+	// Base is the underlying RoundTripper. http.DefaultTransport is used when it is nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	service := t.Service
+	if service == "" {
+		service = req.URL.Host
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if err != nil || !IsRateLimited(resp) {
+			return resp, err
+		}
+		// requests with a body can only be retried if the body can be read again
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		reset, ok := ResetTime(resp.Header, time.Now())
+		if !ok {
+			reset = time.Now().Add(DefaultWait)
+		}
+		resp.Body.Close()
+		if attempt == MaxAttempts {
+			return nil, &ExceededError{Service: service, Attempts: attempt}
+		}
+		err = Wait(service, reset)
+		if err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// IsRateLimited returns true if a response rejects a request because a rate limit is exceeded.
+// Some services, e.g. Github, respond with 403 rather than 429 when the rate limit is exceeded.
+func IsRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// ResetTime returns when a rate limit resets from the headers of a rate-limited response.
+// It supports the Retry-After header (in seconds or as an HTTP date) and the X-RateLimit-Reset
+// and RateLimit-Reset headers (as unix timestamps).
+func ResetTime(header http.Header, now time.Time) (time.Time, bool) {
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return date, true
+		}
+	}
+	for _, name := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if reset := header.Get(name); reset != "" {
+			if timestamp, err := strconv.ParseInt(reset, 10, 64); err == nil {
+				return time.Unix(timestamp, 0), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Wait sleeps until a rate limit resets, or returns an error if it resets later than MaxWait
+func Wait(service string, reset time.Time) error {
+	wait := time.Until(reset)
+	if wait > MaxWait {
+		return &ExceededError{Service: service, Reset: reset}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
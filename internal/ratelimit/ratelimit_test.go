@@ -0,0 +1,214 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RateLimitTestSuite) TestIsRateLimited() {
+	for _, t := range []struct {
+		name       string
+		statusCode int
+		header     http.Header
+		want       bool
+	}{
+		{
+			name:       "429 is rate limited",
+			statusCode: http.StatusTooManyRequests,
+			header:     http.Header{},
+			want:       true,
+		},
+		{
+			name:       "403 without remaining requests is rate limited",
+			statusCode: http.StatusForbidden,
+			header:     http.Header{"X-Ratelimit-Remaining": []string{"0"}},
+			want:       true,
+		},
+		{
+			name:       "403 with Retry-After is rate limited",
+			statusCode: http.StatusForbidden,
+			header:     http.Header{"Retry-After": []string{"60"}},
+			want:       true,
+		},
+		{
+			name:       "403 with remaining requests is not rate limited",
+			statusCode: http.StatusForbidden,
+			header:     http.Header{"X-Ratelimit-Remaining": []string{"10"}},
+			want:       false,
+		},
+		{
+			name:       "200 is not rate limited",
+			statusCode: http.StatusOK,
+			header:     http.Header{"X-Ratelimit-Remaining": []string{"0"}},
+			want:       false,
+		},
+	} {
+		suite.Run(t.name, func() {
+			resp := &http.Response{StatusCode: t.statusCode, Header: t.header}
+			require.Equal(suite.T(), t.want, IsRateLimited(resp))
+		})
+	}
+}
+
+func (suite *RateLimitTestSuite) TestResetTime() {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, t := range []struct {
+		name   string
+		header http.Header
+		want   time.Time
+		wantOk bool
+	}{
+		{
+			name:   "Retry-After in seconds",
+			header: http.Header{"Retry-After": []string{"30"}},
+			want:   now.Add(30 * time.Second),
+			wantOk: true,
+		},
+		{
+			name:   "Retry-After as an HTTP date",
+			header: http.Header{"Retry-After": []string{"Wed, 01 May 2024 12:05:00 GMT"}},
+			want:   now.Add(5 * time.Minute),
+			wantOk: true,
+		},
+		{
+			name:   "X-RateLimit-Reset as a unix timestamp",
+			header: http.Header{"X-Ratelimit-Reset": []string{fmt.Sprint(now.Add(time.Hour).Unix())}},
+			want:   now.Add(time.Hour),
+			wantOk: true,
+		},
+		{
+			name:   "RateLimit-Reset as a unix timestamp",
+			header: http.Header{"Ratelimit-Reset": []string{fmt.Sprint(now.Add(time.Minute).Unix())}},
+			want:   now.Add(time.Minute),
+			wantOk: true,
+		},
+		{
+			name:   "no rate limit headers",
+			header: http.Header{},
+			wantOk: false,
+		},
+	} {
+		suite.Run(t.name, func() {
+			reset, ok := ResetTime(t.header, now)
+			require.Equal(suite.T(), t.wantOk, ok)
+			if t.wantOk {
+				require.True(suite.T(), t.want.Equal(reset), "want %s, got %s", t.want, reset)
+			}
+		})
+	}
+}
+
+func (suite *RateLimitTestSuite) TestTransport() {
+	for _, t := range []struct {
+		name         string
+		responses    []func(w http.ResponseWriter)
+		wantError    string
+		wantStatus   int
+		wantAttempts int
+	}{
+		{
+			name: "a 429 response is retried when the rate limit resets",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name: "a rate-limited 403 response is retried when the rate limit resets",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Unix()))
+					w.WriteHeader(http.StatusForbidden)
+				},
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			wantStatus:   http.StatusOK,
+			wantAttempts: 2,
+		},
+		{
+			name: "a 403 response which is not rate-limited is returned",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+			},
+			wantStatus:   http.StatusForbidden,
+			wantAttempts: 1,
+		},
+		{
+			name: "a rate limit which resets later than the max wait fails",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", "4102444800")
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			wantError:    "Fake rate limit exceeded. It resets at " + time.Unix(4102444800, 0).Format(time.RFC3339) + ", please retry after that",
+			wantAttempts: 1,
+		},
+		{
+			name: "requests fail when they are rate-limited too many times",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			},
+			wantError:    "Fake rate limit exceeded after 3 attempts",
+			wantAttempts: 3,
+		},
+	} {
+		suite.Run(t.name, func() {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.responses[min(attempts, len(t.responses)-1)](w)
+				attempts++
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &Transport{Service: "Fake"}}
+			resp, err := client.Get(server.URL)
+			require.Equal(suite.T(), t.wantAttempts, attempts)
+			if t.wantError != "" {
+				require.ErrorContains(suite.T(), err, t.wantError)
+				return
+			}
+			require.NoError(suite.T(), err)
+			defer resp.Body.Close()
+			require.Equal(suite.T(), t.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *RateLimitTestSuite) TestWait() {
+	err := Wait("Fake", time.Now().Add(-time.Second))
+	require.NoError(suite.T(), err)
+
+	reset := time.Now().Add(MaxWait + time.Minute)
+	err = Wait("Fake", reset)
+	require.EqualError(suite.T(), err, fmt.Sprintf("Fake rate limit exceeded. It resets at %s, please retry after that", reset.Format(time.RFC3339)))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
@@ -0,0 +1,206 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cp "github.com/otiai10/copy"
+)
+
+const outboxEntryFile = "request.json"
+
+// outboxNow returns the time entries are added to the outbox at
+var outboxNow = time.Now
+
+// Outbox is a durable on-disk queue of requests that could not be delivered
+// because the Kosli host was unreachable or returned a server error.
+// Each queued request is stored in its own directory under Dir, together with
+// copies of any files attached to it.
+type Outbox struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// OutboxEntry is a queued request as persisted to disk.
+// Credentials are never persisted, they are supplied again when the entry is replayed.
+type OutboxEntry struct {
+	ID                string            `json:"id"`
+	CreatedAt         int64             `json:"created_at"`
+	Method            string            `json:"method"`
+	URL               string            `json:"url"`
+	Payload           json.RawMessage   `json:"payload,omitempty"`
+	Form              []FormItem        `json:"form,omitempty"`
+	AdditionalHeaders map[string]string `json:"additional_headers,omitempty"`
+	Reason            string            `json:"reason"`
+}
+
+// NewOutbox returns an Outbox stored in dir
+func NewOutbox(dir string) *Outbox {
+	return &Outbox{Dir: dir}
+}
+
+// shouldQueue decides if a failed request can be queued in the outbox.
+// Only requests that change data in Kosli are queued, read requests are
+// meaningless to replay later.
+func shouldQueue(p *RequestParams) bool {
+	return p.Method != http.MethodGet && p.Method != http.MethodHead
+}
+
+// Add persists a request to the outbox. Attachment files of multipart requests
+// are copied into the entry directory, so that the originals (which are
+// often temporary files) can be removed.
+func (o *Outbox) Add(p *RequestParams, reason error) (*OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := outboxNow()
+	entry := &OutboxEntry{
+		CreatedAt:         now.Unix(),
+		Method:            p.Method,
+		URL:               p.URL,
+		AdditionalHeaders: map[string]string{},
+	}
+	if reason != nil {
+		entry.Reason = reason.Error()
+	}
+	// the auth and multipart content type headers are (re)generated when the request is replayed
+	for k, v := range p.AdditionalHeaders {
+		if k != "Authorization" && k != "Content-Type" {
+			entry.AdditionalHeaders[k] = v
+		}
+	}
+
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory %s: %v", o.Dir, err)
+	}
+	// the entry directory must not exist yet, so that processes sharing the outbox
+	// (e.g. parallel CI jobs) never write to the same entry
+	var entryDir string
+	for id := now.UnixNano(); ; id++ {
+		entry.ID = fmt.Sprintf("%d", id)
+		entryDir = filepath.Join(o.Dir, entry.ID)
+		err := os.Mkdir(entryDir, 0700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create outbox entry directory %s: %v", entryDir, err)
+		}
+	}
+
+	if len(p.Form) > 0 {
+		for i, item := range p.Form {
+			if item.Type == "file" {
+				src := item.Content.(string)
+				dst := filepath.Join(entryDir, fmt.Sprintf("%d-%s", i, filepath.Base(src)))
+				if err := cp.Copy(src, dst); err != nil {
+					os.RemoveAll(entryDir)
+					return nil, fmt.Errorf("failed to copy attachment %s to the outbox: %v", src, err)
+				}
+				item.Content = dst
+			}
+			entry.Form = append(entry.Form, item)
+		}
+	} else if p.Payload != nil {
+		payload, err := json.Marshal(p.Payload)
+		if err != nil {
+			os.RemoveAll(entryDir)
+			return nil, err
+		}
+		entry.Payload = payload
+	}
+
+	content, err := json.MarshalIndent(entry, "", "    ")
+	if err != nil {
+		os.RemoveAll(entryDir)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(entryDir, outboxEntryFile), content, 0600); err != nil {
+		os.RemoveAll(entryDir)
+		return nil, fmt.Errorf("failed to write outbox entry %s: %v", entry.ID, err)
+	}
+	return entry, nil
+}
+
+// List returns the queued entries in the order they were added
+func (o *Outbox) List() ([]*OutboxEntry, error) {
+	entries := []*OutboxEntry{}
+	dirEntries, err := os.ReadDir(o.Dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return entries, nil
+		}
+		return entries, err
+	}
+
+	for _, d := range dirEntries {
+		if !d.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(o.Dir, d.Name(), outboxEntryFile))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// an entry that is still being written
+				continue
+			}
+			return entries, err
+		}
+		entry := &OutboxEntry{}
+		if err := json.Unmarshal(content, entry); err != nil {
+			return entries, fmt.Errorf("outbox entry %s is corrupted: %v", d.Name(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		// IDs are nanosecond timestamps, compare them as numbers
+		if len(entries[i].ID) != len(entries[j].ID) {
+			return len(entries[i].ID) < len(entries[j].ID)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Remove deletes an entry (and its attachments) from the outbox
+func (o *Outbox) Remove(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return fmt.Errorf("invalid outbox entry ID: %s", id)
+	}
+	entryDir := filepath.Join(o.Dir, id)
+	if _, err := os.Stat(filepath.Join(entryDir, outboxEntryFile)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("outbox entry %s does not exist", id)
+		}
+		return err
+	}
+	return os.RemoveAll(entryDir)
+}
+
+// RequestParams rebuilds the request parameters of a queued entry using
+// the provided API token
+func (e *OutboxEntry) RequestParams(token string) *RequestParams {
+	p := &RequestParams{
+		Method:            e.Method,
+		URL:               e.URL,
+		Form:              e.Form,
+		AdditionalHeaders: map[string]string{},
+		Token:             token,
+	}
+	for k, v := range e.AdditionalHeaders {
+		p.AdditionalHeaders[k] = v
+	}
+	if len(e.Payload) > 0 {
+		p.Payload = e.Payload
+	}
+	return p
+}
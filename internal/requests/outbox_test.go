@@ -0,0 +1,252 @@
+package requests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/maxcnunes/httpfake"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type OutboxTestSuite struct {
+	suite.Suite
+	fakeService *httpfake.HTTPFake
+	outbox      *Outbox
+}
+
+func (suite *OutboxTestSuite) SetupSuite() {
+	suite.fakeService = httpfake.New()
+	suite.fakeService.NewHandler().
+		Put("/environments/1").
+		Reply(201).
+		BodyString(`{}`)
+	suite.fakeService.NewHandler().
+		Put("/fail/").
+		Reply(500).
+		BodyString("server broken")
+	suite.fakeService.NewHandler().
+		Put("/no-go/").
+		Reply(404).
+		BodyString(`{"message": "resource not found"}`)
+	suite.fakeService.NewHandler().
+		Get("/fail/").
+		Reply(500).
+		BodyString("server broken")
+}
+
+func (suite *OutboxTestSuite) TearDownSuite() {
+	suite.fakeService.Close()
+}
+
+func (suite *OutboxTestSuite) SetupTest() {
+	suite.outbox = NewOutbox(filepath.Join(suite.T().TempDir(), "outbox"))
+}
+
+func (suite *OutboxTestSuite) newClient() *Client {
+	client, err := NewKosliClient("", 1, false, logger.NewStandardLogger())
+	require.NoError(suite.T(), err)
+	client.Outbox = suite.outbox
+	return client
+}
+
+func (suite *OutboxTestSuite) TestAddListRemove() {
+	params := &RequestParams{
+		Method:  http.MethodPut,
+		URL:     "https://app.kosli.com/api/v2/environments/acme/prod/report/K8S",
+		Payload: map[string]interface{}{"artifacts": []string{}},
+		Token:   "secret",
+	}
+	first, err := suite.outbox.Add(params, fmt.Errorf("host unreachable"))
+	require.NoError(suite.T(), err)
+	second, err := suite.outbox.Add(params, nil)
+	require.NoError(suite.T(), err)
+
+	entries, err := suite.outbox.List()
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), entries, 2)
+	require.Equal(suite.T(), first.ID, entries[0].ID)
+	require.Equal(suite.T(), second.ID, entries[1].ID)
+	require.Equal(suite.T(), "host unreachable", entries[0].Reason)
+	require.JSONEq(suite.T(), `{"artifacts": []}`, string(entries[0].Payload))
+
+	content, err := os.ReadFile(filepath.Join(suite.outbox.Dir, first.ID, outboxEntryFile))
+	require.NoError(suite.T(), err)
+	require.NotContains(suite.T(), string(content), "secret")
+
+	replayed := entries[0].RequestParams("new-token")
+	require.Equal(suite.T(), "new-token", replayed.Token)
+	require.Equal(suite.T(), params.URL, replayed.URL)
+	require.Equal(suite.T(), params.Method, replayed.Method)
+
+	require.NoError(suite.T(), suite.outbox.Remove(first.ID))
+	entries, err = suite.outbox.List()
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), entries, 1)
+
+	require.Error(suite.T(), suite.outbox.Remove(first.ID))
+	require.Error(suite.T(), suite.outbox.Remove("../"+second.ID))
+}
+
+func (suite *OutboxTestSuite) TestAddFromProcessesSharingTheOutbox() {
+	// two processes adding an entry at the same time, each with its own Outbox of the same directory
+	defer func(now func() time.Time) { outboxNow = now }(outboxNow)
+	addedAt := time.Now()
+	outboxNow = func() time.Time { return addedAt }
+
+	ids := map[string]bool{}
+	for i, outbox := range []*Outbox{NewOutbox(suite.outbox.Dir), NewOutbox(suite.outbox.Dir)} {
+		entry, err := outbox.Add(&RequestParams{
+			Method:  http.MethodPut,
+			URL:     "https://app.kosli.com/api/v2/environments/acme/prod/report/K8S",
+			Payload: map[string]int{"process": i},
+		}, nil)
+		require.NoError(suite.T(), err)
+		ids[entry.ID] = true
+	}
+	require.Len(suite.T(), ids, 2)
+
+	entries, err := suite.outbox.List()
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), entries, 2)
+	require.JSONEq(suite.T(), `{"process": 0}`, string(entries[0].Payload))
+	require.JSONEq(suite.T(), `{"process": 1}`, string(entries[1].Payload))
+}
+
+func (suite *OutboxTestSuite) TestListEmptyOutbox() {
+	entries, err := suite.outbox.List()
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), entries)
+}
+
+func (suite *OutboxTestSuite) TestAddCopiesAttachments() {
+	attachment := filepath.Join(suite.T().TempDir(), "evidence.tgz")
+	require.NoError(suite.T(), os.WriteFile(attachment, []byte("evidence"), 0600))
+
+	entry, err := suite.outbox.Add(&RequestParams{
+		Method: http.MethodPost,
+		URL:    "https://app.kosli.com/api/v2/attestations/acme/flow/trail/trail/generic",
+		Form: []FormItem{
+			{Type: "field", FieldName: "data_json", Content: map[string]interface{}{"is_compliant": true}},
+			{Type: "file", FieldName: "attachment_file", Content: attachment},
+		},
+	}, nil)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), os.Remove(attachment))
+
+	entries, err := suite.outbox.List()
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), entries, 1)
+	require.Len(suite.T(), entries[0].Form, 2)
+	copied := entries[0].Form[1].Content.(string)
+	require.Equal(suite.T(), filepath.Join(suite.outbox.Dir, entry.ID), filepath.Dir(copied))
+	content, err := os.ReadFile(copied)
+	require.NoError(suite.T(), err)
+	require.Equal(suite.T(), "evidence", string(content))
+
+	// the copied attachment can be sent again
+	_, _, _, err = createMultipartRequestBody(entries[0].RequestParams("").Form)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *OutboxTestSuite) TestDoWithOutbox() {
+	for _, t := range []struct {
+		name          string
+		params        *RequestParams
+		wantError     bool
+		wantQueued    bool
+		wantStatus    int
+		withoutOutbox bool
+	}{
+		{
+			name: "successful request is not queued",
+			params: &RequestParams{
+				Method: http.MethodPut,
+				URL:    suite.fakeService.ResolveURL("/environments/1"),
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "PUT request to 500 endpoint is queued",
+			params: &RequestParams{
+				Method: http.MethodPut,
+				URL:    suite.fakeService.ResolveURL("/fail/"),
+			},
+			wantQueued: true,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "PUT request to unreachable host is queued",
+			params: &RequestParams{
+				Method: http.MethodPut,
+				URL:    "http://localhost:1/environments/1",
+			},
+			wantQueued: true,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "PUT request to 404 endpoint is not queued",
+			params: &RequestParams{
+				Method: http.MethodPut,
+				URL:    suite.fakeService.ResolveURL("/no-go/"),
+			},
+			wantError: true,
+		},
+		{
+			name: "GET request to 500 endpoint is not queued",
+			params: &RequestParams{
+				Method: http.MethodGet,
+				URL:    suite.fakeService.ResolveURL("/fail/"),
+			},
+			wantError: true,
+		},
+		{
+			name: "PUT request to 500 endpoint fails without an outbox",
+			params: &RequestParams{
+				Method: http.MethodPut,
+				URL:    suite.fakeService.ResolveURL("/fail/"),
+			},
+			withoutOutbox: true,
+			wantError:     true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			suite.SetupTest()
+			client := suite.newClient()
+			if t.withoutOutbox {
+				client.Outbox = nil
+			}
+			resp, err := client.Do(t.params)
+			if t.wantError {
+				require.Error(suite.T(), err)
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.wantStatus, resp.Resp.StatusCode)
+			}
+
+			entries, err := suite.outbox.List()
+			require.NoError(suite.T(), err)
+			if t.wantQueued {
+				require.Len(suite.T(), entries, 1)
+				require.Equal(suite.T(), t.params.URL, entries[0].URL)
+				require.NotEmpty(suite.T(), entries[0].Reason)
+			} else {
+				require.Empty(suite.T(), entries)
+			}
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestOutboxTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxTestSuite))
+}
@@ -2,7 +2,9 @@ package requests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -15,6 +17,7 @@ import (
 
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
 	"github.com/kosli-dev/cli/internal/logger"
+	"github.com/kosli-dev/cli/internal/ratelimit"
 	"github.com/kosli-dev/cli/internal/version"
 )
 
@@ -35,33 +38,61 @@ type Client struct {
 	Debug         bool
 	Logger        *logger.Logger
 	HttpClient    *http.Client
+	// RateLimitedHttpClient sends the requests of RequestParams with RateLimited set. They wait for
+	// the rate limit of the host to reset and are retried when it is exceeded.
+	RateLimitedHttpClient *http.Client
+	// Outbox, when set, stores requests that fail because the host is unreachable
+	// or returns a server error, so that they can be replayed later
+	Outbox *Outbox
 }
 
 func NewKosliClient(httpProxyURL string, maxAPIRetries int, debug bool, logger *logger.Logger) (*Client, error) {
-	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = maxAPIRetries
-	if !debug {
-		retryClient.Logger = nil // this silences logging each individual attempt
-	}
-	client := retryClient.StandardClient() // return a standard *http.Client from the retryable client
+	var proxyURL *url.URL
 	if httpProxyURL != "" {
-		proxyURL, err := url.Parse(httpProxyURL)
+		var err error
+		proxyURL, err = url.Parse(httpProxyURL)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse proxy URL when creating a Kosli http client: %s", err)
 		}
-		// client.Transport is already set by retryClient.StandardClient() and we add
-		// the proxy to it
-		client.Transport.(*retryablehttp.RoundTripper).Client.HTTPClient.Transport.(*http.Transport).Proxy = http.ProxyURL(proxyURL)
 	}
 
 	return &Client{
-		MaxAPIRetries: maxAPIRetries,
-		Debug:         debug,
-		Logger:        logger,
-		HttpClient:    client,
+		MaxAPIRetries:         maxAPIRetries,
+		Debug:                 debug,
+		Logger:                logger,
+		HttpClient:            newRetryClient(proxyURL, maxAPIRetries, debug, false),
+		RateLimitedHttpClient: newRetryClient(proxyURL, maxAPIRetries, debug, true),
 	}, nil
 }
 
+// newRetryClient returns a standard *http.Client retrying failed requests. When rateLimited is true,
+// rate-limited requests are retried by the rate limit transport when the rate limit resets,
+// and are not retried again once it gives up.
+func newRetryClient(proxyURL *url.URL, maxAPIRetries int, debug bool, rateLimited bool) *http.Client {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = maxAPIRetries
+	if !debug {
+		retryClient.Logger = nil // this silences logging each individual attempt
+	}
+	// the transport is already set by retryablehttp.NewClient() and we add
+	// the proxy to it
+	transport := retryClient.HTTPClient.Transport.(*http.Transport)
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if rateLimited {
+		retryClient.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+			var rateLimitErr *ratelimit.ExceededError
+			if errors.As(err, &rateLimitErr) {
+				return false, rateLimitErr
+			}
+			return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+		}
+		retryClient.HTTPClient.Transport = &ratelimit.Transport{Base: transport}
+	}
+	return retryClient.StandardClient() // return a standard *http.Client from the retryable client
+}
+
 type RequestParams struct {
 	Method            string
 	URL               string
@@ -72,6 +103,8 @@ type RequestParams struct {
 	Password          string
 	Token             string
 	DryRun            bool
+	// RateLimited requests are sent with the RateLimitedHttpClient of the client
+	RateLimited bool
 }
 
 func (p *RequestParams) newHTTPRequest() (*http.Request, map[string]interface{}, error) {
@@ -211,9 +244,20 @@ func (c *Client) Do(p *RequestParams) (*HTTPResponse, error) {
 		}
 		return nil, nil
 	} else {
-		resp, err := c.HttpClient.Do(req)
+		httpClient := c.HttpClient
+		if p.RateLimited {
+			httpClient = c.RateLimitedHttpClient
+		}
+		resp, err := httpClient.Do(req)
+		var rateLimitErr *ratelimit.ExceededError
+		if errors.As(err, &rateLimitErr) {
+			return nil, rateLimitErr
+		}
 		if err != nil {
 			// err from retryable client is detailed enough
+			if queued, ok := c.queueInOutbox(p, err); ok {
+				return queued, nil
+			}
 			return nil, fmt.Errorf("%v", err)
 		}
 
@@ -225,6 +269,12 @@ func (c *Client) Do(p *RequestParams) (*HTTPResponse, error) {
 
 		c.Logger.Debug("request made to %s and got status %d", req.URL, resp.StatusCode)
 
+		if resp.StatusCode >= 500 {
+			if queued, ok := c.queueInOutbox(p, fmt.Errorf("%s %s returned status %d", req.Method, req.URL, resp.StatusCode)); ok {
+				return queued, nil
+			}
+		}
+
 		if resp.StatusCode != 200 && resp.StatusCode != 201 {
 			var respBody interface{}
 			err := json.Unmarshal([]byte(body), &respBody)
@@ -256,3 +306,22 @@ func (c *Client) Do(p *RequestParams) (*HTTPResponse, error) {
 		return &HTTPResponse{string(body), resp}, nil
 	}
 }
+
+// queueInOutbox stores a failed request in the client outbox (if configured).
+// It returns a synthetic 202 Accepted response and true when the request is queued.
+func (c *Client) queueInOutbox(p *RequestParams, reason error) (*HTTPResponse, bool) {
+	if c.Outbox == nil || !shouldQueue(p) {
+		return nil, false
+	}
+	entry, err := c.Outbox.Add(p, reason)
+	if err != nil {
+		c.Logger.Warning("failed to store request to %s in the outbox: %v", p.URL, err)
+		return nil, false
+	}
+	c.Logger.Warning("request to %s failed: %v", p.URL, reason)
+	c.Logger.Warning("the request is stored in the outbox [%s] as entry %s. Use 'kosli outbox flush' to send it when the host is reachable again", c.Outbox.Dir, entry.ID)
+	return &HTTPResponse{
+		Body: "",
+		Resp: &http.Response{StatusCode: http.StatusAccepted, Status: http.StatusText(http.StatusAccepted), Header: http.Header{}},
+	}, true
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -386,6 +387,51 @@ func (suite *RequestsTestSuite) TestDo() {
 	}
 }
 
+func (suite *RequestsTestSuite) TestDoRateLimited() {
+	for _, t := range []struct {
+		name         string
+		rateLimited  bool
+		wantError    bool
+		wantRequests int
+	}{
+		{
+			name:         "rate-limited responses to Kosli requests are not retried",
+			wantError:    true,
+			wantRequests: 1,
+		},
+		{
+			name:         "rate-limited requests are retried when the rate limit resets",
+			rateLimited:  true,
+			wantRequests: 2,
+		},
+	} {
+		suite.Run(t.name, func() {
+			requestsCount := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestsCount++
+				if requestsCount == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusForbidden)
+					fmt.Fprint(w, `{"message": "rate limit exceeded"}`)
+					return
+				}
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+
+			client, err := NewKosliClient("", 0, false, logger.NewStandardLogger())
+			require.NoError(suite.T(), err)
+			_, err = client.Do(&RequestParams{
+				Method:      http.MethodGet,
+				URL:         server.URL,
+				RateLimited: t.rateLimited,
+			})
+			require.Equal(suite.T(), t.wantError, err != nil, "unexpected error: %v", err)
+			require.Equal(suite.T(), t.wantRequests, requestsCount)
+		})
+	}
+}
+
 func (suite *RequestsTestSuite) TestCreateMultipartRequestBody() {
 	for _, t := range []struct {
 		name                      string
@@ -0,0 +1,356 @@
+package sarif
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/owenrumney/go-sarif/v2/sarif"
+)
+
+// Severity levels used to normalise the results of all scanners, from the most to the least severe
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityInfo     = "info"
+)
+
+// Severities lists the normalised severity levels, from the most to the least severe
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+var severityRanks = map[string]int{
+	SeverityCritical: 4,
+	SeverityHigh:     3,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+	SeverityInfo:     0,
+}
+
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Lines string `json:"lines,omitempty"`
+}
+
+type Finding struct {
+	RuleID           string     `json:"rule_id"`
+	Message          string     `json:"message"`
+	Severity         string     `json:"severity"`
+	SecuritySeverity float64    `json:"security_severity,omitempty"`
+	Locations        []Location `json:"locations,omitempty"`
+}
+
+// SeverityCounts holds the number of findings per normalised severity
+type SeverityCounts struct {
+	Critical int `json:"critical_count"`
+	High     int `json:"high_count"`
+	Medium   int `json:"medium_count"`
+	Low      int `json:"low_count"`
+	Info     int `json:"info_count"`
+}
+
+type RunResult struct {
+	File            string         `json:"file"`
+	Tool            Tool           `json:"tool"`
+	Counts          SeverityCounts `json:"counts"`
+	SuppressedCount int            `json:"suppressed_count"`
+	Findings        []Finding      `json:"findings,omitempty"`
+}
+
+type SarifData struct {
+	SchemaVersion int            `json:"schema_version"`
+	Totals        SeverityCounts `json:"totals"`
+	Runs          []RunResult    `json:"runs"`
+}
+
+// ProcessSarifResultFiles takes paths to SARIF 2.1 files produced by any scanner
+// and returns a summary of their results, normalised by severity
+func ProcessSarifResultFiles(files []string) (*SarifData, error) {
+	data := &SarifData{
+		SchemaVersion: 1,
+		Runs:          []RunResult{},
+	}
+	for _, file := range files {
+		report, err := sarif.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SARIF file [%s]: %v", file, err)
+		}
+		if report.Version != string(sarif.Version210) {
+			return nil, fmt.Errorf("unsupported SARIF version [%s] in file [%s]. Only version %s is supported", report.Version, file, sarif.Version210)
+		}
+
+		for _, run := range report.Runs {
+			result := RunResult{
+				File: filepath.Base(file),
+				Tool: Tool{Name: run.Tool.Driver.Name},
+			}
+			if run.Tool.Driver.Version != nil {
+				result.Tool.Version = *run.Tool.Driver.Version
+			} else if run.Tool.Driver.SemanticVersion != nil {
+				result.Tool.Version = *run.Tool.Driver.SemanticVersion
+			}
+
+			for _, r := range run.Results {
+				if len(r.Suppressions) > 0 {
+					result.SuppressedCount++
+					continue
+				}
+				finding := createFinding(run, r)
+				result.Counts.add(finding.Severity)
+				data.Totals.add(finding.Severity)
+				result.Findings = append(result.Findings, finding)
+			}
+			data.Runs = append(data.Runs, result)
+		}
+	}
+	return data, nil
+}
+
+// AtOrAbove returns the number of findings with the given severity or a more severe one
+func (c SeverityCounts) AtOrAbove(severity string) int {
+	count := 0
+	for _, s := range Severities {
+		if severityRanks[s] >= severityRanks[severity] {
+			count += c.Get(s)
+		}
+	}
+	return count
+}
+
+// Get returns the number of findings with the given severity
+func (c SeverityCounts) Get(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return c.Critical
+	case SeverityHigh:
+		return c.High
+	case SeverityMedium:
+		return c.Medium
+	case SeverityLow:
+		return c.Low
+	case SeverityInfo:
+		return c.Info
+	}
+	return 0
+}
+
+func (c *SeverityCounts) add(severity string) {
+	switch severity {
+	case SeverityCritical:
+		c.Critical++
+	case SeverityHigh:
+		c.High++
+	case SeverityMedium:
+		c.Medium++
+	case SeverityLow:
+		c.Low++
+	default:
+		c.Info++
+	}
+}
+
+// CheckThresholds checks the number of findings against maximum allowed counts
+// (which must be validated with ValidateThresholds).
+// A threshold for a severity applies to the findings of that severity and all
+// more severe ones, e.g. high=0 allows no high nor critical findings.
+// It returns a description of each exceeded threshold.
+func (d *SarifData) CheckThresholds(thresholds map[string]int) []string {
+	exceeded := []string{}
+	for _, severity := range Severities {
+		max, ok := thresholds[severity]
+		if !ok {
+			continue
+		}
+		count := d.Totals.AtOrAbove(severity)
+		if count > max {
+			exceeded = append(exceeded, fmt.Sprintf("%d finding(s) with severity %s or above, maximum allowed is %d", count, severity, max))
+		}
+	}
+	return exceeded
+}
+
+// createFinding creates a Finding from a SARIF result. The severity is taken from
+// (in order of precedence):
+// - the numeric security-severity property (CVSS score) of the result or its rule
+// - the severity or problem.severity property of the result or its rule
+// - the level of the result, or the default level of its rule
+func createFinding(run *sarif.Run, r *sarif.Result) Finding {
+	finding := Finding{
+		Locations: createLocations(r),
+	}
+	if r.RuleID != nil {
+		finding.RuleID = *r.RuleID
+	}
+	if r.Message.Text != nil {
+		finding.Message = *r.Message.Text
+	}
+
+	rule := findRule(run, r)
+	propertySets := []map[string]interface{}{r.Properties}
+	if rule != nil {
+		propertySets = append(propertySets, rule.Properties)
+	}
+
+	for _, properties := range propertySets {
+		if score, ok := securitySeverity(properties); ok {
+			finding.SecuritySeverity = score
+			finding.Severity = severityFromScore(score)
+			return finding
+		}
+	}
+	for _, properties := range propertySets {
+		if severity, ok := severityProperty(properties); ok {
+			finding.Severity = severity
+			return finding
+		}
+	}
+
+	level := ""
+	if r.Level != nil {
+		level = *r.Level
+	} else if rule != nil && rule.DefaultConfiguration != nil {
+		level = rule.DefaultConfiguration.Level
+	}
+	finding.Severity = severityFromLevel(level)
+	return finding
+}
+
+func createLocations(r *sarif.Result) []Location {
+	locations := []Location{}
+	for _, l := range r.Locations {
+		if l != nil && l.PhysicalLocation != nil {
+			lines := ""
+			if l.PhysicalLocation.Region != nil && l.PhysicalLocation.Region.StartLine != nil {
+				lines = strconv.Itoa(*l.PhysicalLocation.Region.StartLine)
+				if l.PhysicalLocation.Region.EndLine != nil && *l.PhysicalLocation.Region.EndLine != *l.PhysicalLocation.Region.StartLine {
+					lines += fmt.Sprintf("-%d", *l.PhysicalLocation.Region.EndLine)
+				}
+			}
+			uri := ""
+			if l.PhysicalLocation.ArtifactLocation != nil && l.PhysicalLocation.ArtifactLocation.URI != nil {
+				uri = *l.PhysicalLocation.ArtifactLocation.URI
+			}
+			locations = append(locations, Location{
+				URI:   uri,
+				Lines: lines,
+			})
+		}
+	}
+	return locations
+}
+
+// findRule returns the rule a result refers to, either by ID or by index
+func findRule(run *sarif.Run, r *sarif.Result) *sarif.ReportingDescriptor {
+	if r.RuleID != nil {
+		if rule, err := run.GetRuleById(*r.RuleID); err == nil {
+			return rule
+		}
+	}
+	if r.RuleIndex != nil && int(*r.RuleIndex) < len(run.Tool.Driver.Rules) {
+		return run.Tool.Driver.Rules[*r.RuleIndex]
+	}
+	return nil
+}
+
+// securitySeverity reads the security-severity property, which holds a
+// CVSS score as a string (e.g. "7.5") in the files of most scanners
+func securitySeverity(properties map[string]interface{}) (float64, bool) {
+	value, ok := properties["security-severity"]
+	if !ok || value == nil {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		score, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err == nil {
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+// severityProperty reads a severity name from the severity property, from the
+// problem.severity property used by CodeQL or from the nested one used by Snyk
+func severityProperty(properties map[string]interface{}) (string, bool) {
+	candidates := []interface{}{properties["severity"], properties["problem.severity"]}
+	if problem, ok := properties["problem"].(map[string]interface{}); ok {
+		candidates = append(candidates, problem["severity"])
+	}
+	for _, candidate := range candidates {
+		if name, ok := candidate.(string); ok {
+			if severity, ok := severityFromName(name); ok {
+				return severity, true
+			}
+		}
+	}
+	return "", false
+}
+
+// severityFromScore maps a CVSS score to a severity
+// using the same ranges as GitHub code scanning
+func severityFromScore(score float64) string {
+	switch {
+	case score >= 9.0:
+		return SeverityCritical
+	case score >= 7.0:
+		return SeverityHigh
+	case score >= 4.0:
+		return SeverityMedium
+	case score >= 0.1:
+		return SeverityLow
+	}
+	return SeverityInfo
+}
+
+// severityFromName maps the severity names used by scanners to a severity
+func severityFromName(name string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "critical", "blocker":
+		return SeverityCritical, true
+	case "high", "error", "major":
+		return SeverityHigh, true
+	case "medium", "moderate", "warning":
+		return SeverityMedium, true
+	case "low", "minor", "note":
+		return SeverityLow, true
+	case "info", "informational", "none", "negligible", "unknown":
+		return SeverityInfo, true
+	}
+	return "", false
+}
+
+// severityFromLevel maps a SARIF level to a severity.
+// SARIF defines "warning" as the default level.
+func severityFromLevel(level string) string {
+	switch level {
+	case "error":
+		return SeverityHigh
+	case "note":
+		return SeverityLow
+	case "none":
+		return SeverityInfo
+	}
+	return SeverityMedium
+}
+
+// ValidateThresholds validates a map of maximum allowed counts per severity
+func ValidateThresholds(thresholds map[string]int) error {
+	for severity, max := range thresholds {
+		if _, ok := severityRanks[severity]; !ok {
+			return fmt.Errorf("invalid severity [%s]. Valid severities are: %s", severity, strings.Join(Severities, ", "))
+		}
+		if max < 0 {
+			return fmt.Errorf("invalid maximum [%d] for severity [%s]. It must not be negative", max, severity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package sarif
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type SarifTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SarifTestSuite) TestProcessSarifResultFiles() {
+	for _, t := range []struct {
+		name            string
+		files           []string
+		wantErr         bool
+		expectedTools   []Tool
+		expectedCounts  []SeverityCounts
+		expectedTotals  SeverityCounts
+		suppressedCount int
+	}{
+		{
+			name:           "trivy results use the security-severity of the rules",
+			files:          []string{"testdata/trivy.sarif"},
+			expectedTools:  []Tool{{Name: "Trivy", Version: "0.50.1"}},
+			expectedCounts: []SeverityCounts{{Critical: 1, High: 1, Low: 1}},
+			expectedTotals: SeverityCounts{Critical: 1, High: 1, Low: 1},
+		},
+		{
+			name:           "semgrep results use the default level of the rules",
+			files:          []string{"testdata/semgrep.sarif"},
+			expectedTools:  []Tool{{Name: "Semgrep OSS", Version: "1.67.0"}},
+			expectedCounts: []SeverityCounts{{Medium: 1, Low: 1}},
+			expectedTotals: SeverityCounts{Medium: 1, Low: 1},
+		},
+		{
+			name:            "codeql results use security-severity, then problem.severity and skip suppressed results",
+			files:           []string{"testdata/codeql.sarif"},
+			expectedTools:   []Tool{{Name: "CodeQL", Version: "2.16.5"}},
+			expectedCounts:  []SeverityCounts{{High: 1, Medium: 1}},
+			expectedTotals:  SeverityCounts{High: 1, Medium: 1},
+			suppressedCount: 1,
+		},
+		{
+			name:           "results without any level default to medium",
+			files:          []string{"testdata/gitleaks.sarif"},
+			expectedTools:  []Tool{{Name: "gitleaks", Version: "v8.18.2"}},
+			expectedCounts: []SeverityCounts{{Medium: 1}},
+			expectedTotals: SeverityCounts{Medium: 1},
+		},
+		{
+			name:           "snyk results use the problem severity of the rules",
+			files:          []string{"../snyk/sarif-container.json"},
+			expectedTools:  []Tool{{Name: "Snyk Container"}, {Name: "Snyk Container"}},
+			expectedCounts: []SeverityCounts{{}, {High: 2, Medium: 1}},
+			expectedTotals: SeverityCounts{High: 2, Medium: 1},
+		},
+		{
+			name:  "multiple files are combined",
+			files: []string{"testdata/trivy.sarif", "testdata/semgrep.sarif", "testdata/codeql.sarif", "testdata/gitleaks.sarif"},
+			expectedTools: []Tool{
+				{Name: "Trivy", Version: "0.50.1"},
+				{Name: "Semgrep OSS", Version: "1.67.0"},
+				{Name: "CodeQL", Version: "2.16.5"},
+				{Name: "gitleaks", Version: "v8.18.2"},
+			},
+			expectedCounts: []SeverityCounts{
+				{Critical: 1, High: 1, Low: 1},
+				{Medium: 1, Low: 1},
+				{High: 1, Medium: 1},
+				{Medium: 1},
+			},
+			expectedTotals:  SeverityCounts{Critical: 1, High: 2, Medium: 3, Low: 2},
+			suppressedCount: 1,
+		},
+		{
+			name:    "non-existing file fails",
+			files:   []string{"testdata/non-existing.sarif"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported SARIF version fails",
+			files:   []string{"testdata/sarif-1.0.json"},
+			wantErr: true,
+		},
+	} {
+		suite.Run(t.name, func() {
+			data, err := ProcessSarifResultFiles(t.files)
+			require.False(suite.T(), (err != nil) != t.wantErr, "ProcessSarifResultFiles() error = %v, wantErr %v", err, t.wantErr)
+			if t.wantErr {
+				return
+			}
+			require.Equal(suite.T(), 1, data.SchemaVersion)
+			require.Len(suite.T(), data.Runs, len(t.expectedTools))
+			suppressed := 0
+			for i, run := range data.Runs {
+				require.Equal(suite.T(), t.expectedTools[i], run.Tool)
+				require.Equal(suite.T(), t.expectedCounts[i], run.Counts)
+				suppressed += run.SuppressedCount
+			}
+			require.Equal(suite.T(), t.expectedTotals, data.Totals)
+			require.Equal(suite.T(), t.suppressedCount, suppressed)
+		})
+	}
+}
+
+func (suite *SarifTestSuite) TestFindingDetails() {
+	data, err := ProcessSarifResultFiles([]string{"testdata/trivy.sarif"})
+	require.NoError(suite.T(), err)
+	finding := data.Runs[0].Findings[0]
+	require.Equal(suite.T(), "CVE-2023-0001", finding.RuleID)
+	require.Equal(suite.T(), SeverityCritical, finding.Severity)
+	require.Equal(suite.T(), 9.8, finding.SecuritySeverity)
+	require.Equal(suite.T(), []Location{{URI: "library/alpine", Lines: "1"}}, finding.Locations)
+	require.Equal(suite.T(), "trivy.sarif", data.Runs[0].File)
+}
+
+func (suite *SarifTestSuite) TestCheckThresholds() {
+	data := &SarifData{Totals: SeverityCounts{Critical: 1, High: 2, Medium: 3, Low: 2}}
+	for _, t := range []struct {
+		name       string
+		thresholds map[string]int
+		want       []string
+	}{
+		{
+			name:       "no thresholds are never exceeded",
+			thresholds: map[string]int{},
+			want:       []string{},
+		},
+		{
+			name:       "thresholds include more severe findings",
+			thresholds: map[string]int{"high": 2, "medium": 6},
+			want:       []string{"3 finding(s) with severity high or above, maximum allowed is 2"},
+		},
+		{
+			name:       "all exceeded thresholds are reported from the most severe",
+			thresholds: map[string]int{"medium": 5, "critical": 0},
+			want: []string{
+				"1 finding(s) with severity critical or above, maximum allowed is 0",
+				"6 finding(s) with severity medium or above, maximum allowed is 5",
+			},
+		},
+		{
+			name:       "thresholds that are not exceeded",
+			thresholds: map[string]int{"low": 8, "info": 8},
+			want:       []string{},
+		},
+	} {
+		suite.Run(t.name, func() {
+			require.Equal(suite.T(), t.want, data.CheckThresholds(t.thresholds))
+		})
+	}
+}
+
+func (suite *SarifTestSuite) TestValidateThresholds() {
+	require.NoError(suite.T(), ValidateThresholds(map[string]int{"critical": 0, "high": 0, "medium": 5, "low": 10, "info": 100}))
+	require.Error(suite.T(), ValidateThresholds(map[string]int{"severe": 0}))
+	require.Error(suite.T(), ValidateThresholds(map[string]int{"high": -1}))
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSarifTestSuite(t *testing.T) {
+	suite.Run(t, new(SarifTestSuite))
+}
@@ -0,0 +1,75 @@
+package sbom
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ComponentQuery matches the components of SBOMs by package URL, or by name, and optionally by version
+type ComponentQuery struct {
+	PURL    string
+	Name    string
+	Version string
+}
+
+// ParseComponentQuery parses a package URL (e.g. pkg:npm/lodash@4.17.21) or a component name
+// with an optional version (e.g. lodash@4.17.21). Without a version, all versions match.
+func ParseComponentQuery(query string) (*ComponentQuery, error) {
+	q := &ComponentQuery{}
+	if strings.HasPrefix(query, "pkg:") {
+		q.PURL, q.Version = splitPURL(query)
+		if q.PURL == "pkg:" || !strings.Contains(q.PURL, "/") {
+			return nil, fmt.Errorf("invalid package URL: %s", query)
+		}
+		return q, nil
+	}
+	q.Name, q.Version = splitVersion(query)
+	if q.Name == "" {
+		return nil, fmt.Errorf("invalid component: %s. It must be a package URL or a component name, e.g. lodash@4.17.21", query)
+	}
+	return q, nil
+}
+
+// Matches returns true if the component matches the query
+func (q *ComponentQuery) Matches(component Component) bool {
+	if q.PURL != "" {
+		purl, version := splitPURL(component.PURL)
+		if !strings.EqualFold(purl, q.PURL) {
+			return false
+		}
+		return q.Version == "" || q.Version == version || q.Version == component.Version
+	}
+	return strings.EqualFold(component.Name, q.Name) && (q.Version == "" || q.Version == component.Version)
+}
+
+// String returns the query as given, without its version if it has none
+func (q *ComponentQuery) String() string {
+	name := q.PURL
+	if name == "" {
+		name = q.Name
+	}
+	if q.Version == "" {
+		return name
+	}
+	return name + "@" + q.Version
+}
+
+// splitPURL returns a package URL without its qualifiers, subpath and version, and its version
+func splitPURL(purl string) (string, string) {
+	purl, _, _ = strings.Cut(purl, "#")
+	purl, _, _ = strings.Cut(purl, "?")
+	if unescaped, err := url.PathUnescape(purl); err == nil {
+		purl = unescaped
+	}
+	return splitVersion(purl)
+}
+
+// splitVersion splits name@version. A leading @ is part of the name, e.g. in @babel/core@7.24.0
+func splitVersion(nameVersion string) (string, string) {
+	i := strings.LastIndex(nameVersion, "@")
+	if i <= 0 || strings.Contains(nameVersion[i:], "/") {
+		return nameVersion, ""
+	}
+	return nameVersion[:i], nameVersion[i+1:]
+}
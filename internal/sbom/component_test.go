@@ -0,0 +1,76 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type ComponentQueryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ComponentQueryTestSuite) TestParseComponentQuery() {
+	for _, t := range []struct {
+		query   string
+		want    *ComponentQuery
+		wantErr string
+	}{
+		{query: "lodash", want: &ComponentQuery{Name: "lodash"}},
+		{query: "lodash@4.17.21", want: &ComponentQuery{Name: "lodash", Version: "4.17.21"}},
+		{query: "@babel/core", want: &ComponentQuery{Name: "@babel/core"}},
+		{query: "@babel/core@7.24.0", want: &ComponentQuery{Name: "@babel/core", Version: "7.24.0"}},
+		{query: "pkg:npm/lodash", want: &ComponentQuery{PURL: "pkg:npm/lodash"}},
+		{query: "pkg:npm/%40babel/core@7.24.0", want: &ComponentQuery{PURL: "pkg:npm/@babel/core", Version: "7.24.0"}},
+		{query: "pkg:apk/alpine/musl@1.2.4-r4?arch=x86_64", want: &ComponentQuery{PURL: "pkg:apk/alpine/musl", Version: "1.2.4-r4"}},
+		{query: "pkg:", wantErr: "invalid package URL: pkg:"},
+		{query: "", wantErr: "invalid component: . It must be a package URL or a component name, e.g. lodash@4.17.21"},
+	} {
+		suite.Run(t.query, func() {
+			actual, err := ParseComponentQuery(t.query)
+			if t.wantErr != "" {
+				require.EqualError(suite.T(), err, t.wantErr)
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+func (suite *ComponentQueryTestSuite) TestMatches() {
+	musl := Component{Name: "musl", Version: "1.2.4-r4", PURL: "pkg:apk/alpine/musl@1.2.4-r4?arch=x86_64&distro=alpine-3.19.1"}
+	babel := Component{Name: "@babel/core", Version: "7.24.0", PURL: "pkg:npm/%40babel/core@7.24.0"}
+	for _, t := range []struct {
+		query     string
+		component Component
+		want      bool
+	}{
+		{query: "musl", component: musl, want: true},
+		{query: "MUSL@1.2.4-r4", component: musl, want: true},
+		{query: "musl@1.2.3-r0", component: musl},
+		{query: "pkg:apk/alpine/musl", component: musl, want: true},
+		{query: "pkg:apk/alpine/musl@1.2.4-r4", component: musl, want: true},
+		{query: "pkg:apk/alpine/musl@1.2.3-r0", component: musl},
+		{query: "pkg:apk/wolfi/musl", component: musl},
+		{query: "pkg:npm/@babel/core@7.24.0", component: babel, want: true},
+		{query: "@babel/core", component: babel, want: true},
+		{query: "pkg:npm/@babel/core", component: Component{Name: "@babel/core"}},
+	} {
+		suite.Run(t.query, func() {
+			q, err := ParseComponentQuery(t.query)
+			require.NoError(suite.T(), err)
+			require.Equal(suite.T(), t.want, q.Matches(t.component))
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestComponentQueryTestSuite(t *testing.T) {
+	suite.Run(t, new(ComponentQueryTestSuite))
+}
@@ -0,0 +1,336 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SBOM formats
+const (
+	FormatCycloneDX = "cyclonedx"
+	FormatSPDX      = "spdx"
+)
+
+// UnknownLicense is the license of components without a license, or with a NOASSERTION license
+const UnknownLicense = "unknown"
+
+// supportedCycloneDXVersions are the CycloneDX specification versions with a JSON format
+var supportedCycloneDXVersions = []string{"1.2", "1.3", "1.4", "1.5", "1.6"}
+
+// sha256Pattern matches a sha256 digest in versions and package URLs, e.g. sha256:<hex> or sha256%3A<hex>
+var sha256Pattern = regexp.MustCompile(`(?i)sha256(?::|%3A)([a-f0-9]{64})`)
+
+// sha256HexPattern matches a bare sha256 digest, e.g. in CycloneDX hashes and SPDX checksums
+var sha256HexPattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+type Component struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Type    string `json:"type,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+type SBOMData struct {
+	SchemaVersion    int            `json:"schema_version"`
+	Format           string         `json:"format"`
+	SpecVersion      string         `json:"spec_version"`
+	Tools            []string       `json:"tools,omitempty"`
+	PrimaryComponent *Component     `json:"primary_component,omitempty"`
+	ComponentCount   int            `json:"component_count"`
+	Licenses         map[string]int `json:"licenses"`
+	Components       []Component    `json:"components"`
+	// digests are the sha256 digests of the primary component, e.g. its image manifest and config digests
+	digests []string
+}
+
+// ProcessSBOMFile validates a CycloneDX or SPDX SBOM in JSON format and summarizes its components and their licenses
+func ProcessSBOMFile(path string) (*SBOMData, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var format struct {
+		BomFormat   string `json:"bomFormat"`
+		SpdxVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(content, &format); err != nil {
+		return nil, fmt.Errorf("failed to parse SBOM file [%s]: %v", path, err)
+	}
+
+	var data *SBOMData
+	switch {
+	case format.BomFormat != "":
+		data, err = processCycloneDX(content)
+	case format.SpdxVersion != "":
+		data, err = processSPDX(content)
+	default:
+		return nil, fmt.Errorf("unsupported SBOM file [%s]. Only CycloneDX and SPDX SBOMs in JSON format are supported", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid SBOM file [%s]: %v", path, err)
+	}
+	return data, nil
+}
+
+// CheckDigest checks that the digest of the primary component of the SBOM is the fingerprint.
+// It returns false if the primary component has no sha256 digest.
+func (data *SBOMData) CheckDigest(fingerprint string) (bool, error) {
+	if len(data.digests) == 0 {
+		return false, nil
+	}
+	if slices.Contains(data.digests, fingerprint) {
+		data.PrimaryComponent.Digest = fingerprint
+		return true, nil
+	}
+	return true, fmt.Errorf("the digest of the SBOM primary component %s (sha256:%s) does not match the artifact fingerprint %s",
+		data.PrimaryComponent.Name, data.digests[0], fingerprint)
+}
+
+func newSBOMData(format, specVersion string) *SBOMData {
+	return &SBOMData{
+		SchemaVersion: 1,
+		Format:        format,
+		SpecVersion:   specVersion,
+		Licenses:      map[string]int{},
+		Components:    []Component{},
+	}
+}
+
+// setPrimaryComponent sets the primary component of the SBOM and its sha256 digests
+func (data *SBOMData) setPrimaryComponent(component *Component, digests []string) {
+	data.PrimaryComponent = component
+	for _, value := range append(digests, component.Version, component.PURL) {
+		value = strings.ToLower(value)
+		if match := sha256Pattern.FindStringSubmatch(value); match != nil {
+			value = match[1]
+		}
+		if sha256HexPattern.MatchString(value) && !slices.Contains(data.digests, value) {
+			data.digests = append(data.digests, value)
+		}
+	}
+	if len(data.digests) > 0 {
+		component.Digest = data.digests[0]
+	}
+}
+
+// addComponent adds a component of the SBOM and counts its licenses
+func (data *SBOMData) addComponent(component Component, licenses []string) {
+	data.Components = append(data.Components, component)
+	data.ComponentCount++
+	if len(licenses) == 0 {
+		data.Licenses[UnknownLicense]++
+	}
+	for _, license := range licenses {
+		data.Licenses[license]++
+	}
+}
+
+type cycloneDXBOM struct {
+	BomFormat   string `json:"bomFormat"`
+	SpecVersion string `json:"specVersion"`
+	Metadata    struct {
+		Component *cycloneDXComponent `json:"component"`
+		Tools     json.RawMessage     `json:"tools"`
+	} `json:"metadata"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	PURL    string `json:"purl"`
+	Hashes  []struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	} `json:"hashes"`
+	Licenses []struct {
+		License *struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func processCycloneDX(content []byte) (*SBOMData, error) {
+	var bom cycloneDXBOM
+	if err := json.Unmarshal(content, &bom); err != nil {
+		return nil, err
+	}
+	if bom.BomFormat != "CycloneDX" {
+		return nil, fmt.Errorf("unsupported bomFormat [%s]", bom.BomFormat)
+	}
+	if !slices.Contains(supportedCycloneDXVersions, bom.SpecVersion) {
+		return nil, fmt.Errorf("unsupported CycloneDX version [%s]. Supported versions are: %s", bom.SpecVersion, strings.Join(supportedCycloneDXVersions, ", "))
+	}
+
+	data := newSBOMData(FormatCycloneDX, bom.SpecVersion)
+	data.Tools = cycloneDXTools(bom.Metadata.Tools)
+	if c := bom.Metadata.Component; c != nil {
+		digests := []string{}
+		for _, hash := range c.Hashes {
+			if hash.Alg == "SHA-256" {
+				digests = append(digests, hash.Content)
+			}
+		}
+		data.setPrimaryComponent(&Component{Name: c.Name, Version: c.Version, Type: c.Type, PURL: c.PURL}, digests)
+	}
+	var count func(components []cycloneDXComponent)
+	count = func(components []cycloneDXComponent) {
+		for _, c := range components {
+			licenses := []string{}
+			for _, l := range c.Licenses {
+				switch {
+				case l.Expression != "":
+					licenses = append(licenses, l.Expression)
+				case l.License != nil && l.License.ID != "":
+					licenses = append(licenses, l.License.ID)
+				case l.License != nil && l.License.Name != "":
+					licenses = append(licenses, l.License.Name)
+				}
+			}
+			data.addComponent(Component{Name: c.Name, Version: c.Version, Type: c.Type, PURL: c.PURL}, licenses)
+			count(c.Components)
+		}
+	}
+	count(bom.Components)
+	return data, nil
+}
+
+// cycloneDXTools returns the tools which created a CycloneDX SBOM. Tools are a list before
+// CycloneDX 1.5, and an object with components and services since then.
+func cycloneDXTools(raw json.RawMessage) []string {
+	var tools []cycloneDXTool
+	if err := json.Unmarshal(raw, &tools); err != nil {
+		var toolsObject struct {
+			Components []cycloneDXTool `json:"components"`
+			Services   []cycloneDXTool `json:"services"`
+		}
+		if err := json.Unmarshal(raw, &toolsObject); err != nil {
+			return nil
+		}
+		tools = append(toolsObject.Components, toolsObject.Services...)
+	}
+	names := []string{}
+	for _, tool := range tools {
+		names = append(names, strings.TrimSpace(tool.Name+" "+tool.Version))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+type spdxDocument struct {
+	SpdxVersion       string   `json:"spdxVersion"`
+	SPDXID            string   `json:"SPDXID"`
+	DocumentDescribes []string `json:"documentDescribes"`
+	CreationInfo      struct {
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages []struct {
+		SPDXID           string `json:"SPDXID"`
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		PrimaryPurpose   string `json:"primaryPackagePurpose"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+		Checksums        []struct {
+			Algorithm     string `json:"algorithm"`
+			ChecksumValue string `json:"checksumValue"`
+		} `json:"checksums"`
+		ExternalRefs []spdxExternalRef `json:"externalRefs"`
+	} `json:"packages"`
+	Relationships []struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+type spdxExternalRef struct {
+	ReferenceType    string `json:"referenceType"`
+	ReferenceLocator string `json:"referenceLocator"`
+}
+
+// spdxPURL returns the package URL of a SPDX package, if it has one
+func spdxPURL(refs []spdxExternalRef) string {
+	for _, ref := range refs {
+		if ref.ReferenceType == "purl" {
+			purl, err := url.PathUnescape(ref.ReferenceLocator)
+			if err != nil {
+				return ref.ReferenceLocator
+			}
+			return purl
+		}
+	}
+	return ""
+}
+
+func processSPDX(content []byte) (*SBOMData, error) {
+	var doc spdxDocument
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.SpdxVersion, "SPDX-2.") {
+		return nil, fmt.Errorf("unsupported SPDX version [%s]. Only SPDX 2 is supported", doc.SpdxVersion)
+	}
+	if doc.SPDXID != "SPDXRef-DOCUMENT" {
+		return nil, fmt.Errorf("invalid SPDXID [%s] of the document. It must be SPDXRef-DOCUMENT", doc.SPDXID)
+	}
+
+	data := newSBOMData(FormatSPDX, strings.TrimPrefix(doc.SpdxVersion, "SPDX-"))
+	for _, creator := range doc.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, "Tool:"); ok {
+			data.Tools = append(data.Tools, strings.TrimSpace(tool))
+		}
+	}
+
+	// the primary packages are the ones described by the document
+	described := doc.DocumentDescribes
+	for _, r := range doc.Relationships {
+		if r.Element == doc.SPDXID && r.Type == "DESCRIBES" && !slices.Contains(described, r.Related) {
+			described = append(described, r.Related)
+		}
+	}
+	for _, p := range doc.Packages {
+		if slices.Contains(described, p.SPDXID) {
+			if data.PrimaryComponent == nil {
+				component := &Component{Name: p.Name, Version: p.VersionInfo, Type: strings.ToLower(p.PrimaryPurpose)}
+				digests := []string{}
+				for _, checksum := range p.Checksums {
+					if checksum.Algorithm == "SHA256" {
+						digests = append(digests, checksum.ChecksumValue)
+					}
+				}
+				component.PURL = spdxPURL(p.ExternalRefs)
+				data.setPrimaryComponent(component, digests)
+			}
+			continue
+		}
+		component := Component{Name: p.Name, Version: p.VersionInfo, Type: strings.ToLower(p.PrimaryPurpose), PURL: spdxPURL(p.ExternalRefs)}
+		license := p.LicenseConcluded
+		if license == "" || license == "NOASSERTION" {
+			license = p.LicenseDeclared
+		}
+		if license == "" || license == "NOASSERTION" {
+			data.addComponent(component, nil)
+		} else {
+			data.addComponent(component, []string{license})
+		}
+	}
+	return data, nil
+}
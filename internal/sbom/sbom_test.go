@@ -0,0 +1,182 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const fingerprint = "7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9"
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type SBOMTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SBOMTestSuite) TestProcessSBOMFile() {
+	for _, t := range []struct {
+		name        string
+		file        string
+		want        *SBOMData
+		wantDigests []string
+		wantErr     string
+	}{
+		{
+			name: "a CycloneDX SBOM of an image is summarized",
+			file: "testdata/cyclonedx.json",
+			want: &SBOMData{
+				SchemaVersion: 1,
+				Format:        FormatCycloneDX,
+				SpecVersion:   "1.5",
+				Tools:         []string{"syft 1.4.1"},
+				PrimaryComponent: &Component{
+					Name:    "ghcr.io/kosli-dev/cli",
+					Version: "sha256:" + fingerprint,
+					Type:    "container",
+					Digest:  fingerprint,
+				},
+				ComponentCount: 5,
+				Licenses:       map[string]int{"Apache-2.0": 1, "MIT": 1, "BSD-3-Clause": 2, UnknownLicense: 1},
+				Components: []Component{
+					{Name: "github.com/spf13/cobra", Version: "v1.8.0", Type: "library", PURL: "pkg:golang/github.com/spf13/cobra@v1.8.0"},
+					{Name: "github.com/stretchr/testify", Version: "v1.9.0", Type: "library", PURL: "pkg:golang/github.com/stretchr/testify@v1.9.0"},
+					{Name: "golang.org/x/crypto", Version: "v0.22.0", Type: "library", PURL: "pkg:golang/golang.org/x/crypto@v0.22.0"},
+					{Name: "golang.org/x/crypto/ssh", Version: "v0.22.0", Type: "library"},
+					{Name: "alpine", Version: "3.19.1", Type: "operating-system"},
+				},
+			},
+			wantDigests: []string{fingerprint},
+		},
+		{
+			name: "a SPDX SBOM of an image is summarized",
+			file: "testdata/spdx.json",
+			want: &SBOMData{
+				SchemaVersion: 1,
+				Format:        FormatSPDX,
+				SpecVersion:   "2.3",
+				Tools:         []string{"syft-1.4.1"},
+				PrimaryComponent: &Component{
+					Name:    "ghcr.io/kosli-dev/cli",
+					Version: "sha256:0f8a5e6c1b2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f",
+					Type:    "container",
+					PURL:    "pkg:oci/cli@sha256:" + fingerprint + "?repository_url=ghcr.io/kosli-dev/cli",
+					Digest:  "0f8a5e6c1b2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f",
+				},
+				ComponentCount: 3,
+				Licenses:       map[string]int{"Apache-2.0": 1, "MIT": 1, UnknownLicense: 1},
+				Components: []Component{
+					{Name: "github.com/spf13/cobra", Version: "v1.8.0"},
+					{Name: "github.com/stretchr/testify", Version: "v1.9.0"},
+					{Name: "musl", Version: "1.2.4_git20230717-r4", PURL: "pkg:apk/alpine/musl@1.2.4_git20230717-r4?arch=x86_64&distro=alpine-3.19.1"},
+				},
+			},
+			wantDigests: []string{"0f8a5e6c1b2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f", fingerprint},
+		},
+		{
+			name:    "files which are not JSON are rejected",
+			file:    "sbom.go",
+			wantErr: "failed to parse SBOM file [sbom.go]: invalid character 'p' looking for beginning of value",
+		},
+		{
+			name:    "JSON files which are not SBOMs are rejected",
+			file:    "../provenance/testdata/slsa-v1.json",
+			wantErr: "unsupported SBOM file [../provenance/testdata/slsa-v1.json]. Only CycloneDX and SPDX SBOMs in JSON format are supported",
+		},
+	} {
+		suite.Run(t.name, func() {
+			actual, err := ProcessSBOMFile(t.file)
+			if t.wantErr != "" {
+				require.EqualError(suite.T(), err, t.wantErr)
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.wantDigests, actual.digests)
+				actual.digests = nil
+				require.Equal(suite.T(), t.want, actual)
+			}
+		})
+	}
+}
+
+func (suite *SBOMTestSuite) TestProcessSBOMFileFailsForInvalidSBOMs() {
+	dir := suite.T().TempDir()
+	for content, wantErr := range map[string]string{
+		`{"bomFormat": "SomethingElse", "specVersion": "1.5"}`:               "unsupported bomFormat [SomethingElse]",
+		`{"bomFormat": "CycloneDX", "specVersion": "1.1"}`:                   "unsupported CycloneDX version [1.1]. Supported versions are: 1.2, 1.3, 1.4, 1.5, 1.6",
+		`{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": {}}`: "json: cannot unmarshal object into Go struct field cycloneDXBOM.components of type []sbom.cycloneDXComponent",
+		`{"spdxVersion": "SPDX-3.0", "SPDXID": "SPDXRef-DOCUMENT"}`:          "unsupported SPDX version [SPDX-3.0]. Only SPDX 2 is supported",
+		`{"spdxVersion": "SPDX-2.3", "SPDXID": "SPDXRef-Package"}`:           "invalid SPDXID [SPDXRef-Package] of the document. It must be SPDXRef-DOCUMENT",
+	} {
+		path := filepath.Join(dir, "sbom.json")
+		require.NoError(suite.T(), os.WriteFile(path, []byte(content), 0600))
+		_, err := ProcessSBOMFile(path)
+		require.EqualError(suite.T(), err, "invalid SBOM file ["+path+"]: "+wantErr)
+	}
+}
+
+func (suite *SBOMTestSuite) TestCheckDigest() {
+	otherFingerprint := "0000000000000000000000000000000000000000000000000000000000000000"
+	for _, t := range []struct {
+		name        string
+		file        string
+		fingerprint string
+		wantChecked bool
+		wantDigest  string
+		wantErr     string
+	}{
+		{
+			name:        "the primary component digest of a CycloneDX SBOM matches the fingerprint",
+			file:        "testdata/cyclonedx.json",
+			fingerprint: fingerprint,
+			wantChecked: true,
+			wantDigest:  fingerprint,
+		},
+		{
+			name:        "the primary component purl digest of a SPDX SBOM matches the fingerprint",
+			file:        "testdata/spdx.json",
+			fingerprint: fingerprint,
+			wantChecked: true,
+			wantDigest:  fingerprint,
+		},
+		{
+			name:        "a different fingerprint does not match",
+			file:        "testdata/cyclonedx.json",
+			fingerprint: otherFingerprint,
+			wantChecked: true,
+			wantErr:     "the digest of the SBOM primary component ghcr.io/kosli-dev/cli (sha256:" + fingerprint + ") does not match the artifact fingerprint " + otherFingerprint,
+		},
+	} {
+		suite.Run(t.name, func() {
+			data, err := ProcessSBOMFile(t.file)
+			require.NoError(suite.T(), err)
+			checked, err := data.CheckDigest(t.fingerprint)
+			require.Equal(suite.T(), t.wantChecked, checked)
+			if t.wantErr != "" {
+				require.EqualError(suite.T(), err, t.wantErr)
+			} else {
+				require.NoError(suite.T(), err)
+				require.Equal(suite.T(), t.wantDigest, data.PrimaryComponent.Digest)
+			}
+		})
+	}
+}
+
+func (suite *SBOMTestSuite) TestCheckDigestWithoutPrimaryComponentDigest() {
+	path := filepath.Join(suite.T().TempDir(), "sbom.json")
+	require.NoError(suite.T(), os.WriteFile(path, []byte(`{"bomFormat": "CycloneDX", "specVersion": "1.4"}`), 0600))
+	data, err := ProcessSBOMFile(path)
+	require.NoError(suite.T(), err)
+	checked, err := data.CheckDigest(fingerprint)
+	require.False(suite.T(), checked)
+	require.NoError(suite.T(), err)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSBOMTestSuite(t *testing.T) {
+	suite.Run(t, new(SBOMTestSuite))
+}
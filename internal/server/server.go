@@ -37,7 +37,8 @@ type PathsSpec struct {
 // CreateServerArtifactsData creates a list of ServerData for server artifacts at given paths
 // and excludePaths can contain Glob patterns
 // if paths have Glob patterns, each path matching the pattern will be treated as an artifact
-func CreateServerArtifactsData(paths, excludePaths []string, logger *logger.Logger) ([]*ServerData, error) {
+// cache is used to skip hashing unchanged files and can be nil
+func CreateServerArtifactsData(paths, excludePaths []string, cache *digest.FileDigestCache, logger *logger.Logger) ([]*ServerData, error) {
 	result := []*ServerData{}
 
 	pathsToInclude := []string{}
@@ -54,7 +55,7 @@ func CreateServerArtifactsData(paths, excludePaths []string, logger *logger.Logg
 	}
 
 	for _, p := range pathsToInclude {
-		data, err := getArtifactDataForPath(p, "", excludePaths, logger)
+		data, err := getArtifactDataForPath(p, "", excludePaths, cache, logger)
 		if err != nil {
 			return result, err
 		}
@@ -66,7 +67,7 @@ func CreateServerArtifactsData(paths, excludePaths []string, logger *logger.Logg
 // getArtifactDataForPath calculates the artifact fingerprint for path (while excluding excludePaths)
 // and returns a ServerData object.
 // If artifactName is empty, it is defaulted to the absolute path of the artifact path
-func getArtifactDataForPath(path, artifactName string, excludePaths []string, logger *logger.Logger) (*ServerData, error) {
+func getArtifactDataForPath(path, artifactName string, excludePaths []string, cache *digest.FileDigestCache, logger *logger.Logger) (*ServerData, error) {
 	data := &ServerData{}
 	digests := make(map[string]string)
 
@@ -87,9 +88,9 @@ func getArtifactDataForPath(path, artifactName string, excludePaths []string, lo
 		if utils.Contains(excludePaths, path) {
 			return data, fmt.Errorf("path [%s] is both included and excluded", path)
 		}
-		fingerprint, err = digest.FileSha256(path)
+		fingerprint, err = cache.FileSha256(path)
 	} else {
-		fingerprint, err = digest.DirSha256(path, excludePaths, logger)
+		fingerprint, err = digest.DirSha256WithCache(path, excludePaths, cache, logger)
 	}
 
 	if err != nil {
@@ -117,11 +118,12 @@ func getPathLastModifiedTimestamp(path string) (int64, error) {
 }
 
 // CreatePathsArtifactsData creates a list of ServerData for artifacts as defined in a pathSpecFile
-func CreatePathsArtifactsData(ps *PathsSpec, logger *logger.Logger) ([]*ServerData, error) {
+// cache is used to skip hashing unchanged files and can be nil
+func CreatePathsArtifactsData(ps *PathsSpec, cache *digest.FileDigestCache, logger *logger.Logger) ([]*ServerData, error) {
 	result := []*ServerData{}
 	for artifactName, pathSpec := range ps.Artifacts {
 		logger.Debug("fingerprinting artifact [%s] with spec [ Include: %s, Exclude: %s]", artifactName, pathSpec.Path, pathSpec.Exclude)
-		data, err := getArtifactDataForPath(pathSpec.Path, artifactName, pathSpec.Exclude, logger)
+		data, err := getArtifactDataForPath(pathSpec.Path, artifactName, pathSpec.Exclude, cache, logger)
 		if err != nil {
 			return result, fmt.Errorf("failed to calculate fingerprint for artifact [%s]: %v", artifactName, err)
 		}
@@ -0,0 +1,198 @@
+package signature
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// sigstoreBundle is the JSON encoding of a sigstore bundle, as written by
+// 'cosign sign-blob --bundle', 'cosign attest-blob --bundle' or GitHub artifact attestations
+type sigstoreBundle struct {
+	VerificationMaterial struct {
+		X509CertificateChain *struct {
+			Certificates []bundleCertificate `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		Certificate *bundleCertificate `json:"certificate"`
+		TlogEntries []bundleTlogEntry  `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	MessageSignature *struct {
+		MessageDigest struct {
+			Algorithm string `json:"algorithm"`
+			Digest    string `json:"digest"`
+		} `json:"messageDigest"`
+		Signature string `json:"signature"`
+	} `json:"messageSignature"`
+	DsseEnvelope *dsseEnvelope `json:"dsseEnvelope"`
+}
+
+type bundleCertificate struct {
+	RawBytes string `json:"rawBytes"`
+}
+
+// bundleTlogEntry is a transparency log entry of a sigstore bundle, where
+// 64-bit integers are encoded as strings
+type bundleTlogEntry struct {
+	LogIndex json.Number `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime   json.Number `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+}
+
+// dsseEnvelope is a DSSE envelope, as stored in sigstore bundles and cosign attestation layers
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// VerifyBundleFiles reads the signatures and attestations of an artifact from sigstore bundle files
+// and verifies them offline
+func VerifyBundleFiles(paths []string, fingerprint string, opts *VerifyOptions) (*SignatureData, error) {
+	items := []*signedItem{}
+	for _, path := range paths {
+		bundleItems, err := readBundleFile(path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, bundleItems...)
+	}
+	return verifyItems(items, fingerprint, opts), nil
+}
+
+// readBundleFile reads the signed items of a sigstore bundle file
+func readBundleFile(path string) ([]*signedItem, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var bundle sigstoreBundle
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse sigstore bundle %s: %v", path, err)
+	}
+	items, err := bundle.items(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sigstore bundle %s: %v", path, err)
+	}
+	return items, nil
+}
+
+// items returns the signed items of the bundle, sharing its verification material
+func (bundle *sigstoreBundle) items(source string) ([]*signedItem, error) {
+	material := signedItem{source: source}
+
+	var rawCertificates []bundleCertificate
+	if bundle.VerificationMaterial.Certificate != nil {
+		rawCertificates = []bundleCertificate{*bundle.VerificationMaterial.Certificate}
+	} else if bundle.VerificationMaterial.X509CertificateChain != nil {
+		rawCertificates = bundle.VerificationMaterial.X509CertificateChain.Certificates
+	}
+	for i, raw := range rawCertificates {
+		der, err := base64.StdEncoding.DecodeString(raw.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+		if i == 0 {
+			material.certificate = certificate
+		} else {
+			material.intermediates = append(material.intermediates, certificate)
+		}
+	}
+
+	if len(bundle.VerificationMaterial.TlogEntries) > 0 {
+		entry, err := bundle.VerificationMaterial.TlogEntries[0].tlogEntry()
+		if err != nil {
+			return nil, fmt.Errorf("invalid transparency log entry: %v", err)
+		}
+		material.tlogEntry = entry
+	}
+
+	switch {
+	case bundle.MessageSignature != nil:
+		if bundle.MessageSignature.MessageDigest.Algorithm != "SHA2_256" {
+			return nil, fmt.Errorf("unsupported message digest algorithm %s", bundle.MessageSignature.MessageDigest.Algorithm)
+		}
+		item := material
+		item.kind = KindSignature
+		var err error
+		if item.messageDigest, err = base64.StdEncoding.DecodeString(bundle.MessageSignature.MessageDigest.Digest); err != nil {
+			return nil, fmt.Errorf("invalid message digest: %v", err)
+		}
+		if item.signature, err = base64.StdEncoding.DecodeString(bundle.MessageSignature.Signature); err != nil {
+			return nil, fmt.Errorf("invalid signature: %v", err)
+		}
+		return []*signedItem{&item}, nil
+	case bundle.DsseEnvelope != nil:
+		return bundle.DsseEnvelope.items(material)
+	default:
+		return nil, errors.New("no message signature nor DSSE envelope found")
+	}
+}
+
+// items returns a signed item per signature of the envelope, with the verification material
+func (envelope *dsseEnvelope) items(material signedItem) ([]*signedItem, error) {
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSSE payload: %v", err)
+	}
+	if len(envelope.Signatures) == 0 {
+		return nil, errors.New("no signature found in DSSE envelope")
+	}
+	items := []*signedItem{}
+	for _, s := range envelope.Signatures {
+		item := material
+		item.kind = KindAttestation
+		item.payload = payload
+		item.payloadType = envelope.PayloadType
+		if item.signature, err = base64.StdEncoding.DecodeString(s.Sig); err != nil {
+			return nil, fmt.Errorf("invalid DSSE signature: %v", err)
+		}
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+// tlogEntry returns the transparency log entry of a sigstore bundle
+func (entry bundleTlogEntry) tlogEntry() (*tlogEntry, error) {
+	logIndex, err := entry.LogIndex.Int64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid log index: %v", err)
+	}
+	integratedTime, err := entry.IntegratedTime.Int64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid integrated time: %v", err)
+	}
+	logID, err := base64.StdEncoding.DecodeString(entry.LogID.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log id: %v", err)
+	}
+	result := &tlogEntry{
+		body:           entry.CanonicalizedBody,
+		integratedTime: integratedTime,
+		logIndex:       logIndex,
+		logID:          hex.EncodeToString(logID),
+	}
+	if entry.InclusionPromise != nil {
+		result.signedEntryTimestamp, err = base64.StdEncoding.DecodeString(entry.InclusionPromise.SignedEntryTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signed entry timestamp: %v", err)
+		}
+	}
+	return result, nil
+}
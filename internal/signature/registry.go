@@ -0,0 +1,184 @@
+package signature
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+)
+
+// Annotations of the layers of cosign signature and attestation manifests
+const (
+	cosignSignatureAnnotation   = "dev.cosignproject.cosign/signature"
+	cosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+	cosignChainAnnotation       = "dev.sigstore.cosign/chain"
+	cosignBundleAnnotation      = "dev.sigstore.cosign/bundle"
+)
+
+// dsseEnvelopeMediaType is the media type of the layers of cosign attestation manifests
+const dsseEnvelopeMediaType = "application/vnd.dsse.envelope.v1+json"
+
+// maxLayerSize is the maximum size of a layer of a cosign signature or attestation manifest
+const maxLayerSize = 32 << 20
+
+// cosignRekorBundle is the Rekor bundle annotation of cosign signature layers
+type cosignRekorBundle struct {
+	SignedEntryTimestamp string `json:"SignedEntryTimestamp"`
+	Payload              struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+	} `json:"Payload"`
+}
+
+// VerifyImageSignatures fetches the cosign signatures and attestations of an image from its registry
+// and verifies them offline. Cosign stores them in the image repository with the tags
+// sha256-<fingerprint>.sig and sha256-<fingerprint>.att
+func VerifyImageSignatures(imageName, fingerprint string, sysCtx *types.SystemContext, opts *VerifyOptions) (*SignatureData, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image reference for %s: %w", imageName, err)
+	}
+	items := []*signedItem{}
+	for _, tag := range []struct {
+		suffix string
+		kind   string
+	}{{".sig", KindSignature}, {".att", KindAttestation}} {
+		tagged := fmt.Sprintf("%s:sha256-%s%s", named.Name(), fingerprint, tag.suffix)
+		tagItems, err := fetchCosignItems(tagged, tag.kind, sysCtx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, tagItems...)
+	}
+	return verifyItems(items, fingerprint, opts), nil
+}
+
+// fetchCosignItems fetches the signed items of a cosign signature or attestation manifest.
+// It returns no items if the manifest does not exist.
+func fetchCosignItems(tagged, kind string, sysCtx *types.SystemContext) ([]*signedItem, error) {
+	ctx := context.Background()
+	ref, err := docker.ParseReference("//" + tagged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image reference for %s: %w", tagged, err)
+	}
+	// the image source reads the manifest when created
+	source, err := ref.NewImageSource(ctx, sysCtx)
+	if isNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get manifest for %s: %w", tagged, err)
+	}
+	defer source.Close()
+
+	blob, mimeType, err := source.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest for %s: %w", tagged, err)
+	}
+	m, err := manifest.FromBlob(blob, manifest.NormalizedMIMEType(mimeType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest for %s: %w", tagged, err)
+	}
+
+	items := []*signedItem{}
+	for _, layer := range m.LayerInfos() {
+		content, err := fetchLayer(ctx, source, layer.BlobInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get layer %s of %s: %w", layer.Digest, tagged, err)
+		}
+		layerItems, err := cosignLayerItems(tagged, kind, layer.BlobInfo, content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer %s of %s: %w", layer.Digest, tagged, err)
+		}
+		items = append(items, layerItems...)
+	}
+	return items, nil
+}
+
+// fetchLayer returns the content of a layer, checking its digest
+func fetchLayer(ctx context.Context, source types.ImageSource, info types.BlobInfo) ([]byte, error) {
+	reader, _, err := source.GetBlob(ctx, info, none.NoCache)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(io.LimitReader(reader, maxLayerSize))
+	if err != nil {
+		return nil, err
+	}
+	if info.Digest.Validate() != nil || info.Digest.Algorithm().FromBytes(content) != info.Digest {
+		return nil, errors.New("the layer content does not match its digest")
+	}
+	return content, nil
+}
+
+// cosignLayerItems returns the signed items of a layer of a cosign signature or attestation manifest
+func cosignLayerItems(source, kind string, layer types.BlobInfo, content []byte) ([]*signedItem, error) {
+	material := signedItem{source: source}
+	if certificate := layer.Annotations[cosignCertificateAnnotation]; certificate != "" {
+		certificates, err := parsePEMCertificates([]byte(certificate))
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+		material.certificate = certificates[0]
+	}
+	if chain := layer.Annotations[cosignChainAnnotation]; chain != "" {
+		certificates, err := parsePEMCertificates([]byte(chain))
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate chain: %v", err)
+		}
+		material.intermediates = certificates
+	}
+	if bundle := layer.Annotations[cosignBundleAnnotation]; bundle != "" {
+		var rekorBundle cosignRekorBundle
+		if err := json.Unmarshal([]byte(bundle), &rekorBundle); err != nil {
+			return nil, fmt.Errorf("invalid Rekor bundle: %v", err)
+		}
+		set, err := base64.StdEncoding.DecodeString(rekorBundle.SignedEntryTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signed entry timestamp: %v", err)
+		}
+		material.tlogEntry = &tlogEntry{
+			body:                 rekorBundle.Payload.Body,
+			integratedTime:       rekorBundle.Payload.IntegratedTime,
+			logIndex:             rekorBundle.Payload.LogIndex,
+			logID:                rekorBundle.Payload.LogID,
+			signedEntryTimestamp: set,
+		}
+	}
+
+	if kind == KindAttestation || layer.MediaType == dsseEnvelopeMediaType {
+		var envelope dsseEnvelope
+		if err := json.Unmarshal(content, &envelope); err != nil {
+			return nil, fmt.Errorf("invalid DSSE envelope: %v", err)
+		}
+		return envelope.items(material)
+	}
+
+	item := material
+	item.kind = KindSignature
+	item.payload = content
+	signature, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+	if err != nil || len(signature) == 0 {
+		return nil, errors.New("no valid signature annotation found")
+	}
+	item.signature = signature
+	return []*signedItem{&item}, nil
+}
+
+// isNotFound returns true if a registry error is that a manifest or repository does not exist
+func isNotFound(err error) bool {
+	var registryErr errcode.ErrorCoder
+	return errors.As(err, &registryErr) && registryErr.ErrorCode().Descriptor().HTTPStatusCode == http.StatusNotFound
+}
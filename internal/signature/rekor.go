@@ -0,0 +1,104 @@
+package signature
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// tlogEntry is a Rekor transparency log entry of a signature, with its signed entry timestamp
+type tlogEntry struct {
+	// body is the base64 encoded canonicalized body of the entry
+	body           string
+	integratedTime int64
+	logIndex       int64
+	// logID is the hex encoded sha256 digest of the public key of the log
+	logID                string
+	signedEntryTimestamp []byte
+}
+
+// rekorEntryBody is the part of the body of the hashedrekord, dsse and intoto log entries holding signatures
+type rekorEntryBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		// hashedrekord
+		Signature struct {
+			Content string `json:"content"`
+		} `json:"signature"`
+		// dsse
+		Signatures []struct {
+			Signature string `json:"signature"`
+		} `json:"signatures"`
+		// intoto
+		Content struct {
+			Envelope struct {
+				Signatures []struct {
+					Sig string `json:"sig"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+		} `json:"content"`
+	} `json:"spec"`
+}
+
+// verify verifies the signed entry timestamp of the entry with the public key of the log
+// and checks that the entry logs the signature. It returns the time the entry was logged.
+func (entry *tlogEntry) verify(rekorPublicKey crypto.PublicKey, signature []byte) (time.Time, error) {
+	if len(entry.signedEntryTimestamp) == 0 {
+		return time.Time{}, errors.New("no signed entry timestamp")
+	}
+	// the signed entry timestamp signs the canonical JSON of the entry (with sorted keys)
+	payload, err := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{entry.body, entry.integratedTime, entry.logID, entry.logIndex})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := verifySignature(rekorPublicKey, payload, entry.signedEntryTimestamp, false); err != nil {
+		return time.Time{}, fmt.Errorf("signed entry timestamp: %v", err)
+	}
+	if err := entry.checkBody(signature); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(entry.integratedTime, 0), nil
+}
+
+// checkBody checks that the body of the entry holds the signature
+func (entry *tlogEntry) checkBody(signature []byte) error {
+	decoded, err := base64.StdEncoding.DecodeString(entry.body)
+	if err != nil {
+		return fmt.Errorf("invalid body: %v", err)
+	}
+	var body rekorEntryBody
+	if err := json.Unmarshal(decoded, &body); err != nil {
+		return fmt.Errorf("invalid body: %v", err)
+	}
+	logged := []string{}
+	switch body.Kind {
+	case "hashedrekord":
+		logged = append(logged, body.Spec.Signature.Content)
+	case "dsse":
+		for _, s := range body.Spec.Signatures {
+			logged = append(logged, s.Signature)
+		}
+	case "intoto":
+		for _, s := range body.Spec.Content.Envelope.Signatures {
+			logged = append(logged, s.Sig)
+		}
+	default:
+		return fmt.Errorf("unsupported entry kind %s", body.Kind)
+	}
+	encoded := base64.StdEncoding.EncodeToString(signature)
+	for _, value := range logged {
+		// intoto entries hold the base64 encoded envelope signatures encoded again
+		if twice, err := base64.StdEncoding.DecodeString(value); value == encoded || (err == nil && string(twice) == encoded) {
+			return nil
+		}
+	}
+	return errors.New("the entry does not log the signature")
+}
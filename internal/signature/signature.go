@@ -0,0 +1,382 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Kinds of verified signatures
+const (
+	// KindSignature is a cosign signature of an image, or a sigstore message signature of an artifact
+	KindSignature = "signature"
+	// KindAttestation is a signed in-toto attestation (a DSSE envelope) about an artifact
+	KindAttestation = "attestation"
+)
+
+// dssePayloadType is the DSSE payload type of in-toto statements
+const dssePayloadType = "application/vnd.in-toto+json"
+
+var (
+	// oidIssuerV1 is the Fulcio certificate extension holding the OIDC issuer as raw bytes
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	// oidIssuerV2 is the Fulcio certificate extension holding the OIDC issuer as a DER UTF8String
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Signature is the verification result of a signature, or of a signed in-toto attestation, of an artifact
+type Signature struct {
+	Kind           string `json:"kind"`
+	Source         string `json:"source"`
+	PredicateType  string `json:"predicate_type,omitempty"`
+	SignerIdentity string `json:"signer_identity,omitempty"`
+	Issuer         string `json:"issuer,omitempty"`
+	TlogIndex      *int64 `json:"tlog_index,omitempty"`
+	IntegratedTime string `json:"tlog_integrated_time,omitempty"`
+	Verified       bool   `json:"verified"`
+	Error          string `json:"error,omitempty"`
+}
+
+// SignatureData is the summary of the verified signatures and attestations of an artifact
+type SignatureData struct {
+	SchemaVersion        int          `json:"schema_version"`
+	Fingerprint          string       `json:"fingerprint"`
+	VerifiedSignatures   int          `json:"verified_signatures_count"`
+	VerifiedAttestations int          `json:"verified_attestations_count"`
+	Signatures           []*Signature `json:"signatures"`
+}
+
+// Verified returns true if at least one signature or attestation of the artifact is verified
+func (data *SignatureData) Verified() bool {
+	return data.VerifiedSignatures+data.VerifiedAttestations > 0
+}
+
+// VerifyOptions holds the trusted material to verify signatures with
+type VerifyOptions struct {
+	// PublicKey verifies signatures made with a key pair (e.g. cosign sign --key)
+	PublicKey crypto.PublicKey
+	// Roots and Intermediates verify the certificates of keyless signatures (e.g. the Fulcio certificate chain)
+	Roots         *x509.CertPool
+	Intermediates *x509.CertPool
+	// RekorPublicKey verifies the signed entry timestamps of the transparency log entries of signatures
+	RekorPublicKey crypto.PublicKey
+	// CertificateIdentity and CertificateOIDCIssuer are the expected signer of keyless signatures
+	CertificateIdentity   string
+	CertificateOIDCIssuer string
+}
+
+// signedItem is a signature of an artifact, with the material to verify it
+type signedItem struct {
+	kind   string
+	source string
+	// payload is the signed payload: a simple signing payload for image signatures,
+	// or an in-toto statement for attestations
+	payload []byte
+	// payloadType is the DSSE payload type of attestations, which are signed using the DSSE PAE encoding
+	payloadType string
+	// messageDigest is the signed sha256 digest of message signatures, which have no payload
+	messageDigest []byte
+	signature     []byte
+	certificate   *x509.Certificate
+	intermediates []*x509.Certificate
+	tlogEntry     *tlogEntry
+}
+
+// simpleSigningPayload is the part of the payload of cosign image signatures binding them to an image
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// inTotoStatement is the part of an in-toto statement binding it to its subjects
+type inTotoStatement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+// LoadPublicKey loads a PEM encoded public key (e.g. cosign.pub) from a file
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded public key found in %s", path)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key in %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// LoadCertificateChain loads PEM encoded certificates from a file, returning the self-signed
+// certificates as roots and the others as intermediates
+func LoadCertificateChain(path string) (*x509.CertPool, *x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificates, err := parsePEMCertificates(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse certificates in %s: %v", path, err)
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	rootsCount := 0
+	for _, certificate := range certificates {
+		if certificate.CheckSignatureFrom(certificate) == nil {
+			roots.AddCert(certificate)
+			rootsCount++
+		} else {
+			intermediates.AddCert(certificate)
+		}
+	}
+	if rootsCount == 0 {
+		return nil, nil, fmt.Errorf("no root certificate found in %s", path)
+	}
+	return roots, intermediates, nil
+}
+
+// parsePEMCertificates parses all the certificates of PEM content
+func parsePEMCertificates(content []byte) ([]*x509.Certificate, error) {
+	certificates := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return certificates, nil
+}
+
+// verifyItems verifies signed items of an artifact and summarizes the results
+func verifyItems(items []*signedItem, fingerprint string, opts *VerifyOptions) *SignatureData {
+	data := &SignatureData{
+		SchemaVersion: 1,
+		Fingerprint:   fingerprint,
+		Signatures:    []*Signature{},
+	}
+	for _, item := range items {
+		result := item.verify(fingerprint, opts)
+		if result.Verified {
+			if result.Kind == KindAttestation {
+				data.VerifiedAttestations++
+			} else {
+				data.VerifiedSignatures++
+			}
+		}
+		data.Signatures = append(data.Signatures, result)
+	}
+	return data
+}
+
+// verify verifies a signed item of an artifact. Verification failures are reported in the result.
+func (item *signedItem) verify(fingerprint string, opts *VerifyOptions) *Signature {
+	result := &Signature{Kind: item.kind, Source: item.source}
+	if err := item.check(result, fingerprint, opts); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Verified = true
+	}
+	return result
+}
+
+func (item *signedItem) check(result *Signature, fingerprint string, opts *VerifyOptions) error {
+	if err := item.checkSubject(result, fingerprint); err != nil {
+		return err
+	}
+
+	var integratedTime time.Time
+	if opts.RekorPublicKey != nil {
+		if item.tlogEntry == nil {
+			return errors.New("no transparency log entry found for the signature")
+		}
+		var err error
+		integratedTime, err = item.tlogEntry.verify(opts.RekorPublicKey, item.signature)
+		if err != nil {
+			return fmt.Errorf("invalid transparency log entry: %v", err)
+		}
+		result.TlogIndex = &item.tlogEntry.logIndex
+		result.IntegratedTime = integratedTime.UTC().Format(time.RFC3339)
+	}
+
+	var publicKey crypto.PublicKey
+	if item.certificate != nil {
+		result.SignerIdentity, result.Issuer = certificateIdentity(item.certificate)
+		if opts.Roots == nil {
+			return errors.New("a certificate chain is required to verify keyless signatures")
+		}
+		if integratedTime.IsZero() {
+			return errors.New("a verified transparency log entry is required to verify keyless signatures")
+		}
+		if err := item.verifyCertificate(opts, integratedTime); err != nil {
+			return fmt.Errorf("invalid signing certificate: %v", err)
+		}
+		if opts.CertificateIdentity != "" && result.SignerIdentity != opts.CertificateIdentity {
+			return fmt.Errorf("signer identity %s does not match the expected identity %s", result.SignerIdentity, opts.CertificateIdentity)
+		}
+		if opts.CertificateOIDCIssuer != "" && result.Issuer != opts.CertificateOIDCIssuer {
+			return fmt.Errorf("issuer %s does not match the expected issuer %s", result.Issuer, opts.CertificateOIDCIssuer)
+		}
+		publicKey = item.certificate.PublicKey
+	} else if opts.PublicKey != nil {
+		publicKey = opts.PublicKey
+	} else {
+		return errors.New("a public key is required to verify signatures without a certificate")
+	}
+
+	return verifySignature(publicKey, item.signedMessage(), item.signature, item.messageDigest != nil)
+}
+
+// checkSubject checks that the signed item is about the artifact with the fingerprint
+func (item *signedItem) checkSubject(result *Signature, fingerprint string) error {
+	switch {
+	case item.messageDigest != nil:
+		if hex.EncodeToString(item.messageDigest) != fingerprint {
+			return fmt.Errorf("the signature is for sha256:%x, not for the artifact", item.messageDigest)
+		}
+	case item.payloadType != "":
+		if item.payloadType != dssePayloadType {
+			return fmt.Errorf("unsupported attestation payload type %s", item.payloadType)
+		}
+		var statement inTotoStatement
+		if err := json.Unmarshal(item.payload, &statement); err != nil {
+			return fmt.Errorf("invalid in-toto statement: %v", err)
+		}
+		result.PredicateType = statement.PredicateType
+		for _, subject := range statement.Subject {
+			if subject.Digest["sha256"] == fingerprint {
+				return nil
+			}
+		}
+		return errors.New("the attestation subjects do not include the artifact")
+	default:
+		var payload simpleSigningPayload
+		if err := json.Unmarshal(item.payload, &payload); err != nil {
+			return fmt.Errorf("invalid signature payload: %v", err)
+		}
+		if payload.Critical.Image.DockerManifestDigest != "sha256:"+fingerprint {
+			return fmt.Errorf("the signature is for %s, not for the artifact", payload.Critical.Image.DockerManifestDigest)
+		}
+	}
+	return nil
+}
+
+// signedMessage returns the message signed by the signature of the item
+func (item *signedItem) signedMessage() []byte {
+	switch {
+	case item.messageDigest != nil:
+		return item.messageDigest
+	case item.payloadType != "":
+		return dssePAE(item.payloadType, item.payload)
+	default:
+		return item.payload
+	}
+}
+
+// verifyCertificate verifies the certificate chain of the item at the time it was logged
+func (item *signedItem) verifyCertificate(opts *VerifyOptions, at time.Time) error {
+	intermediates := x509.NewCertPool()
+	if opts.Intermediates != nil {
+		intermediates = opts.Intermediates.Clone()
+	}
+	for _, certificate := range item.intermediates {
+		intermediates.AddCert(certificate)
+	}
+	_, err := item.certificate.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	return err
+}
+
+// certificateIdentity returns the signer identity (the email or URI subject alternative name)
+// and the OIDC issuer of a Fulcio certificate
+func certificateIdentity(certificate *x509.Certificate) (string, string) {
+	identity := ""
+	if len(certificate.EmailAddresses) > 0 {
+		identity = certificate.EmailAddresses[0]
+	} else if len(certificate.URIs) > 0 {
+		identity = certificate.URIs[0].String()
+	}
+	issuerV1, issuerV2 := "", ""
+	for _, extension := range certificate.Extensions {
+		switch {
+		case extension.Id.Equal(oidIssuerV2):
+			_, _ = asn1.Unmarshal(extension.Value, &issuerV2)
+		case extension.Id.Equal(oidIssuerV1):
+			issuerV1 = string(extension.Value)
+		}
+	}
+	if issuerV2 != "" {
+		return identity, issuerV2
+	}
+	return identity, issuerV1
+}
+
+// dssePAE returns the DSSE pre-authentication encoding of a payload, which is what DSSE signatures sign
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// verifySignature verifies a signature of a message, or of a sha256 digest if prehashed,
+// with an ECDSA, RSA or ed25519 public key
+func verifySignature(publicKey crypto.PublicKey, message, signature []byte, prehashed bool) error {
+	digest := message
+	if !prehashed {
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	}
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature); err != nil {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if prehashed {
+			return errors.New("ed25519 keys cannot verify signatures of message digests")
+		}
+		if !ed25519.Verify(key, message, signature) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
@@ -0,0 +1,518 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	signerEmail  = "dev@example.com"
+	signerIssuer = "https://token.actions.example.com"
+	logIndex     = int64(42)
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type SignatureTestSuite struct {
+	suite.Suite
+	fingerprint    string
+	signingKey     *ecdsa.PrivateKey
+	rekorKey       *ecdsa.PrivateKey
+	fulcioKey      *ecdsa.PrivateKey
+	rootCert       *x509.Certificate
+	leafKey        *ecdsa.PrivateKey
+	leafCert       *x509.Certificate
+	integratedTime int64
+}
+
+func (suite *SignatureTestSuite) SetupSuite() {
+	digest := sha256.Sum256([]byte("the artifact"))
+	suite.fingerprint = hex.EncodeToString(digest[:])
+	suite.signingKey = suite.newKey()
+	suite.rekorKey = suite.newKey()
+	suite.fulcioKey = suite.newKey()
+	suite.leafKey = suite.newKey()
+	now := time.Now()
+	suite.integratedTime = now.Unix()
+
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	suite.rootCert = suite.createCertificate(rootTemplate, rootTemplate, &suite.fulcioKey.PublicKey)
+
+	issuer, err := asn1.MarshalWithParams(signerIssuer, "utf8")
+	require.NoError(suite.T(), err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       now.Add(-time.Minute),
+		NotAfter:        now.Add(10 * time.Minute),
+		EmailAddresses:  []string{signerEmail},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+	}
+	suite.leafCert = suite.createCertificate(leafTemplate, suite.rootCert, &suite.leafKey.PublicKey)
+}
+
+func (suite *SignatureTestSuite) newKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(suite.T(), err)
+	return key
+}
+
+func (suite *SignatureTestSuite) createCertificate(template, parent *x509.Certificate, publicKey *ecdsa.PublicKey) *x509.Certificate {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, suite.fulcioKey)
+	require.NoError(suite.T(), err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(suite.T(), err)
+	return certificate
+}
+
+func (suite *SignatureTestSuite) sign(key *ecdsa.PrivateKey, message []byte, prehashed bool) []byte {
+	digest := message
+	if !prehashed {
+		sum := sha256.Sum256(message)
+		digest = sum[:]
+	}
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest)
+	require.NoError(suite.T(), err)
+	return signature
+}
+
+// tlogEntry returns a hashedrekord entry of a signature, signed by the test Rekor key
+func (suite *SignatureTestSuite) tlogEntry(signature []byte) *tlogEntry {
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]any{
+			"signature": map[string]any{"content": base64.StdEncoding.EncodeToString(signature)},
+		},
+	})
+	require.NoError(suite.T(), err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&suite.rekorKey.PublicKey)
+	require.NoError(suite.T(), err)
+	logID := sha256.Sum256(publicKey)
+	entry := &tlogEntry{
+		body:           base64.StdEncoding.EncodeToString(body),
+		integratedTime: suite.integratedTime,
+		logIndex:       logIndex,
+		logID:          hex.EncodeToString(logID[:]),
+	}
+	payload := fmt.Sprintf(`{"body":%q,"integratedTime":%d,"logID":%q,"logIndex":%d}`, entry.body, entry.integratedTime, entry.logID, entry.logIndex)
+	entry.signedEntryTimestamp = suite.sign(suite.rekorKey, []byte(payload), false)
+	return entry
+}
+
+// writeBundle writes a sigstore bundle with a signature of the message digest, or with a DSSE envelope
+// of the statement, signed with the key and its certificate if not nil
+func (suite *SignatureTestSuite) writeBundle(key *ecdsa.PrivateKey, certificate *x509.Certificate, messageDigest []byte, statement []byte) string {
+	var signature []byte
+	bundle := map[string]any{"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.2"}
+	if statement != nil {
+		signature = suite.sign(key, dssePAE(dssePayloadType, statement), false)
+		bundle["dsseEnvelope"] = map[string]any{
+			"payloadType": dssePayloadType,
+			"payload":     base64.StdEncoding.EncodeToString(statement),
+			"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(signature)}},
+		}
+	} else {
+		signature = suite.sign(key, messageDigest, true)
+		bundle["messageSignature"] = map[string]any{
+			"messageDigest": map[string]string{"algorithm": "SHA2_256", "digest": base64.StdEncoding.EncodeToString(messageDigest)},
+			"signature":     base64.StdEncoding.EncodeToString(signature),
+		}
+	}
+	entry := suite.tlogEntry(signature)
+	logID, _ := hex.DecodeString(entry.logID)
+	material := map[string]any{
+		"tlogEntries": []map[string]any{{
+			"logIndex":          strconv.FormatInt(entry.logIndex, 10),
+			"logId":             map[string]string{"keyId": base64.StdEncoding.EncodeToString(logID)},
+			"kindVersion":       map[string]string{"kind": "hashedrekord", "version": "0.0.1"},
+			"integratedTime":    strconv.FormatInt(entry.integratedTime, 10),
+			"inclusionPromise":  map[string]string{"signedEntryTimestamp": base64.StdEncoding.EncodeToString(entry.signedEntryTimestamp)},
+			"canonicalizedBody": entry.body,
+		}},
+	}
+	if certificate != nil {
+		material["x509CertificateChain"] = map[string]any{
+			"certificates": []map[string]string{{"rawBytes": base64.StdEncoding.EncodeToString(certificate.Raw)}},
+		}
+	}
+	bundle["verificationMaterial"] = material
+
+	content, err := json.Marshal(bundle)
+	require.NoError(suite.T(), err)
+	path := filepath.Join(suite.T().TempDir(), "artifact.sigstore.json")
+	require.NoError(suite.T(), os.WriteFile(path, content, 0600))
+	return path
+}
+
+func (suite *SignatureTestSuite) statement(fingerprint string) []byte {
+	return []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"app","digest":{"sha256":"%s"}}],"predicateType":"https://slsa.dev/provenance/v1","predicate":{}}`, fingerprint))
+}
+
+func (suite *SignatureTestSuite) keylessOptions() *VerifyOptions {
+	roots := x509.NewCertPool()
+	roots.AddCert(suite.rootCert)
+	return &VerifyOptions{
+		Roots:                 roots,
+		RekorPublicKey:        &suite.rekorKey.PublicKey,
+		CertificateIdentity:   signerEmail,
+		CertificateOIDCIssuer: signerIssuer,
+	}
+}
+
+func (suite *SignatureTestSuite) TestVerifyBundleFiles() {
+	digest, _ := hex.DecodeString(suite.fingerprint)
+	otherDigest := sha256.Sum256([]byte("another artifact"))
+	keyOptions := &VerifyOptions{PublicKey: &suite.signingKey.PublicKey}
+	integratedTime := time.Unix(suite.integratedTime, 0).UTC().Format(time.RFC3339)
+
+	for _, t := range []struct {
+		name    string
+		bundle  string
+		opts    *VerifyOptions
+		want    *Signature
+		wantErr string
+	}{
+		{
+			name:   "a message signature is verified with a public key",
+			bundle: suite.writeBundle(suite.signingKey, nil, digest, nil),
+			opts:   keyOptions,
+			want:   &Signature{Kind: KindSignature, Verified: true},
+		},
+		{
+			name:   "a message signature is verified with a public key and its transparency log entry",
+			bundle: suite.writeBundle(suite.signingKey, nil, digest, nil),
+			opts:   &VerifyOptions{PublicKey: &suite.signingKey.PublicKey, RekorPublicKey: &suite.rekorKey.PublicKey},
+			want:   &Signature{Kind: KindSignature, TlogIndex: &[]int64{logIndex}[0], IntegratedTime: integratedTime, Verified: true},
+		},
+		{
+			name:    "a message signature is not verified with another public key",
+			bundle:  suite.writeBundle(suite.leafKey, nil, digest, nil),
+			opts:    keyOptions,
+			want:    &Signature{Kind: KindSignature},
+			wantErr: "invalid signature",
+		},
+		{
+			name:    "a message signature of another artifact is not verified",
+			bundle:  suite.writeBundle(suite.signingKey, nil, otherDigest[:], nil),
+			opts:    keyOptions,
+			want:    &Signature{Kind: KindSignature},
+			wantErr: fmt.Sprintf("the signature is for sha256:%x, not for the artifact", otherDigest),
+		},
+		{
+			name:   "a keyless message signature is verified with the certificate chain and Rekor public key",
+			bundle: suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts:   suite.keylessOptions(),
+			want: &Signature{Kind: KindSignature, SignerIdentity: signerEmail, Issuer: signerIssuer,
+				TlogIndex: &[]int64{logIndex}[0], IntegratedTime: integratedTime, Verified: true},
+		},
+		{
+			name:    "a keyless message signature is not verified without the Rekor public key",
+			bundle:  suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts:    &VerifyOptions{Roots: suite.keylessOptions().Roots},
+			want:    &Signature{Kind: KindSignature, SignerIdentity: signerEmail, Issuer: signerIssuer},
+			wantErr: "a verified transparency log entry is required to verify keyless signatures",
+		},
+		{
+			name:    "a keyless message signature is not verified with another Rekor public key",
+			bundle:  suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts:    &VerifyOptions{Roots: suite.keylessOptions().Roots, RekorPublicKey: &suite.signingKey.PublicKey},
+			want:    &Signature{Kind: KindSignature},
+			wantErr: "invalid transparency log entry: signed entry timestamp: invalid signature",
+		},
+		{
+			name:    "a keyless message signature is not verified without the certificate chain",
+			bundle:  suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts:    &VerifyOptions{RekorPublicKey: &suite.rekorKey.PublicKey},
+			want:    &Signature{Kind: KindSignature, SignerIdentity: signerEmail, Issuer: signerIssuer, TlogIndex: &[]int64{logIndex}[0], IntegratedTime: integratedTime},
+			wantErr: "a certificate chain is required to verify keyless signatures",
+		},
+		{
+			name:   "a keyless message signature of another signer is not verified",
+			bundle: suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts: func() *VerifyOptions {
+				opts := suite.keylessOptions()
+				opts.CertificateIdentity = "someone@example.com"
+				return opts
+			}(),
+			want:    &Signature{Kind: KindSignature, SignerIdentity: signerEmail, Issuer: signerIssuer, TlogIndex: &[]int64{logIndex}[0], IntegratedTime: integratedTime},
+			wantErr: "signer identity dev@example.com does not match the expected identity someone@example.com",
+		},
+		{
+			name:   "a keyless message signature of another issuer is not verified",
+			bundle: suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts: func() *VerifyOptions {
+				opts := suite.keylessOptions()
+				opts.CertificateOIDCIssuer = "https://accounts.example.com"
+				return opts
+			}(),
+			want:    &Signature{Kind: KindSignature, SignerIdentity: signerEmail, Issuer: signerIssuer, TlogIndex: &[]int64{logIndex}[0], IntegratedTime: integratedTime},
+			wantErr: "issuer https://token.actions.example.com does not match the expected issuer https://accounts.example.com",
+		},
+		{
+			name:    "a keyless message signature with an untrusted certificate is not verified",
+			bundle:  suite.writeBundle(suite.leafKey, suite.leafCert, digest, nil),
+			opts:    func() *VerifyOptions { opts := suite.keylessOptions(); opts.Roots = x509.NewCertPool(); return opts }(),
+			want:    &Signature{Kind: KindSignature, SignerIdentity: signerEmail, Issuer: signerIssuer, TlogIndex: &[]int64{logIndex}[0], IntegratedTime: integratedTime},
+			wantErr: "invalid signing certificate: x509: certificate signed by unknown authority",
+		},
+		{
+			name:   "an attestation is verified with a public key",
+			bundle: suite.writeBundle(suite.signingKey, nil, nil, suite.statement(suite.fingerprint)),
+			opts:   keyOptions,
+			want:   &Signature{Kind: KindAttestation, PredicateType: "https://slsa.dev/provenance/v1", Verified: true},
+		},
+		{
+			name:    "an attestation of another artifact is not verified",
+			bundle:  suite.writeBundle(suite.signingKey, nil, nil, suite.statement(hex.EncodeToString(otherDigest[:]))),
+			opts:    keyOptions,
+			want:    &Signature{Kind: KindAttestation, PredicateType: "https://slsa.dev/provenance/v1"},
+			wantErr: "the attestation subjects do not include the artifact",
+		},
+	} {
+		suite.Run(t.name, func() {
+			data, err := VerifyBundleFiles([]string{t.bundle}, suite.fingerprint, t.opts)
+			require.NoError(suite.T(), err)
+			require.Len(suite.T(), data.Signatures, 1)
+			t.want.Source = t.bundle
+			t.want.Error = t.wantErr
+			actual := data.Signatures[0]
+			if t.wantErr != "" && t.want.TlogIndex == nil {
+				// the transparency log entry is only reported when verified
+				actual.TlogIndex, actual.IntegratedTime = nil, ""
+			}
+			require.Equal(suite.T(), t.want, actual)
+			require.Equal(suite.T(), t.wantErr == "", data.Verified())
+		})
+	}
+}
+
+func (suite *SignatureTestSuite) TestVerifyBundleFilesFailsForInvalidBundles() {
+	dir := suite.T().TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(suite.T(), os.WriteFile(invalid, []byte(`{"verificationMaterial": {}}`), 0600))
+	_, err := VerifyBundleFiles([]string{invalid}, suite.fingerprint, &VerifyOptions{})
+	require.EqualError(suite.T(), err, fmt.Sprintf("invalid sigstore bundle %s: no message signature nor DSSE envelope found", invalid))
+
+	require.NoError(suite.T(), os.WriteFile(invalid, []byte(`{"messageSignature": {"messageDigest": {"algorithm": "SHA2_512"}}}`), 0600))
+	_, err = VerifyBundleFiles([]string{invalid}, suite.fingerprint, &VerifyOptions{})
+	require.EqualError(suite.T(), err, fmt.Sprintf("invalid sigstore bundle %s: unsupported message digest algorithm SHA2_512", invalid))
+
+	require.NoError(suite.T(), os.WriteFile(invalid, []byte(`not json`), 0600))
+	_, err = VerifyBundleFiles([]string{invalid}, suite.fingerprint, &VerifyOptions{})
+	require.ErrorContains(suite.T(), err, "failed to parse sigstore bundle")
+
+	_, err = VerifyBundleFiles([]string{filepath.Join(dir, "missing.json")}, suite.fingerprint, &VerifyOptions{})
+	require.Error(suite.T(), err)
+}
+
+func (suite *SignatureTestSuite) TestVerifyBundleFilesOfTestdata() {
+	publicKey, err := LoadPublicKey("testdata/cosign.pub")
+	require.NoError(suite.T(), err)
+	data, err := VerifyBundleFiles([]string{"testdata/file1.sigstore.json"}, "7509e5bda0c762d2bac7f90d758b5b2263fa01ccbc542ab5e3df163be08e6ca9", &VerifyOptions{PublicKey: publicKey})
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), data.Verified())
+	require.Equal(suite.T(), 1, data.VerifiedSignatures)
+}
+
+func (suite *SignatureTestSuite) TestLoadCertificateChain() {
+	dir := suite.T().TempDir()
+	chain := filepath.Join(dir, "chain.pem")
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: suite.leafCert.Raw})
+	encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: suite.rootCert.Raw})...)
+	require.NoError(suite.T(), os.WriteFile(chain, encoded, 0600))
+
+	roots, intermediates, err := LoadCertificateChain(chain)
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), roots.Equal(func() *x509.CertPool { p := x509.NewCertPool(); p.AddCert(suite.rootCert); return p }()))
+	require.True(suite.T(), intermediates.Equal(func() *x509.CertPool { p := x509.NewCertPool(); p.AddCert(suite.leafCert); return p }()))
+
+	require.NoError(suite.T(), os.WriteFile(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: suite.leafCert.Raw}), 0600))
+	_, _, err = LoadCertificateChain(chain)
+	require.EqualError(suite.T(), err, fmt.Sprintf("no root certificate found in %s", chain))
+
+	require.NoError(suite.T(), os.WriteFile(chain, []byte("not a certificate"), 0600))
+	_, _, err = LoadCertificateChain(chain)
+	require.EqualError(suite.T(), err, fmt.Sprintf("failed to parse certificates in %s: no PEM encoded certificate found", chain))
+}
+
+func (suite *SignatureTestSuite) TestLoadPublicKey() {
+	dir := suite.T().TempDir()
+	path := filepath.Join(dir, "cosign.pub")
+	der, err := x509.MarshalPKIXPublicKey(&suite.signingKey.PublicKey)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	key, err := LoadPublicKey(path)
+	require.NoError(suite.T(), err)
+	require.True(suite.T(), suite.signingKey.PublicKey.Equal(key))
+
+	require.NoError(suite.T(), os.WriteFile(path, []byte("not a key"), 0600))
+	_, err = LoadPublicKey(path)
+	require.EqualError(suite.T(), err, fmt.Sprintf("no PEM encoded public key found in %s", path))
+}
+
+// registry serves the cosign signature and attestation manifests of an image, and their layers
+func (suite *SignatureTestSuite) registry(manifests map[string]map[string]any, blobs map[string][]byte) *httptest.Server {
+	encoded := map[string][]byte{}
+	for tag, m := range manifests {
+		content, err := json.Marshal(m)
+		require.NoError(suite.T(), err)
+		encoded[tag] = content
+	}
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case strings.HasPrefix(r.URL.Path, "/v2/app/manifests/"):
+			content, ok := encoded[strings.TrimPrefix(r.URL.Path, "/v2/app/manifests/")]
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			_, _ = w.Write(content)
+		case strings.HasPrefix(r.URL.Path, "/v2/app/blobs/"):
+			content, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/app/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// cosignManifest returns a cosign manifest with a layer per content, adding the layers to the blobs
+func cosignManifest(blobs map[string][]byte, mediaType string, layers map[string]map[string]string) map[string]any {
+	descriptor := func(mediaType string, content []byte, annotations map[string]string) map[string]any {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(content))
+		blobs[digest] = content
+		return map[string]any{"mediaType": mediaType, "digest": digest, "size": len(content), "annotations": annotations}
+	}
+	m := map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        descriptor("application/vnd.oci.image.config.v1+json", []byte("{}"), nil),
+	}
+	descriptors := []map[string]any{}
+	for content, annotations := range layers {
+		descriptors = append(descriptors, descriptor(mediaType, []byte(content), annotations))
+	}
+	m["layers"] = descriptors
+	return m
+}
+
+func (suite *SignatureTestSuite) TestVerifyImageSignatures() {
+	payload := fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"app"},"image":{"docker-manifest-digest":"sha256:%s"},"type":"cosign container image signature"},"optional":null}`, suite.fingerprint)
+	keySignature := suite.sign(suite.signingKey, []byte(payload), false)
+	// the keyless signature has a different payload so that the layers have different digests
+	keylessPayload := strings.Replace(payload, `"optional":null`, `"optional":{}`, 1)
+	keylessSignature := suite.sign(suite.leafKey, []byte(keylessPayload), false)
+	entry := suite.tlogEntry(keylessSignature)
+	rekorBundle, err := json.Marshal(map[string]any{
+		"SignedEntryTimestamp": base64.StdEncoding.EncodeToString(entry.signedEntryTimestamp),
+		"Payload":              map[string]any{"body": entry.body, "integratedTime": entry.integratedTime, "logIndex": entry.logIndex, "logID": entry.logID},
+	})
+	require.NoError(suite.T(), err)
+
+	statement := suite.statement(suite.fingerprint)
+	envelope, err := json.Marshal(map[string]any{
+		"payloadType": dssePayloadType,
+		"payload":     base64.StdEncoding.EncodeToString(statement),
+		"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(suite.sign(suite.signingKey, dssePAE(dssePayloadType, statement), false))}},
+	})
+	require.NoError(suite.T(), err)
+
+	blobs := map[string][]byte{}
+	manifests := map[string]map[string]any{
+		"sha256-" + suite.fingerprint + ".sig": cosignManifest(blobs, "application/vnd.dev.cosign.simplesigning.v1+json", map[string]map[string]string{
+			payload: {cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(keySignature)},
+			keylessPayload: {
+				cosignSignatureAnnotation:   base64.StdEncoding.EncodeToString(keylessSignature),
+				cosignCertificateAnnotation: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: suite.leafCert.Raw})),
+				cosignBundleAnnotation:      string(rekorBundle),
+			},
+		}),
+		"sha256-" + suite.fingerprint + ".att": cosignManifest(blobs, dsseEnvelopeMediaType, map[string]map[string]string{
+			string(envelope): {cosignSignatureAnnotation: ""},
+		}),
+	}
+	server := suite.registry(manifests, blobs)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	sysCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue, DockerAuthConfig: &types.DockerAuthConfig{}}
+
+	opts := suite.keylessOptions()
+	opts.PublicKey = &suite.signingKey.PublicKey
+	opts.RekorPublicKey = nil
+	data, err := VerifyImageSignatures(host+"/app:1.0", suite.fingerprint, sysCtx, opts)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), data.Signatures, 3)
+	require.Equal(suite.T(), 1, data.VerifiedSignatures)
+	require.Equal(suite.T(), 1, data.VerifiedAttestations)
+
+	opts.RekorPublicKey = &suite.rekorKey.PublicKey
+	data, err = VerifyImageSignatures(host+"/app:1.0", suite.fingerprint, sysCtx, opts)
+	require.NoError(suite.T(), err)
+	// the key signature and attestation have no transparency log entry
+	require.Equal(suite.T(), 1, data.VerifiedSignatures)
+	require.Equal(suite.T(), 0, data.VerifiedAttestations)
+	for _, signature := range data.Signatures {
+		if signature.Verified {
+			require.Equal(suite.T(), signerEmail, signature.SignerIdentity)
+			require.Equal(suite.T(), signerIssuer, signature.Issuer)
+		} else {
+			require.Equal(suite.T(), "no transparency log entry found for the signature", signature.Error)
+		}
+	}
+
+	otherDigest := sha256.Sum256([]byte("unsigned artifact"))
+	data, err = VerifyImageSignatures(host+"/app:1.0", hex.EncodeToString(otherDigest[:]), sysCtx, opts)
+	require.NoError(suite.T(), err)
+	require.Empty(suite.T(), data.Signatures)
+	require.False(suite.T(), data.Verified())
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestSignatureTestSuite(t *testing.T) {
+	suite.Run(t, new(SignatureTestSuite))
+}
@@ -1,15 +1,93 @@
 package types
 
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Review states of pull request reviewers
+const (
+	ReviewApproved         = "APPROVED"
+	ReviewChangesRequested = "CHANGES_REQUESTED"
+	ReviewCommented        = "COMMENTED"
+	ReviewDismissed        = "DISMISSED"
+)
+
 type PREvidence struct {
-	MergeCommit string   `json:"merge_commit"`
-	URL         string   `json:"url"`
-	State       string   `json:"state"`
-	Approvers   []string `json:"approvers"`
-	// LastCommit             string `json:"lastCommit"`
-	// LastCommitter          string `json:"lastCommitter"`
-	// SelfApproved           bool   `json:"selfApproved"`
+	MergeCommit         string        `json:"merge_commit"`
+	URL                 string        `json:"url"`
+	State               string        `json:"state"`
+	Author              string        `json:"author"`
+	Approvers           []string      `json:"approvers"`
+	Reviewers           []*PRReviewer `json:"reviewers"`
+	LastCommit          string        `json:"last_commit"`
+	LastCommitter       string        `json:"last_committer"`
+	LastCommitTimestamp int64         `json:"last_commit_timestamp"`
+	// LastCommitPushedTimestamp is when the last commit was pushed to the pull request, for providers
+	// which do not record the commit that a review was made on
+	LastCommitPushedTimestamp int64 `json:"last_commit_pushed_timestamp,omitempty"`
+	SelfApproved              bool  `json:"self_approved"`
+	ApprovedAfterLastCommit   bool  `json:"approved_after_last_commit"`
+}
+
+// PRReviewer is a review of a pull request, e.g. an approval, with the unix timestamp of the review
+// and, for providers which record it, the commit which the pull request was at when it was reviewed
+type PRReviewer struct {
+	Username  string `json:"username"`
+	State     string `json:"state"`
+	Timestamp int64  `json:"timestamp"`
+	CommitID  string `json:"commit_id,omitempty"`
 }
 
 type PRRetriever interface {
 	PREvidenceForCommit(string) ([]*PREvidence, error)
 }
+
+// CheckFourEyes sets SelfApproved if the author or the last committer of the pull request approved it,
+// and ApprovedAfterLastCommit if someone else approved its last commit.
+// A reviewer's approval only counts if it is their latest approval, change request or dismissal.
+func (e *PREvidence) CheckFourEyes() {
+	reviews := slices.Clone(e.Reviewers)
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].Timestamp < reviews[j].Timestamp })
+	approvals := map[string]*PRReviewer{}
+	for _, r := range reviews {
+		switch r.State {
+		case ReviewApproved:
+			approvals[r.Username] = r
+		case ReviewChangesRequested, ReviewDismissed:
+			delete(approvals, r.Username)
+		}
+	}
+
+	e.SelfApproved = false
+	e.ApprovedAfterLastCommit = false
+	for username, approval := range approvals {
+		if sameUser(username, e.Author) || sameUser(username, e.LastCommitter) {
+			e.SelfApproved = true
+		} else if e.approvesLastCommit(approval) {
+			e.ApprovedAfterLastCommit = true
+		}
+	}
+}
+
+// sameUser returns true if two usernames are the same user. Unknown (empty) usernames are never the same user.
+func sameUser(username, other string) bool {
+	return username != "" && other != "" && strings.EqualFold(username, other)
+}
+
+// approvesLastCommit returns true if an approval was made on the last commit of the pull request.
+// The date of a commit is set by its committer and is kept when the commit is rebased, so an approval
+// without a commit must be made after the last commit was pushed, not only after it was committed.
+func (e *PREvidence) approvesLastCommit(approval *PRReviewer) bool {
+	if approval.CommitID != "" {
+		return e.LastCommit != "" && strings.EqualFold(approval.CommitID, e.LastCommit)
+	}
+	return approval.Timestamp >= max(e.LastCommitTimestamp, e.LastCommitPushedTimestamp)
+}
+
+// FourEyes returns true if the pull request was approved, after its last commit,
+// by someone who is neither its author nor its last committer
+func (e *PREvidence) FourEyes() bool {
+	return e.ApprovedAfterLastCommit
+}
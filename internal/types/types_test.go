@@ -0,0 +1,133 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// Define the suite, and absorb the built-in basic suite
+// functionality from testify - including a T() method which
+// returns the current testing context
+type TypesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TypesTestSuite) TestCheckFourEyes() {
+	for _, t := range []struct {
+		name                        string
+		reviewers                   []*PRReviewer
+		pushedAt                    int64
+		unknownLastCommitter        bool
+		wantSelfApproved            bool
+		wantApprovedAfterLastCommit bool
+	}{
+		{
+			name: "an approval by a reviewer after the last commit satisfies four-eyes",
+			reviewers: []*PRReviewer{
+				{Username: "bob", State: ReviewCommented, Timestamp: 50},
+				{Username: "bob", State: ReviewApproved, Timestamp: 150},
+			},
+			wantApprovedAfterLastCommit: true,
+		},
+		{
+			name:      "an approval by a reviewer before the last commit does not satisfy four-eyes",
+			reviewers: []*PRReviewer{{Username: "bob", State: ReviewApproved, Timestamp: 50}},
+		},
+		{
+			name:             "an approval by the author is a self-approval",
+			reviewers:        []*PRReviewer{{Username: "Alice", State: ReviewApproved, Timestamp: 150}},
+			wantSelfApproved: true,
+		},
+		{
+			name:             "an approval by the last committer is a self-approval",
+			reviewers:        []*PRReviewer{{Username: "carol", State: ReviewApproved, Timestamp: 150}},
+			wantSelfApproved: true,
+		},
+		{
+			name: "a self-approval does not prevent an approval by a reviewer from satisfying four-eyes",
+			reviewers: []*PRReviewer{
+				{Username: "alice", State: ReviewApproved, Timestamp: 150},
+				{Username: "bob", State: ReviewApproved, Timestamp: 160},
+			},
+			wantSelfApproved:            true,
+			wantApprovedAfterLastCommit: true,
+		},
+		{
+			name: "an approval dismissed later does not count",
+			reviewers: []*PRReviewer{
+				{Username: "bob", State: ReviewDismissed, Timestamp: 200},
+				{Username: "bob", State: ReviewApproved, Timestamp: 150},
+			},
+		},
+		{
+			name: "an approval after requesting changes counts",
+			reviewers: []*PRReviewer{
+				{Username: "bob", State: ReviewApproved, Timestamp: 200},
+				{Username: "bob", State: ReviewChangesRequested, Timestamp: 150},
+			},
+			wantApprovedAfterLastCommit: true,
+		},
+		{
+			name:                        "an approval of the last commit satisfies four-eyes",
+			reviewers:                   []*PRReviewer{{Username: "bob", State: ReviewApproved, Timestamp: 50, CommitID: "def"}},
+			wantApprovedAfterLastCommit: true,
+		},
+		{
+			name:      "an approval of another commit does not satisfy four-eyes even if it is later than the last commit date",
+			reviewers: []*PRReviewer{{Username: "bob", State: ReviewApproved, Timestamp: 150, CommitID: "abc"}},
+		},
+		{
+			name:      "an approval after the last commit date but before the last commit was pushed does not satisfy four-eyes",
+			reviewers: []*PRReviewer{{Username: "bob", State: ReviewApproved, Timestamp: 150}},
+			pushedAt:  200,
+		},
+		{
+			name:                        "an approval after the last commit was pushed satisfies four-eyes",
+			reviewers:                   []*PRReviewer{{Username: "bob", State: ReviewApproved, Timestamp: 250}},
+			pushedAt:                    200,
+			wantApprovedAfterLastCommit: true,
+		},
+		{
+			name:                        "an approval by a reviewer without a username is not a self-approval of an unknown last committer",
+			reviewers:                   []*PRReviewer{{Username: "", State: ReviewApproved, Timestamp: 150}},
+			unknownLastCommitter:        true,
+			wantApprovedAfterLastCommit: true,
+		},
+		{
+			name:                 "an approval by the author is a self-approval when the last committer is unknown",
+			reviewers:            []*PRReviewer{{Username: "alice", State: ReviewApproved, Timestamp: 150}},
+			unknownLastCommitter: true,
+			wantSelfApproved:     true,
+		},
+		{
+			name: "no reviews do not satisfy four-eyes",
+		},
+	} {
+		suite.Run(t.name, func() {
+			lastCommitter := "carol"
+			if t.unknownLastCommitter {
+				lastCommitter = ""
+			}
+			evidence := &PREvidence{
+				Author:                    "alice",
+				LastCommitter:             lastCommitter,
+				LastCommit:                "def",
+				LastCommitTimestamp:       100,
+				LastCommitPushedTimestamp: t.pushedAt,
+				Reviewers:                 t.reviewers,
+			}
+			evidence.CheckFourEyes()
+			require.Equal(suite.T(), t.wantSelfApproved, evidence.SelfApproved)
+			require.Equal(suite.T(), t.wantApprovedAfterLastCommit, evidence.ApprovedAfterLastCommit)
+			require.Equal(suite.T(), t.wantApprovedAfterLastCommit, evidence.FourEyes())
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestTypesTestSuite(t *testing.T) {
+	suite.Run(t, new(TypesTestSuite))
+}